@@ -20,6 +20,9 @@ var (
 
 	# Process sidecar.yaml
 	%[1]s build --sidecar --input <input-dir> --output <output-dir>
+
+	# Log the files which would be generated and which of them differ from the previous build without writing them
+	%[1]s build --dry-run
 	`
 )
 
@@ -30,6 +33,7 @@ type builderOptions struct {
 	inputDir  string
 	outputDir string
 	sidecar   bool
+	dryRun    bool
 
 	configBuilderOptions []config.BuilderOption
 }
@@ -76,6 +80,7 @@ func newBuilderCmd(o *builderOptions) *cobra.Command {
 	fl.StringVar(&o.inputDir, "input", "", "read config files from this directory instead of default")
 	fl.StringVar(&o.outputDir, "output", "", "write config files to this directory instead of default")
 	fl.BoolVar(&o.sidecar, "sidecar", false, "process sidecar configuration files")
+	fl.BoolVar(&o.dryRun, "dry-run", false, "only log the generated files and their checksums, do not write them")
 	o.configFlags.AddFlags(fl)
 	return cmd
 }
@@ -88,6 +93,9 @@ func (c *builderOptions) Complete(cmd *cobra.Command, args []string) error {
 	if c.sidecar {
 		c.configBuilderOptions = append(c.configBuilderOptions, config.WithSidecar())
 	}
+	if c.dryRun {
+		c.configBuilderOptions = append(c.configBuilderOptions, config.WithDryRun())
+	}
 	return nil
 }
 
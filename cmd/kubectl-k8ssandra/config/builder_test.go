@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/require"
@@ -22,9 +23,25 @@ func TestDefaultBuilderCommand(t *testing.T) {
 	require.Empty(options.inputDir)
 	require.Empty(options.outputDir)
 	require.False(options.sidecar)
+	require.False(options.dryRun)
 	require.Empty(options.configBuilderOptions)
 }
 
+func TestDryRunBuilderCommand(t *testing.T) {
+	require := require.New(t)
+
+	options := newBuilderOptions(genericiooptions.NewTestIOStreamsDiscard())
+	cmd := newBuilderCmd(options)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return nil
+	}
+
+	cmd.Root().SetArgs([]string{"build", "--dry-run"})
+	require.NoError(cmd.Execute())
+	require.True(options.dryRun)
+	require.Len(options.configBuilderOptions, 1)
+}
+
 func TestSidecarBuilderCommand(t *testing.T) {
 	require := require.New(t)
 
@@ -73,3 +90,25 @@ func TestSidecarBuilderCommandMissingDirectories(t *testing.T) {
 		})
 	}
 }
+
+func TestWatchCommand(t *testing.T) {
+	require := require.New(t)
+
+	options := newWatchOptions(genericiooptions.NewTestIOStreamsDiscard())
+	cmd := newWatchCmd(options)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return nil
+	}
+
+	cmd.Root().SetArgs([]string{"watch", "--source", "/config-input/config.json", "--interval", "30s", "--nodetool-args=-u,cassandra"})
+	require.NoError(cmd.Execute())
+	require.Equal("/config-input/config.json", options.source)
+	require.Equal(30*time.Second, options.interval)
+	require.Equal("nodetool", options.nodetool)
+	require.Equal([]string{"-u", "cassandra"}, options.nodetoolArgs)
+
+	options = newWatchOptions(genericiooptions.NewTestIOStreamsDiscard())
+	cmd = newWatchCmd(options)
+	cmd.Root().SetArgs([]string{"watch"})
+	require.Error(cmd.Execute())
+}
@@ -28,6 +28,7 @@ func NewCmd(streams genericclioptions.IOStreams) *cobra.Command {
 
 	// Add subcommands
 	cmd.AddCommand(NewBuilderCmd(streams))
+	cmd.AddCommand(NewWatchCmd(streams))
 	// TODO Add the idea of allowing to modify cassandra-yaml with interactive editor from the
 	// command line
 
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/k8ssandra/k8ssandra-client/pkg/config"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var (
+	configWatchExample = `
+	# Watch the mounted ConfigInput and apply the runtime settings with nodetool
+	%[1]s watch --source /config-input/config.json
+
+	# Pass the JMX credentials to nodetool
+	%[1]s watch --source /config-input/config.json --nodetool-args=-u,cassandra,-pwf,/etc/jmx/password
+	`
+)
+
+type watchOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	genericclioptions.IOStreams
+
+	inputDir     string
+	outputDir    string
+	source       string
+	interval     time.Duration
+	nodetool     string
+	nodetoolArgs []string
+}
+
+func newWatchOptions(streams genericclioptions.IOStreams) *watchOptions {
+	return &watchOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		IOStreams:   streams,
+	}
+}
+
+// NewWatchCmd provides a cobra command wrapping watchOptions
+func NewWatchCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	return newWatchCmd(newWatchOptions(streams))
+}
+
+func newWatchCmd(o *watchOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "watch [flags]",
+		Short:        "Rebuild config files when the cass-operator input changes and apply the runtime settings",
+		Example:      fmt.Sprintf(configWatchExample, "kubectl k8ssandra config"),
+		SilenceUsage: true,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			if err := o.Validate(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	fl := cmd.Flags()
+	fl.StringVar(&o.inputDir, "input", "", "read config files from this directory instead of default")
+	fl.StringVar(&o.outputDir, "output", "", "write config files to this directory instead of default")
+	fl.StringVar(&o.source, "source", "", "file with the cass-operator input, usually a mounted ConfigMap or Secret")
+	fl.DurationVar(&o.interval, "interval", 10*time.Second, "how often the source is checked for changes")
+	fl.StringVar(&o.nodetool, "nodetool", "nodetool", "nodetool executable used to apply the runtime settings")
+	fl.StringSliceVar(&o.nodetoolArgs, "nodetool-args", nil, "additional arguments for nodetool, such as the JMX credentials")
+	o.configFlags.AddFlags(fl)
+	return cmd
+}
+
+// Validate ensures that all required arguments and flag values are provided
+func (c *watchOptions) Validate() error {
+	if c.source == "" {
+		return errors.New("--source is required")
+	}
+
+	if c.interval <= 0 {
+		return errors.New("--interval must be positive")
+	}
+
+	return nil
+}
+
+// Run watches the source until the process is stopped
+func (c *watchOptions) Run() error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	applier := &config.NodetoolApplier{Path: c.nodetool, Args: c.nodetoolArgs}
+	watcher := config.NewWatcher(c.inputDir, c.outputDir, c.source, applier, config.WithWatchInterval(c.interval))
+	return watcher.Run(ctx)
+}
@@ -0,0 +1,207 @@
+package diag
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/k8ssandra/k8ssandra-client/pkg/cassdcutil"
+	"github.com/k8ssandra/k8ssandra-client/pkg/diag"
+	"github.com/k8ssandra/k8ssandra-client/pkg/kubernetes"
+	"github.com/k8ssandra/k8ssandra-client/pkg/util"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+var (
+	collectExample = `
+	# collect the diagnostics of datacenter dc1 to a timestamped archive in the current directory
+	%[1]s collect dc1 -n cassandra
+
+	# collect only the last 10000 lines of the container logs to another directory
+	%[1]s collect dc1 -n cassandra --tail 10000 --output-dir /tmp
+	`
+)
+
+type collectOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	genericclioptions.IOStreams
+	namespace string
+	dcName    string
+	outputDir string
+	tailLines int64
+}
+
+func newCollectOptions(streams genericclioptions.IOStreams) *collectOptions {
+	return &collectOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		IOStreams:   streams,
+	}
+}
+
+// NewCmd provides the diag command for gathering the diagnostics of datacenters
+func NewCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "diag [subcommand] [flags]",
+		Short:   "Gather the diagnostics of datacenters for support tickets",
+		Example: fmt.Sprintf(collectExample, "kubectl k8ssandra diag"),
+	}
+
+	cmd.AddCommand(NewCollectCmd(streams))
+
+	return cmd
+}
+
+// NewCollectCmd provides a cobra command writing the diagnostics bundle of a datacenter
+func NewCollectCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	o := newCollectOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:          "collect <datacenter> [flags]",
+		Short:        "collect the resources, events, logs, nodetool output and config files of the datacenter to a tar.gz archive",
+		Example:      fmt.Sprintf(collectExample, "kubectl k8ssandra diag"),
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Run(); err != nil {
+				log.Error("Error collecting the datacenter diagnostics", "error", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	fl := cmd.Flags()
+	fl.StringVar(&o.outputDir, "output-dir", ".", "directory to write the archive to")
+	fl.Int64Var(&o.tailLines, "tail", 0, "collect only the last lines of each container log, default is all the lines")
+	o.configFlags.AddFlags(fl)
+
+	return cmd
+}
+
+// Complete parses the arguments and necessary flags to options
+func (c *collectOptions) Complete(cmd *cobra.Command, args []string) error {
+	var err error
+	c.dcName = args[0]
+	c.namespace, _, err = c.configFlags.ToRawKubeConfigLoader().Namespace()
+	return err
+}
+
+// Validate ensures that all required arguments and flag values are provided
+func (c *collectOptions) Validate() error {
+	if c.tailLines < 0 {
+		return fmt.Errorf("--tail must not be negative")
+	}
+
+	info, err := os.Stat(c.outputDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("--output-dir %s is not a directory", c.outputDir)
+	}
+
+	return nil
+}
+
+// Run writes the diagnostics bundle of the datacenter
+func (c *collectOptions) Run() error {
+	ctx := context.Background()
+
+	restConfig, err := c.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	kubeClient, err := kubernetes.GetClient(restConfig)
+	if err != nil {
+		return err
+	}
+
+	cs, err := clientset.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+
+	opts := []diag.CollectorOption{diag.WithTailLines(c.tailLines)}
+
+	// Without the superuser the nodetool output is missing from the bundle, but the rest is still useful
+	cassManager := cassdcutil.NewManager(kubeClient)
+	if dc, err := cassManager.CassandraDatacenter(ctx, c.dcName, c.namespace); err != nil {
+		return err
+	} else if auth, err := cassManager.CassandraAuthDetails(ctx, dc); err != nil {
+		log.Warn("Failed to read the superuser credentials, running nodetool without them", "error", err)
+	} else {
+		opts = append(opts, diag.WithNodetoolArgs(cassdcutil.NodetoolAuthParameters(auth)))
+	}
+
+	bundle := diag.BundleName(c.dcName, time.Now())
+	archivePath := filepath.Join(c.outputDir, bundle+".tar.gz")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+
+	collector := diag.NewCollector(kubeClient, cs, &podExecutor{configFlags: c.configFlags}, opts...)
+	failures, err := collector.Collect(ctx, c.namespace, c.dcName, bundle, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if err := os.Remove(archivePath); err != nil {
+			log.Warn("Failed to remove the incomplete archive", "path", archivePath, "error", err)
+		}
+		return err
+	}
+
+	if len(failures) > 0 {
+		log.Warn("Some diagnostics could not be collected, they're listed in errors.txt of the archive", "failures", len(failures))
+	}
+
+	_, err = fmt.Fprintf(c.Out, "Diagnostics of datacenter %s written to %s\n", c.dcName, archivePath)
+	return err
+}
+
+// podExecutor runs the commands in the pods like kubectl exec does
+type podExecutor struct {
+	configFlags *genericclioptions.ConfigFlags
+}
+
+func (p *podExecutor) Exec(ctx context.Context, namespace, pod, container string, command []string) ([]byte, error) {
+	var out, errOut bytes.Buffer
+	execOptions, err := util.GetExecOptions(genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: &out, ErrOut: &errOut}, p.configFlags)
+	if err != nil {
+		return nil, err
+	}
+
+	execOptions.Namespace = namespace
+	execOptions.PodName = pod
+	execOptions.ContainerName = container
+	execOptions.Command = command
+
+	if err := execOptions.Run(); err != nil {
+		if stderr := strings.TrimSpace(errOut.String()); stderr != "" {
+			return nil, fmt.Errorf("%w: %s", err, stderr)
+		}
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
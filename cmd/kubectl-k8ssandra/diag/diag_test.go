@@ -0,0 +1,30 @@
+package diag
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func TestCollectCommand(t *testing.T) {
+	require := require.New(t)
+
+	cmd := NewCollectCmd(genericiooptions.NewTestIOStreamsDiscard())
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return nil
+	}
+
+	cmd.SetArgs([]string{"dc1", "--output-dir", t.TempDir(), "--tail", "1000"})
+	require.NoError(cmd.Execute())
+
+	cmd.SetArgs([]string{})
+	require.Error(cmd.Execute())
+
+	cmd.SetArgs([]string{"dc1", "--tail", "-1"})
+	require.ErrorContains(cmd.Execute(), "--tail must not be negative")
+
+	cmd.SetArgs([]string{"dc1", "--tail", "0", "--output-dir", "/nonexistent-diag-dir"})
+	require.Error(cmd.Execute())
+}
@@ -0,0 +1,189 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/log"
+	"github.com/k8ssandra/k8ssandra-client/pkg/doctor"
+	"github.com/k8ssandra/k8ssandra-client/pkg/helmutil"
+	"github.com/k8ssandra/k8ssandra-client/pkg/kubernetes"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+)
+
+var (
+	doctorExample = `
+	# check the cluster and the installed operators before running operations
+	%[1]s
+
+	# check the plugin's permissions in all the namespaces instead of the current one
+	%[1]s --all-namespaces
+
+	# print the results as JSON for CI, the command fails if any check fails
+	%[1]s --output json
+	`
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+type options struct {
+	configFlags *genericclioptions.ConfigFlags
+	genericclioptions.IOStreams
+	namespace     string
+	allNamespaces bool
+	output        string
+	minVersion    string
+	maxVersion    string
+}
+
+func newOptions(streams genericclioptions.IOStreams) *options {
+	return &options{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		IOStreams:   streams,
+	}
+}
+
+// NewCmd provides a cobra command checking the cluster and the operators are healthy and compatible
+func NewCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	o := newOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:          "doctor [flags]",
+		Short:        "Check the operators and the cluster are healthy and compatible before running operations",
+		Example:      fmt.Sprintf(doctorExample, "kubectl k8ssandra doctor"),
+		SilenceUsage: true,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Run(); err != nil {
+				log.Error("Error checking the cluster", "error", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	fl := cmd.Flags()
+	fl.StringVarP(&o.output, "output", "o", outputTable, "output format: table or json")
+	fl.BoolVarP(&o.allNamespaces, "all-namespaces", "A", false, "check the plugin's permissions in all the namespaces instead of the current one")
+	fl.StringVar(&o.minVersion, "min-kubernetes-version", doctor.MinKubernetesVersion, "oldest supported Kubernetes version")
+	fl.StringVar(&o.maxVersion, "max-kubernetes-version", doctor.MaxKubernetesVersion, "newest tested Kubernetes version, newer versions are a warning")
+	o.configFlags.AddFlags(fl)
+
+	return cmd
+}
+
+// Complete parses the arguments and necessary flags to options
+func (c *options) Complete(cmd *cobra.Command, args []string) error {
+	if c.allNamespaces {
+		return nil
+	}
+
+	var err error
+	c.namespace, _, err = c.configFlags.ToRawKubeConfigLoader().Namespace()
+	return err
+}
+
+// Validate ensures that all required arguments and flag values are provided
+func (c *options) Validate() error {
+	if c.output != outputTable && c.output != outputJSON {
+		return fmt.Errorf("unsupported output format %s, must be %s or %s", c.output, outputTable, outputJSON)
+	}
+	return nil
+}
+
+// Run checks the cluster and prints the report, it fails if any check failed
+func (c *options) Run() error {
+	restConfig, err := c.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	kubeClient, err := kubernetes.GetClient(restConfig)
+	if err != nil {
+		return err
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+
+	d, err := doctor.NewDoctor(kubeClient, discoveryClient, c.namespace, c.minVersion, c.maxVersion)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := helmutil.ActionConfig(c.configFlags, "")
+	if err != nil {
+		return err
+	}
+
+	releases, err := helmutil.ListInstallations(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to list the Helm releases: %w", err)
+	}
+
+	report, err := d.Run(context.Background(), releases)
+	if err != nil {
+		return err
+	}
+
+	if c.output == outputJSON {
+		err = printJSON(c.Out, report)
+	} else {
+		err = printTable(c.Out, report)
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.Failed() {
+		return fmt.Errorf("%d checks failed", report.Count(doctor.StatusFail))
+	}
+
+	return nil
+}
+
+func printTable(out io.Writer, report doctor.Report) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "CHECK\tSTATUS\tMESSAGE"); err != nil {
+		return err
+	}
+
+	for _, result := range report.Results {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", result.Check, result.Status, result.Message); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(out, "\n%d passed, %d warnings, %d failed\n", report.Count(doctor.StatusPass), report.Count(doctor.StatusWarn), report.Count(doctor.StatusFail))
+	return err
+}
+
+func printJSON(out io.Writer, report doctor.Report) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,57 @@
+package doctor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/k8ssandra/k8ssandra-client/pkg/doctor"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+var testReport = doctor.Report{
+	Results: []doctor.Result{
+		{Check: "kubernetes version", Status: doctor.StatusPass, Message: "v1.31.0 is within the supported versions 1.30 to 1.36"},
+		{Check: "deployments cass-operator/cass-operator", Status: doctor.StatusFail, Message: "not ready: cass-operator"},
+	},
+}
+
+func TestDoctorCommand(t *testing.T) {
+	require := require.New(t)
+
+	cmd := NewCmd(genericiooptions.NewTestIOStreamsDiscard())
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return nil
+	}
+
+	cmd.SetArgs([]string{"--output", "json", "-A"})
+	require.NoError(cmd.Execute())
+
+	cmd.SetArgs([]string{"--output", "yaml"})
+	require.ErrorContains(cmd.Execute(), "unsupported output format yaml")
+}
+
+func TestPrintTable(t *testing.T) {
+	require := require.New(t)
+
+	var out bytes.Buffer
+	require.NoError(printTable(&out, testReport))
+	require.Equal(`CHECK                                    STATUS  MESSAGE
+kubernetes version                       pass    v1.31.0 is within the supported versions 1.30 to 1.36
+deployments cass-operator/cass-operator  fail    not ready: cass-operator
+
+1 passed, 0 warnings, 1 failed
+`, out.String())
+}
+
+func TestPrintJSON(t *testing.T) {
+	require := require.New(t)
+
+	var out bytes.Buffer
+	require.NoError(printJSON(&out, testReport))
+	require.JSONEq(`{"results": [
+		{"check": "kubernetes version", "status": "pass", "message": "v1.31.0 is within the supported versions 1.30 to 1.36"},
+		{"check": "deployments cass-operator/cass-operator", "status": "fail", "message": "not ready: cass-operator"}
+	]}`, out.String())
+}
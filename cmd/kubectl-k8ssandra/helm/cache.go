@@ -0,0 +1,202 @@
+package helm
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/log"
+	"github.com/k8ssandra/k8ssandra-client/pkg/helmutil"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var (
+	cacheExample = `
+	# list the cached chart releases
+	%[1]s list
+
+	# verify the cached chart releases have not been modified or partially extracted
+	%[1]s verify
+
+	# remove the cached releases of a chart, or all of them without --chartName
+	%[1]s clean --chartName <chartName>
+	`
+)
+
+type cacheOptions struct {
+	genericclioptions.IOStreams
+	chartRepo string
+	chartName string
+}
+
+func newCacheOptions(streams genericclioptions.IOStreams) *cacheOptions {
+	return &cacheOptions{
+		IOStreams: streams,
+	}
+}
+
+func (c *cacheOptions) addFlags(fl *pflag.FlagSet) {
+	fl.StringVar(&c.chartRepo, "chartRepo", helmutil.K8ssandraRepoName, "chart repository name of the cached releases")
+	fl.StringVar(&c.chartName, "chartName", "", "optional chart name, default is all the charts of the repository")
+}
+
+// NewCacheCmd provides the cache command for managing the extracted chart releases the CRD upgrades use
+func NewCacheCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "cache [subcommand] [flags]",
+		Short:   "Manage the cached chart releases",
+		Example: fmt.Sprintf(cacheExample, "kubectl k8ssandra helm cache"),
+	}
+
+	cmd.AddCommand(newCacheListCmd(streams))
+	cmd.AddCommand(newCacheVerifyCmd(streams))
+	cmd.AddCommand(newCacheCleanCmd(streams))
+
+	return cmd
+}
+
+func newCacheListCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	o := newCacheOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:          "list [flags]",
+		Short:        "list the cached chart releases",
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.List(); err != nil {
+				log.Error("Error listing the chart cache", "error", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	o.addFlags(cmd.Flags())
+
+	return cmd
+}
+
+func newCacheVerifyCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	o := newCacheOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:          "verify [flags]",
+		Short:        "verify the cached chart releases against the digests recorded on extraction",
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Verify(); err != nil {
+				log.Error("Error verifying the chart cache", "error", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	o.addFlags(cmd.Flags())
+
+	return cmd
+}
+
+func newCacheCleanCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	o := newCacheOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:          "clean [flags]",
+		Short:        "remove the cached chart releases",
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Clean(); err != nil {
+				log.Error("Error cleaning the chart cache", "error", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	o.addFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (c *cacheOptions) cached() ([]helmutil.CachedChart, error) {
+	cached, err := helmutil.ListChartCache(c.chartRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]helmutil.CachedChart, 0, len(cached))
+	for _, entry := range cached {
+		if c.chartName == "" || entry.Chart == c.chartName {
+			selected = append(selected, entry)
+		}
+	}
+	return selected, nil
+}
+
+// List prints the cached chart releases
+func (c *cacheOptions) List() error {
+	cached, err := c.cached()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.Out, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "CHART\tVERSION\tSIZE\tEXTRACTED\tDIGEST\tSIGNED BY\tPATH"); err != nil {
+		return err
+	}
+
+	for _, entry := range cached {
+		extracted, digest, signedBy := "-", "-", "-"
+		if entry.Digest != "" {
+			extracted = entry.Extracted.Format("2006-01-02 15:04:05")
+			digest = entry.Digest
+		}
+		if entry.SignedBy != "" {
+			signedBy = entry.SignedBy
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", entry.Chart, entry.Version, entry.Size, extracted, digest, signedBy, entry.Dir); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// Verify checks every cached chart release and fails if any of them is modified or incomplete
+func (c *cacheOptions) Verify() error {
+	cached, err := c.cached()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, entry := range cached {
+		status := "ok"
+		if err := helmutil.VerifyCachedChart(entry.Dir); err != nil {
+			status = err.Error()
+			failed++
+		}
+		if _, err := fmt.Fprintf(c.Out, "%s %s: %s\n", entry.Chart, entry.Version, status); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d cached chart releases failed verification, they are downloaded again by the next upgrade or can be removed with clean", failed)
+	}
+
+	return nil
+}
+
+// Clean removes the cached chart releases
+func (c *cacheOptions) Clean() error {
+	removed, err := helmutil.CleanChartCache(c.chartRepo, c.chartName)
+	for _, path := range removed {
+		log.Info("Removed cached chart release", "path", path)
+	}
+	return err
+}
@@ -2,13 +2,17 @@ package helm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 
 	"github.com/charmbracelet/log"
 	"github.com/k8ssandra/k8ssandra-client/pkg/helmutil"
 	"github.com/k8ssandra/k8ssandra-client/pkg/kubernetes"
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var (
@@ -18,20 +22,39 @@ var (
 
 	# update CRDs in the namespace to chartVersion with non-default chartRepo (helm.k8ssandra.io)
 	%[1]s upgrade --chartName <chartName> --chartVersion <chartVersion> --chartRepo <repository> [<args>]
+
+	# update CRDs from a local chart archive or directory without access to the chart repository
+	%[1]s upgrade --chart-path <chart.tgz|chartDir> [<args>]
+
+	# show which CRDs would be created or updated and their schema changes, without applying them
+	%[1]s upgrade --chartName <chartName> --chartVersion <chartVersion> --dry-run
+
+	# take over the CRD fields set by other field managers, such as helm install, instead of failing
+	%[1]s upgrade --chartName <chartName> --chartVersion <chartVersion> --force-conflicts
+
+	# fail the upgrade unless the chart is signed by a key in the keyring
+	%[1]s upgrade --chartName <chartName> --chartVersion <chartVersion> --verify always --keyring <pubring.gpg>
 	`
-	errNotEnoughParameters = fmt.Errorf("not enough parameters, requires chartName and chartVersion")
+	errNotEnoughParameters = fmt.Errorf("not enough parameters, requires chartName and chartVersion or chart-path")
 )
 
 type options struct {
 	configFlags *genericclioptions.ConfigFlags
 	genericclioptions.IOStreams
-	namespace    string
-	chartName    string
-	chartVersion string
-	chartRepo    string
-	repoURL      string
-	download     bool
-	chartList    []string
+	namespace      string
+	chartName      string
+	chartVersion   string
+	chartRepo      string
+	repoURL        string
+	chartPath      string
+	download       bool
+	dryRun         bool
+	forceConflicts bool
+	noCache        bool
+	verify         string
+	keyring        string
+	verification   helmutil.ChartVerification
+	chartList      []string
 }
 
 func newOptions(streams genericclioptions.IOStreams) *options {
@@ -74,26 +97,24 @@ func NewUpgradeCmd(streams genericclioptions.IOStreams) *cobra.Command {
 	fl.StringVar(&o.chartName, "chartName", "", "chartName to upgrade")
 	fl.StringVar(&o.chartVersion, "chartVersion", "", "chartVersion to upgrade to")
 	fl.StringVar(&o.chartRepo, "chartRepo", "", "optional chart repository name to override the default (k8ssandra)")
-	fl.StringVar(&o.repoURL, "repoURL", "", "optional chart repository url to override the default (helm.k8ssandra.io)")
+	fl.StringVar(&o.repoURL, "repoURL", "", "optional chart repository url to override the default (helm.k8ssandra.io), oci:// registries are supported")
 	fl.StringSliceVar(&o.chartList, "charts", []string{}, "optional list of dependency charts to upgrade, default is just the main chart. Use \"_\" to update all the subcharts.")
+	fl.StringVar(&o.chartPath, "chart-path", "", "optional local chart archive (.tgz) or directory to read the CRDs from instead of the chart repository")
 	fl.BoolVar(&o.download, "download", false, "only download the chart")
+	fl.BoolVar(&o.dryRun, "dry-run", false, "only report the changes to the CRDs, validated with a server-side dry-run")
+	fl.BoolVar(&o.noCache, "no-cache", false, "download the chart release instead of using the cached one, without caching it")
+	fl.StringVar(&o.verify, "verify", helmutil.VerifyNever, "verify the provenance of the downloaded chart: never, if-possible or always")
+	fl.StringVar(&o.keyring, "keyring", "", "public keyring with the trusted chart signers (default ~/.gnupg/pubring.gpg)")
+	fl.BoolVar(&o.forceConflicts, "force-conflicts", false, "take over the CRD fields owned by other field managers instead of failing the server-side apply")
 	o.configFlags.AddFlags(fl)
 
-	if err := cmd.MarkFlagRequired("chartName"); err != nil {
-		panic(err)
-	}
-
-	if err := cmd.MarkFlagRequired("chartVersion"); err != nil {
-		panic(err)
-	}
-
 	return cmd
 }
 
 // Complete parses the arguments and necessary flags to options
 func (c *options) Complete(cmd *cobra.Command, args []string) error {
 	var err error
-	if c.chartName == "" || c.chartVersion == "" {
+	if c.chartPath == "" && (c.chartName == "" || c.chartVersion == "") {
 		return errNotEnoughParameters
 	}
 
@@ -112,6 +133,24 @@ func (c *options) Complete(cmd *cobra.Command, args []string) error {
 // Validate ensures that all required arguments and flag values are provided
 func (c *options) Validate() error {
 	// TODO Validate that the chartVersion is valid
+	if c.chartPath != "" && c.download {
+		return errors.New("--download can not be used with --chart-path")
+	}
+	if c.dryRun && c.download {
+		return errors.New("--download can not be used with --dry-run")
+	}
+	if c.noCache && c.download {
+		return errors.New("--download can not be used with --no-cache")
+	}
+
+	var err error
+	c.verification, err = helmutil.NewChartVerification(c.verify, c.keyring)
+	if err != nil {
+		return err
+	}
+	if c.chartPath != "" && c.verification.Required() {
+		return errors.New("--verify always can not be used with --chart-path, local charts are not verified")
+	}
 	return nil
 }
 
@@ -122,9 +161,10 @@ func (c *options) Run() error {
 		return err
 	}
 
-	var kubeClient kubernetes.NamespacedClient
+	// CRDs are cluster scoped and their custom resources are migrated in all the namespaces
+	var kubeClient client.Client
 	if !c.download {
-		kubeClient, err = kubernetes.GetClientInNamespace(restConfig, c.namespace)
+		kubeClient, err = kubernetes.GetClient(restConfig)
 		if err != nil {
 			return err
 		}
@@ -132,11 +172,106 @@ func (c *options) Run() error {
 
 	ctx := context.Background()
 
-	upgrader, err := helmutil.NewUpgrader(kubeClient, c.chartRepo, c.repoURL, c.chartName, c.chartList)
+	opts := make([]helmutil.UpgraderOption, 0)
+	if c.forceConflicts {
+		opts = append(opts, helmutil.WithForceConflicts())
+	}
+	if c.noCache {
+		opts = append(opts, helmutil.WithNoCache())
+	}
+	opts = append(opts, helmutil.WithVerification(c.verification))
+
+	upgrader, err := helmutil.NewUpgrader(kubeClient, c.chartRepo, c.repoURL, c.chartName, c.chartList, opts...)
 	if err != nil {
 		return err
 	}
 
-	_, err = upgrader.Upgrade(ctx, c.chartVersion)
-	return err
+	if c.dryRun {
+		return c.dryRunUpgrade(ctx, upgrader)
+	}
+
+	// Only the chart release cache is filled
+	if c.download {
+		_, _, err = upgrader.ChartDir(c.chartVersion)
+		return err
+	}
+
+	if c.chartPath != "" {
+		_, err = upgrader.UpgradeFromPath(ctx, c.chartPath)
+	} else {
+		_, err = upgrader.Upgrade(ctx, c.chartVersion)
+	}
+	if err != nil {
+		return err
+	}
+
+	return printTakeovers(c.Out, upgrader.Takeovers())
+}
+
+func (c *options) dryRunUpgrade(ctx context.Context, upgrader *helmutil.Upgrader) error {
+	var chartDir string
+	if c.chartPath != "" {
+		dir, cleanup, err := helmutil.LocalChartDir(c.chartPath, c.chartName)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		chartDir = dir
+	} else {
+		dir, cleanup, err := upgrader.ChartDir(c.chartVersion)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		chartDir = dir
+	}
+
+	changes, err := upgrader.DryRun(ctx, chartDir)
+	if err != nil {
+		return err
+	}
+
+	return printCRDChanges(c.Out, changes)
+}
+
+func printTakeovers(out io.Writer, takeovers []helmutil.FieldTakeover) error {
+	for _, takeover := range takeovers {
+		if _, err := fmt.Fprintf(out, "%s: took over fields from %s\n", takeover.CRD, takeover.Manager); err != nil {
+			return err
+		}
+		for _, field := range takeover.Fields {
+			if _, err := fmt.Fprintf(out, "  %s\n", field); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func printCRDChanges(out io.Writer, changes []helmutil.CRDChange) error {
+	for _, change := range changes {
+		if _, err := fmt.Fprintf(out, "%s: %s\n", change.Name, change.Action); err != nil {
+			return err
+		}
+
+		for _, version := range change.Versions {
+			if _, err := fmt.Fprintf(out, "  version %s: %s\n", version.Version, version.Change); err != nil {
+				return err
+			}
+			for _, line := range version.Diff {
+				if _, err := fmt.Fprintf(out, "    %s\n", line); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(change.DroppedStoredVersions) > 0 {
+			if _, err := fmt.Fprintf(out, "  stored versions dropped: %s\n", strings.Join(change.DroppedStoredVersions, ", ")); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
 }
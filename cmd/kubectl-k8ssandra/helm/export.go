@@ -0,0 +1,178 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/k8ssandra/k8ssandra-client/pkg/helmutil"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var (
+	exportExample = `
+	# print the CRDs of chartVersion as YAML
+	%[1]s export --chartName <chartName> --chartVersion <chartVersion> [<args>]
+
+	# write the CRDs of the chart and all its subcharts to a directory, one file per CRD
+	%[1]s export --chartName <chartName> --chartVersion <chartVersion> --charts _ --output-dir <dir>
+
+	# export the CRDs from a local chart archive or directory
+	%[1]s export --chart-path <chart.tgz|chartDir> --output-dir <dir>
+
+	# fail the export unless the chart is signed by a key in the keyring
+	%[1]s export --chartName <chartName> --chartVersion <chartVersion> --verify always --keyring <pubring.gpg>
+	`
+)
+
+type exportOptions struct {
+	genericclioptions.IOStreams
+	chartName    string
+	chartVersion string
+	chartRepo    string
+	repoURL      string
+	chartPath    string
+	outputDir    string
+	noCache      bool
+	verify       string
+	keyring      string
+	verification helmutil.ChartVerification
+	chartList    []string
+}
+
+func newExportOptions(streams genericclioptions.IOStreams) *exportOptions {
+	return &exportOptions{
+		IOStreams: streams,
+	}
+}
+
+// NewExportCmd provides a cobra command writing the CRDs selected like the upgrade command does to disk or stdout
+func NewExportCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	o := newExportOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:          "export [flags]",
+		Short:        "export CRDs from chart as YAML, for GitOps pipelines",
+		Example:      fmt.Sprintf(exportExample, "kubectl k8ssandra helm crds"),
+		SilenceUsage: true,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Run(); err != nil {
+				log.Error("Error exporting CustomResourceDefinitions", "error", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	fl := cmd.Flags()
+	fl.StringVar(&o.chartName, "chartName", "", "chartName to export")
+	fl.StringVar(&o.chartVersion, "chartVersion", "", "chartVersion to export")
+	fl.StringVar(&o.chartRepo, "chartRepo", "", "optional chart repository name to override the default (k8ssandra)")
+	fl.StringVar(&o.repoURL, "repoURL", "", "optional chart repository url to override the default (helm.k8ssandra.io), oci:// registries are supported")
+	fl.StringSliceVar(&o.chartList, "charts", []string{}, "optional list of dependency charts to export, default is just the main chart. Use \"_\" to export all the subcharts.")
+	fl.StringVar(&o.chartPath, "chart-path", "", "optional local chart archive (.tgz) or directory to read the CRDs from instead of the chart repository")
+	fl.BoolVar(&o.noCache, "no-cache", false, "download the chart release instead of using the cached one, without caching it")
+	fl.StringVar(&o.outputDir, "output-dir", "", "directory to write the CRDs to, one file per CRD. Defaults to printing them to stdout")
+	fl.StringVar(&o.verify, "verify", helmutil.VerifyNever, "verify the provenance of the downloaded chart: never, if-possible or always")
+	fl.StringVar(&o.keyring, "keyring", "", "public keyring with the trusted chart signers (default ~/.gnupg/pubring.gpg)")
+
+	return cmd
+}
+
+// Complete parses the arguments and necessary flags to options
+func (c *exportOptions) Complete(cmd *cobra.Command, args []string) error {
+	if c.chartPath == "" && (c.chartName == "" || c.chartVersion == "") {
+		return errNotEnoughParameters
+	}
+
+	if c.repoURL == "" {
+		c.repoURL = helmutil.StableK8ssandraRepoURL
+	}
+
+	if c.chartRepo == "" {
+		c.chartRepo = helmutil.K8ssandraRepoName
+	}
+
+	return nil
+}
+
+// Validate ensures that all required arguments and flag values are provided
+func (c *exportOptions) Validate() error {
+	var err error
+	c.verification, err = helmutil.NewChartVerification(c.verify, c.keyring)
+	if err != nil {
+		return err
+	}
+	if c.chartPath != "" && c.verification.Required() {
+		return errors.New("--verify always can not be used with --chart-path, local charts are not verified")
+	}
+	return nil
+}
+
+// Run writes the CRDs of the chart to the output directory or stdout
+func (c *exportOptions) Run() error {
+	// No cluster access is needed, the client is only used for applying the CRDs
+	opts := make([]helmutil.UpgraderOption, 0)
+	if c.noCache {
+		opts = append(opts, helmutil.WithNoCache())
+	}
+	opts = append(opts, helmutil.WithVerification(c.verification))
+
+	upgrader, err := helmutil.NewUpgrader(nil, c.chartRepo, c.repoURL, c.chartName, c.chartList, opts...)
+	if err != nil {
+		return err
+	}
+
+	var chartDir string
+	if c.chartPath != "" {
+		dir, cleanup, err := helmutil.LocalChartDir(c.chartPath, c.chartName)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		chartDir = dir
+	} else {
+		dir, cleanup, err := upgrader.ChartDir(c.chartVersion)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		chartDir = dir
+	}
+
+	crds, err := upgrader.CRDs(chartDir)
+	if err != nil {
+		return err
+	}
+
+	if len(crds) == 0 {
+		log.Warn("No CustomResourceDefinitions found in the chart", "chartDir", chartDir)
+	}
+
+	if c.outputDir == "" {
+		return helmutil.WriteCRDs(crds, c.Out)
+	}
+
+	files, err := helmutil.ExportCRDs(crds, c.outputDir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		log.Info("Exported CustomResourceDefinition", "path", f)
+	}
+
+	return nil
+}
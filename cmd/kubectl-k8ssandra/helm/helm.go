@@ -28,6 +28,11 @@ func NewHelmCmd(streams genericclioptions.IOStreams) *cobra.Command {
 
 	// Add subcommands
 	cmd.AddCommand(NewUpgradeCmd(streams))
+	cmd.AddCommand(NewExportCmd(streams))
+	cmd.AddCommand(NewCacheCmd(streams))
+	cmd.AddCommand(NewStatusCmd(streams))
+	cmd.AddCommand(NewHistoryCmd(streams))
+	cmd.AddCommand(NewValuesCmd(streams))
 
 	// cmd.Flags().BoolVar(&o.listNamespaces, "list", o.listNamespaces, "if true, print the list of all namespaces in the current KUBECONFIG")
 	o.configFlags.AddFlags(cmd.Flags())
@@ -1,10 +1,17 @@
 package helm
 
 import (
+	"bytes"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/k8ssandra/k8ssandra-client/pkg/helmutil"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/require"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	releasecommon "helm.sh/helm/v4/pkg/release/common"
+	release "helm.sh/helm/v4/pkg/release/v1"
 	"k8s.io/cli-runtime/pkg/genericiooptions"
 )
 
@@ -56,3 +63,240 @@ func TestAllParamsCRDCommand(t *testing.T) {
 		"--chartRepo", "devel", "--repoURL", "https://helm.k8ssandra.io/devel", "--download", "true", "--charts", "cass-operator", "--charts", "k8ssandra-operator,cass-operator"})
 	require.NoError(cmd.Execute())
 }
+
+func TestChartPathCRDCommand(t *testing.T) {
+	require := require.New(t)
+
+	cmd := NewUpgradeCmd(genericiooptions.NewTestIOStreamsDiscard())
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return nil
+	}
+
+	cmd.Root().SetArgs([]string{"upgrade", "--chart-path", "k8ssandra-operator-1.0.0.tgz"})
+	require.NoError(cmd.Execute())
+
+	cmd.Root().SetArgs([]string{"upgrade", "--chart-path", "k8ssandra-operator-1.0.0.tgz", "--download", "true"})
+	require.Error(cmd.Execute())
+}
+
+func TestExportCRDCommand(t *testing.T) {
+	require := require.New(t)
+
+	cmd := NewExportCmd(genericiooptions.NewTestIOStreamsDiscard())
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return nil
+	}
+
+	cmd.Root().SetArgs([]string{"export", "--chartName", "k8ssandra-operator"})
+	require.ErrorIs(cmd.Execute(), errNotEnoughParameters)
+
+	cmd.Root().SetArgs([]string{"export", "--chartName", "k8ssandra-operator", "--chartVersion", "1.0.0", "--charts", "_", "--output-dir", "crds"})
+	require.NoError(cmd.Execute())
+
+	cmd.Root().SetArgs([]string{"export", "--chart-path", "charts/k8ssandra-operator"})
+	require.NoError(cmd.Execute())
+}
+
+func TestExportVerifyCRDCommand(t *testing.T) {
+	require := require.New(t)
+
+	for _, tc := range []struct {
+		args  []string
+		valid bool
+	}{
+		{[]string{"--chartName", "k8ssandra-operator", "--chartVersion", "1.0.0", "--verify", "always", "--keyring", "pubring.gpg"}, true},
+		{[]string{"--chartName", "k8ssandra-operator", "--chartVersion", "1.0.0", "--verify", "sometimes"}, false},
+		{[]string{"--chart-path", "charts/k8ssandra-operator", "--verify", "if-possible"}, true},
+		{[]string{"--chart-path", "charts/k8ssandra-operator", "--verify", "always"}, false},
+	} {
+		cmd := NewExportCmd(genericiooptions.NewTestIOStreamsDiscard())
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			return nil
+		}
+
+		cmd.Root().SetArgs(append([]string{"export"}, tc.args...))
+		if tc.valid {
+			require.NoError(cmd.Execute(), tc.args)
+		} else {
+			require.Error(cmd.Execute(), tc.args)
+		}
+	}
+}
+
+func TestDryRunCRDCommand(t *testing.T) {
+	require := require.New(t)
+
+	cmd := NewUpgradeCmd(genericiooptions.NewTestIOStreamsDiscard())
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return nil
+	}
+
+	cmd.Root().SetArgs([]string{"upgrade", "--chartName", "k8ssandra-operator", "--chartVersion", "1.0.0", "--dry-run"})
+	require.NoError(cmd.Execute())
+
+	cmd.Root().SetArgs([]string{"upgrade", "--chartName", "k8ssandra-operator", "--chartVersion", "1.0.0", "--dry-run", "--download", "true"})
+	require.Error(cmd.Execute())
+}
+
+func TestPrintCRDChanges(t *testing.T) {
+	require := require.New(t)
+
+	var out bytes.Buffer
+	require.NoError(printCRDChanges(&out, []helmutil.CRDChange{
+		{
+			Name:   "cassandradatacenters.cassandra.datastax.com",
+			Action: helmutil.CRDUpdate,
+			Versions: []helmutil.CRDVersionChange{
+				{Version: "v1beta1", Change: "changed", Diff: []string{"~ storage: true -> false"}},
+				{Version: "v1", Change: "added"},
+			},
+			DroppedStoredVersions: []string{"v1alpha1"},
+		},
+		{Name: "cassandratasks.control.k8ssandra.io", Action: helmutil.CRDUnchanged},
+	}))
+
+	require.Equal(`cassandradatacenters.cassandra.datastax.com: update
+  version v1beta1: changed
+    ~ storage: true -> false
+  version v1: added
+  stored versions dropped: v1alpha1
+cassandratasks.control.k8ssandra.io: unchanged
+`, out.String())
+}
+
+func TestPrintTakeovers(t *testing.T) {
+	require := require.New(t)
+
+	var out bytes.Buffer
+	require.NoError(printTakeovers(&out, []helmutil.FieldTakeover{
+		{CRD: "cassandratasks.control.k8ssandra.io", Manager: "helm (Update)", Fields: []string{".spec.versions", ".spec.scope"}},
+	}))
+
+	require.Equal(`cassandratasks.control.k8ssandra.io: took over fields from helm (Update)
+  .spec.versions
+  .spec.scope
+`, out.String())
+}
+
+func TestCacheCommands(t *testing.T) {
+	require := require.New(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	chartDir, err := helmutil.ChartCacheDir(helmutil.K8ssandraRepoName, "cass-operator", "0.40.0")
+	require.NoError(err)
+	require.NoError(os.MkdirAll(chartDir, 0755))
+
+	var out bytes.Buffer
+	streams := genericiooptions.IOStreams{In: &bytes.Buffer{}, Out: &out, ErrOut: &bytes.Buffer{}}
+
+	cmd := NewCacheCmd(streams)
+	cmd.SetArgs([]string{"list"})
+	require.NoError(cmd.Execute())
+	require.Contains(out.String(), "cass-operator")
+	require.Contains(out.String(), chartDir)
+
+	// The release without the extraction metadata is incomplete
+	out.Reset()
+	cmd.SetArgs([]string{"verify"})
+	require.Error(cmd.Execute())
+	require.Contains(out.String(), "cass-operator 0.40.0: chart release cache is incomplete")
+
+	cmd.SetArgs([]string{"clean", "--chartName", "cass-operator"})
+	require.NoError(cmd.Execute())
+	require.NoDirExists(chartDir)
+
+	out.Reset()
+	cmd.SetArgs([]string{"verify"})
+	require.NoError(cmd.Execute())
+	require.Empty(out.String())
+}
+
+func TestReleaseCommands(t *testing.T) {
+	require := require.New(t)
+
+	for _, newCmd := range []func(genericiooptions.IOStreams) *cobra.Command{NewStatusCmd, NewHistoryCmd, NewValuesCmd} {
+		cmd := newCmd(genericiooptions.NewTestIOStreamsDiscard())
+		var releaseName string
+		cmd.RunE = func(c *cobra.Command, args []string) error {
+			releaseName = args[0]
+			return nil
+		}
+
+		cmd.SetArgs([]string{})
+		require.Error(cmd.Execute(), cmd.Name())
+
+		cmd.SetArgs([]string{"k8ssandra-operator", "-n", "k8ssandra-operator"})
+		require.NoError(cmd.Execute(), cmd.Name())
+		require.Equal("k8ssandra-operator", releaseName)
+	}
+}
+
+func testRelease(revision int, status releasecommon.Status, description string) *release.Release {
+	return &release.Release{
+		Name:      "cass-operator",
+		Namespace: "cass-operator",
+		Version:   revision,
+		Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "cass-operator", Version: "0.40.0", AppVersion: "1.22.0"}},
+		Info: &release.Info{
+			Status:       status,
+			Description:  description,
+			LastDeployed: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+	}
+}
+
+func TestPrintReleaseStatus(t *testing.T) {
+	require := require.New(t)
+
+	var out bytes.Buffer
+	require.NoError(printReleaseStatus(&out, testRelease(2, releasecommon.StatusDeployed, "Upgrade complete"), []helmutil.CRDStatus{
+		{Name: "cassandradatacenters.cassandra.datastax.com", State: helmutil.CRDMatches},
+		{Name: "cassandratasks.control.k8ssandra.io", State: helmutil.CRDDiffers, Versions: []helmutil.CRDVersionChange{{Version: "v1beta1", Change: "changed"}}},
+		{Name: "clientconfigs.config.k8ssandra.io", State: helmutil.CRDMissing},
+	}))
+
+	require.Equal(`NAME:           cass-operator
+NAMESPACE:      cass-operator
+CHART:          cass-operator-0.40.0
+APP VERSION:    1.22.0
+REVISION:       2
+STATUS:         deployed
+LAST DEPLOYED:  2026-01-02T03:04:05Z
+CRDS:           do not match chart version 0.40.0
+  cassandratasks.control.k8ssandra.io: differs
+    version v1beta1: changed in chart
+  clientconfigs.config.k8ssandra.io: missing
+`, out.String())
+}
+
+func TestPrintReleaseHistory(t *testing.T) {
+	require := require.New(t)
+
+	var out bytes.Buffer
+	require.NoError(printReleaseHistory(&out, []*release.Release{
+		testRelease(1, releasecommon.StatusSuperseded, "Install complete"),
+		testRelease(2, releasecommon.StatusDeployed, "Upgrade complete"),
+	}))
+
+	require.Equal(`REVISION  UPDATED               STATUS      CHART                 APP VERSION  DESCRIPTION
+1         2026-01-02T03:04:05Z  superseded  cass-operator-0.40.0  1.22.0       Install complete
+2         2026-01-02T03:04:05Z  deployed    cass-operator-0.40.0  1.22.0       Upgrade complete
+`, out.String())
+}
+
+func TestPrintValueOverrides(t *testing.T) {
+	require := require.New(t)
+
+	var out bytes.Buffer
+	require.NoError(printValueOverrides(&out, []helmutil.ValueOverride{
+		{Path: "extra", Value: "value"},
+		{Path: "image.tag", Default: "v1.22.0", Value: "v1.23.0"},
+		{Path: "replicas", Default: 1, Value: 1},
+	}))
+
+	require.Equal(`PATH       DEFAULT  VALUE    OVERRIDDEN
+extra      -        value    true
+image.tag  v1.22.0  v1.23.0  true
+replicas   1        1        false
+`, out.String())
+}
@@ -0,0 +1,319 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/k8ssandra/k8ssandra-client/pkg/helmutil"
+	"github.com/k8ssandra/k8ssandra-client/pkg/kubernetes"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	release "helm.sh/helm/v4/pkg/release/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var (
+	statusExample = `
+	# show the installed k8ssandra-operator release and whether the cluster CRDs match its chart version
+	%[1]s status k8ssandra-operator -n k8ssandra-operator
+	`
+
+	historyExample = `
+	# list the revisions of the cass-operator release
+	%[1]s history cass-operator -n cass-operator
+	`
+
+	valuesExample = `
+	# compare the user supplied values of the release to the chart defaults
+	%[1]s values k8ssandra-operator -n k8ssandra-operator
+
+	# print all the values the release was rendered with, the user supplied ones merged onto the chart defaults
+	%[1]s values k8ssandra-operator -n k8ssandra-operator --all
+	`
+)
+
+type releaseOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	genericclioptions.IOStreams
+	namespace   string
+	releaseName string
+	allValues   bool
+}
+
+func newReleaseOptions(streams genericclioptions.IOStreams) *releaseOptions {
+	return &releaseOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		IOStreams:   streams,
+	}
+}
+
+// NewStatusCmd provides a cobra command showing the installed operator release and the state of its CRDs
+func NewStatusCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	o := newReleaseOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:          "status <release> [flags]",
+		Short:        "show the installed release, its chart and app version and whether the cluster CRDs match the chart",
+		Example:      fmt.Sprintf(statusExample, "kubectl k8ssandra helm"),
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			return o.Complete(c, args)
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Status(); err != nil {
+				log.Error("Error getting the release status", "error", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// NewHistoryCmd provides a cobra command listing the revisions of the release
+func NewHistoryCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	o := newReleaseOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:          "history <release> [flags]",
+		Short:        "list the revisions of the release",
+		Example:      fmt.Sprintf(historyExample, "kubectl k8ssandra helm"),
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			return o.Complete(c, args)
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.History(); err != nil {
+				log.Error("Error getting the release history", "error", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// NewValuesCmd provides a cobra command comparing the user supplied values of the release to the chart defaults
+func NewValuesCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	o := newReleaseOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:          "values <release> [flags]",
+		Short:        "show the user supplied values of the release and the chart defaults they replace",
+		Example:      fmt.Sprintf(valuesExample, "kubectl k8ssandra helm"),
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			return o.Complete(c, args)
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Values(); err != nil {
+				log.Error("Error getting the release values", "error", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	fl := cmd.Flags()
+	fl.BoolVar(&o.allValues, "all", false, "print all the values of the release as YAML, the user supplied ones merged onto the chart defaults")
+	o.configFlags.AddFlags(fl)
+
+	return cmd
+}
+
+// Complete parses the arguments and necessary flags to options
+func (c *releaseOptions) Complete(cmd *cobra.Command, args []string) error {
+	var err error
+	c.releaseName = args[0]
+	c.namespace, _, err = c.configFlags.ToRawKubeConfigLoader().Namespace()
+	return err
+}
+
+func (c *releaseOptions) release() (*release.Release, error) {
+	cfg, err := helmutil.ActionConfig(c.configFlags, c.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, err := helmutil.Release(cfg, c.releaseName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find release %s in namespace %s: %w", c.releaseName, c.namespace, err)
+	}
+	return rel, nil
+}
+
+// Status prints the release and compares the CRDs in the cluster to the CRDs of the release's chart
+func (c *releaseOptions) Status() error {
+	rel, err := c.release()
+	if err != nil {
+		return err
+	}
+
+	restConfig, err := c.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	kubeClient, err := kubernetes.GetClient(restConfig)
+	if err != nil {
+		return err
+	}
+
+	statuses, err := helmutil.CompareReleaseCRDs(context.Background(), kubeClient, rel)
+	if err != nil {
+		return err
+	}
+
+	return printReleaseStatus(c.Out, rel, statuses)
+}
+
+// History prints the revisions of the release
+func (c *releaseOptions) History() error {
+	cfg, err := helmutil.ActionConfig(c.configFlags, c.namespace)
+	if err != nil {
+		return err
+	}
+
+	history, err := helmutil.ReleaseHistory(cfg, c.releaseName)
+	if err != nil {
+		return fmt.Errorf("failed to find release %s in namespace %s: %w", c.releaseName, c.namespace, err)
+	}
+
+	return printReleaseHistory(c.Out, history)
+}
+
+// Values prints the user supplied values of the release next to the chart defaults, or all the values with --all
+func (c *releaseOptions) Values() error {
+	rel, err := c.release()
+	if err != nil {
+		return err
+	}
+
+	if c.allValues {
+		values, err := helmutil.AllValues(rel)
+		if err != nil {
+			return err
+		}
+		return printYaml(c.Out, values)
+	}
+
+	defaults, err := helmutil.DefaultValues(rel)
+	if err != nil {
+		return err
+	}
+
+	return printValueOverrides(c.Out, helmutil.ValueOverrides(rel.Config, defaults))
+}
+
+func printReleaseStatus(out io.Writer, rel *release.Release, statuses []helmutil.CRDStatus) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, line := range [][2]string{
+		{"NAME", rel.Name},
+		{"NAMESPACE", rel.Namespace},
+		{"CHART", fmt.Sprintf("%s-%s", rel.Chart.Metadata.Name, rel.Chart.Metadata.Version)},
+		{"APP VERSION", rel.Chart.Metadata.AppVersion},
+		{"REVISION", fmt.Sprintf("%d", rel.Version)},
+		{"STATUS", string(rel.Info.Status)},
+		{"LAST DEPLOYED", formatTime(rel.Info.LastDeployed)},
+	} {
+		if _, err := fmt.Fprintf(w, "%s:\t%s\n", line[0], line[1]); err != nil {
+			return err
+		}
+	}
+
+	crds := fmt.Sprintf("match chart version %s", rel.Chart.Metadata.Version)
+	if len(statuses) == 0 {
+		crds = "chart has no CRDs"
+	} else if !helmutil.CRDsMatch(statuses) {
+		crds = fmt.Sprintf("do not match chart version %s", rel.Chart.Metadata.Version)
+	}
+	if _, err := fmt.Fprintf(w, "CRDS:\t%s\n", crds); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		if status.State == helmutil.CRDMatches {
+			continue
+		}
+		if _, err := fmt.Fprintf(out, "  %s: %s\n", status.Name, status.State); err != nil {
+			return err
+		}
+		for _, version := range status.Versions {
+			if _, err := fmt.Fprintf(out, "    version %s: %s in chart\n", version.Version, version.Change); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func printReleaseHistory(out io.Writer, history []*release.Release) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "REVISION\tUPDATED\tSTATUS\tCHART\tAPP VERSION\tDESCRIPTION"); err != nil {
+		return err
+	}
+
+	for _, rel := range history {
+		chart := fmt.Sprintf("%s-%s", rel.Chart.Metadata.Name, rel.Chart.Metadata.Version)
+		if _, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", rel.Version, formatTime(rel.Info.LastDeployed), rel.Info.Status, chart, rel.Chart.Metadata.AppVersion, rel.Info.Description); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+func printValueOverrides(out io.Writer, overrides []helmutil.ValueOverride) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "PATH\tDEFAULT\tVALUE\tOVERRIDDEN"); err != nil {
+		return err
+	}
+
+	for _, override := range overrides {
+		defaultValue := "-"
+		if override.Default != nil {
+			defaultValue = fmt.Sprintf("%v", override.Default)
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%v\t%t\n", override.Path, defaultValue, override.Value, override.Overridden()); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+func printYaml(out io.Writer, values map[string]any) error {
+	b, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(b)
+	return err
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -8,9 +8,12 @@ import (
 	// "github.com/k8ssandra/k8ssandra-client/cmd/kubectl-k8ssandra/list"
 	// "github.com/k8ssandra/k8ssandra-client/cmd/kubectl-k8ssandra/migrate"
 	"github.com/k8ssandra/k8ssandra-client/cmd/kubectl-k8ssandra/config"
+	"github.com/k8ssandra/k8ssandra-client/cmd/kubectl-k8ssandra/diag"
+	"github.com/k8ssandra/k8ssandra-client/cmd/kubectl-k8ssandra/doctor"
 	"github.com/k8ssandra/k8ssandra-client/cmd/kubectl-k8ssandra/helm"
 	"github.com/k8ssandra/k8ssandra-client/cmd/kubectl-k8ssandra/nodetool"
 	"github.com/k8ssandra/k8ssandra-client/cmd/kubectl-k8ssandra/operate"
+	"github.com/k8ssandra/k8ssandra-client/cmd/kubectl-k8ssandra/operator"
 	"github.com/k8ssandra/k8ssandra-client/cmd/kubectl-k8ssandra/register"
 	"github.com/k8ssandra/k8ssandra-client/cmd/kubectl-k8ssandra/tools"
 	"github.com/k8ssandra/k8ssandra-client/cmd/kubectl-k8ssandra/users"
@@ -51,8 +54,13 @@ func NewCmd(streams genericclioptions.IOStreams) *cobra.Command {
 	cmd.AddCommand(users.NewCmd(streams))
 	cmd.AddCommand(config.NewCmd(streams))
 	cmd.AddCommand(helm.NewHelmCmd(streams))
+	cmd.AddCommand(operator.NewInstallCmd(streams))
+	cmd.AddCommand(operator.NewUninstallCmd(streams))
+	cmd.AddCommand(operator.NewUpgradeCmd(streams))
 	cmd.AddCommand(nodetool.NewCmd(streams))
 	cmd.AddCommand(tools.NewToolsCmd(streams))
+	cmd.AddCommand(doctor.NewCmd(streams))
+	cmd.AddCommand(diag.NewCmd(streams))
 	register.SetupRegisterClusterCmd(cmd, streams)
 
 	// cmd.Flags().BoolVar(&o.listNamespaces, "list", o.listNamespaces, "if true, print the list of all namespaces in the current KUBECONFIG")
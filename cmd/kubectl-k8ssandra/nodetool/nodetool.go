@@ -117,24 +117,9 @@ func (c *options) Run() error {
 	}
 	c.execOptions.Command = []string{"nodetool"}
 
-	c.execOptions.Command = append(c.execOptions.Command, nodetoolAuthParameters(cassSecret)...)
+	c.execOptions.Command = append(c.execOptions.Command, cassdcutil.NodetoolAuthParameters(cassSecret)...)
 
 	c.execOptions.Command = append(c.execOptions.Command, c.params...)
 
 	return c.execOptions.Run()
 }
-
-func nodetoolAuthParameters(authDetails *cassdcutil.CassandraAuth) []string {
-	auth := []string{"--username", authDetails.Username, "--password", authDetails.Password}
-
-	if authDetails.KeystorePath != "" {
-		auth = append(auth, "-Dcom.sun.management.jmxremote.ssl.need.client.auth=true")
-		auth = append(auth, "-Dcom.sun.management.jmxremote.registry.ssl=true")
-		auth = append(auth, "-Djavax.net.ssl.keyStore="+authDetails.KeystorePath)
-		auth = append(auth, "-Djavax.net.ssl.keyStorePassword="+authDetails.KeystorePassword)
-		auth = append(auth, "-Djavax.net.ssl.trustStore="+authDetails.TruststorePath)
-		auth = append(auth, "-Djavax.net.ssl.trustStorePassword="+authDetails.TruststorePassword)
-	}
-
-	return auth
-}
@@ -0,0 +1,168 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/k8ssandra/k8ssandra-client/pkg/helmutil"
+	"github.com/k8ssandra/k8ssandra-client/pkg/kubernetes"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v4/pkg/cli"
+	"helm.sh/helm/v4/pkg/cli/values"
+	"helm.sh/helm/v4/pkg/getter"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var (
+	installExample = `
+	# install the latest k8ssandra-operator to the current namespace
+	%[1]s operator
+
+	# install a specific version watching all the namespaces
+	%[1]s operator --version <version> --cluster-scoped -n k8ssandra-operator
+
+	# install only cass-operator with values
+	%[1]s operator --chart cass-operator -f values.yaml --set image.tag=<tag>
+	`
+)
+
+type installOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	genericclioptions.IOStreams
+	chartOptions
+	verifyOptions
+	namespace     string
+	releaseName   string
+	clusterScoped bool
+	timeout       time.Duration
+	values        values.Options
+}
+
+func newInstallOptions(streams genericclioptions.IOStreams) *installOptions {
+	return &installOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		IOStreams:   streams,
+	}
+}
+
+// NewInstallOperatorCmd provides a cobra command installing the operator chart
+func NewInstallOperatorCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	o := newInstallOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:          "operator [flags]",
+		Short:        "Install k8ssandra-operator or cass-operator with Helm",
+		Example:      fmt.Sprintf(installExample, "kubectl k8ssandra install"),
+		SilenceUsage: true,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Run(); err != nil {
+				log.Error("Error installing the operator", "error", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	fl := cmd.Flags()
+	o.chartOptions.addFlags(fl, "version to install, defaults to the latest")
+	o.verifyOptions.addFlags(fl)
+	fl.StringVar(&o.releaseName, "release-name", "", "name of the Helm release, defaults to the chart name")
+	fl.BoolVar(&o.clusterScoped, "cluster-scoped", false, "watch all the namespaces instead of only the installation namespace")
+	fl.DurationVar(&o.timeout, "timeout", 5*time.Minute, "time to wait for the operator to become ready")
+	fl.StringSliceVarP(&o.values.ValueFiles, "values", "f", []string{}, "values files for the release")
+	fl.StringArrayVar(&o.values.Values, "set", []string{}, "set release values on the command line (key1=val1,key2=val2)")
+	o.configFlags.AddFlags(fl)
+
+	return cmd
+}
+
+// Complete parses the arguments and necessary flags to options
+func (c *installOptions) Complete(cmd *cobra.Command, args []string) error {
+	var err error
+	c.chartOptions.complete()
+
+	if c.releaseName == "" {
+		c.releaseName = c.chartName
+	}
+
+	c.namespace, _, err = c.configFlags.ToRawKubeConfigLoader().Namespace()
+	return err
+}
+
+// Validate ensures that all required arguments and flag values are provided
+func (c *installOptions) Validate() error {
+	if c.timeout <= 0 {
+		return errors.New("--timeout must be positive")
+	}
+	if err := c.verifyOptions.validate(); err != nil {
+		return err
+	}
+	return helmutil.ValidateOperatorChart(c.chartName)
+}
+
+// Run installs the CRDs and the release and waits for the operator to become ready
+func (c *installOptions) Run() error {
+	ctx := context.Background()
+
+	restConfig, err := c.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	kubeClient, err := kubernetes.GetClient(restConfig)
+	if err != nil {
+		return err
+	}
+
+	userValues, err := c.values.MergeValues(getter.All(cli.New()))
+	if err != nil {
+		return err
+	}
+
+	chartDir, version, err := helmutil.FetchChart(c.chartRepo, c.repoURL, c.chartName, c.chartVersion, c.verification)
+	if err != nil {
+		return err
+	}
+
+	// Helm only installs missing CRDs and never updates them, so they're applied before the release
+	upgrader, err := helmutil.NewUpgrader(kubeClient, c.chartRepo, c.repoURL, c.chartName, []string{helmutil.AllSubCharts})
+	if err != nil {
+		return err
+	}
+
+	if _, err := upgrader.ApplyCRDs(ctx, chartDir); err != nil {
+		return err
+	}
+
+	cfg, err := helmutil.ActionConfig(c.configFlags, c.namespace)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Installing release", "release", c.releaseName, "chart", c.chartName, "version", version, "namespace", c.namespace)
+	rel, err := helmutil.Install(cfg, c.releaseName, chartDir, c.namespace, helmutil.OperatorValues(userValues, c.clusterScoped), false, true, c.timeout)
+	if err != nil {
+		return err
+	}
+
+	if err := kubernetes.WaitForDeploymentsReady(ctx, kubeClient, c.namespace, map[string]string{helmutil.InstanceLabel: rel.Name}, c.timeout); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c.Out, "Installed %s %s as release %s in namespace %s\n", c.chartName, version, rel.Name, c.namespace)
+	return err
+}
@@ -0,0 +1,87 @@
+package operator
+
+import (
+	"github.com/k8ssandra/k8ssandra-client/pkg/helmutil"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// chartOptions select the operator chart and the repository it is fetched from
+type chartOptions struct {
+	chartName    string
+	chartVersion string
+	chartRepo    string
+	repoURL      string
+}
+
+func (c *chartOptions) addFlags(fl *pflag.FlagSet, versionUsage string) {
+	fl.StringVar(&c.chartName, "chart", helmutil.K8ssandraOperatorChartName, "operator chart, k8ssandra-operator or cass-operator")
+	fl.StringVar(&c.chartVersion, "version", "", versionUsage)
+	fl.StringVar(&c.chartRepo, "chart-repo", "", "optional chart repository name to override the default (k8ssandra)")
+	fl.StringVar(&c.repoURL, "repo-url", "", "optional chart repository url to override the default (helm.k8ssandra.io)")
+}
+
+func (c *chartOptions) complete() {
+	if c.repoURL == "" {
+		c.repoURL = helmutil.StableK8ssandraRepoURL
+	}
+
+	if c.chartRepo == "" {
+		c.chartRepo = helmutil.K8ssandraRepoName
+	}
+}
+
+// verifyOptions select how the provenance of the downloaded chart is verified
+type verifyOptions struct {
+	verify       string
+	keyring      string
+	verification helmutil.ChartVerification
+}
+
+func (c *verifyOptions) addFlags(fl *pflag.FlagSet) {
+	fl.StringVar(&c.verify, "verify", helmutil.VerifyNever, "verify the provenance of the chart: never, if-possible or always")
+	fl.StringVar(&c.keyring, "keyring", "", "public keyring with the trusted chart signers (default ~/.gnupg/pubring.gpg)")
+}
+
+func (c *verifyOptions) validate() error {
+	var err error
+	c.verification, err = helmutil.NewChartVerification(c.verify, c.keyring)
+	return err
+}
+
+// NewInstallCmd provides the install command, with a subcommand for each installable component
+func NewInstallCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install [subcommand] [flags]",
+		Short: "Install k8ssandra components to the cluster",
+	}
+
+	cmd.AddCommand(NewInstallOperatorCmd(streams))
+
+	return cmd
+}
+
+// NewUninstallCmd provides the uninstall command, with a subcommand for each installable component
+func NewUninstallCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "uninstall [subcommand] [flags]",
+		Short: "Uninstall k8ssandra components from the cluster",
+	}
+
+	cmd.AddCommand(NewUninstallOperatorCmd(streams))
+
+	return cmd
+}
+
+// NewUpgradeCmd provides the upgrade command, with a subcommand for each upgradeable component
+func NewUpgradeCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "upgrade [subcommand] [flags]",
+		Short: "Upgrade k8ssandra components in the cluster",
+	}
+
+	cmd.AddCommand(NewUpgradeOperatorCmd(streams))
+
+	return cmd
+}
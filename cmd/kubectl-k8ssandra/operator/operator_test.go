@@ -0,0 +1,84 @@
+package operator
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func TestInstallOperatorCommand(t *testing.T) {
+	require := require.New(t)
+
+	cmd := NewInstallCmd(genericiooptions.NewTestIOStreamsDiscard())
+	installCmd, _, err := cmd.Find([]string{"operator"})
+	require.NoError(err)
+	installCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return nil
+	}
+
+	cmd.SetArgs([]string{"operator", "--cluster-scoped", "--version", "1.20.0", "-f", "values.yaml", "--set", "image.tag=latest"})
+	require.NoError(cmd.Execute())
+
+	for flag, expected := range map[string]string{
+		"chart":          "k8ssandra-operator",
+		"version":        "1.20.0",
+		"cluster-scoped": "true",
+		"release-name":   "k8ssandra-operator",
+		"timeout":        "5m0s",
+	} {
+		require.Equal(expected, installCmd.Flags().Lookup(flag).Value.String(), flag)
+	}
+}
+
+func TestInstallOperatorCommandValidation(t *testing.T) {
+	require := require.New(t)
+
+	cmd := NewInstallCmd(genericiooptions.NewTestIOStreamsDiscard())
+	installCmd, _, err := cmd.Find([]string{"operator"})
+	require.NoError(err)
+	installCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return nil
+	}
+
+	cmd.SetArgs([]string{"operator", "--chart", "medusa"})
+	require.ErrorContains(cmd.Execute(), "unsupported chart medusa")
+
+	cmd.SetArgs([]string{"operator", "--timeout", "0s"})
+	require.ErrorContains(cmd.Execute(), "--timeout must be positive")
+}
+
+func TestUninstallOperatorCommand(t *testing.T) {
+	require := require.New(t)
+
+	cmd := NewUninstallCmd(genericiooptions.NewTestIOStreamsDiscard())
+	uninstallCmd, _, err := cmd.Find([]string{"operator"})
+	require.NoError(err)
+	uninstallCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return nil
+	}
+
+	cmd.SetArgs([]string{"operator", "--release-name", "operators"})
+	require.NoError(cmd.Execute())
+	require.Equal("operators", uninstallCmd.Flags().Lookup("release-name").Value.String())
+}
+
+func TestUpgradeOperatorCommand(t *testing.T) {
+	require := require.New(t)
+
+	cmd := NewUpgradeCmd(genericiooptions.NewTestIOStreamsDiscard())
+	upgradeCmd, _, err := cmd.Find([]string{"operator"})
+	require.NoError(err)
+	upgradeCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return nil
+	}
+
+	cmd.SetArgs([]string{"operator", "--release-name", "operators"})
+	require.ErrorContains(cmd.Execute(), `required flag(s) "to" not set`)
+
+	cmd.SetArgs([]string{"operator", "--to", "1.21.0", "--release-name", "operators"})
+	require.NoError(cmd.Execute())
+	require.Equal("1.21.0", upgradeCmd.Flags().Lookup("to").Value.String())
+	require.Equal("operators", upgradeCmd.Flags().Lookup("release-name").Value.String())
+}
@@ -0,0 +1,91 @@
+package operator
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/k8ssandra/k8ssandra-client/pkg/helmutil"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var (
+	uninstallExample = `
+	# uninstall the k8ssandra-operator release from the current namespace
+	%[1]s operator
+
+	# uninstall a release with a non-default name
+	%[1]s operator --release-name <release> -n k8ssandra-operator
+	`
+)
+
+type uninstallOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	genericclioptions.IOStreams
+	namespace   string
+	releaseName string
+}
+
+func newUninstallOptions(streams genericclioptions.IOStreams) *uninstallOptions {
+	return &uninstallOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		IOStreams:   streams,
+	}
+}
+
+// NewUninstallOperatorCmd provides a cobra command removing the operator release. The CRDs and the custom resources
+// are not removed.
+func NewUninstallOperatorCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	o := newUninstallOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:          "operator [flags]",
+		Short:        "Uninstall the operator Helm release, CRDs and custom resources are kept",
+		Example:      fmt.Sprintf(uninstallExample, "kubectl k8ssandra uninstall"),
+		SilenceUsage: true,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			return o.Complete(c, args)
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Run(); err != nil {
+				log.Error("Error uninstalling the operator", "error", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	fl := cmd.Flags()
+	fl.StringVar(&o.releaseName, "release-name", helmutil.K8ssandraOperatorChartName, "name of the Helm release")
+	o.configFlags.AddFlags(fl)
+
+	return cmd
+}
+
+// Complete parses the arguments and necessary flags to options
+func (c *uninstallOptions) Complete(cmd *cobra.Command, args []string) error {
+	var err error
+	c.namespace, _, err = c.configFlags.ToRawKubeConfigLoader().Namespace()
+	return err
+}
+
+// Run uninstalls the release
+func (c *uninstallOptions) Run() error {
+	cfg, err := helmutil.ActionConfig(c.configFlags, c.namespace)
+	if err != nil {
+		return err
+	}
+
+	if _, err := helmutil.Release(cfg, c.releaseName); err != nil {
+		return fmt.Errorf("failed to find release %s in namespace %s: %w", c.releaseName, c.namespace, err)
+	}
+
+	log.Info("Uninstalling release", "release", c.releaseName, "namespace", c.namespace)
+	if _, err := helmutil.Uninstall(cfg, c.releaseName); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c.Out, "Uninstalled release %s from namespace %s\n", c.releaseName, c.namespace)
+	return err
+}
@@ -0,0 +1,229 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/k8ssandra/k8ssandra-client/pkg/helmutil"
+	"github.com/k8ssandra/k8ssandra-client/pkg/kubernetes"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v4/pkg/cli"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+var (
+	upgradeExample = `
+	# upgrade the k8ssandra-operator release in the current namespace
+	%[1]s operator --to <version>
+
+	# upgrade a release with a non-default name
+	%[1]s operator --to <version> --release-name <release> -n k8ssandra-operator
+
+	# take over the CRD fields set by the helm install instead of failing the CRD upgrade
+	%[1]s operator --to <version> --force-conflicts
+
+	# only upgrade to a chart signed by a key in the keyring
+	%[1]s operator --to <version> --verify always --keyring <pubring.gpg>
+	`
+)
+
+type upgradeOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	genericclioptions.IOStreams
+	verifyOptions
+	namespace      string
+	releaseName    string
+	targetVer      string
+	chartRepo      string
+	repoURL        string
+	timeout        time.Duration
+	forceConflicts bool
+}
+
+func newUpgradeOptions(streams genericclioptions.IOStreams) *upgradeOptions {
+	return &upgradeOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		IOStreams:   streams,
+	}
+}
+
+// NewUpgradeOperatorCmd provides a cobra command upgrading the operator release and its CRDs
+func NewUpgradeOperatorCmd(streams genericclioptions.IOStreams) *cobra.Command {
+	o := newUpgradeOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:          "operator [flags]",
+		Short:        "Upgrade the operator CRDs and Helm release, keeping the user set values",
+		Example:      fmt.Sprintf(upgradeExample, "kubectl k8ssandra upgrade"),
+		SilenceUsage: true,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Run(); err != nil {
+				log.Error("Error upgrading the operator", "error", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	fl := cmd.Flags()
+	fl.StringVar(&o.targetVer, "to", "", "chart version to upgrade to")
+	fl.StringVar(&o.releaseName, "release-name", helmutil.K8ssandraOperatorChartName, "name of the Helm release")
+	fl.StringVar(&o.chartRepo, "chart-repo", "", "optional chart repository name to override the default (k8ssandra)")
+	fl.StringVar(&o.repoURL, "repo-url", "", "optional chart repository url to override the default (helm.k8ssandra.io)")
+	fl.DurationVar(&o.timeout, "timeout", 5*time.Minute, "time to wait for the operator to become ready")
+	o.verifyOptions.addFlags(fl)
+	fl.BoolVar(&o.forceConflicts, "force-conflicts", false, "take over the CRD fields owned by other field managers instead of failing the server-side apply")
+	if err := cmd.MarkFlagRequired("to"); err != nil {
+		panic(err)
+	}
+	o.configFlags.AddFlags(fl)
+
+	return cmd
+}
+
+// Complete parses the arguments and necessary flags to options
+func (c *upgradeOptions) Complete(cmd *cobra.Command, args []string) error {
+	var err error
+	if c.repoURL == "" {
+		c.repoURL = helmutil.StableK8ssandraRepoURL
+	}
+
+	if c.chartRepo == "" {
+		c.chartRepo = helmutil.K8ssandraRepoName
+	}
+
+	c.namespace, _, err = c.configFlags.ToRawKubeConfigLoader().Namespace()
+	return err
+}
+
+// Validate ensures that all required arguments and flag values are provided
+func (c *upgradeOptions) Validate() error {
+	if c.timeout <= 0 {
+		return errors.New("--timeout must be positive")
+	}
+	return c.verifyOptions.validate()
+}
+
+// Run upgrades the CRDs and the release, rolling the release back if the operator does not become ready
+func (c *upgradeOptions) Run() error {
+	ctx := context.Background()
+
+	cfg, err := helmutil.ActionConfig(c.configFlags, c.namespace)
+	if err != nil {
+		return err
+	}
+
+	rel, err := helmutil.Release(cfg, c.releaseName)
+	if err != nil {
+		return fmt.Errorf("failed to find release %s in namespace %s: %w", c.releaseName, c.namespace, err)
+	}
+
+	// The revision before the upgrade, a failed upgrade is only rolled back if it recorded a newer one
+	previousRevision := rel.Version
+	current := rel.Chart
+	chartName := current.Metadata.Name
+	currentVersion := current.Metadata.Version
+	if err := helmutil.ValidateOperatorChart(chartName); err != nil {
+		return err
+	}
+
+	cmp, err := helmutil.CompareChartVersions(currentVersion, c.targetVer)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case cmp == 0:
+		_, err = fmt.Fprintf(c.Out, "Release %s is already at %s %s\n", c.releaseName, chartName, currentVersion)
+		return err
+	case cmp > 0:
+		return fmt.Errorf("downgrading release %s from %s to %s is not supported", c.releaseName, currentVersion, c.targetVer)
+	}
+
+	restConfig, err := c.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	kubeClient, err := kubernetes.GetClient(restConfig)
+	if err != nil {
+		return err
+	}
+
+	chartDir, version, err := helmutil.FetchChart(c.chartRepo, c.repoURL, chartName, c.targetVer, c.verification)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Upgrading CRDs", "chart", chartName, "from", currentVersion, "to", version)
+	opts := make([]helmutil.UpgraderOption, 0)
+	if c.forceConflicts {
+		opts = append(opts, helmutil.WithForceConflicts())
+	}
+
+	upgrader, err := helmutil.NewUpgrader(kubeClient, c.chartRepo, c.repoURL, chartName, []string{helmutil.AllSubCharts}, opts...)
+	if err != nil {
+		return err
+	}
+
+	if _, err := upgrader.ApplyCRDs(ctx, chartDir); err != nil {
+		return err
+	}
+
+	// The user set values of the release are merged onto the new chart's defaults
+	extractDir := filepath.Dir(chartDir)
+	valuesFile, err := helmutil.MergeValuesFile(cfg, cli.New(), extractDir, version, chartName, c.releaseName)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = valuesFile.Close()
+		if err := os.Remove(valuesFile.Name()); err != nil {
+			log.Warn("Failed to remove merged values file", "path", valuesFile.Name(), "error", err)
+		}
+	}()
+
+	if _, err := valuesFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	log.Info("Upgrading release", "release", c.releaseName, "from", currentVersion, "to", version, "namespace", c.namespace)
+	_, err = helmutil.UpgradeValues(cfg, extractDir, chartName, c.releaseName, c.namespace, valuesFile, c.timeout)
+	if err == nil {
+		err = kubernetes.WaitForDeploymentsReady(ctx, kubeClient, c.namespace, map[string]string{helmutil.InstanceLabel: c.releaseName}, c.timeout)
+	}
+
+	if err != nil {
+		// The CRDs are backwards compatible and are kept at the new version
+		log.Warn("Upgrade failed, rolling back the release if it was changed", "release", c.releaseName, "version", currentVersion, "revision", previousRevision, "error", err)
+		rolledBack, rbErr := helmutil.RollbackUpgrade(cfg, c.releaseName, previousRevision, c.timeout)
+		if rbErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to roll back release %s: %w", c.releaseName, rbErr))
+		}
+		if !rolledBack {
+			return fmt.Errorf("upgrade to %s failed, release %s was not changed from %s: %w", version, c.releaseName, currentVersion, err)
+		}
+		return fmt.Errorf("upgrade to %s failed and release %s was rolled back to %s: %w", version, c.releaseName, currentVersion, err)
+	}
+
+	_, err = fmt.Fprintf(c.Out, "Upgraded release %s from %s %s to %s\n", c.releaseName, chartName, currentVersion, version)
+	return err
+}
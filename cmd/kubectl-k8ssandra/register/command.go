@@ -3,6 +3,7 @@ package register
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/k8ssandra/k8ssandra-client/pkg/registration"
@@ -32,6 +33,8 @@ func SetupRegisterClusterCmd(cmd *cobra.Command, streams genericclioptions.IOStr
 	registerClusterCmd.Flags().String("destination-name", "", "name for remote clientConfig and secret on destination cluster")
 	registerClusterCmd.Flags().String("override-src-ip", "", "override source IP for when you need to specify a different IP for the source cluster than is contained in kubeconfig")
 	registerClusterCmd.Flags().String("override-src-port", "", "override source port for when you need to specify a different port for the source cluster than is contained in src kubeconfig")
+	registerClusterCmd.Flags().String("token-mode", TokenModeSecret, "how the service account token is issued: secret copies a legacy token secret, bound requests a short-lived token which must be renewed with register refresh")
+	registerClusterCmd.Flags().Duration("token-expiration", 24*time.Hour, "requested lifetime of the token in bound token mode, the API server may shorten it")
 
 	if err := registerClusterCmd.MarkFlagRequired("source-context"); err != nil {
 		panic(err)
@@ -40,9 +43,43 @@ func SetupRegisterClusterCmd(cmd *cobra.Command, streams genericclioptions.IOStr
 		panic(err)
 	}
 	registerClusterCmd.MarkFlagsRequiredTogether("override-src-ip", "override-src-port")
+	setupRefreshCmd(registerClusterCmd)
+	setupListCmd(registerClusterCmd, streams)
+	setupRemoveCmd(registerClusterCmd)
 	cmd.AddCommand(registerClusterCmd)
 }
 
+func setupRefreshCmd(registerClusterCmd *cobra.Command) {
+	refreshCmd := &cobra.Command{
+		Use:   "refresh [flags]",
+		Short: "renew the bound token of a registered data plane before it expires.",
+		Long:  `refresh requests a new token for the ServiceAccount on the source cluster and replaces it in the kubeconfig secret on the destination cluster, if the current token expires within --renew-before. It makes a single attempt and exits with an error on failure, so it can be run periodically from a CronJob. Only data planes registered with --token-mode bound can be refreshed.`,
+		RunE:  refreshEntrypoint,
+	}
+
+	refreshCmd.Flags().String("source-kubeconfig",
+		"",
+		"path to source cluster's kubeconfig file - defaults to KUBECONFIG then ~/.kube/config")
+	refreshCmd.Flags().String("dest-kubeconfig",
+		"",
+		"path to destination cluster's kubeconfig file - defaults to KUBECONFIG then ~/.kube/config")
+	refreshCmd.Flags().String("source-context", "", "context name for source cluster")
+	refreshCmd.Flags().String("dest-context", "", "context name for destination cluster")
+	refreshCmd.Flags().String("dest-namespace", "k8ssandra-operator", "namespace of the secret and clientConfig on destination cluster")
+	refreshCmd.Flags().String("destination-name", "", "name of the remote clientConfig and secret on destination cluster")
+	refreshCmd.Flags().Duration("token-expiration", 0, "requested lifetime of the new token, defaults to the lifetime requested at registration")
+	refreshCmd.Flags().Duration("renew-before", 8*time.Hour, "renew the token if it expires within this duration")
+	refreshCmd.Flags().Bool("force", false, "renew the token even if it does not expire soon")
+
+	if err := refreshCmd.MarkFlagRequired("source-context"); err != nil {
+		panic(err)
+	}
+	if err := refreshCmd.MarkFlagRequired("dest-context"); err != nil {
+		panic(err)
+	}
+	registerClusterCmd.AddCommand(refreshCmd)
+}
+
 func entrypoint(cmd *cobra.Command, args []string) error {
 	executor := NewRegistrationExecutorFromRegisterClusterCmd(*cmd)
 
@@ -63,12 +100,29 @@ func entrypoint(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func refreshEntrypoint(cmd *cobra.Command, args []string) error {
+	executor := NewRegistrationExecutorFromRefreshCmd(*cmd)
+	renewBefore, _ := cmd.Flags().GetDuration("renew-before")
+	force, _ := cmd.Flags().GetBool("force")
+
+	renewed, err := executor.RefreshToken(renewBefore, force)
+	if err != nil {
+		log.Error(fmt.Sprintf("Token refresh failed: %s", err.Error()))
+		return err
+	}
+	if renewed {
+		log.Info("Token refreshed successfully", "destination", executor.DestinationName)
+	}
+	return nil
+}
+
 func NewRegistrationExecutorFromRegisterClusterCmd(cmd cobra.Command) *RegistrationExecutor {
 	destName := cmd.Flag("destination-name").Value.String()
 	srcContext := cmd.Flag("source-context").Value.String()
 	if destName == "" {
 		destName = registration.CleanupForKubernetes(srcContext)
 	}
+	tokenExpiration, _ := cmd.Flags().GetDuration("token-expiration")
 	return &RegistrationExecutor{
 		SourceKubeconfig:   cmd.Flag("source-kubeconfig").Value.String(),
 		DestKubeconfig:     cmd.Flag("dest-kubeconfig").Value.String(),
@@ -79,7 +133,29 @@ func NewRegistrationExecutorFromRegisterClusterCmd(cmd cobra.Command) *Registrat
 		ServiceAccount:     cmd.Flag("serviceaccount-name").Value.String(),
 		OverrideSourceIP:   cmd.Flag("override-src-ip").Value.String(),
 		OverrideSourcePort: cmd.Flag("override-src-port").Value.String(),
+		TokenMode:          cmd.Flag("token-mode").Value.String(),
+		TokenExpiration:    tokenExpiration,
 		Context:            cmd.Context(),
 		DestinationName:    destName,
 	}
 }
+
+func NewRegistrationExecutorFromRefreshCmd(cmd cobra.Command) *RegistrationExecutor {
+	destName := cmd.Flag("destination-name").Value.String()
+	srcContext := cmd.Flag("source-context").Value.String()
+	if destName == "" {
+		destName = registration.CleanupForKubernetes(srcContext)
+	}
+	tokenExpiration, _ := cmd.Flags().GetDuration("token-expiration")
+	return &RegistrationExecutor{
+		SourceKubeconfig: cmd.Flag("source-kubeconfig").Value.String(),
+		DestKubeconfig:   cmd.Flag("dest-kubeconfig").Value.String(),
+		SourceContext:    srcContext,
+		DestContext:      cmd.Flag("dest-context").Value.String(),
+		DestNamespace:    cmd.Flag("dest-namespace").Value.String(),
+		TokenMode:        TokenModeBound,
+		TokenExpiration:  tokenExpiration,
+		Context:          cmd.Context(),
+		DestinationName:  destName,
+	}
+}
@@ -1,7 +1,9 @@
 package register
 
 import (
+	"bytes"
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/require"
@@ -42,6 +44,68 @@ func TestInputParameters(t *testing.T) {
 	require.Equal("source-namespace", executor.SourceNamespace)
 	require.Equal("dest-namespace", executor.DestNamespace)
 	require.Equal("test-sa", executor.ServiceAccount)
+	require.Equal(TokenModeSecret, executor.TokenMode)
+	require.Equal(24*time.Hour, executor.TokenExpiration)
+}
+
+func TestBoundTokenParameters(t *testing.T) {
+	require := require.New(t)
+
+	var executor *RegistrationExecutor
+
+	cmd := &cobra.Command{}
+	SetupRegisterClusterCmd(cmd, genericiooptions.NewTestIOStreamsDiscard())
+	cmd.Commands()[0].RunE = func(cmd *cobra.Command, args []string) error {
+		executor = NewRegistrationExecutorFromRegisterClusterCmd(*cmd)
+		return nil
+	}
+	cmd.Root().SetArgs([]string{
+		"register",
+		"--source-context", "source-ctx",
+		"--dest-context", "dest-ctx",
+		"--token-mode", "bound",
+		"--token-expiration", "2h"})
+
+	require.NoError(cmd.Execute())
+
+	require.Equal(TokenModeBound, executor.TokenMode)
+	require.Equal(2*time.Hour, executor.TokenExpiration)
+	require.Equal("source-ctx", executor.DestinationName)
+}
+
+func TestRefreshParameters(t *testing.T) {
+	require := require.New(t)
+
+	var executor *RegistrationExecutor
+	var renewBefore time.Duration
+
+	cmd := &cobra.Command{}
+	SetupRegisterClusterCmd(cmd, genericiooptions.NewTestIOStreamsDiscard())
+	refreshCmd, _, err := cmd.Find([]string{"register", "refresh"})
+	require.NoError(err)
+	require.Equal("refresh", refreshCmd.Name())
+	refreshCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		executor = NewRegistrationExecutorFromRefreshCmd(*cmd)
+		renewBefore, _ = cmd.Flags().GetDuration("renew-before")
+		return nil
+	}
+	cmd.Root().SetArgs([]string{
+		"register", "refresh",
+		"--source-context", "source-ctx",
+		"--dest-context", "dest-ctx",
+		"--dest-namespace", "dest-namespace",
+		"--destination-name", "dp1",
+		"--renew-before", "1h"})
+
+	require.NoError(cmd.Execute())
+
+	require.Equal("source-ctx", executor.SourceContext)
+	require.Equal("dest-ctx", executor.DestContext)
+	require.Equal("dest-namespace", executor.DestNamespace)
+	require.Equal("dp1", executor.DestinationName)
+	require.Equal(TokenModeBound, executor.TokenMode)
+	require.Zero(executor.TokenExpiration)
+	require.Equal(time.Hour, renewBefore)
 }
 
 func TestIncorrectParameters(t *testing.T) {
@@ -63,3 +127,60 @@ func TestIncorrectParameters(t *testing.T) {
 	require.Error(err)
 	require.Equal("unknown flag: --service-account", err.Error())
 }
+
+func TestRemoveParameters(t *testing.T) {
+	require := require.New(t)
+
+	var executor *RegistrationExecutor
+	var deleteSourceCredentials bool
+
+	cmd := &cobra.Command{}
+	SetupRegisterClusterCmd(cmd, genericiooptions.NewTestIOStreamsDiscard())
+	removeCmd, _, err := cmd.Find([]string{"register", "remove"})
+	require.NoError(err)
+	require.NotNil(removeCmd.RunE)
+	removeCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		executor = NewRegistrationExecutorFromRemoveCmd(*cmd, args[0])
+		deleteSourceCredentials, _ = cmd.Flags().GetBool("delete-source-credentials")
+		return nil
+	}
+	cmd.Root().SetArgs([]string{
+		"register", "remove", "dp1",
+		"--dest-context", "dest-ctx",
+		"--dest-namespace", "dest-namespace",
+		"--source-context", "source-ctx",
+		"--delete-source-credentials"})
+
+	require.NoError(cmd.Execute())
+
+	require.Equal("dp1", executor.DestinationName)
+	require.Equal("dest-ctx", executor.DestContext)
+	require.Equal("dest-namespace", executor.DestNamespace)
+	require.Equal("source-ctx", executor.SourceContext)
+	require.Equal("k8ssandra-operator", executor.SourceNamespace)
+	require.Equal("k8ssandra-operator", executor.ServiceAccount)
+	require.True(deleteSourceCredentials)
+
+	cmd.Root().SetArgs([]string{"register", "remove"})
+	require.Error(cmd.Execute())
+
+	// Flag combinations are validated before connecting to any cluster
+	var nonRecoverable NonRecoverableError
+	executor = &RegistrationExecutor{DestinationName: "dp1"}
+	require.ErrorAs(executor.RemoveRegistration(false, true), &nonRecoverable)
+	require.ErrorAs(executor.RemoveRegistration(true, false), &nonRecoverable)
+}
+
+func TestPrintRegistrations(t *testing.T) {
+	require := require.New(t)
+
+	var out bytes.Buffer
+	require.NoError(printRegistrations(&out, []RegisteredDataPlane{
+		{Name: "dp1", Secret: "dp1", Server: "https://10.0.0.1:6443", Expiration: "2026-01-02T03:04:05Z", Status: "ok (system:serviceaccount:k8ssandra-operator:k8ssandra-operator)"},
+		{Name: "dp2", Secret: "dp2", Server: "-", Expiration: "-", Status: "secret not found"},
+	}))
+	require.Equal(`NAME  SECRET  SERVER                 TOKEN EXPIRATION      STATUS
+dp1   dp1     https://10.0.0.1:6443  2026-01-02T03:04:05Z  ok (system:serviceaccount:k8ssandra-operator:k8ssandra-operator)
+dp2   dp2     -                      -                     secret not found
+`, out.String())
+}
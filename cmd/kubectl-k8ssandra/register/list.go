@@ -0,0 +1,159 @@
+package register
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/k8ssandra/k8ssandra-client/pkg/registration"
+	configapi "github.com/k8ssandra/k8ssandra-operator/apis/config/v1beta1"
+)
+
+// RegisteredDataPlane is a ClientConfig on the control plane and the state of the credentials it references
+type RegisteredDataPlane struct {
+	Name       string
+	Secret     string
+	Server     string
+	Expiration string
+	Status     string
+}
+
+func setupListCmd(registerClusterCmd *cobra.Command, streams genericclioptions.IOStreams) {
+	listCmd := &cobra.Command{
+		Use:   "list [flags]",
+		Short: "list the data planes registered into the control plane.",
+		Long:  `list shows the ClientConfigs on the destination cluster with the secret they reference, the server URL of the data plane and whether the stored credentials can still authenticate to it.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			executor := NewRegistrationExecutorFromDestinationCmd(*cmd)
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+
+			dataPlanes, err := executor.ListRegistrations(timeout)
+			if err != nil {
+				log.Error(fmt.Sprintf("Listing registrations failed: %s", err.Error()))
+				return err
+			}
+
+			return printRegistrations(streams.Out, dataPlanes)
+		},
+	}
+
+	addDestinationFlags(listCmd)
+	listCmd.Flags().Duration("timeout", 10*time.Second, "timeout of the connectivity check of each data plane")
+	registerClusterCmd.AddCommand(listCmd)
+}
+
+// addDestinationFlags adds the flags selecting the destination cluster of the registrations
+func addDestinationFlags(cmd *cobra.Command) {
+	cmd.Flags().String("dest-kubeconfig",
+		"",
+		"path to destination cluster's kubeconfig file - defaults to KUBECONFIG then ~/.kube/config")
+	cmd.Flags().String("dest-context", "", "context name for destination cluster - defaults to the current context")
+	cmd.Flags().String("dest-namespace", "k8ssandra-operator", "namespace of the secrets and clientConfigs on destination cluster")
+}
+
+func NewRegistrationExecutorFromDestinationCmd(cmd cobra.Command) *RegistrationExecutor {
+	return &RegistrationExecutor{
+		DestKubeconfig: cmd.Flag("dest-kubeconfig").Value.String(),
+		DestContext:    cmd.Flag("dest-context").Value.String(),
+		DestNamespace:  cmd.Flag("dest-namespace").Value.String(),
+		Context:        cmd.Context(),
+	}
+}
+
+// ListRegistrations returns the ClientConfigs in the destination namespace and checks if their credentials are
+// accepted by the data plane
+func (e *RegistrationExecutor) ListRegistrations(timeout time.Duration) ([]RegisteredDataPlane, error) {
+	destClient, err := registration.GetClient(e.DestKubeconfig, e.DestContext)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := configapi.AddToScheme(destClient.Scheme()); err != nil {
+		return nil, err
+	}
+
+	clientConfigs := &configapi.ClientConfigList{}
+	if err := destClient.List(e.Context, clientConfigs, client.InNamespace(e.DestNamespace)); err != nil {
+		return nil, err
+	}
+
+	dataPlanes := make([]RegisteredDataPlane, 0, len(clientConfigs.Items))
+	for _, clientConfig := range clientConfigs.Items {
+		dataPlanes = append(dataPlanes, e.checkRegistration(destClient, clientConfig, timeout))
+	}
+
+	return dataPlanes, nil
+}
+
+func (e *RegistrationExecutor) checkRegistration(destClient client.Client, clientConfig configapi.ClientConfig, timeout time.Duration) RegisteredDataPlane {
+	dataPlane := RegisteredDataPlane{
+		Name:       clientConfig.Name,
+		Secret:     clientConfig.Spec.KubeConfigSecret.Name,
+		Server:     "-",
+		Expiration: "-",
+	}
+
+	secret := &corev1.Secret{}
+	if err := destClient.Get(e.Context, client.ObjectKey{Name: dataPlane.Secret, Namespace: clientConfig.Namespace}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			dataPlane.Status = "secret not found"
+		} else {
+			dataPlane.Status = fmt.Sprintf("error: %s", err)
+		}
+		return dataPlane
+	}
+
+	if expiration, found := secret.Annotations[registration.TokenExpirationAnnotation]; found {
+		dataPlane.Expiration = expiration
+	}
+
+	config, err := clientcmd.Load(secret.Data["kubeconfig"])
+	if err != nil {
+		dataPlane.Status = fmt.Sprintf("invalid kubeconfig: %s", err)
+		return dataPlane
+	}
+
+	server, err := registration.KubeconfigServer(config, clientConfig.Spec.ContextName)
+	if err != nil {
+		dataPlane.Status = fmt.Sprintf("invalid kubeconfig: %s", err)
+		return dataPlane
+	}
+	dataPlane.Server = server
+
+	ctx, cancel := context.WithTimeout(e.Context, timeout)
+	defer cancel()
+
+	username, err := registration.CheckAccess(ctx, config, clientConfig.Spec.ContextName)
+	if err != nil {
+		dataPlane.Status = fmt.Sprintf("error: %s", err)
+		return dataPlane
+	}
+	dataPlane.Status = fmt.Sprintf("ok (%s)", username)
+
+	return dataPlane
+}
+
+func printRegistrations(out io.Writer, dataPlanes []RegisteredDataPlane) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "NAME\tSECRET\tSERVER\tTOKEN EXPIRATION\tSTATUS"); err != nil {
+		return err
+	}
+
+	for _, dataPlane := range dataPlanes {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", dataPlane.Name, dataPlane.Secret, dataPlane.Server, dataPlane.Expiration, dataPlane.Status); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
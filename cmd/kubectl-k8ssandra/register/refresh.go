@@ -0,0 +1,71 @@
+package register
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/k8ssandra/k8ssandra-client/pkg/registration"
+)
+
+// RefreshToken requests a new bound token for the data plane registered as DestinationName and replaces it in the
+// destination secret if the current one expires within renewBefore. It returns true if the token was renewed.
+func (e *RegistrationExecutor) RefreshToken(renewBefore time.Duration, force bool) (bool, error) {
+	srcClient, err := registration.GetClient(e.SourceKubeconfig, e.SourceContext)
+	if err != nil {
+		return false, err
+	}
+
+	destClient, err := registration.GetClient(e.DestKubeconfig, e.DestContext)
+	if err != nil {
+		return false, err
+	}
+
+	destSecret := &corev1.Secret{}
+	if err := destClient.Get(e.Context, client.ObjectKey{Name: e.DestinationName, Namespace: e.DestNamespace}, destSecret); err != nil {
+		return false, err
+	}
+
+	saKey, expiration, err := registration.BoundServiceAccount(destSecret)
+	if err != nil {
+		return false, NonRecoverable(err.Error())
+	}
+
+	if !force && !registration.NeedsRenewal(destSecret, renewBefore, time.Now()) {
+		log.Info("Token does not need renewal yet", "secret", client.ObjectKeyFromObject(destSecret), "expiration", destSecret.Annotations[registration.TokenExpirationAnnotation])
+		return false, nil
+	}
+
+	if e.TokenExpiration > 0 {
+		expiration = e.TokenExpiration
+	}
+
+	serviceAccount := &corev1.ServiceAccount{}
+	if err := srcClient.Get(e.Context, saKey, serviceAccount); err != nil {
+		return false, err
+	}
+
+	token, err := registration.RequestToken(e.Context, srcClient, serviceAccount, expiration)
+	if err != nil {
+		return false, err
+	}
+
+	kubeconfig, err := registration.ReplaceToken(destSecret.Data["kubeconfig"], token.Token)
+	if err != nil {
+		return false, NonRecoverable(fmt.Sprintf("invalid kubeconfig in secret %s/%s: %s", destSecret.Namespace, destSecret.Name, err))
+	}
+
+	destSecret.Data["kubeconfig"] = kubeconfig
+	for k, v := range token.Annotations(serviceAccount) {
+		destSecret.Annotations[k] = v
+	}
+
+	if err := destClient.Update(e.Context, destSecret); err != nil {
+		return false, fmt.Errorf("error updating secret. err: %s sa %s", err, saKey)
+	}
+
+	return true, nil
+}
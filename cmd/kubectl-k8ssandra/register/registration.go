@@ -3,6 +3,7 @@ package register
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/charmbracelet/log"
 
@@ -10,12 +11,21 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/k8ssandra/k8ssandra-client/pkg/registration"
 	configapi "github.com/k8ssandra/k8ssandra-operator/apis/config/v1beta1"
 )
 
+const (
+	// TokenModeSecret copies the token of a kubernetes.io/service-account-token Secret, which never expires
+	TokenModeSecret = "secret"
+
+	// TokenModeBound requests a short-lived token with the TokenRequest API, which has to be renewed with register refresh
+	TokenModeBound = "bound"
+)
+
 type RegistrationExecutor struct {
 	DestinationName    string
 	SourceKubeconfig   string
@@ -27,6 +37,8 @@ type RegistrationExecutor struct {
 	ServiceAccount     string
 	OverrideSourceIP   string
 	OverrideSourcePort string
+	TokenMode          string
+	TokenExpiration    time.Duration
 	Context            context.Context
 }
 
@@ -59,6 +71,16 @@ func (e *RegistrationExecutor) RegisterCluster() error {
 		return NonRecoverable("source and destination context and kubeconfig are the same, you should not register the same cluster to itself. Reference it by leaving the k8sContext field blank instead")
 	}
 
+	switch e.TokenMode {
+	case "", TokenModeSecret:
+	case TokenModeBound:
+		if e.TokenExpiration < 10*time.Minute {
+			return NonRecoverable("token expiration must be at least 10m, the minimum accepted by the TokenRequest API")
+		}
+	default:
+		return NonRecoverable(fmt.Sprintf("unsupported token mode %s, supported modes are %s and %s", e.TokenMode, TokenModeSecret, TokenModeBound))
+	}
+
 	srcClient, err := registration.GetClient(e.SourceKubeconfig, e.SourceContext)
 	if err != nil {
 		return err
@@ -79,54 +101,49 @@ func (e *RegistrationExecutor) RegisterCluster() error {
 		}
 		return err
 	}
-	// Get a secret in this namespace which holds the service account token
-	secretsList := &corev1.SecretList{}
-	if err := srcClient.List(e.Context, secretsList, client.InNamespace(e.SourceNamespace)); err != nil {
-		return err
-	}
 
-	var secret *corev1.Secret
-	for _, s := range secretsList.Items {
-		if s.Annotations["kubernetes.io/service-account.name"] == e.ServiceAccount && s.Type == corev1.SecretTypeServiceAccountToken {
-			secret = &s
-			break
-		}
-	}
-
-	if secret == nil {
-		secret = getDefaultSecret(e.SourceNamespace, e.ServiceAccount)
-		if err := srcClient.Create(e.Context, secret); err != nil {
-			return err
-		}
-		return fmt.Errorf("no secret found for service account %s", e.ServiceAccount)
-	}
-
-	// Create Secret on destination cluster
 	host, err := registration.KubeconfigToHost(e.SourceKubeconfig, e.SourceContext, e.OverrideSourceIP, e.OverrideSourcePort)
 	if err != nil {
 		return err
 	}
-	saConfig, err := registration.TokenToKubeconfig(*secret, host, e.DestinationName)
+
+	var saConfig clientcmdapi.Config
+	var annotations map[string]string
+	if e.TokenMode == TokenModeBound {
+		saConfig, annotations, err = e.boundTokenKubeconfig(srcClient, serviceAccount, host)
+	} else {
+		saConfig, err = e.secretTokenKubeconfig(srcClient, host)
+	}
 	if err != nil {
-		return fmt.Errorf("error converting token to kubeconfig: %s, secret: %#v", err.Error(), secret)
+		return err
 	}
 
+	// Create Secret on destination cluster
 	secretData, err := clientcmd.Write(saConfig)
 	if err != nil {
 		return err
 	}
 	destSecret := corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      e.DestinationName,
-			Namespace: e.DestNamespace,
+			Name:        e.DestinationName,
+			Namespace:   e.DestNamespace,
+			Annotations: annotations,
 		},
 		Type: corev1.SecretTypeOpaque,
 		Data: map[string][]byte{
 			"kubeconfig": secretData,
 		},
 	}
-	if err := destClient.Create(e.Context, &destSecret); err != nil && !apierrors.IsAlreadyExists(err) {
-		return fmt.Errorf("error creating secret. err: %s sa %s", err, e.ServiceAccount)
+	if err := destClient.Create(e.Context, &destSecret); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("error creating secret. err: %s sa %s", err, e.ServiceAccount)
+		}
+		// A new bound token was requested, it replaces the previous one
+		if e.TokenMode == TokenModeBound {
+			if err := updateKubeconfigSecret(e.Context, destClient, &destSecret); err != nil {
+				return fmt.Errorf("error updating secret. err: %s sa %s", err, e.ServiceAccount)
+			}
+		}
 	}
 
 	// Create ClientConfig on destination cluster
@@ -152,3 +169,60 @@ func (e *RegistrationExecutor) RegisterCluster() error {
 
 	return nil
 }
+
+// secretTokenKubeconfig builds the kubeconfig from the ServiceAccount's token Secret, creating the Secret if it's missing
+func (e *RegistrationExecutor) secretTokenKubeconfig(srcClient client.Client, host string) (clientcmdapi.Config, error) {
+	// Get a secret in this namespace which holds the service account token
+	secrets, err := registration.ServiceAccountTokenSecrets(e.Context, srcClient, e.SourceNamespace, e.ServiceAccount)
+	if err != nil {
+		return clientcmdapi.Config{}, err
+	}
+
+	if len(secrets) == 0 {
+		if err := srcClient.Create(e.Context, getDefaultSecret(e.SourceNamespace, e.ServiceAccount)); err != nil {
+			return clientcmdapi.Config{}, err
+		}
+		return clientcmdapi.Config{}, fmt.Errorf("no secret found for service account %s", e.ServiceAccount)
+	}
+
+	secret := &secrets[0]
+	saConfig, err := registration.TokenToKubeconfig(*secret, host, e.DestinationName)
+	if err != nil {
+		return clientcmdapi.Config{}, fmt.Errorf("error converting token to kubeconfig: %s, secret: %#v", err.Error(), secret)
+	}
+	return saConfig, nil
+}
+
+// boundTokenKubeconfig builds the kubeconfig from a token requested for the ServiceAccount. The returned annotations
+// of the destination secret allow renewing the token with register refresh.
+func (e *RegistrationExecutor) boundTokenKubeconfig(srcClient client.Client, serviceAccount *corev1.ServiceAccount, host string) (clientcmdapi.Config, map[string]string, error) {
+	caData, err := registration.RootCA(e.Context, srcClient, e.SourceNamespace)
+	if err != nil {
+		return clientcmdapi.Config{}, nil, err
+	}
+
+	token, err := registration.RequestToken(e.Context, srcClient, serviceAccount, e.TokenExpiration)
+	if err != nil {
+		return clientcmdapi.Config{}, nil, err
+	}
+
+	return registration.CredentialsToKubeconfig(caData, token.Token, host, e.DestinationName), token.Annotations(serviceAccount), nil
+}
+
+// updateKubeconfigSecret replaces the kubeconfig and the token annotations of the existing destination secret
+func updateKubeconfigSecret(ctx context.Context, destClient client.Client, desired *corev1.Secret) error {
+	existing := &corev1.Secret{}
+	if err := destClient.Get(ctx, client.ObjectKeyFromObject(desired), existing); err != nil {
+		return err
+	}
+
+	if existing.Annotations == nil {
+		existing.Annotations = make(map[string]string, len(desired.Annotations))
+	}
+	for k, v := range desired.Annotations {
+		existing.Annotations[k] = v
+	}
+	existing.Data = desired.Data
+
+	return destClient.Update(ctx, existing)
+}
@@ -0,0 +1,165 @@
+package register
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/k8ssandra/k8ssandra-client/pkg/registration"
+	configapi "github.com/k8ssandra/k8ssandra-operator/apis/config/v1beta1"
+)
+
+func setupRemoveCmd(registerClusterCmd *cobra.Command) {
+	removeCmd := &cobra.Command{
+		Use:   "remove <name> [flags]",
+		Short: "remove a registered data plane from the control plane.",
+		Long:  `remove deletes the ClientConfig and the kubeconfig secret it references from the destination cluster. With --delete-source-credentials it also deletes the ServiceAccount token secrets on the source cluster, which revokes the tokens copied by register.`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			executor := NewRegistrationExecutorFromRemoveCmd(*cmd, args[0])
+			deleteSourceCredentials, _ := cmd.Flags().GetBool("delete-source-credentials")
+			deleteServiceAccount, _ := cmd.Flags().GetBool("delete-serviceaccount")
+
+			if err := executor.RemoveRegistration(deleteSourceCredentials, deleteServiceAccount); err != nil {
+				log.Error(fmt.Sprintf("Removal failed: %s", err.Error()))
+				return err
+			}
+			log.Info("Registration removed successfully", "destination", executor.DestinationName)
+			return nil
+		},
+	}
+
+	addDestinationFlags(removeCmd)
+	removeCmd.Flags().String("source-kubeconfig",
+		"",
+		"path to source cluster's kubeconfig file - defaults to KUBECONFIG then ~/.kube/config")
+	removeCmd.Flags().String("source-context", "", "context name for source cluster")
+	removeCmd.Flags().String("source-namespace", "k8ssandra-operator", "namespace containing service account for source cluster, used if the registration does not record it")
+	removeCmd.Flags().String("serviceaccount-name", "k8ssandra-operator", "serviceaccount name for source cluster, used if the registration does not record it")
+	removeCmd.Flags().Bool("delete-source-credentials", false, "delete the service account token secrets on the source cluster")
+	removeCmd.Flags().Bool("delete-serviceaccount", false, "also delete the service account on the source cluster, which revokes bound tokens. Do not use if the operator on the data plane runs as this service account")
+	registerClusterCmd.AddCommand(removeCmd)
+}
+
+func NewRegistrationExecutorFromRemoveCmd(cmd cobra.Command, name string) *RegistrationExecutor {
+	return &RegistrationExecutor{
+		SourceKubeconfig: cmd.Flag("source-kubeconfig").Value.String(),
+		DestKubeconfig:   cmd.Flag("dest-kubeconfig").Value.String(),
+		SourceContext:    cmd.Flag("source-context").Value.String(),
+		DestContext:      cmd.Flag("dest-context").Value.String(),
+		SourceNamespace:  cmd.Flag("source-namespace").Value.String(),
+		DestNamespace:    cmd.Flag("dest-namespace").Value.String(),
+		ServiceAccount:   cmd.Flag("serviceaccount-name").Value.String(),
+		Context:          cmd.Context(),
+		DestinationName:  name,
+	}
+}
+
+// RemoveRegistration deletes the ClientConfig and kubeconfig secret of DestinationName from the destination cluster
+// and optionally the credentials they were created from on the source cluster
+func (e *RegistrationExecutor) RemoveRegistration(deleteSourceCredentials, deleteServiceAccount bool) error {
+	if deleteServiceAccount && !deleteSourceCredentials {
+		return NonRecoverable("--delete-serviceaccount requires --delete-source-credentials")
+	}
+	if deleteSourceCredentials && e.SourceContext == "" {
+		return NonRecoverable("--delete-source-credentials requires --source-context")
+	}
+
+	destClient, err := registration.GetClient(e.DestKubeconfig, e.DestContext)
+	if err != nil {
+		return err
+	}
+
+	if err := configapi.AddToScheme(destClient.Scheme()); err != nil {
+		return err
+	}
+
+	// The secret name defaults to the registration name if the ClientConfig was already removed
+	secretName := e.DestinationName
+	clientConfig := &configapi.ClientConfig{}
+	clientConfigFound := true
+	if err := destClient.Get(e.Context, client.ObjectKey{Name: e.DestinationName, Namespace: e.DestNamespace}, clientConfig); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		clientConfigFound = false
+	} else if clientConfig.Spec.KubeConfigSecret.Name != "" {
+		secretName = clientConfig.Spec.KubeConfigSecret.Name
+	}
+
+	secret := &corev1.Secret{}
+	secretFound := true
+	if err := destClient.Get(e.Context, client.ObjectKey{Name: secretName, Namespace: e.DestNamespace}, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		secretFound = false
+	}
+
+	if !clientConfigFound && !secretFound {
+		return NonRecoverable(fmt.Sprintf("no registration %s found in namespace %s", e.DestinationName, e.DestNamespace))
+	}
+
+	// The source credentials are removed first, the secret on the destination records which ServiceAccount they belong to
+	if deleteSourceCredentials {
+		saKey := client.ObjectKey{Namespace: e.SourceNamespace, Name: e.ServiceAccount}
+		if boundSaKey, _, err := registration.BoundServiceAccount(secret); err == nil {
+			saKey = boundSaKey
+		}
+		if err := e.removeSourceCredentials(saKey, deleteServiceAccount); err != nil {
+			return err
+		}
+	}
+
+	if secretFound {
+		if err := destClient.Delete(e.Context, secret); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	if clientConfigFound {
+		if err := destClient.Delete(e.Context, clientConfig); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *RegistrationExecutor) removeSourceCredentials(saKey client.ObjectKey, deleteServiceAccount bool) error {
+	srcClient, err := registration.GetClient(e.SourceKubeconfig, e.SourceContext)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := registration.ServiceAccountTokenSecrets(e.Context, srcClient, saKey.Namespace, saKey.Name)
+	if err != nil {
+		return err
+	}
+
+	for i := range secrets {
+		if err := srcClient.Delete(e.Context, &secrets[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		log.Info("Deleted service account token secret", "secret", client.ObjectKeyFromObject(&secrets[i]))
+	}
+
+	if !deleteServiceAccount {
+		if len(secrets) == 0 {
+			log.Warn("No token secrets found, bound tokens stay valid until they expire unless the service account is deleted", "serviceaccount", saKey)
+		}
+		return nil
+	}
+
+	sa := getDefaultServiceAccount(saKey.Name, saKey.Namespace)
+	if err := srcClient.Delete(e.Context, sa); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	log.Info("Deleted service account", "serviceaccount", saKey)
+
+	return nil
+}
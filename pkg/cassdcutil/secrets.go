@@ -41,3 +41,19 @@ func (c *CassManager) CassandraAuthDetails(ctx context.Context, cassdc *cassdcap
 
 	return auth, nil
 }
+
+// NodetoolAuthParameters returns the nodetool parameters authenticating with the superuser and the JMX keystores
+func NodetoolAuthParameters(authDetails *CassandraAuth) []string {
+	auth := []string{"--username", authDetails.Username, "--password", authDetails.Password}
+
+	if authDetails.KeystorePath != "" {
+		auth = append(auth, "-Dcom.sun.management.jmxremote.ssl.need.client.auth=true")
+		auth = append(auth, "-Dcom.sun.management.jmxremote.registry.ssl=true")
+		auth = append(auth, "-Djavax.net.ssl.keyStore="+authDetails.KeystorePath)
+		auth = append(auth, "-Djavax.net.ssl.keyStorePassword="+authDetails.KeystorePassword)
+		auth = append(auth, "-Djavax.net.ssl.trustStore="+authDetails.TruststorePath)
+		auth = append(auth, "-Djavax.net.ssl.trustStorePassword="+authDetails.TruststorePassword)
+	}
+
+	return auth
+}
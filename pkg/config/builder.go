@@ -2,11 +2,12 @@ package config
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -36,12 +37,25 @@ const (
 	sidecarConfigName         = "sidecar.yaml"
 	ipv4Local                 = "0.0.0.0"
 	ipv6Local                 = "::"
+	rackDCLocationProvider    = "RackDCFileLocationProvider"
+
+	rackDCTemplate = `dc={{ .DatacenterName }}
+rack={{ .RackName }}
+{{- range .Properties }}
+{{ .Key }}={{ .Value }}
+{{- end }}
+`
 )
 
+// reservedRackDCProperties are managed by the builder and can not be set with additional-properties
+var reservedRackDCProperties = []string{"dc", "rack", "dc_suffix", "prefer_local"}
+
 type Builder struct {
 	configInputDir  string
 	configOutputDir string
 	sidecar         bool
+	dryRun          bool
+	configInputFile string
 }
 
 type BuilderOption func(*Builder)
@@ -52,6 +66,21 @@ func WithSidecar() BuilderOption {
 	}
 }
 
+// WithDryRun renders the files to a temporary directory and only logs the manifest, comparing it to the manifest
+// of the previous build in the output directory
+func WithDryRun() BuilderOption {
+	return func(builder *Builder) {
+		builder.dryRun = true
+	}
+}
+
+// WithConfigInputFile reads the ConfigInput from a file, such as a mounted ConfigMap, instead of CONFIG_FILE_DATA
+func WithConfigInputFile(path string) BuilderOption {
+	return func(builder *Builder) {
+		builder.configInputFile = path
+	}
+}
+
 func NewBuilder(overrideConfigInput, overrideConfigOutput string, opts ...BuilderOption) *Builder {
 	b := &Builder{
 		configInputDir:  defaultInputDir,
@@ -74,74 +103,110 @@ func NewBuilder(overrideConfigInput, overrideConfigOutput string, opts ...Builde
 }
 
 var (
-	prefixRegexp = regexp.MustCompile(gentypes.JvmServerOptionsPrefixExp)
+	prefixRegexp = regexp.MustCompile(gentypes.JvmServerOptionsPrefixExp + "|^-Xmn")
 )
 
 func (b *Builder) Build(ctx context.Context) error {
-	// Parse input from cass-operator
-	configInput, err := parseConfigInput()
-	if err != nil {
-		return err
+	targetDir := b.configOutputDir
+	if b.dryRun {
+		tempDir, err := os.MkdirTemp("", "k8ssandra-config-dry-run")
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if err := os.RemoveAll(tempDir); err != nil {
+				log.Warnf("Failed to remove dry-run directory %s: %v", tempDir, err)
+			}
+		}()
+		targetDir = tempDir
 	}
 
-	nodeInfo, err := parseNodeInfo()
+	manifest, err := b.build(targetDir)
 	if err != nil {
 		return err
 	}
 
-	log.Infof("Parsed ConfigInput and NodeInfo for node %s", nodeInfo.Name)
-	if b.sidecar {
-		return createSidecarYaml(configInput, nodeInfo, b.configInputDir, b.configOutputDir)
+	for _, f := range manifest.Files {
+		log.Info("Generated config file", "name", f.Name, "source", f.Source, "checksum", f.Checksum, "layers", strings.Join(f.Layers, ","))
 	}
 
-	podOverrides := podOverridesForNode(configInput, nodeInfo)
+	if b.dryRun {
+		previous, err := ReadManifest(b.configOutputDir)
+		if err != nil {
+			return err
+		}
 
-	// Apply non-cassandra.yaml overrides directly into configInput so they participate in standard merging
-	configInput.CassandraEnv = mergeCassandraEnvOptions(configInput.CassandraEnv, podOverrides.CassandraEnv)
+		changed := manifest.Changed(previous)
+		log.Info("Dry-run completed, no files were written", "checksum", manifest.Checksum, "changed", strings.Join(changed, ","))
+		return nil
+	}
 
-	// Create rack information
-	if err := createRackProperties(configInput, nodeInfo, b.configOutputDir); err != nil {
-		return err
+	log.Info("Config files generated", "checksum", manifest.Checksum)
+	if b.sidecar {
+		// The sidecar output directory only has the sidecar.yaml
+		return nil
 	}
 
-	// Create cassandra-env.sh
-	if err := createCassandraEnv(configInput, b.configInputDir, b.configOutputDir); err != nil {
-		return err
+	return writeManifest(manifest, targetDir)
+}
+
+// build renders the files with the ConfigInput and NodeInfo from the environment and writes them to the targetDir
+func (b *Builder) build(targetDir string) (*Manifest, error) {
+	// Parse input from cass-operator
+	configInput, err := b.parseConfigInput()
+	if err != nil {
+		return nil, err
 	}
 
-	// Create jvm*-server.options (merge per-pod overrides inside the helper)
-	if err := createJVMOptions(configInput, b.configInputDir, b.configOutputDir, podOverrides); err != nil {
-		return err
+	nodeInfo, err := parseNodeInfo()
+	if err != nil {
+		return nil, err
 	}
 
-	// Create cassandra.yaml (apply per-pod overrides at the very end)
-	var finalCassYaml map[string]interface{}
-	if podOverrides != nil {
-		finalCassYaml = podOverrides.CassYaml
+	render := Render
+	if b.sidecar {
+		render = RenderSidecar
 	}
-	if err := createCassandraYaml(configInput, nodeInfo, b.configInputDir, b.configOutputDir, finalCassYaml); err != nil {
-		return err
+
+	_, manifest, err := render(configInput, nodeInfo, os.DirFS(b.configInputDir), DirOutput(targetDir))
+	if err != nil {
+		return nil, err
 	}
 
-	// Copy files which we're not modifying
-	if err := copyFiles(b.configInputDir, b.configOutputDir); err != nil {
-		return err
+	for i, f := range manifest.Files {
+		if f.Source != "" {
+			manifest.Files[i].Source = filepath.Join(b.configInputDir, f.Source)
+		}
 	}
 
-	return nil
+	return manifest, nil
 }
 
 // Refactor to methods to saner names and files..
 
 func parseConfigInput() (*ConfigInput, error) {
 	configInputStr := os.Getenv("CONFIG_FILE_DATA")
-	return parseConfigInputFromData(configInputStr)
+	return DecodeConfigInput([]byte(configInputStr))
+}
+
+func (b *Builder) parseConfigInput() (*ConfigInput, error) {
+	if b.configInputFile == "" {
+		return parseConfigInput()
+	}
+
+	data, err := os.ReadFile(b.configInputFile)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeConfigInput(data)
 }
 
-func parseConfigInputFromData(data string) (*ConfigInput, error) {
+// DecodeConfigInput parses the ConfigInput JSON in the format cass-operator passes in CONFIG_FILE_DATA
+func DecodeConfigInput(data []byte) (*ConfigInput, error) {
 	configInput := &ConfigInput{}
 
-	d := json.NewDecoder(strings.NewReader(data))
+	d := json.NewDecoder(bytes.NewReader(data))
 	d.UseNumber() // This decodes the numbers as strings
 	if err := d.Decode(configInput); err != nil {
 		return nil, err
@@ -172,23 +237,46 @@ func mergeCassandraEnvOptions(lowerPriority, higherPriority CassandraEnvOptions)
 		merged.HeapDumpDir = higherPriority.HeapDumpDir
 	}
 	if len(higherPriority.AdditionalOpts) > 0 {
-		merged.AdditionalOpts = append(merged.AdditionalOpts, higherPriority.AdditionalOpts...)
+		merged.AdditionalOpts = append(slices.Clone(merged.AdditionalOpts), higherPriority.AdditionalOpts...)
+	}
+	if higherPriority.JMX != nil {
+		merged.JMX = higherPriority.JMX
+	}
+	if len(higherPriority.Env) > 0 {
+		env := make(map[string]string, len(lowerPriority.Env)+len(higherPriority.Env))
+		maps.Copy(env, lowerPriority.Env)
+		maps.Copy(env, higherPriority.Env)
+		merged.Env = env
+	}
+	if len(higherPriority.JavaAgents) > 0 {
+		merged.JavaAgents = append(slices.Clone(merged.JavaAgents), higherPriority.JavaAgents...)
 	}
 	return merged
 }
 
+// parseNodeInfo reads the pod information from the environment. POD_IPS and HOST_IPS are the dual-stack lists
+// from the downward API, the rest of the variables are the counterparts of the NetworkingOptions.
 func parseNodeInfo() (*NodeInfo, error) {
 	rackName := os.Getenv("RACK_NAME")
 	podName := os.Getenv("POD_NAME")
 
 	n := &NodeInfo{
-		Name: podName,
-		Rack: rackName,
+		Name:    podName,
+		Rack:    rackName,
+		PodIPs:  parseIPList(os.Getenv("POD_IP"), os.Getenv("POD_IPS")),
+		HostIPs: parseIPList(os.Getenv("HOST_IP"), os.Getenv("HOST_IPS")),
+		Networking: NetworkingOptions{
+			BroadcastMode:          os.Getenv("BROADCAST_MODE"),
+			InternodeBroadcastMode: os.Getenv("INTERNODE_BROADCAST_MODE"),
+			IPFamily:               os.Getenv("IP_FAMILY"),
+			RPCAddressMode:         os.Getenv("RPC_ADDRESS_MODE"),
+			ServiceIP:              os.Getenv("SERVICE_IP"),
+			ExternalIP:             os.Getenv("NODE_EXTERNAL_IP"),
+			Hostname:               os.Getenv("BROADCAST_HOSTNAME"),
+			DNSDomain:              os.Getenv("DNS_DOMAIN"),
+		},
 	}
 
-	podIp := os.Getenv("POD_IP")
-	hostIp := os.Getenv("HOST_IP")
-
 	useHostIp := false
 	useHostIpStr := os.Getenv("USE_HOST_IP_FOR_BROADCAST")
 	if useHostIpStr != "" {
@@ -199,125 +287,143 @@ func parseNodeInfo() (*NodeInfo, error) {
 		}
 	}
 
-	broadcastIp := podIp
-	if useHostIp {
-		broadcastIp = hostIp
+	if useHostIp && n.Networking.BroadcastMode == "" {
+		n.Networking.BroadcastMode = NetworkModeHostIP
+		n.hostIPForBroadcast = true
 	}
 
-	if ip := net.ParseIP(broadcastIp); ip != nil {
-		n.BroadcastIP = ip
+	// Downward API resourceFieldRef values, limits.memory in bytes and limits.cpu in cores
+	if memoryLimit := os.Getenv("CONTAINER_MEMORY_LIMIT"); memoryLimit != "" {
+		limit, err := strconv.ParseInt(memoryLimit, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CONTAINER_MEMORY_LIMIT: %w", err)
+		}
+		n.Resources.MemoryLimit = limit
 	}
 
-	if ip := net.ParseIP(podIp); ip != nil {
-		n.ListenIP = ip
+	if cpuLimit := os.Getenv("CONTAINER_CPU_LIMIT"); cpuLimit != "" {
+		limit, err := strconv.ParseFloat(cpuLimit, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CONTAINER_CPU_LIMIT: %w", err)
+		}
+		n.Resources.CPULimit = limit
 	}
 
-	if ip := net.ParseIP(podIp); ip != nil {
-		if ip4 := ip.To4(); ip4 != nil {
-			n.RPCIP = net.ParseIP(ipv4Local)
-		} else if len(ip) == net.IPv6len {
-			n.RPCIP = net.ParseIP(ipv6Local)
-		}
+	if err := n.resolveAddresses(NetworkingOptions{}); err != nil {
+		return nil, err
 	}
 
 	return n, nil
 }
 
-func createRackProperties(configInput *ConfigInput, nodeInfo *NodeInfo, targetDir string) error {
-	// This creates the cassandra-rackdc.properites with a template with only the values we currently support
-	targetFileT := filepath.Join(targetDir, "cassandra-rackdc.properties")
-	fT, err := os.OpenFile(targetFileT, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0770)
-	if err != nil {
-		return err
+// createRackProperties writes cassandra-rackdc.properties. With RackDCFileLocationProvider (5.1 and newer) the
+// prefer_local setting is not read from this file, instead k8ssandraOverrides sets prefer_local_connections.
+func createRackProperties(configInput *ConfigInput, nodeInfo *NodeInfo, out Output, locationProvider bool) error {
+	properties := map[string]string{}
+	for k, v := range configInput.RackDC.AdditionalProperties {
+		if slices.Contains(reservedRackDCProperties, k) {
+			return fmt.Errorf("cassandra-rackdc.properties key %s can not be set in additional-properties", k)
+		}
+		properties[k] = v
 	}
 
-	defer func() {
-		if err := fT.Close(); err != nil {
-			panic(err)
-		}
-	}()
+	if configInput.RackDC.DCSuffix != "" {
+		properties["dc_suffix"] = configInput.RackDC.DCSuffix
+	}
+
+	if configInput.RackDC.PreferLocal != nil && !locationProvider {
+		properties["prefer_local"] = strconv.FormatBool(*configInput.RackDC.PreferLocal)
+	}
 
-	rackTemplate, err := template.New("cassandra-rackdc.properties").Parse("dc={{ .DatacenterName }}\nrack={{ .RackName }}\n")
+	rackTemplate, err := template.New("cassandra-rackdc.properties").Parse(rackDCTemplate)
 	if err != nil {
 		return err
 	}
 
+	type Property struct {
+		Key   string
+		Value string
+	}
+
 	type RackTemplate struct {
 		DatacenterName string
 		RackName       string
+		Properties     []Property
 	}
 
 	rt := RackTemplate{
 		DatacenterName: configInput.DatacenterInfo.Name,
 		RackName:       nodeInfo.Rack,
+		Properties:     make([]Property, 0, len(properties)),
 	}
 
-	return rackTemplate.Execute(fT, rt)
-}
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
 
-func createCassandraEnv(configInput *ConfigInput, sourceDir, targetDir string) error {
-	envPath := filepath.Join(sourceDir, "cassandra-env.sh")
-	f, err := os.ReadFile(envPath)
-	if err != nil {
-		return err
+	for _, k := range keys {
+		rt.Properties = append(rt.Properties, Property{Key: k, Value: properties[k]})
 	}
 
-	targetFileT := filepath.Join(targetDir, "cassandra-env.sh")
-	fT, err := os.OpenFile(targetFileT, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0770)
-	if err != nil {
+	var buf bytes.Buffer
+	if err := rackTemplate.Execute(&buf, rt); err != nil {
 		return err
 	}
 
-	defer func() {
-		if err := fT.Close(); err != nil {
-			panic(err)
-		}
-	}()
+	return out.WriteFile("cassandra-rackdc.properties", buf.Bytes(), 0770)
+}
 
-	if configInput.CassandraEnv.MallocArenaMax > 0 {
-		if _, err := fmt.Fprintf(fT, "export MALLOC_ARENA_MAX=%d\n", configInput.CassandraEnv.MallocArenaMax); err != nil {
-			return err
-		}
+func mergeRackDCOptions(lowerPriority, higherPriority RackDCOptions) RackDCOptions {
+	merged := lowerPriority
+	if higherPriority.PreferLocal != nil {
+		merged.PreferLocal = higherPriority.PreferLocal
 	}
-
-	if configInput.CassandraEnv.HeapDumpDir != "" {
-		if _, err := fmt.Fprintf(fT, "export CASSANDRA_HEAPDUMP_DIR=%s\n", configInput.CassandraEnv.HeapDumpDir); err != nil {
-			return err
-		}
+	if higherPriority.DCSuffix != "" {
+		merged.DCSuffix = higherPriority.DCSuffix
 	}
-
-	if _, err = fT.Write(f); err != nil {
-		return err
+	if len(higherPriority.AdditionalProperties) > 0 {
+		additional := maps.Clone(merged.AdditionalProperties)
+		if additional == nil {
+			additional = make(map[string]string, len(higherPriority.AdditionalProperties))
+		}
+		maps.Copy(additional, higherPriority.AdditionalProperties)
+		merged.AdditionalProperties = additional
 	}
+	return merged
+}
 
-	if _, err := fmt.Fprintf(fT, "\n"); err != nil {
-		return err
-	}
+// usesLocationProvider returns true if Cassandra reads the dc and rack with RackDCFileLocationProvider instead of the snitch
+func usesLocationProvider(cassandraYaml map[string]any) bool {
+	provider, ok := cassandraYaml["initial_location_provider"].(string)
+	return ok && strings.HasSuffix(provider, rackDCLocationProvider)
+}
 
-	for _, opt := range configInput.CassandraEnv.AdditionalOpts {
-		if _, err := fmt.Fprintf(fT, "JVM_OPTS=\"$JVM_OPTS %s\"\n", opt); err != nil {
+// createJVMOptions writes all the jvm*-server.options
+func createJVMOptions(configInput *ConfigInput, nodeInfo *NodeInfo, base fs.FS, out Output, podOverrides *ConfigOverrides) error {
+	var tuning *jvmTuning
+	if configInput.JVMAutoTuning.Enabled {
+		var err error
+		if tuning, err = newJVMTuning(configInput, nodeInfo, podOverrides); err != nil {
 			return err
 		}
 	}
 
-	return nil
-}
-
-// createJVMOptions writes all the jvm*-server.options
-func createJVMOptions(configInput *ConfigInput, sourceDir, targetDir string, podOverrides *ConfigOverrides) error {
-	if err := createServerJVMOptions(configInput.ServerOptions, podOverrides.ServerOptions, "jvm-server.options", sourceDir, targetDir); err != nil {
+	// The heap size is calculated per JVM version, since it depends on the garbage collector
+	if err := createServerJVMOptions(configInput.ServerOptions, podOverrides.ServerOptions, "jvm-server.options", base, out, nil); err != nil {
 		return err
 	}
 
-	if err := createServerJVMOptions(configInput.ServerOptions11, podOverrides.ServerOptions11, "jvm11-server.options", sourceDir, targetDir); err != nil {
+	if err := createServerJVMOptions(configInput.ServerOptions11, podOverrides.ServerOptions11, "jvm11-server.options", base, out, tuning); err != nil {
 		return err
 	}
 
-	if err := createServerJVMOptions(configInput.ServerOptions17, podOverrides.ServerOptions17, "jvm17-server.options", sourceDir, targetDir); err != nil {
+	if err := createServerJVMOptions(configInput.ServerOptions17, podOverrides.ServerOptions17, "jvm17-server.options", base, out, tuning); err != nil {
 		return err
 	}
 
-	if err := createServerJVMOptions(configInput.ServerOptions21, podOverrides.ServerOptions21, "jvm21-server.options", sourceDir, targetDir); err != nil {
+	if err := createServerJVMOptions(configInput.ServerOptions21, podOverrides.ServerOptions21, "jvm21-server.options", base, out, tuning); err != nil {
 		return err
 	}
 
@@ -330,20 +436,30 @@ func optionsFilenameToMap(filename string) map[string]metadata.Metadata {
 		return gentypes.JvmServerOptionsPrefix
 	case "jvm11-server.options":
 		return gentypes.Jvm11ServerOptionsPrefix
+	case "jvm17-server.options":
+		return Jvm17ServerOptionsPrefix
+	case "jvm21-server.options":
+		return Jvm21ServerOptionsPrefix
 	default:
-		// JVM17 and newer do not have alias tables in the EDNs
 		return make(map[string]metadata.Metadata, 0)
 	}
 }
 
-func createServerJVMOptions(baseOptions, overrideOptions map[string]interface{}, filename, sourceDir, targetDir string) error {
+func createServerJVMOptions(baseOptions, overrideOptions map[string]interface{}, filename string, base fs.FS, out Output, tuning *jvmTuning) error {
 	// Read the current jvm-server-options as []string, do linear search to replace the values with the inputs we get
-	optionsPath := filepath.Join(sourceDir, filename)
-	currentOptions, err := readJvmServerOptions(optionsPath)
+	currentOptions, err := readJvmServerOptions(base, filename)
 	if err != nil {
 		return err
 	}
 
+	if err := validateJVMOptions(baseOptions, filename); err != nil {
+		return err
+	}
+
+	if err := validateJVMOptions(overrideOptions, filename); err != nil {
+		return err
+	}
+
 	options := make(map[string]interface{})
 	for k, v := range baseOptions {
 		options[k] = v
@@ -359,7 +475,7 @@ func createServerJVMOptions(baseOptions, overrideOptions map[string]interface{},
 				return fmt.Errorf("additional-jvm-opts must be a list of strings")
 			}
 
-			options["additional-jvm-opts"] = append(addOptsSlice, overrideAddOptsSlice...)
+			options["additional-jvm-opts"] = append(slices.Clone(addOptsSlice), overrideAddOptsSlice...)
 		} else {
 			// The original options had no additional-jvm-opts, we use our value as is
 			options["additional-jvm-opts"] = overrideAddOpts
@@ -401,7 +517,9 @@ func createServerJVMOptions(baseOptions, overrideOptions map[string]interface{},
 		}
 
 		s := optionsFilenameToMap(filename)
-		for k, v := range options {
+		// Sorted to keep the output (and its checksum) stable
+		for _, k := range slices.Sorted(maps.Keys(options)) {
+			v := options[k]
 			if k == "additional-jvm-opts" || k == "garbage_collector" {
 				continue
 			}
@@ -411,7 +529,15 @@ func createServerJVMOptions(baseOptions, overrideOptions map[string]interface{},
 					// We need another process here..
 					continue
 				}
-				targetOptions = append(targetOptions, outputVal.Output(fmt.Sprintf("%v", v)))
+				if !slices.Contains(strictJVMOptionFiles, filename) {
+					// The older files keep their existing output, which never has the boolean flags
+					targetOptions = append(targetOptions, outputVal.Output(fmt.Sprintf("%v", v)))
+				} else if output := jvmAliasOutput(outputVal, fmt.Sprintf("%v", v)); output != "" {
+					targetOptions = append(targetOptions, output)
+				} else if outputVal.ValueType == metadata.StaticConstant {
+					// Disabled flag also removes it from the base file
+					currentOptions = slices.DeleteFunc(currentOptions, func(s string) bool { return s == outputVal.Key })
+				}
 			}
 		}
 	}
@@ -425,8 +551,14 @@ func createServerJVMOptions(baseOptions, overrideOptions map[string]interface{},
 
 			if matches := re.FindStringSubmatch(filename); len(matches) > 1 {
 				jvmVersion, _ = strconv.Atoi(matches[1])
+
+				// jvm-server.options is shared by all the JVM versions, so only the versioned files are validated
+				if err := validateGarbageCollector(fmt.Sprintf("%v", gcOpts), jvmVersion); err != nil {
+					return fmt.Errorf("invalid garbage_collector in %s: %w", filename, err)
+				}
 			}
 
+			gcName := fmt.Sprintf("%v", gcOpts)
 			currentOptions = slices.DeleteFunc(currentOptions, func(s string) bool {
 				allOpts := getAllGCOptions(jvmVersion)
 				for _, opt := range allOpts {
@@ -434,12 +566,25 @@ func createServerJVMOptions(baseOptions, overrideOptions map[string]interface{},
 						return true
 					}
 				}
-				return false
+				return conflictingGCOption(s, gcName)
 			})
 
 			// Add GC options for this JVM version
-			currentOptions = append(currentOptions, getGCOptions(fmt.Sprintf("%v", gcOpts), jvmVersion)...)
+			currentOptions = append(currentOptions, getGCOptions(gcName, jvmVersion)...)
+		}
+	}
+
+	// Tuned options replace the base file values, but not the values set by the user. Files which are not in the
+	// base config are not used by the Cassandra version, so we do not create them.
+	if tuning != nil && len(currentOptions) > 0 {
+		jvmVersion := 8
+		if matches := regexp.MustCompile(`jvm(\d+)-server\.options`).FindStringSubmatch(filename); len(matches) > 1 {
+			jvmVersion, _ = strconv.Atoi(matches[1])
 		}
+
+		gcName := detectGarbageCollector(append(toAnySlice(targetOptions), toAnySlice(currentOptions)...))
+		tuned := tuning.options(jvmVersion, gcName, targetOptions)
+		currentOptions = append(filterJVMOptions(currentOptions, tuned), tuned...)
 	}
 curOptions:
 	for _, v := range currentOptions {
@@ -474,26 +619,12 @@ curOptions:
 		return nil
 	}
 
-	targetFileT := filepath.Join(targetDir, filename)
-	fT, err := os.OpenFile(targetFileT, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0770)
-	if err != nil {
-		return err
-	}
-
-	defer func() {
-		if err := fT.Close(); err != nil {
-			panic(err)
-		}
-	}()
-
+	var buf bytes.Buffer
 	for _, v := range targetOptions {
-		_, err := fmt.Fprintf(fT, "%s\n", v)
-		if err != nil {
-			return err
-		}
+		fmt.Fprintf(&buf, "%s\n", v)
 	}
 
-	return nil
+	return out.WriteFile(filename, buf.Bytes(), 0770)
 }
 
 const (
@@ -526,6 +657,14 @@ func detectGarbageCollector(opts []any) string {
 	return ""
 }
 
+func toAnySlice(opts []string) []any {
+	anyOpts := make([]any, 0, len(opts))
+	for _, opt := range opts {
+		anyOpts = append(anyOpts, opt)
+	}
+	return anyOpts
+}
+
 // filterGCOptions removes garbage collector related options from the given slice
 func filterGCOptions(opts []any) []any {
 	return slices.DeleteFunc(opts, func(s any) bool {
@@ -563,11 +702,15 @@ func getGCOptions(gcName string, jvmMajor int) []string {
 	case "Shenandoah":
 		return []string{"-XX:+UseShenandoahGC"}
 	case "ZGC":
-		zgcOpts := make([]string, 0, 1)
+		zgcOpts := make([]string, 0, 2)
 		if jvmMajor < 17 {
 			zgcOpts = append(zgcOpts, "-XX:+UnlockExperimentalVMOptions")
 		}
 		zgcOpts = append(zgcOpts, "-XX:+UseZGC")
+		// Generational mode is opt-in in JDK21 and the only mode from JDK23 onwards
+		if jvmMajor >= 21 && jvmMajor < 23 {
+			zgcOpts = append(zgcOpts, "-XX:+ZGenerational")
+		}
 		return zgcOpts
 	default:
 		// User needs to define all the settings
@@ -575,6 +718,59 @@ func getGCOptions(gcName string, jvmMajor int) []string {
 	}
 }
 
+// validateGarbageCollector returns an error if the collector is not available in the given JVM major version.
+// Collectors we do not know about are left for the user to configure.
+func validateGarbageCollector(gcName string, jvmMajor int) error {
+	switch gcName {
+	case CMS:
+		if jvmMajor >= 14 {
+			return fmt.Errorf("garbage collector CMS is not available in JDK%d, it was removed in JDK14", jvmMajor)
+		}
+	case ZGC, Shenandoah:
+		if jvmMajor < 11 {
+			return fmt.Errorf("garbage collector %s is not available in JDK%d, it requires JDK11 or newer", gcName, jvmMajor)
+		}
+	}
+	return nil
+}
+
+// zgcOptions are the names of the ZGC specific options, the names which start with one of these such as
+// ZCollectionIntervalMinor and ZUncommitDelay included
+var zgcOptions = []string{
+	"UseZGC",
+	"ZGenerational",
+	"ZCollectionInterval",
+	"ZUncommit",
+	"ZAllocationSpikeTolerance",
+	"ZFragmentationLimit",
+	"ZProactive",
+	"ZYoungGCThreads",
+	"ZOldGCThreads",
+	"ZYoungCompactionLimit",
+	"ZStatisticsInterval",
+	"ZMarkStackSpaceLimit",
+}
+
+// conflictingGCOption returns true if the option is specific to another collector than the selected one
+func conflictingGCOption(option, gcName string) bool {
+	name := strings.TrimPrefix(jvmOptionKey(option), "-XX:")
+	name = strings.TrimLeft(name, "+-")
+
+	optionGC := ""
+	switch {
+	case strings.Contains(name, "G1") || name == "InitiatingHeapOccupancyPercent":
+		optionGC = G1GC
+	case strings.Contains(name, "CMS") || name == "UseConcMarkSweepGC":
+		optionGC = CMS
+	case strings.Contains(name, "Shenandoah"):
+		optionGC = Shenandoah
+	case slices.ContainsFunc(zgcOptions, func(prefix string) bool { return strings.HasPrefix(name, prefix) }):
+		optionGC = ZGC
+	}
+
+	return optionGC != "" && optionGC != gcName
+}
+
 func prefixMatcher(value string) (bool, string) {
 	// r := regexp.MustCompile(gentypes.JvmServerOptionsPrefixExp)
 	parts := prefixRegexp.FindStringSubmatch(value)
@@ -584,18 +780,14 @@ func prefixMatcher(value string) (bool, string) {
 	return false, ""
 }
 
-func readJvmServerOptions(path string) ([]string, error) {
+func readJvmServerOptions(base fs.FS, name string) ([]string, error) {
 	options := make([]string, 0)
 
-	if _, err := os.Stat(path); err != nil {
-		if os.IsNotExist(err) {
-			return make([]string, 0), nil
-		}
-		return nil, err
-	}
-
-	f, err := os.Open(path)
+	f, err := base.Open(name)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return options, nil
+		}
 		return nil, err
 	}
 
@@ -621,52 +813,59 @@ func readJvmServerOptions(path string) ([]string, error) {
 	return options, nil
 }
 
-func createCassandraYaml(configInput *ConfigInput, nodeInfo *NodeInfo, sourceDir, targetDir string, finalOverrides map[string]interface{}) error {
-	targetConfigFileName := oldCassandraConfigName
-	// Verify if we should use cassandra_latest.yaml (5.0 and newer) or cassandra.yaml (4.1 and older)
-	if _, err := os.Stat(filepath.Join(sourceDir, latestCassandraConfigName)); err == nil {
-		targetConfigFileName = latestCassandraConfigName
+// renderCassandraYaml merges the base cassandra.yaml with the overrides without writing it
+func renderCassandraYaml(configInput *ConfigInput, nodeInfo *NodeInfo, base fs.FS, finalOverrides map[string]interface{}) (map[string]any, error) {
+	flavor, err := resolveServerFlavor(configInput, base)
+	if err != nil {
+		return nil, err
 	}
 
-	yamlPath := filepath.Join(sourceDir, targetConfigFileName)
-	yamlFile, err := os.ReadFile(yamlPath)
+	yamlFile, err := fs.ReadFile(base, flavor.cassandraYamlSource(base))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Unmarshal, Marshal to remove all comments (and some fields if necessary)
 	cassandraYaml := make(map[string]any)
 
 	if err := yaml.Unmarshal(yamlFile, cassandraYaml); err != nil {
-		return err
+		return nil, err
+	}
+
+	// The server flavor's defaults are below the ConfigInput's changes
+	if flavor.cassandraYamlDefaults != nil {
+		if cassandraYaml, err = mergeYaml(cassandraYaml, flavor.cassandraYamlDefaults()); err != nil {
+			return nil, err
+		}
 	}
 
 	// Merge with the ConfigInput's cassandraYaml changes - configInput.CassYaml changes have to take priority
 	merged, err := mergeYaml(cassandraYaml, configInput.CassYaml)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Take the NodeInfo information and add those modifications to the merge output (a priority)
 	// Take the mandatory changes we require and merge them (a priority again)
 	merged = k8ssandraOverrides(merged, configInput, nodeInfo)
+	if flavor.overrides != nil {
+		flavor.overrides(merged)
+	}
 
 	// Apply per-pod final overrides last (highest priority) - these could break the configuration
 	if len(finalOverrides) > 0 {
 		merged2, err := mergeYaml(merged, finalOverrides)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		merged = merged2
 	}
 
-	// Write to the targetDir the new modified file
-	targetFile := filepath.Join(targetDir, "cassandra.yaml")
-	return writeYaml(merged, targetFile)
+	return merged, nil
 }
 
-func createSidecarYaml(configInput *ConfigInput, nodeInfo *NodeInfo, sourceDir, targetDir string) error {
-	yamlFile, err := os.ReadFile(filepath.Join(sourceDir, sidecarConfigName))
+func createSidecarYaml(configInput *ConfigInput, nodeInfo *NodeInfo, base fs.FS, out Output) error {
+	yamlFile, err := fs.ReadFile(base, sidecarConfigName)
 	if err != nil {
 		return err
 	}
@@ -685,7 +884,7 @@ func createSidecarYaml(configInput *ConfigInput, nodeInfo *NodeInfo, sourceDir,
 		return err
 	}
 
-	return writeYaml(merged, filepath.Join(targetDir, sidecarConfigName))
+	return writeYaml(merged, out, sidecarConfigName)
 }
 
 func mergeYaml(lowerPriority, higherPriority map[string]any) (map[string]any, error) {
@@ -694,7 +893,14 @@ func mergeYaml(lowerPriority, higherPriority map[string]any) (map[string]any, er
 		return nil, err
 	}
 
-	// goalesce treats false as a zero value, so explicitly preserve boolean overrides.
+	preserveZeroOverrides(merged, higherPriority)
+
+	return merged, nil
+}
+
+// preserveZeroOverrides sets the false and nil values of higherPriority in merged, also in the nested maps. goalesce
+// treats them as zero values and keeps the lower priority value instead.
+func preserveZeroOverrides(merged, higherPriority map[string]any) {
 	for key, value := range higherPriority {
 		if reflectValue := reflect.ValueOf(value); reflectValue.Kind() == reflect.Bool {
 			merged[key] = reflectValue.Bool()
@@ -702,9 +908,12 @@ func mergeYaml(lowerPriority, higherPriority map[string]any) (map[string]any, er
 		if value == nil {
 			merged[key] = nil
 		}
+		if nested, ok := value.(map[string]any); ok {
+			if mergedNested, ok := merged[key].(map[string]any); ok {
+				preserveZeroOverrides(mergedNested, nested)
+			}
+		}
 	}
-
-	return merged, nil
 }
 
 func k8ssandraOverrides(merged map[string]any, configInput *ConfigInput, nodeInfo *NodeInfo) map[string]any {
@@ -724,19 +933,27 @@ func k8ssandraOverrides(merged map[string]any, configInput *ConfigInput, nodeInf
 	// Only set rpc_address if it's empty or localhost
 	if merged["rpc_address"] == "" || merged["rpc_address"] == "localhost" {
 		ipv6 := merged["rpc_interface_prefer_ipv6"]
-		if ipv6Bool, ok := ipv6.(bool); ok && ipv6Bool {
+		if ipv6Bool, ok := ipv6.(bool); ok && ipv6Bool && (nodeInfo.RPCIP == nil || nodeInfo.RPCIP.IsUnspecified()) {
 			// force rpcaddress to be ipv6 local if rpc_interface_prefer_ipv6 is true
 			merged["rpc_address"] = ipv6Local
 		} else {
 			merged["rpc_address"] = nodeInfo.RPCIP.String()
 		}
 	}
-	delete(merged, "broadcast_address") // Sets it to the same as listen_address
-	merged["broadcast_rpc_address"] = nodeInfo.BroadcastIP
+	if broadcastAddress := nodeInfo.internodeBroadcastAddress(); broadcastAddress != "" {
+		merged["broadcast_address"] = broadcastAddress
+	} else {
+		delete(merged, "broadcast_address") // Sets it to the same as listen_address
+	}
+	merged["broadcast_rpc_address"] = nodeInfo.broadcastRPCAddress()
 
 	// 5.1 and newer have deprecated endpoint_snitch
 	if nodeProximity, found := merged["node_proximity"]; found && nodeProximity.(string) == "NetworkTopologyProximity" {
-		merged["initial_location_provider"] = "RackDCFileLocationProvider"
+		merged["initial_location_provider"] = rackDCLocationProvider
+		// prefer_local from cassandra-rackdc.properties is only read by the snitches
+		if configInput.RackDC.PreferLocal != nil {
+			merged["prefer_local_connections"] = *configInput.RackDC.PreferLocal
+		}
 	} else if !found {
 		merged["endpoint_snitch"] = "GossipingPropertyFileSnitch"
 	}
@@ -779,28 +996,22 @@ func sidecarK8ssandraOverrides(merged map[string]any, nodeInfo *NodeInfo) (err e
 	return err
 }
 
-func writeYaml(doc map[string]any, targetFile string) error {
+func writeYaml(doc map[string]any, out Output, name string) error {
 	b, err := yaml.Marshal(doc)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(targetFile, b, 0660)
+	return out.WriteFile(name, b, 0660)
 }
 
-func copyFiles(sourceDir, targetDir string) error {
-	// Copy the files we're not modifying
-	files := []string{"jvm-clients.options", "jvm11-clients.options", "jvm17-clients.options", "logback.xml", "logback-tools.xml", "jvm-dependent.sh", "jvm.options", "cassandra-jaas.config"}
-
-	for _, f := range files {
-		sourceFile := filepath.Join(sourceDir, f)
-		targetFile := filepath.Join(targetDir, f)
+// copiedFiles are copied from the base config without modifications
+var copiedFiles = []string{"jvm-clients.options", "jvm11-clients.options", "jvm17-clients.options", "logback-tools.xml", "jvm-dependent.sh", "jvm.options"}
 
-		if _, err := os.Stat(sourceFile); err == nil {
-			if err := copyFile(sourceFile, targetFile); err != nil {
-				return err
-			}
-		} else if !os.IsNotExist(err) {
+func copyFiles(base fs.FS, out Output) error {
+	// Copy the files we're not modifying
+	for _, f := range copiedFiles {
+		if err := copyBaseFile(base, out, f); err != nil && !errors.Is(err, fs.ErrNotExist) {
 			return err
 		}
 	}
@@ -808,27 +1019,11 @@ func copyFiles(sourceDir, targetDir string) error {
 	return nil
 }
 
-func copyFile(source, target string) error {
-	src, err := os.Open(source)
-	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("failed to open %s", source))
-	}
-	defer func() {
-		if err := src.Close(); err != nil {
-			panic(err)
-		}
-	}()
-
-	dst, err := os.Create(target)
+// copyBaseFile writes the base config file to the output as is
+func copyBaseFile(base fs.FS, out Output, name string) error {
+	b, err := fs.ReadFile(base, name)
 	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("failed to open %s", target))
+		return err
 	}
-	defer func() {
-		if err := dst.Close(); err != nil {
-			panic(err)
-		}
-	}()
-
-	_, err = io.Copy(dst, src)
-	return err
+	return out.WriteFile(name, b, 0660)
 }
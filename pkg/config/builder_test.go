@@ -190,6 +190,43 @@ var booleanOverride = `
     }
 }`
 
+var rackDCConfig = `
+{
+	"cassandra-rackdc-properties": {
+		"prefer-local": true,
+		"dc-suffix": "_east",
+		"additional-properties": {
+			"ec2_naming_scheme": "legacy"
+		}
+	},
+	"cluster-info": {
+		"name": "test",
+		"seeds": "test-seed-service"
+	},
+	"datacenter-info": {
+		"name": "datacenter1"
+	}
+}
+`
+
+var rackDCLocationProviderConfig = `
+{
+	"cassandra-yaml": {
+		"node_proximity": "NetworkTopologyProximity"
+	},
+	"cassandra-rackdc-properties": {
+		"prefer-local": true
+	},
+	"cluster-info": {
+		"name": "test",
+		"seeds": "test-seed-service"
+	},
+	"datacenter-info": {
+		"name": "datacenter1"
+	}
+}
+`
+
 var removeAllocateTokens = `
 {
     "cassandra-yaml": {
@@ -507,7 +544,6 @@ func TestSidecarK8ssandraOverrides(t *testing.T) {
 func TestCassandraYamlWriting(t *testing.T) {
 	require := require.New(t)
 	cassYamlDir := filepath.Join(envtest.RootDir(), "testfiles")
-	tempDir := t.TempDir()
 
 	// Create mandatory configs..
 	t.Setenv("CONFIG_FILE_DATA", existingConfig)
@@ -520,21 +556,14 @@ func TestCassandraYamlWriting(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(nodeInfo)
 
-	require.NoError(createCassandraYaml(configInput, nodeInfo, cassYamlDir, tempDir, nil))
+	docs, _, err := Render(configInput, nodeInfo, os.DirFS(cassYamlDir), nil)
+	require.NoError(err)
+	cassandraYaml := renderedYaml(t, docs, "cassandra.yaml")
 
 	yamlOrigPath := filepath.Join(cassYamlDir, "cassandra_latest.yaml")
-	yamlPath := filepath.Join(tempDir, "cassandra.yaml")
-
 	yamlOrigFile, err := os.ReadFile(yamlOrigPath)
 	require.NoError(err)
 
-	yamlFile, err := os.ReadFile(yamlPath)
-	require.NoError(err)
-
-	// Unmarshal, Marshal to remove all comments (and some fields if necessary)
-	cassandraYaml := make(map[string]any)
-	require.NoError(yaml.Unmarshal(yamlFile, cassandraYaml))
-
 	cassandraOrigYaml := make(map[string]any)
 	require.NoError(yaml.Unmarshal(yamlOrigFile, cassandraOrigYaml))
 
@@ -569,18 +598,12 @@ func TestCassandraBaseConfigFilePick(t *testing.T) {
 	require := require.New(t)
 	testFilesPath := filepath.Join(envtest.RootDir(), "testfiles")
 
-	// Create input directories and copy correct files to them
+	// Create input directories and copy correct files to them, only the new one has the cassandra_latest.yaml
 	inputDirOld := t.TempDir()
 	inputDirNew := t.TempDir()
-
-	// Copy the correct files to the directories
-	require.NoError(copyFile(filepath.Join(testFilesPath, "cassandra.yaml"), filepath.Join(inputDirOld, "cassandra.yaml")))
-	require.NoError(copyFile(filepath.Join(testFilesPath, "cassandra.yaml"), filepath.Join(inputDirNew, "cassandra.yaml")))
-	require.NoError(copyFile(filepath.Join(testFilesPath, "cassandra_latest.yaml"), filepath.Join(inputDirNew, "cassandra_latest.yaml")))
-
-	// Then process both..
-	outputDirOld := t.TempDir()
-	outputDirNew := t.TempDir()
+	copyAllFiles(t, testFilesPath, inputDirOld)
+	copyAllFiles(t, testFilesPath, inputDirNew)
+	require.NoError(os.Remove(filepath.Join(inputDirOld, "cassandra_latest.yaml")))
 
 	// Create mandatory configs..
 	t.Setenv("CONFIG_FILE_DATA", existingConfig)
@@ -593,35 +616,15 @@ func TestCassandraBaseConfigFilePick(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(nodeInfo)
 
-	require.NoError(createCassandraYaml(configInput, nodeInfo, inputDirOld, outputDirOld, nil))
-	require.NoError(createCassandraYaml(configInput, nodeInfo, inputDirNew, outputDirNew, nil))
-
-	// Verify only cassandra.yaml is created to destination
-	entriesOld, err := os.ReadDir(outputDirOld)
+	// Then process both..
+	docsOld, _, err := Render(configInput, nodeInfo, os.DirFS(inputDirOld), nil)
 	require.NoError(err)
-	require.Len(entriesOld, 1)
-	require.Equal("cassandra.yaml", entriesOld[0].Name())
-
-	entriesNew, err := os.ReadDir(outputDirNew)
+	docsNew, _, err := Render(configInput, nodeInfo, os.DirFS(inputDirNew), nil)
 	require.NoError(err)
-	require.Len(entriesNew, 1)
-	require.Equal("cassandra.yaml", entriesNew[0].Name())
 
 	// Verify content differences (that we actually used the _latest when it's present)
-	yamlOldOutput := filepath.Join(outputDirOld, "cassandra.yaml")
-	yamlNewOutput := filepath.Join(outputDirNew, "cassandra.yaml")
-
-	yamlOldFile, err := os.ReadFile(yamlOldOutput)
-	require.NoError(err)
-
-	yamlNewFile, err := os.ReadFile(yamlNewOutput)
-	require.NoError(err)
-
-	cassandraYamlOld := make(map[string]any)
-	require.NoError(yaml.Unmarshal(yamlOldFile, cassandraYamlOld))
-
-	cassandraYamlNew := make(map[string]any)
-	require.NoError(yaml.Unmarshal(yamlNewFile, cassandraYamlNew))
+	cassandraYamlOld := renderedYaml(t, docsOld, "cassandra.yaml")
+	cassandraYamlNew := renderedYaml(t, docsNew, "cassandra.yaml")
 
 	require.Equal("heap_buffers", cassandraYamlOld["memtable_allocation_type"])
 	require.Equal("offheap_objects", cassandraYamlNew["memtable_allocation_type"])
@@ -630,7 +633,6 @@ func TestCassandraBaseConfigFilePick(t *testing.T) {
 func TestCassandraYamlSubPath(t *testing.T) {
 	require := require.New(t)
 	cassYamlDir := filepath.Join(envtest.RootDir(), "testfiles")
-	tempDir := t.TempDir()
 
 	// Create mandatory configs..
 	t.Setenv("CONFIG_FILE_DATA", cass50Config)
@@ -643,16 +645,9 @@ func TestCassandraYamlSubPath(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(nodeInfo)
 
-	require.NoError(createCassandraYaml(configInput, nodeInfo, cassYamlDir, tempDir, nil))
-
-	yamlPath := filepath.Join(tempDir, "cassandra.yaml")
-
-	yamlFile, err := os.ReadFile(yamlPath)
+	docs, _, err := Render(configInput, nodeInfo, os.DirFS(cassYamlDir), nil)
 	require.NoError(err)
-
-	// Unmarshal, Marshal to remove all comments (and some fields if necessary)
-	cassandraYaml := make(map[string]any)
-	require.NoError(yaml.Unmarshal(yamlFile, cassandraYaml))
+	cassandraYaml := renderedYaml(t, docs, "cassandra.yaml")
 
 	authenticator := cassandraYaml["authenticator"]
 	authenticatorStruct := authenticator.(map[string]any)
@@ -662,7 +657,6 @@ func TestCassandraYamlSubPath(t *testing.T) {
 func TestBooleanOverride(t *testing.T) {
 	require := require.New(t)
 	cassYamlDir := filepath.Join(envtest.RootDir(), "testfiles")
-	tempDir := t.TempDir()
 
 	// Create mandatory configs..
 	t.Setenv("CONFIG_FILE_DATA", booleanOverride)
@@ -675,16 +669,9 @@ func TestBooleanOverride(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(nodeInfo)
 
-	require.NoError(createCassandraYaml(configInput, nodeInfo, cassYamlDir, tempDir, nil))
-
-	yamlPath := filepath.Join(tempDir, "cassandra.yaml")
-
-	yamlFile, err := os.ReadFile(yamlPath)
+	docs, _, err := Render(configInput, nodeInfo, os.DirFS(cassYamlDir), nil)
 	require.NoError(err)
-
-	// Unmarshal, Marshal to remove all comments (and some fields if necessary)
-	cassandraYaml := make(map[string]any)
-	require.NoError(yaml.Unmarshal(yamlFile, cassandraYaml))
+	cassandraYaml := renderedYaml(t, docs, "cassandra.yaml")
 
 	authenticator := cassandraYaml["authenticator"]
 	require.Equal("com.datastax.bdp.cassandra.auth.DseAuthenticator", authenticator)
@@ -696,7 +683,6 @@ func TestBooleanOverride(t *testing.T) {
 func TestNilOverride(t *testing.T) {
 	require := require.New(t)
 	cassYamlDir := filepath.Join(envtest.RootDir(), "testfiles")
-	tempDir := t.TempDir()
 
 	// Create mandatory configs..
 	t.Setenv("CONFIG_FILE_DATA", removeAllocateTokens)
@@ -709,16 +695,9 @@ func TestNilOverride(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(nodeInfo)
 
-	require.NoError(createCassandraYaml(configInput, nodeInfo, cassYamlDir, tempDir, nil))
-
-	yamlPath := filepath.Join(tempDir, "cassandra.yaml")
-
-	yamlFile, err := os.ReadFile(yamlPath)
+	docs, _, err := Render(configInput, nodeInfo, os.DirFS(cassYamlDir), nil)
 	require.NoError(err)
-
-	// Unmarshal, Marshal to remove all comments (and some fields if necessary)
-	cassandraYaml := make(map[string]any)
-	require.NoError(yaml.Unmarshal(yamlFile, cassandraYaml))
+	cassandraYaml := renderedYaml(t, docs, "cassandra.yaml")
 
 	require.Contains(cassandraYaml, "allocate_tokens_for_local_replication_factor")
 	require.Nil(cassandraYaml["allocate_tokens_for_local_replication_factor"])
@@ -739,13 +718,77 @@ func TestRackProperties(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(nodeInfo)
 
-	require.NoError(createRackProperties(configInput, nodeInfo, tempDir))
+	require.NoError(createRackProperties(configInput, nodeInfo, DirOutput(tempDir), false))
 
 	lines, err := readFileToLines(tempDir, "cassandra-rackdc.properties")
 	require.NoError(err)
 	require.Equal(2, len(lines))
 	require.Contains(lines, "dc=datacenter1")
 	require.Contains(lines, "rack=r1")
+
+	// Rewriting must not duplicate the lines
+	require.NoError(createRackProperties(configInput, nodeInfo, DirOutput(tempDir), false))
+	lines, err = readFileToLines(tempDir, "cassandra-rackdc.properties")
+	require.NoError(err)
+	require.Equal(2, len(lines))
+}
+
+func TestRackPropertiesOptions(t *testing.T) {
+	require := require.New(t)
+	tempDir := t.TempDir()
+
+	t.Setenv("CONFIG_FILE_DATA", rackDCConfig)
+	configInput, err := parseConfigInput()
+	require.NoError(err)
+	nodeInfo := &NodeInfo{Rack: "r1"}
+
+	require.NoError(createRackProperties(configInput, nodeInfo, DirOutput(tempDir), false))
+
+	lines, err := readFileToLines(tempDir, "cassandra-rackdc.properties")
+	require.NoError(err)
+	require.Equal([]string{"dc=datacenter1", "rack=r1", "dc_suffix=_east", "ec2_naming_scheme=legacy", "prefer_local=true"}, lines)
+
+	// Location provider does not read prefer_local
+	require.NoError(createRackProperties(configInput, nodeInfo, DirOutput(tempDir), true))
+
+	lines, err = readFileToLines(tempDir, "cassandra-rackdc.properties")
+	require.NoError(err)
+	require.Equal([]string{"dc=datacenter1", "rack=r1", "dc_suffix=_east", "ec2_naming_scheme=legacy"}, lines)
+
+	// Pod overrides take priority
+	preferLocal := false
+	merged := mergeRackDCOptions(configInput.RackDC, RackDCOptions{PreferLocal: &preferLocal, AdditionalProperties: map[string]string{"ec2_naming_scheme": "standard"}})
+	require.False(*merged.PreferLocal)
+	require.Equal("_east", merged.DCSuffix)
+	require.Equal("standard", merged.AdditionalProperties["ec2_naming_scheme"])
+	require.Equal("legacy", configInput.RackDC.AdditionalProperties["ec2_naming_scheme"])
+
+	configInput.RackDC.AdditionalProperties["rack"] = "r2"
+	require.Error(createRackProperties(configInput, nodeInfo, DirOutput(tempDir), false))
+}
+
+func TestRackPropertiesLocationProvider(t *testing.T) {
+	require := require.New(t)
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	t.Setenv("CONFIG_FILE_DATA", rackDCLocationProviderConfig)
+	t.Setenv("POD_IP", "172.27.0.1")
+	t.Setenv("RACK_NAME", "r1")
+
+	require.NoError(NewBuilder(inputDir, tempDir).Build(t.Context()))
+
+	yamlFile, err := os.ReadFile(filepath.Join(tempDir, "cassandra.yaml"))
+	require.NoError(err)
+
+	cassandraYaml := make(map[string]any)
+	require.NoError(yaml.Unmarshal(yamlFile, cassandraYaml))
+	require.Equal(rackDCLocationProvider, cassandraYaml["initial_location_provider"])
+	require.Equal(true, cassandraYaml["prefer_local_connections"])
+
+	lines, err := readFileToLines(tempDir, "cassandra-rackdc.properties")
+	require.NoError(err)
+	require.Equal([]string{"dc=datacenter1", "rack=r1"}, lines)
 }
 
 func TestServerOptionsOutput(t *testing.T) {
@@ -759,12 +802,9 @@ func TestServerOptionsOutput(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(configInput)
 
-	require.NoError(createJVMOptions(configInput, optionsDir, tempDir, &ConfigOverrides{}))
+	require.NoError(createJVMOptions(configInput, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(tempDir), &ConfigOverrides{}))
 
-	inputFile := filepath.Join(tempDir, "jvm-server.options")
-	inputFile11 := filepath.Join(tempDir, "jvm11-server.options")
-
-	s, err := readJvmServerOptions(inputFile)
+	s, err := readJvmServerOptions(os.DirFS(tempDir), "jvm-server.options")
 	require.NoError(err)
 
 	require.Contains(s, "-Xss384k")
@@ -775,7 +815,7 @@ func TestServerOptionsOutput(t *testing.T) {
 	require.Contains(s, "-Dcassandra.system_distributed_replication=test-dc:1")
 	require.Contains(s, "-Dcom.sun.management.jmxremote.authenticate=true")
 
-	s11, err := readJvmServerOptions(inputFile11)
+	s11, err := readJvmServerOptions(os.DirFS(tempDir), "jvm11-server.options")
 
 	require.NoError(err)
 
@@ -796,11 +836,9 @@ func TestServerOptionsOutput(t *testing.T) {
 	// Test empty also and check we get the default G1 settings
 	ci := &ConfigInput{}
 	tempDir2 := t.TempDir()
-	require.NoError(createJVMOptions(ci, optionsDir, tempDir2, &ConfigOverrides{}))
-
-	inputFile11 = filepath.Join(tempDir2, "jvm11-server.options")
+	require.NoError(createJVMOptions(ci, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(tempDir2), &ConfigOverrides{}))
 
-	s11, err = readJvmServerOptions(inputFile11)
+	s11, err = readJvmServerOptions(os.DirFS(tempDir2), "jvm11-server.options")
 	require.NoError(err)
 
 	for _, v := range defaultG1Settings {
@@ -817,11 +855,9 @@ func TestServerOptionsOutput(t *testing.T) {
 	}
 
 	tempDir3 := t.TempDir()
-	require.NoError(createJVMOptions(ci, optionsDir, tempDir3, &ConfigOverrides{}))
-
-	inputFile11 = filepath.Join(tempDir3, "jvm11-server.options")
+	require.NoError(createJVMOptions(ci, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(tempDir3), &ConfigOverrides{}))
 
-	s11, err = readJvmServerOptions(inputFile11)
+	s11, err = readJvmServerOptions(os.DirFS(tempDir3), "jvm11-server.options")
 	require.NoError(err)
 
 	for _, v := range defaultCMSSettings {
@@ -842,6 +878,95 @@ func TestGCOptions(t *testing.T) {
 
 	assert.Equal([]string{"-XX:+UnlockExperimentalVMOptions", "-XX:+UseZGC"}, getGCOptions("ZGC", 11))
 	assert.Equal([]string{"-XX:+UseZGC"}, getGCOptions("ZGC", 17))
+	assert.Equal([]string{"-XX:+UseZGC", "-XX:+ZGenerational"}, getGCOptions("ZGC", 21))
+	assert.Equal([]string{"-XX:+UseZGC"}, getGCOptions("ZGC", 23))
+
+	assert.NoError(validateGarbageCollector(CMS, 11))
+	assert.Error(validateGarbageCollector(CMS, 17))
+	assert.Error(validateGarbageCollector(CMS, 21))
+	assert.NoError(validateGarbageCollector(ZGC, 11))
+	assert.NoError(validateGarbageCollector(ZGC, 21))
+	assert.Error(validateGarbageCollector(ZGC, 8))
+	assert.NoError(validateGarbageCollector(Shenandoah, 17))
+	assert.Error(validateGarbageCollector(Shenandoah, 8))
+	assert.NoError(validateGarbageCollector("ParallelGC", 17))
+
+	assert.True(conflictingGCOption("-XX:G1HeapRegionSize=16m", ZGC))
+	assert.True(conflictingGCOption("-XX:InitiatingHeapOccupancyPercent=70", Shenandoah))
+	assert.True(conflictingGCOption("-XX:+ZGenerational", G1GC))
+	assert.True(conflictingGCOption("-XX:ShenandoahGCHeuristics=compact", ZGC))
+	assert.True(conflictingGCOption("-XX:+CMSClassUnloadingEnabled", G1GC))
+	assert.False(conflictingGCOption("-XX:G1RSetUpdatingPauseTimePercent=5", G1GC))
+	assert.False(conflictingGCOption("-XX:+ParallelRefProcEnabled", ZGC))
+	assert.False(conflictingGCOption("-Xmx4G", ZGC))
+	assert.True(conflictingGCOption("-XX:ZCollectionIntervalMinor=5", G1GC))
+	assert.True(conflictingGCOption("-XX:ZUncommitDelay=300", Shenandoah))
+	assert.True(conflictingGCOption("-XX:ZAllocationSpikeTolerance=2", G1GC))
+	assert.False(conflictingGCOption("-XX:+ZeroTLAB", G1GC))
+	assert.False(conflictingGCOption("-XX:-ZeroTLAB", ZGC))
+}
+
+func TestJVM21ConcurrentGarbageCollectors(t *testing.T) {
+	require := require.New(t)
+	optionsDir := filepath.Join(envtest.RootDir(), "testfiles")
+
+	tempDir := t.TempDir()
+	configInput := &ConfigInput{
+		ConfigOverrides: ConfigOverrides{
+			ServerOptions21: map[string]any{
+				"garbage_collector": "ZGC",
+			},
+		},
+	}
+	require.NoError(createJVMOptions(configInput, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(tempDir), &ConfigOverrides{}))
+
+	options, err := readJvmServerOptions(os.DirFS(tempDir), "jvm21-server.options")
+	require.NoError(err)
+	require.Contains(options, "-XX:+UseZGC")
+	require.Contains(options, "-XX:+ZGenerational")
+	require.NotContains(options, "-XX:+UseG1GC")
+	require.NotContains(options, "-XX:G1HeapRegionSize=16m")
+	require.NotContains(options, "-XX:InitiatingHeapOccupancyPercent=70")
+	require.Contains(options, "-XX:+ParallelRefProcEnabled")
+	require.Contains(options, "-Djdk.attach.allowAttachSelf=true")
+
+	// Detected from the additional-jvm-opts
+	tempDir = t.TempDir()
+	configInput = &ConfigInput{
+		ConfigOverrides: ConfigOverrides{
+			ServerOptions21: map[string]any{
+				"additional-jvm-opts": []any{"-XX:+UseShenandoahGC", "-XX:ShenandoahGCHeuristics=compact"},
+			},
+		},
+	}
+	require.NoError(createJVMOptions(configInput, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(tempDir), &ConfigOverrides{}))
+
+	options, err = readJvmServerOptions(os.DirFS(tempDir), "jvm21-server.options")
+	require.NoError(err)
+	require.Contains(options, "-XX:+UseShenandoahGC")
+	require.Contains(options, "-XX:ShenandoahGCHeuristics=compact")
+	require.NotContains(options, "-XX:+UseG1GC")
+	require.NotContains(options, "-XX:G1HeapRegionSize=16m")
+	require.NotContains(options, "-XX:+ZGenerational")
+
+	// CMS was removed in JDK14
+	configInput = &ConfigInput{
+		ConfigOverrides: ConfigOverrides{
+			ServerOptions17: map[string]any{
+				"garbage_collector": "CMS",
+			},
+		},
+	}
+	require.ErrorContains(createJVMOptions(configInput, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(t.TempDir()), &ConfigOverrides{}), "CMS is not available in JDK17")
+
+	configInput = &ConfigInput{
+		ConfigOverrides: ConfigOverrides{
+			ServerOptions21: map[string]any{
+				"additional-jvm-opts": []any{"-XX:+UseConcMarkSweepGC"},
+			},
+		},
+	}
+	require.ErrorContains(createJVMOptions(configInput, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(t.TempDir()), &ConfigOverrides{}), "CMS is not available in JDK21")
 }
 
 func TestJVM17GarbageCollectorOptions(t *testing.T) {
@@ -859,10 +984,9 @@ func TestJVM17GarbageCollectorOptions(t *testing.T) {
 		},
 	}
 
-	require.NoError(createJVMOptions(ciG1, optionsDir, tempDirG1, &ConfigOverrides{}))
+	require.NoError(createJVMOptions(ciG1, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(tempDirG1), &ConfigOverrides{}))
 
-	jvm17FileG1 := filepath.Join(tempDirG1, "jvm17-server.options")
-	optionsG1, err := readJvmServerOptions(jvm17FileG1)
+	optionsG1, err := readJvmServerOptions(os.DirFS(tempDirG1), "jvm17-server.options")
 	require.NoError(err)
 
 	g1gcFound := false
@@ -886,10 +1010,9 @@ func TestJVM17GarbageCollectorOptions(t *testing.T) {
 		},
 	}
 
-	require.NoError(createJVMOptions(ciZ, optionsDir, tempDirZ, &ConfigOverrides{}))
+	require.NoError(createJVMOptions(ciZ, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(tempDirZ), &ConfigOverrides{}))
 
-	jvm17FileZ := filepath.Join(tempDirZ, "jvm17-server.options")
-	optionsZ, err := readJvmServerOptions(jvm17FileZ)
+	optionsZ, err := readJvmServerOptions(os.DirFS(tempDirZ), "jvm17-server.options")
 	require.NoError(err)
 
 	zgcFound := false
@@ -913,10 +1036,9 @@ func TestJVM17GarbageCollectorOptions(t *testing.T) {
 		},
 	}
 
-	require.NoError(createJVMOptions(ciS, optionsDir, tempDirS, &ConfigOverrides{}))
+	require.NoError(createJVMOptions(ciS, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(tempDirS), &ConfigOverrides{}))
 
-	jvm17FileS := filepath.Join(tempDirS, "jvm17-server.options")
-	optionsS, err := readJvmServerOptions(jvm17FileS)
+	optionsS, err := readJvmServerOptions(os.DirFS(tempDirS), "jvm17-server.options")
 	require.NoError(err)
 
 	shenandoahFound := false
@@ -941,7 +1063,7 @@ func TestCassandraEnv(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(configInput)
 
-	require.NoError(createCassandraEnv(configInput, envDir, tempDir))
+	require.NoError(createCassandraEnv(configInput, os.DirFS(envDir), DirOutput(tempDir)))
 
 	// Verify output
 	lines, err := readFileToLines(tempDir, "cassandra-env.sh")
@@ -964,7 +1086,7 @@ func TestReadOptionsWithNumeric(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(configInput)
 
-	require.NoError(createJVMOptions(configInput, optionsDir, tempDir, &ConfigOverrides{}))
+	require.NoError(createJVMOptions(configInput, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(tempDir), &ConfigOverrides{}))
 
 	lines, err := readFileToLines(tempDir, "jvm-server.options")
 	require.NoError(err)
@@ -988,10 +1110,9 @@ func TestCass50GCOverrides(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(nodeInfo)
 
-	require.NoError(createJVMOptions(configInput, cassYamlDir, tempDir, &ConfigOverrides{}))
+	require.NoError(createJVMOptions(configInput, &NodeInfo{}, os.DirFS(cassYamlDir), DirOutput(tempDir), &ConfigOverrides{}))
 
-	jvm17OptionsFile := filepath.Join(tempDir, "jvm17-server.options")
-	options, err := readJvmServerOptions(jvm17OptionsFile)
+	options, err := readJvmServerOptions(os.DirFS(tempDir), "jvm17-server.options")
 	require.NoError(err)
 
 	require.NotContains(options, "-XX:+UseZGC")
@@ -1015,10 +1136,9 @@ func TestCass50GCOverridesAdditionalOpts(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(nodeInfo)
 
-	require.NoError(createJVMOptions(configInput, cassYamlDir, tempDir, &ConfigOverrides{}))
+	require.NoError(createJVMOptions(configInput, &NodeInfo{}, os.DirFS(cassYamlDir), DirOutput(tempDir), &ConfigOverrides{}))
 
-	jvm17OptionsFile := filepath.Join(tempDir, "jvm17-server.options")
-	options, err := readJvmServerOptions(jvm17OptionsFile)
+	options, err := readJvmServerOptions(os.DirFS(tempDir), "jvm17-server.options")
 	require.NoError(err)
 
 	require.Contains(options, "-XX:+UseZGC")
@@ -1026,6 +1146,157 @@ func TestCass50GCOverridesAdditionalOpts(t *testing.T) {
 	require.NotContains(options, "-XX:+UseShenandoahGC")
 }
 
+func TestJVM17And21OptionAliases(t *testing.T) {
+	require := require.New(t)
+	optionsDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	configInput := &ConfigInput{
+		ConfigOverrides: ConfigOverrides{
+			ServerOptions17: map[string]any{
+				"max_gc_pause_millis":                 float64(200),
+				"g1r_set_updating_pause_time_percent": "10",
+				"max_direct_memory_size":              "2G",
+				"parallel_ref_proc_enabled":           false,
+				"always_pre_touch":                    true,
+			},
+			ServerOptions21: map[string]any{
+				"young_generation_size":        "1G",
+				"z_generational":               true,
+				"z_allocation_spike_tolerance": "5",
+				"shenandoah_gc_heuristics":     "adaptive",
+			},
+		},
+	}
+	podOverrides := &ConfigOverrides{
+		ServerOptions21: map[string]any{
+			"young_generation_size": "2G",
+		},
+	}
+
+	require.NoError(createJVMOptions(configInput, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(tempDir), podOverrides))
+
+	options17, err := readJvmServerOptions(os.DirFS(tempDir), "jvm17-server.options")
+	require.NoError(err)
+	require.Contains(options17, "-XX:MaxGCPauseMillis=200")
+	require.NotContains(options17, "-XX:MaxGCPauseMillis=300")
+	require.Contains(options17, "-XX:G1RSetUpdatingPauseTimePercent=10")
+	require.Contains(options17, "-XX:MaxDirectMemorySize=2G")
+	require.Contains(options17, "-XX:+AlwaysPreTouch")
+	require.NotContains(options17, "-XX:+ParallelRefProcEnabled")
+	require.Contains(options17, "-XX:+UseG1GC")
+
+	options21, err := readJvmServerOptions(os.DirFS(tempDir), "jvm21-server.options")
+	require.NoError(err)
+	require.Contains(options21, "-Xmn2G")
+	require.NotContains(options21, "-Xmn1G")
+	require.Contains(options21, "-XX:+ZGenerational")
+	require.Contains(options21, "-XX:ZAllocationSpikeTolerance=5")
+	require.Contains(options21, "-XX:ShenandoahGCHeuristics=adaptive")
+	require.Contains(options21, "-XX:+ParallelRefProcEnabled")
+	require.NotContains(options21, "-XX:G1RSetUpdatingPauseTimePercent=5")
+}
+
+func TestJVM8And11OptionAliasOutput(t *testing.T) {
+	require := require.New(t)
+	optionsDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	// The boolean flags of the older alias tables are neither added nor removed from the base files
+	configInput := &ConfigInput{
+		ConfigOverrides: ConfigOverrides{
+			ServerOptions: map[string]any{
+				"per_thread_stack_size":        "384k",
+				"unlock-diagnostic-vm-options": true,
+				"perf_disable_shared_mem":      false,
+			},
+			ServerOptions11: map[string]any{
+				"jdk_attach_allow_attach_self": false,
+				"conc_gc_threads":              float64(4),
+			},
+		},
+	}
+
+	require.NoError(createJVMOptions(configInput, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(tempDir), &ConfigOverrides{}))
+
+	options, err := readJvmServerOptions(os.DirFS(tempDir), "jvm-server.options")
+	require.NoError(err)
+	require.Contains(options, "-Xss384k")
+	require.NotContains(options, "-Xss256k")
+	require.NotContains(options, "-XX:+UnlockDiagnosticVMOptions")
+	require.Contains(options, "-XX:+PerfDisableSharedMem")
+
+	options11, err := readJvmServerOptions(os.DirFS(tempDir), "jvm11-server.options")
+	require.NoError(err)
+	require.Contains(options11, "-XX:ConcGCThreads=4")
+	require.Contains(options11, "-Djdk.attach.allowAttachSelf=true")
+}
+
+func TestJVM17And21OptionValidation(t *testing.T) {
+	optionsDir := filepath.Join(envtest.RootDir(), "testfiles")
+
+	tests := []struct {
+		name         string
+		configInput  *ConfigInput
+		podOverrides *ConfigOverrides
+		errContains  string
+	}{
+		{
+			name: "unknown key in jvm17",
+			configInput: &ConfigInput{ConfigOverrides: ConfigOverrides{
+				ServerOptions17: map[string]any{"max_gc_pause": "200"},
+			}},
+			podOverrides: &ConfigOverrides{},
+			errContains:  "unknown option max_gc_pause in jvm17-server-options",
+		},
+		{
+			name: "removed option in jvm21",
+			configInput: &ConfigInput{ConfigOverrides: ConfigOverrides{
+				ServerOptions21: map[string]any{"g1r_set_updating_pause_time_percent": "5"},
+			}},
+			podOverrides: &ConfigOverrides{},
+			errContains:  "unknown option g1r_set_updating_pause_time_percent in jvm21-server-options",
+		},
+		{
+			name:        "unknown key in pod overrides",
+			configInput: &ConfigInput{},
+			podOverrides: &ConfigOverrides{
+				ServerOptions21: map[string]any{"use_cms": true},
+			},
+			errContains: "unknown option use_cms in jvm21-server-options",
+		},
+		{
+			name: "invalid integer",
+			configInput: &ConfigInput{ConfigOverrides: ConfigOverrides{
+				ServerOptions17: map[string]any{"conc_gc_threads": "many"},
+			}},
+			podOverrides: &ConfigOverrides{},
+			errContains:  "option conc_gc_threads in jvm17-server-options must be an integer",
+		},
+		{
+			name: "invalid boolean",
+			configInput: &ConfigInput{ConfigOverrides: ConfigOverrides{
+				ServerOptions21: map[string]any{"z_generational": "sometimes"},
+			}},
+			podOverrides: &ConfigOverrides{},
+			errContains:  "option z_generational in jvm21-server-options must be a boolean",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := createJVMOptions(test.configInput, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(t.TempDir()), test.podOverrides)
+			require.ErrorContains(t, err, test.errContains)
+		})
+	}
+
+	// Older files keep ignoring the unknown keys
+	configInput := &ConfigInput{ConfigOverrides: ConfigOverrides{
+		ServerOptions11: map[string]any{"unknown_option": "1"},
+	}}
+	require.NoError(t, createJVMOptions(configInput, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(t.TempDir()), &ConfigOverrides{}))
+}
+
 // readFileToLines is a small test helper, reads file to []string (per line). This version does not filter anything, not even whitespace.
 func readFileToLines(dir, filename string) ([]string, error) {
 	outputFile := filepath.Join(dir, filename)
@@ -1059,7 +1330,7 @@ func TestCopyFiles(t *testing.T) {
 	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
 	tempDir := t.TempDir()
 
-	require.NoError(copyFiles(inputDir, tempDir))
+	require.NoError(copyFiles(os.DirFS(inputDir), DirOutput(tempDir)))
 
 	// We should have tempDir/jvm11-clients.options
 	_, err := os.Stat(filepath.Join(tempDir, "jvm11-clients.options"))
@@ -1076,7 +1347,9 @@ func copyAllFiles(t *testing.T, srcDir, dstDir string) {
 			// Only copy top-level files used by builder; subdirs are not required here
 			continue
 		}
-		require.NoError(t, copyFile(filepath.Join(srcDir, e.Name()), filepath.Join(dstDir, e.Name())))
+		b, err := os.ReadFile(filepath.Join(srcDir, e.Name()))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dstDir, e.Name()), b, 0660))
 	}
 }
 
@@ -1195,7 +1468,7 @@ func TestPerPodOverridesMergeJvmOptions(t *testing.T) {
 func TestConfigInputPodOverridesParsing(t *testing.T) {
 	require := require.New(t)
 
-	configInput, err := parseConfigInputFromData(`{
+	configInput, err := DecodeConfigInput([]byte(`{
 		"cluster-info": {
 			"name": "test",
 			"seeds": "test-seed-service"
@@ -1216,7 +1489,7 @@ func TestConfigInputPodOverridesParsing(t *testing.T) {
 				}
 			}
 		}
-	}`)
+	}`))
 	require.NoError(err)
 
 	podOverrides := configInput.PodOverrides["test-datacenter1-r1-sts-0"]
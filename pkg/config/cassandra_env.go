@@ -0,0 +1,232 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+var (
+	envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	jmxPortRegexp = regexp.MustCompile(`(?m)^JMX_PORT=.*$`)
+)
+
+// createCassandraEnv writes the cassandra-env.sh. The variables are set before the base file and the JVM_OPTS
+// after it, so that they override the values set by the base file.
+func createCassandraEnv(configInput *ConfigInput, base fs.FS, out Output) error {
+	f, err := fs.ReadFile(base, "cassandra-env.sh")
+	if err != nil {
+		return err
+	}
+
+	rendered, err := renderCassandraEnv(f, configInput.CassandraEnv)
+	if err != nil {
+		return err
+	}
+
+	return out.WriteFile("cassandra-env.sh", rendered, 0770)
+}
+
+func renderCassandraEnv(base []byte, opts CassandraEnvOptions) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if opts.MallocArenaMax > 0 {
+		fmt.Fprintf(&buf, "export MALLOC_ARENA_MAX=%d\n", opts.MallocArenaMax)
+	}
+
+	if opts.HeapDumpDir != "" {
+		fmt.Fprintf(&buf, "export CASSANDRA_HEAPDUMP_DIR=%s\n", opts.HeapDumpDir)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(opts.Env)) {
+		if !envNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment variable name %s in cassandra-env-sh", name)
+		}
+		fmt.Fprintf(&buf, "export %s=%s\n", name, shellQuote(opts.Env[name]))
+	}
+
+	jvmOpts := make([]string, 0, len(opts.AdditionalOpts)+len(opts.JavaAgents))
+
+	if jmx := opts.JMX; jmx != nil {
+		if jmx.Local != nil {
+			localJMX := "no"
+			if *jmx.Local {
+				localJMX = "yes"
+			}
+			fmt.Fprintf(&buf, "LOCAL_JMX=%s\n", localJMX)
+		}
+
+		if jmx.Port != 0 {
+			if jmx.Port < 1 || jmx.Port > 65535 {
+				return nil, fmt.Errorf("invalid jmx port %d", jmx.Port)
+			}
+			// The base file sets the port unconditionally
+			if jmxPortRegexp.Match(base) {
+				base = jmxPortRegexp.ReplaceAll(base, []byte(fmt.Sprintf(`JMX_PORT="%d"`, jmx.Port)))
+			} else {
+				fmt.Fprintf(&buf, "JMX_PORT=\"%d\"\n", jmx.Port)
+			}
+		}
+
+		jmxOpts, err := jmxOptions(jmx)
+		if err != nil {
+			return nil, err
+		}
+		jvmOpts = append(jvmOpts, jmxOpts...)
+	}
+
+	for _, agent := range opts.JavaAgents {
+		if agent.Path == "" {
+			return nil, errors.New("java agent requires a path")
+		}
+		path, err := jvmOptValue("java agent path", agent.Path)
+		if err != nil {
+			return nil, err
+		}
+		opt := "-javaagent:" + path
+		if agent.Args != "" {
+			args, err := jvmOptValue("java agent args", agent.Args)
+			if err != nil {
+				return nil, err
+			}
+			opt += "=" + args
+		}
+		jvmOpts = append(jvmOpts, opt)
+	}
+
+	jvmOpts = append(jvmOpts, opts.AdditionalOpts...)
+
+	buf.Write(base)
+	buf.WriteString("\n")
+
+	for _, opt := range jvmOpts {
+		fmt.Fprintf(&buf, "JVM_OPTS=\"$JVM_OPTS %s\"\n", opt)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// jmxOptions returns the system properties for the JMX settings, the last definition of a property wins so these
+// replace the ones set in the base file
+func jmxOptions(jmx *JMXOptions) ([]string, error) {
+	opts := make([]string, 0)
+
+	addProperty := func(field, property, value string) error {
+		escaped, err := jvmOptValue(field, value)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, "-D"+property+"="+escaped)
+		return nil
+	}
+
+	// The password is read from the file when Cassandra starts, so that it is not stored in cassandra-env.sh
+	addPasswordFile := func(field, property, path string) error {
+		if strings.ContainsFunc(path, unicode.IsSpace) {
+			return fmt.Errorf("%s %q must not contain whitespace", field, path)
+		}
+		opts = append(opts, fmt.Sprintf(`-D%s=$(cat "%s")`, property, shellEscape(path)))
+		return nil
+	}
+
+	if jmx.RMIPort != 0 {
+		if jmx.RMIPort < 1 || jmx.RMIPort > 65535 {
+			return nil, fmt.Errorf("invalid jmx rmi-port %d", jmx.RMIPort)
+		}
+		opts = append(opts, fmt.Sprintf("-Dcom.sun.management.jmxremote.rmi.port=%d", jmx.RMIPort))
+	}
+
+	if jmx.Authenticate != nil {
+		opts = append(opts, fmt.Sprintf("-Dcom.sun.management.jmxremote.authenticate=%t", *jmx.Authenticate))
+	}
+
+	if jmx.PasswordFile != "" {
+		if err := addProperty("jmx password-file", "com.sun.management.jmxremote.password.file", jmx.PasswordFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if jmx.AccessFile != "" {
+		if err := addProperty("jmx access-file", "com.sun.management.jmxremote.access.file", jmx.AccessFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if ssl := jmx.SSL; ssl != nil && ssl.Enabled {
+		// The base file uses the JMX_PORT for RMI with remote connections, which does not work with SSL
+		if remote := jmx.Local != nil && !*jmx.Local; remote && (jmx.RMIPort == 0 || jmx.RMIPort == jmx.Port) {
+			return nil, errors.New("jmx ssl with remote connections requires an rmi-port different from the jmx port")
+		}
+
+		opts = append(opts, "-Dcom.sun.management.jmxremote.ssl=true")
+		if ssl.NeedClientAuth {
+			opts = append(opts, "-Dcom.sun.management.jmxremote.ssl.need.client.auth=true")
+		}
+
+		properties := []struct {
+			field, property, value string
+		}{
+			{"jmx ssl enabled-protocols", "com.sun.management.jmxremote.ssl.enabled.protocols", strings.Join(ssl.EnabledProtocols, ",")},
+			{"jmx ssl enabled-cipher-suites", "com.sun.management.jmxremote.ssl.enabled.cipher.suites", strings.Join(ssl.EnabledCipherSuites, ",")},
+			{"jmx ssl keystore", "javax.net.ssl.keyStore", ssl.Keystore},
+		}
+		for _, p := range properties {
+			if p.value == "" {
+				continue
+			}
+			if err := addProperty(p.field, p.property, p.value); err != nil {
+				return nil, err
+			}
+		}
+
+		if ssl.KeystorePasswordFile != "" {
+			if err := addPasswordFile("jmx ssl keystore-password-file", "javax.net.ssl.keyStorePassword", ssl.KeystorePasswordFile); err != nil {
+				return nil, err
+			}
+		}
+
+		if ssl.Truststore != "" {
+			if err := addProperty("jmx ssl truststore", "javax.net.ssl.trustStore", ssl.Truststore); err != nil {
+				return nil, err
+			}
+		}
+
+		if ssl.TruststorePasswordFile != "" {
+			if err := addPasswordFile("jmx ssl truststore-password-file", "javax.net.ssl.trustStorePassword", ssl.TruststorePasswordFile); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return opts, nil
+}
+
+// jvmOptValue escapes the value for the double quoted JVM_OPTS assignment. Cassandra splits the JVM_OPTS on whitespace
+// when it starts the JVM, so values with whitespace are rejected.
+func jvmOptValue(field, value string) (string, error) {
+	if strings.ContainsFunc(value, unicode.IsSpace) {
+		return "", fmt.Errorf("%s %q must not contain whitespace", field, value)
+	}
+	return shellEscape(value), nil
+}
+
+var (
+	shellQuoteReplacer  = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`")
+	shellEscapeReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", `$`, `\$`)
+)
+
+// shellQuote double quotes the value, variable references are still expanded by the shell
+func shellQuote(value string) string {
+	return `"` + shellQuoteReplacer.Replace(value) + `"`
+}
+
+// shellEscape escapes the value for use inside double quotes, without variable or command expansion
+func shellEscape(value string) string {
+	return shellEscapeReplacer.Replace(value)
+}
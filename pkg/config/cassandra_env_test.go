@@ -0,0 +1,245 @@
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/k8ssandra/k8ssandra-client/internal/envtest"
+	"github.com/stretchr/testify/require"
+)
+
+var structuredCassandraEnvConfig = `
+{
+	"cassandra-env-sh": {
+		"malloc-arena-max": 4,
+		"env": {
+			"OTEL_SERVICE_NAME": "cassandra",
+			"OTEL_RESOURCE_ATTRIBUTES": "pod=$POD_NAME,\"quoted\""
+		},
+		"jmx": {
+			"local": false,
+			"port": 7299,
+			"rmi-port": 7300,
+			"authenticate": true,
+			"password-file": "/etc/jmx/jmxremote.password",
+			"ssl": {
+				"enabled": true,
+				"need-client-auth": true,
+				"enabled-protocols": ["TLSv1.2", "TLSv1.3"],
+				"keystore": "/etc/jmx/keystore.jks",
+				"keystore-password-file": "/etc/jmx/keystore.password"
+			}
+		},
+		"java-agents": [
+			{
+				"path": "/opt/agents/jmx_prometheus_javaagent.jar",
+				"args": "9103:/etc/jmx/exporter.yaml"
+			},
+			{
+				"path": "/opt/agents/opentelemetry-javaagent.jar"
+			}
+		],
+		"additional-jvm-opts": [
+			"-Dcom.sun.management.jmxremote.authenticate=false"
+		]
+	},
+	"pod-overrides": {
+		"cluster1-dc1-r1-sts-0": {
+			"cassandra-env-sh": {
+				"env": {
+					"OTEL_SERVICE_NAME": "cassandra-0"
+				},
+				"java-agents": [
+					{
+						"path": "/opt/agents/profiler.jar",
+						"args": "start"
+					}
+				]
+			}
+		}
+	},
+	"cluster-info": {
+		"name": "cluster1",
+		"seeds": "cluster1-seed-service"
+	},
+	"datacenter-info": {
+		"name": "dc1"
+	}
+}
+`
+
+func TestStructuredCassandraEnv(t *testing.T) {
+	require := require.New(t)
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	t.Setenv("CONFIG_FILE_DATA", structuredCassandraEnvConfig)
+	t.Setenv("POD_NAME", "cluster1-dc1-r1-sts-0")
+	t.Setenv("POD_IP", "172.27.0.1")
+	t.Setenv("RACK_NAME", "r1")
+
+	// Leftovers from a previous run must be replaced
+	require.NoError(os.WriteFile(filepath.Join(tempDir, "cassandra-env.sh"), []byte("export STALE=true\n"), 0660))
+
+	require.NoError(NewBuilder(inputDir, tempDir).Build(t.Context()))
+
+	lines, err := readFileToLines(tempDir, "cassandra-env.sh")
+	require.NoError(err)
+
+	require.NotContains(lines, "export STALE=true")
+	require.Equal("export MALLOC_ARENA_MAX=4", lines[0])
+	require.Equal(`export OTEL_RESOURCE_ATTRIBUTES="pod=$POD_NAME,\"quoted\""`, lines[1])
+	require.Equal(`export OTEL_SERVICE_NAME="cassandra-0"`, lines[2])
+	require.Equal("LOCAL_JMX=no", lines[3])
+
+	require.Contains(lines, `JMX_PORT="7299"`)
+	require.NotContains(lines, `JMX_PORT="7199"`)
+
+	require.Contains(lines, `JVM_OPTS="$JVM_OPTS -Dcom.sun.management.jmxremote.rmi.port=7300"`)
+	require.Contains(lines, `JVM_OPTS="$JVM_OPTS -Dcom.sun.management.jmxremote.password.file=/etc/jmx/jmxremote.password"`)
+	require.Contains(lines, `JVM_OPTS="$JVM_OPTS -Dcom.sun.management.jmxremote.ssl=true"`)
+	require.Contains(lines, `JVM_OPTS="$JVM_OPTS -Dcom.sun.management.jmxremote.ssl.need.client.auth=true"`)
+	require.Contains(lines, `JVM_OPTS="$JVM_OPTS -Dcom.sun.management.jmxremote.ssl.enabled.protocols=TLSv1.2,TLSv1.3"`)
+	require.Contains(lines, `JVM_OPTS="$JVM_OPTS -Djavax.net.ssl.keyStorePassword=$(cat "/etc/jmx/keystore.password")"`)
+	require.Contains(lines, `JVM_OPTS="$JVM_OPTS -javaagent:/opt/agents/opentelemetry-javaagent.jar"`)
+	require.Contains(lines, `JVM_OPTS="$JVM_OPTS -javaagent:/opt/agents/profiler.jar=start"`)
+
+	// The additional options come last, so that they win over the structured ones
+	authIdx := -1
+	for i, line := range lines {
+		if line == `JVM_OPTS="$JVM_OPTS -Dcom.sun.management.jmxremote.authenticate=true"` {
+			authIdx = i
+		}
+	}
+	require.Equal(`JVM_OPTS="$JVM_OPTS -Dcom.sun.management.jmxremote.authenticate=false"`, lines[len(lines)-1])
+	require.Less(authIdx, len(lines)-1)
+	require.Equal(`JVM_OPTS="$JVM_OPTS -javaagent:/opt/agents/jmx_prometheus_javaagent.jar=9103:/etc/jmx/exporter.yaml"`, lines[len(lines)-4])
+}
+
+func TestCassandraEnvValidation(t *testing.T) {
+	base := []byte("JMX_PORT=\"7199\"\n")
+	local, remote := true, false
+
+	tests := []struct {
+		name string
+		opts CassandraEnvOptions
+	}{
+		{
+			name: "invalid env name",
+			opts: CassandraEnvOptions{Env: map[string]string{"NOT-VALID": "x"}},
+		},
+		{
+			name: "invalid port",
+			opts: CassandraEnvOptions{JMX: &JMXOptions{Port: 70000}},
+		},
+		{
+			name: "invalid rmi port",
+			opts: CassandraEnvOptions{JMX: &JMXOptions{RMIPort: -1}},
+		},
+		{
+			name: "remote ssl without rmi port",
+			opts: CassandraEnvOptions{JMX: &JMXOptions{Local: &remote, SSL: &JMXSSLOptions{Enabled: true}}},
+		},
+		{
+			name: "java agent without path",
+			opts: CassandraEnvOptions{JavaAgents: []JavaAgent{{Args: "port=9103"}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := renderCassandraEnv(base, test.opts)
+			require.Error(t, err)
+		})
+	}
+
+	// Local connections do not use the RMI port
+	out, err := renderCassandraEnv(base, CassandraEnvOptions{JMX: &JMXOptions{Local: &local, Port: 7299, SSL: &JMXSSLOptions{Enabled: true}}})
+	require.NoError(t, err)
+	require.Equal(t, "LOCAL_JMX=yes\nJMX_PORT=\"7299\"\n\nJVM_OPTS=\"$JVM_OPTS -Dcom.sun.management.jmxremote.ssl=true\"\n", string(out))
+
+	// Without JMX_PORT in the base file, the port is set before it
+	out, err = renderCassandraEnv([]byte("echo\n"), CassandraEnvOptions{JMX: &JMXOptions{Port: 7299}})
+	require.NoError(t, err)
+	require.Equal(t, "JMX_PORT=\"7299\"\necho\n\n", string(out))
+}
+
+func TestCassandraEnvEscaping(t *testing.T) {
+	require := require.New(t)
+	tempDir := t.TempDir()
+
+	// Expanded by the shell, the commands would change the JVM_OPTS
+	value := `q"d$(id)` + "`id`" + `\$HOME'`
+	passwordFile := filepath.Join(tempDir, value+".password")
+	require.NoError(os.WriteFile(passwordFile, []byte("pw"), 0600))
+
+	remote := false
+	newOpts := func() CassandraEnvOptions {
+		return CassandraEnvOptions{
+			JMX: &JMXOptions{
+				Local:        &remote,
+				RMIPort:      7200,
+				PasswordFile: value,
+				AccessFile:   value,
+				SSL: &JMXSSLOptions{
+					Enabled:                true,
+					EnabledProtocols:       []string{value},
+					EnabledCipherSuites:    []string{value},
+					Keystore:               value,
+					KeystorePasswordFile:   passwordFile,
+					Truststore:             value,
+					TruststorePasswordFile: passwordFile,
+				},
+			},
+			JavaAgents: []JavaAgent{{Path: value, Args: value}},
+		}
+	}
+
+	out, err := renderCassandraEnv(nil, newOpts())
+	require.NoError(err)
+	require.NotContains(string(out), "pw\n")
+
+	script := filepath.Join(tempDir, "cassandra-env.sh")
+	require.NoError(os.WriteFile(script, out, 0600))
+	jvmOpts, err := exec.Command("sh", "-c", `. "$1" && printf '%s' "$JVM_OPTS"`, "sh", script).Output()
+	require.NoError(err)
+
+	expected := []string{
+		"",
+		"-Dcom.sun.management.jmxremote.rmi.port=7200",
+		"-Dcom.sun.management.jmxremote.password.file=" + value,
+		"-Dcom.sun.management.jmxremote.access.file=" + value,
+		"-Dcom.sun.management.jmxremote.ssl=true",
+		"-Dcom.sun.management.jmxremote.ssl.enabled.protocols=" + value,
+		"-Dcom.sun.management.jmxremote.ssl.enabled.cipher.suites=" + value,
+		"-Djavax.net.ssl.keyStore=" + value,
+		"-Djavax.net.ssl.keyStorePassword=pw",
+		"-Djavax.net.ssl.trustStore=" + value,
+		"-Djavax.net.ssl.trustStorePassword=pw",
+		"-javaagent:" + value + "=" + value,
+	}
+	require.Equal(strings.Join(expected, " "), string(jvmOpts))
+
+	// The JVM_OPTS are split on whitespace when Cassandra starts
+	withSpaces := map[string]func(*CassandraEnvOptions){
+		"password-file":            func(o *CassandraEnvOptions) { o.JMX.PasswordFile = "a b" },
+		"access-file":              func(o *CassandraEnvOptions) { o.JMX.AccessFile = "a b" },
+		"enabled-protocols":        func(o *CassandraEnvOptions) { o.JMX.SSL.EnabledProtocols = []string{"a b"} },
+		"enabled-cipher-suites":    func(o *CassandraEnvOptions) { o.JMX.SSL.EnabledCipherSuites = []string{"a\tb"} },
+		"keystore":                 func(o *CassandraEnvOptions) { o.JMX.SSL.Keystore = "a b" },
+		"keystore-password-file":   func(o *CassandraEnvOptions) { o.JMX.SSL.KeystorePasswordFile = "a b" },
+		"truststore":               func(o *CassandraEnvOptions) { o.JMX.SSL.Truststore = "a\nb" },
+		"truststore-password-file": func(o *CassandraEnvOptions) { o.JMX.SSL.TruststorePasswordFile = "a b" },
+		"java agent path":          func(o *CassandraEnvOptions) { o.JavaAgents[0].Path = "a b" },
+		"java agent args":          func(o *CassandraEnvOptions) { o.JavaAgents[0].Args = "a b" },
+	}
+	for name, set := range withSpaces {
+		opts := newOpts()
+		set(&opts)
+		_, err := renderCassandraEnv(nil, opts)
+		require.ErrorContains(err, "must not contain whitespace", name)
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+)
+
+const commitLogArchivingConfigName = "commitlog_archiving.properties"
+
+var commitLogPrecisions = []string{"MILLISECONDS", "MICROSECONDS"}
+
+// createCommitLogArchiving writes commitlog_archiving.properties. The set values replace the ones in the base file
+// and the rest of the file is kept as is. Without any options the base file is copied.
+func createCommitLogArchiving(configInput *ConfigInput, base fs.FS, out Output) error {
+	opts := configInput.CommitLogArchiving
+	properties := map[string]string{}
+	if opts.ArchiveCommand != "" {
+		properties["archive_command"] = opts.ArchiveCommand
+	}
+	if opts.RestoreCommand != "" {
+		properties["restore_command"] = opts.RestoreCommand
+	}
+	if opts.RestoreDirectories != "" {
+		properties["restore_directories"] = opts.RestoreDirectories
+	}
+	if opts.RestorePointInTime != "" {
+		properties["restore_point_in_time"] = opts.RestorePointInTime
+	}
+	if opts.Precision != "" {
+		precision := strings.ToUpper(opts.Precision)
+		if !slices.Contains(commitLogPrecisions, precision) {
+			return fmt.Errorf("unknown commitlog archiving precision %s, supported values are %s", opts.Precision, strings.Join(commitLogPrecisions, ", "))
+		}
+		properties["precision"] = precision
+	}
+
+	baseFile, err := fs.ReadFile(base, commitLogArchivingConfigName)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if len(properties) == 0 {
+		if baseFile == nil {
+			return nil
+		}
+		return out.WriteFile(commitLogArchivingConfigName, baseFile, 0660)
+	}
+
+	return out.WriteFile(commitLogArchivingConfigName, replaceProperties(baseFile, properties), 0660)
+}
+
+// replaceProperties replaces the values of the given keys in a .properties file and appends the missing keys in
+// sorted order
+func replaceProperties(base []byte, properties map[string]string) []byte {
+	var out bytes.Buffer
+	written := make(map[string]bool, len(properties))
+
+	scanner := bufio.NewScanner(bytes.NewReader(base))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "#") {
+			if key, _, found := strings.Cut(trimmed, "="); found {
+				key = strings.TrimSpace(key)
+				if value, ok := properties[key]; ok {
+					if !written[key] {
+						fmt.Fprintf(&out, "%s=%s\n", key, value)
+						written[key] = true
+					}
+					continue
+				}
+			}
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		if !written[k] {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(&out, "%s=%s\n", k, properties[k])
+	}
+
+	return out.Bytes()
+}
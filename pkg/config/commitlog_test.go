@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8ssandra/k8ssandra-client/internal/envtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitLogArchiving(t *testing.T) {
+	require := require.New(t)
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	configInput := &ConfigInput{
+		CommitLogArchiving: CommitLogArchivingOptions{
+			ArchiveCommand: "/bin/ln %path /backup/%name",
+			Precision:      "milliseconds",
+		},
+	}
+
+	require.NoError(createCommitLogArchiving(configInput, os.DirFS(inputDir), DirOutput(tempDir)))
+
+	lines, err := readFileToLines(tempDir, commitLogArchivingConfigName)
+	require.NoError(err)
+	require.Contains(lines, "archive_command=/bin/ln %path /backup/%name")
+	require.Contains(lines, "restore_command=")
+	require.Contains(lines, "precision=MILLISECONDS")
+	require.NotContains(lines, "precision=MICROSECONDS")
+	require.Contains(lines, "# Example: archive_command=/bin/ln %path /backup/%name")
+
+	// Rewrite does not duplicate anything and works without base file
+	require.NoError(createCommitLogArchiving(configInput, os.DirFS(t.TempDir()), DirOutput(tempDir)))
+	lines, err = readFileToLines(tempDir, commitLogArchivingConfigName)
+	require.NoError(err)
+	require.Equal([]string{"archive_command=/bin/ln %path /backup/%name", "precision=MILLISECONDS"}, lines)
+
+	configInput.CommitLogArchiving.Precision = "nanos"
+	require.Error(createCommitLogArchiving(configInput, os.DirFS(inputDir), DirOutput(t.TempDir())))
+}
+
+func TestCommitLogArchivingCopiedWithoutOverrides(t *testing.T) {
+	require := require.New(t)
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	require.NoError(createCommitLogArchiving(&ConfigInput{}, os.DirFS(inputDir), DirOutput(tempDir)))
+
+	orig, err := os.ReadFile(filepath.Join(inputDir, commitLogArchivingConfigName))
+	require.NoError(err)
+	copied, err := os.ReadFile(filepath.Join(tempDir, commitLogArchivingConfigName))
+	require.NoError(err)
+	require.Equal(orig, copied)
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Server types, same values as the serverType of the CassandraDatacenter
+	ServerTypeCassandra = "cassandra"
+	ServerTypeDSE       = "dse"
+	ServerTypeHCD       = "hcd"
+
+	dseConfigName = "dse.yaml"
+)
+
+// serverFlavor has the differences of the server distributions in their config file layouts
+type serverFlavor struct {
+	name string
+
+	// latestConfig is true if the base cassandra_latest.yaml is used instead of the cassandra.yaml when present
+	latestConfig bool
+
+	// jvmOptionFiles are the jvm*-server.options the server reads, setting the options of other files is an error
+	jvmOptionFiles []string
+
+	// cassandraYamlDefaults and dseYamlDefaults are merged over the base files, the ConfigInput overrides them
+	cassandraYamlDefaults func() map[string]any
+	dseYamlDefaults       func() map[string]any
+
+	// overrides modifies the cassandra.yaml after the k8ssandra overrides, the ConfigInput can not change these
+	overrides func(merged map[string]any)
+}
+
+var serverFlavors = map[string]*serverFlavor{
+	ServerTypeCassandra: {
+		name:           ServerTypeCassandra,
+		latestConfig:   true,
+		jvmOptionFiles: []string{"jvm-server.options", "jvm11-server.options", "jvm17-server.options", "jvm21-server.options"},
+	},
+	ServerTypeDSE: {
+		name:           ServerTypeDSE,
+		jvmOptionFiles: []string{"jvm-server.options", "jvm11-server.options"},
+		// The DSE unified authentication with internal users, the same as cass-operator sets for DSE
+		cassandraYamlDefaults: func() map[string]any {
+			return map[string]any{
+				"authenticator": "com.datastax.bdp.cassandra.auth.DseAuthenticator",
+				"authorizer":    "com.datastax.bdp.cassandra.auth.DseAuthorizer",
+				"role_manager":  "com.datastax.bdp.cassandra.auth.DseRoleManager",
+			}
+		},
+		dseYamlDefaults: func() map[string]any {
+			return map[string]any{
+				"authentication_options": map[string]any{
+					"enabled":        true,
+					"default_scheme": "internal",
+				},
+				"authorization_options": map[string]any{
+					"enabled": true,
+				},
+				"role_management_options": map[string]any{
+					"mode": "internal",
+				},
+			}
+		},
+		overrides: snitchOverrides,
+	},
+	ServerTypeHCD: {
+		name:           ServerTypeHCD,
+		jvmOptionFiles: []string{"jvm-server.options", "jvm11-server.options", "jvm17-server.options", "jvm21-server.options"},
+		overrides:      snitchOverrides,
+	},
+}
+
+// snitchOverrides sets the snitch for the servers without the node_proximity of Cassandra 5.1, they would not start
+// with the location provider settings
+func snitchOverrides(merged map[string]any) {
+	delete(merged, "node_proximity")
+	delete(merged, "initial_location_provider")
+	delete(merged, "prefer_local_connections")
+	merged["endpoint_snitch"] = "GossipingPropertyFileSnitch"
+}
+
+// resolveServerFlavor returns the flavor set in the ConfigInput. Without it, a base config with dse.yaml is DSE and
+// anything else is Cassandra. HCD uses the same file layout as Cassandra, so it has to be set in the ConfigInput.
+func resolveServerFlavor(configInput *ConfigInput, base fs.FS) (*serverFlavor, error) {
+	if serverType := strings.ToLower(configInput.ServerType); serverType != "" {
+		flavor, found := serverFlavors[serverType]
+		if !found {
+			return nil, fmt.Errorf("unknown server-type %s, supported values are %s, %s and %s", configInput.ServerType, ServerTypeCassandra, ServerTypeDSE, ServerTypeHCD)
+		}
+		return flavor, nil
+	}
+
+	if _, err := fs.Stat(base, dseConfigName); err == nil {
+		return serverFlavors[ServerTypeDSE], nil
+	}
+
+	return serverFlavors[ServerTypeCassandra], nil
+}
+
+// cassandraYamlSource returns the name of the base config file, cassandra_latest.yaml (Cassandra 5.0 and newer) if
+// present or cassandra.yaml (4.1 and older and the other flavors)
+func (f *serverFlavor) cassandraYamlSource(base fs.FS) string {
+	if f.latestConfig {
+		if _, err := fs.Stat(base, latestCassandraConfigName); err == nil {
+			return latestCassandraConfigName
+		}
+	}
+	return oldCassandraConfigName
+}
+
+// validate checks that the ConfigInput does not set sections the flavor has no config file for
+func (f *serverFlavor) validate(configInput *ConfigInput, podOverrides *ConfigOverrides) error {
+	for _, overrides := range []*ConfigOverrides{&configInput.ConfigOverrides, podOverrides} {
+		for filename, options := range map[string]map[string]interface{}{
+			"jvm-server.options":   overrides.ServerOptions,
+			"jvm11-server.options": overrides.ServerOptions11,
+			"jvm17-server.options": overrides.ServerOptions17,
+			"jvm21-server.options": overrides.ServerOptions21,
+		} {
+			if len(options) > 0 && !slices.Contains(f.jvmOptionFiles, filename) {
+				return fmt.Errorf("%s-options are not supported by server-type %s", strings.TrimSuffix(filename, ".options"), f.name)
+			}
+		}
+
+		if len(overrides.DseYaml) > 0 && f.name != ServerTypeDSE {
+			return fmt.Errorf("dse-yaml is not supported by server-type %s", f.name)
+		}
+	}
+
+	return nil
+}
+
+// createDseYaml writes the dse.yaml for DSE, the base file is optional
+func (f *serverFlavor) createDseYaml(configInput *ConfigInput, base fs.FS, out Output, finalOverrides map[string]interface{}) error {
+	dseYaml := make(map[string]any)
+
+	yamlFile, err := fs.ReadFile(base, dseConfigName)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err := yaml.Unmarshal(yamlFile, dseYaml); err != nil {
+		return fmt.Errorf("invalid %s: %w", dseConfigName, err)
+	}
+
+	if f.dseYamlDefaults != nil {
+		if dseYaml, err = mergeYaml(dseYaml, f.dseYamlDefaults()); err != nil {
+			return err
+		}
+	}
+
+	merged, err := mergeYaml(dseYaml, configInput.DseYaml)
+	if err != nil {
+		return err
+	}
+
+	if len(finalOverrides) > 0 {
+		if merged, err = mergeYaml(merged, finalOverrides); err != nil {
+			return err
+		}
+	}
+
+	return writeYaml(merged, out, dseConfigName)
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"net"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func flavorBaseConfig() fstest.MapFS {
+	return fstest.MapFS{
+		"cassandra.yaml":        {Data: []byte("num_tokens: 16\n")},
+		"cassandra_latest.yaml": {Data: []byte("num_tokens: 8\n")},
+		"cassandra-env.sh":      {Data: []byte("JMX_PORT=\"7199\"\n")},
+	}
+}
+
+func renderedYaml(t *testing.T, docs *Documents, name string) map[string]any {
+	t.Helper()
+	data, found := docs.Get(name)
+	require.True(t, found, name)
+
+	doc := make(map[string]any)
+	require.NoError(t, yaml.Unmarshal(data, doc))
+	return doc
+}
+
+func TestResolveServerFlavor(t *testing.T) {
+	require := require.New(t)
+
+	flavor, err := resolveServerFlavor(&ConfigInput{}, flavorBaseConfig())
+	require.NoError(err)
+	require.Equal(ServerTypeCassandra, flavor.name)
+	require.Equal(latestCassandraConfigName, flavor.cassandraYamlSource(flavorBaseConfig()))
+
+	base := flavorBaseConfig()
+	base[dseConfigName] = &fstest.MapFile{Data: []byte("authentication_options:\n  enabled: false\n")}
+	flavor, err = resolveServerFlavor(&ConfigInput{}, base)
+	require.NoError(err)
+	require.Equal(ServerTypeDSE, flavor.name)
+	require.Equal(oldCassandraConfigName, flavor.cassandraYamlSource(base))
+
+	// The ConfigInput takes priority over the detection
+	flavor, err = resolveServerFlavor(&ConfigInput{ServerType: "HCD"}, base)
+	require.NoError(err)
+	require.Equal(ServerTypeHCD, flavor.name)
+	require.Equal(oldCassandraConfigName, flavor.cassandraYamlSource(base))
+
+	_, err = resolveServerFlavor(&ConfigInput{ServerType: "scylla"}, base)
+	require.ErrorContains(err, "unknown server-type scylla")
+}
+
+func TestRenderDSE(t *testing.T) {
+	require := require.New(t)
+
+	base := flavorBaseConfig()
+	base[dseConfigName] = &fstest.MapFile{Data: []byte("authentication_options:\n  enabled: false\nserver_id: base\n")}
+
+	configInput, err := DecodeConfigInput([]byte(`{
+		"cluster-info": {"name": "cluster1", "seeds": "cluster1-seed-service"},
+		"cassandra-yaml": {"authorizer": "AllowAllAuthorizer", "node_proximity": "NetworkTopologyProximity"},
+		"dse-yaml": {"authentication_options": {"enabled": true}, "authorization_options": {"enabled": false}},
+		"pod-overrides": {
+			"cluster1-dc1-r1-sts-0": {"dse-yaml": {"server_id": "pod"}}
+		}
+	}`))
+	require.NoError(err)
+
+	docs, manifest, err := Render(configInput, &NodeInfo{Name: "cluster1-dc1-r1-sts-0", PodIPs: []net.IP{net.ParseIP("10.0.0.1")}}, base, nil)
+	require.NoError(err)
+
+	// The DSE authentication defaults are below the ConfigInput
+	dseYaml := renderedYaml(t, docs, dseConfigName)
+	require.Equal(map[string]any{"enabled": true, "default_scheme": "internal"}, dseYaml["authentication_options"])
+	require.Equal(map[string]any{"enabled": false}, dseYaml["authorization_options"])
+	require.Equal(map[string]any{"mode": "internal"}, dseYaml["role_management_options"])
+	require.Equal("pod", dseYaml["server_id"])
+
+	// DSE does not have cassandra_latest.yaml
+	cassYaml := renderedYaml(t, docs, "cassandra.yaml")
+	require.Equal(16, cassYaml["num_tokens"])
+	require.Equal("org.apache.cassandra.locator.K8SeedProvider", cassYaml["seed_provider"].([]any)[0].(map[string]any)["class_name"])
+	require.Equal("com.datastax.bdp.cassandra.auth.DseAuthenticator", cassYaml["authenticator"])
+	require.Equal("com.datastax.bdp.cassandra.auth.DseRoleManager", cassYaml["role_manager"])
+	require.Equal("AllowAllAuthorizer", cassYaml["authorizer"])
+
+	// DSE has no node_proximity, the rack is read by the snitch
+	require.Equal("GossipingPropertyFileSnitch", cassYaml["endpoint_snitch"])
+	require.NotContains(cassYaml, "node_proximity")
+	require.NotContains(cassYaml, "initial_location_provider")
+	rackDC, found := docs.Get("cassandra-rackdc.properties")
+	require.True(found)
+	require.Contains(string(rackDC), "dc=")
+
+	files := make(map[string]ManifestFile)
+	for _, f := range manifest.Files {
+		files[f.Name] = f
+	}
+	require.Equal([]string{LayerBase, ServerTypeDSE, "dse-yaml", LayerPodOverrides}, files[dseConfigName].Layers)
+	require.Equal([]string{LayerBase, ServerTypeDSE, "cassandra-yaml", LayerK8ssandra}, files["cassandra.yaml"].Layers)
+	require.Equal(oldCassandraConfigName, files["cassandra.yaml"].Source)
+
+	// Options for JVM versions DSE does not run on are rejected
+	configInput.ServerOptions17 = map[string]interface{}{"max_heap_size": "1G"}
+	_, _, err = Render(configInput, &NodeInfo{}, base, nil)
+	require.ErrorContains(err, "jvm17-server-options are not supported by server-type dse")
+}
+
+func TestRenderHCD(t *testing.T) {
+	require := require.New(t)
+
+	base := flavorBaseConfig()
+	base["jvm21-server.options"] = &fstest.MapFile{Data: []byte("-XX:+UseG1GC\n")}
+
+	configInput := &ConfigInput{
+		ServerType: ServerTypeHCD,
+		ConfigOverrides: ConfigOverrides{
+			CassYaml: map[string]interface{}{"node_proximity": "NetworkTopologyProximity"},
+		},
+		PodOverrides: map[string]ConfigOverrides{
+			"pod-0": {ServerOptions21: map[string]interface{}{"max_heap_size": "1G"}},
+		},
+	}
+	docs, _, err := Render(configInput, &NodeInfo{Name: "pod-0", PodIPs: []net.IP{net.ParseIP("10.0.0.1")}}, base, nil)
+	require.NoError(err)
+
+	cassYaml := renderedYaml(t, docs, "cassandra.yaml")
+	require.Equal(16, cassYaml["num_tokens"])
+	require.Equal("GossipingPropertyFileSnitch", cassYaml["endpoint_snitch"])
+	require.NotContains(cassYaml, "node_proximity")
+	require.NotContains(cassYaml, "authenticator")
+
+	options21, found := docs.Get("jvm21-server.options")
+	require.True(found)
+	require.Contains(string(options21), "-Xmx1G")
+
+	_, found = docs.Get(dseConfigName)
+	require.False(found)
+
+	configInput.PodOverrides = nil
+	configInput.DseYaml = map[string]interface{}{"server_id": "hcd"}
+	_, _, err = Render(configInput, &NodeInfo{}, flavorBaseConfig(), nil)
+	require.ErrorContains(err, "dse-yaml is not supported by server-type hcd")
+}
+
+func TestRenderCassandraFlavor(t *testing.T) {
+	require := require.New(t)
+
+	configInput := &ConfigInput{
+		ConfigOverrides: ConfigOverrides{
+			CassYaml: map[string]interface{}{"node_proximity": "NetworkTopologyProximity"},
+		},
+	}
+	docs, manifest, err := Render(configInput, &NodeInfo{PodIPs: []net.IP{net.ParseIP("10.0.0.1")}}, flavorBaseConfig(), nil)
+	require.NoError(err)
+
+	// Cassandra 5.1 reads the rack with the location provider and has no flavor defaults
+	cassYaml := renderedYaml(t, docs, "cassandra.yaml")
+	require.Equal(8, cassYaml["num_tokens"])
+	require.Equal(rackDCLocationProvider, cassYaml["initial_location_provider"])
+	require.NotContains(cassYaml, "endpoint_snitch")
+	require.NotContains(cassYaml, "authenticator")
+
+	for _, f := range manifest.Files {
+		if f.Name == "cassandra.yaml" {
+			require.Equal([]string{LayerBase, "cassandra-yaml", LayerK8ssandra}, f.Layers)
+		}
+	}
+}
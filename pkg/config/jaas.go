@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const jaasConfigName = "cassandra-jaas.config"
+
+var (
+	jaasFlags      = []string{"REQUIRED", "REQUISITE", "SUFFICIENT", "OPTIONAL"}
+	jaasEntryRegex = regexp.MustCompile(`(?s)([A-Za-z0-9_.\-]+)\s*\{.*?\}\s*;`)
+)
+
+// createJAASConfig replaces or adds the login configuration entries in cassandra-jaas.config. Entries of the base
+// file which are not overridden are kept as is. Without any options the base file is copied.
+func createJAASConfig(configInput *ConfigInput, base fs.FS, out Output) error {
+	baseFile, err := fs.ReadFile(base, jaasConfigName)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if len(configInput.JAAS.Entries) == 0 {
+		if baseFile == nil {
+			return nil
+		}
+		return out.WriteFile(jaasConfigName, baseFile, 0660)
+	}
+
+	rendered, err := renderJAASConfig(string(baseFile), configInput.JAAS.Entries)
+	if err != nil {
+		return err
+	}
+
+	return out.WriteFile(jaasConfigName, []byte(rendered), 0660)
+}
+
+func renderJAASConfig(base string, entries map[string][]JAASLoginModule) (string, error) {
+	rendered := make(map[string]string, len(entries))
+	for name, modules := range entries {
+		entry, err := renderJAASEntry(name, modules)
+		if err != nil {
+			return "", err
+		}
+		rendered[name] = entry
+	}
+
+	replaced := make(map[string]bool, len(entries))
+	out := jaasEntryRegex.ReplaceAllStringFunc(base, func(existing string) string {
+		name := jaasEntryRegex.FindStringSubmatch(existing)[1]
+		if entry, found := rendered[name]; found {
+			replaced[name] = true
+			return strings.TrimSuffix(entry, "\n")
+		}
+		return existing
+	})
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		if !replaced[name] {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var sb strings.Builder
+	sb.WriteString(out)
+	for _, name := range names {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(rendered[name])
+	}
+
+	return sb.String(), nil
+}
+
+func renderJAASEntry(name string, modules []JAASLoginModule) (string, error) {
+	if len(modules) == 0 {
+		return "", fmt.Errorf("cassandra-jaas.config entry %s requires at least one login module", name)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s {\n", name)
+	for _, module := range modules {
+		if module.Class == "" {
+			return "", fmt.Errorf("cassandra-jaas.config entry %s has a login module without a class", name)
+		}
+
+		flag := strings.ToUpper(module.Flag)
+		if !slices.Contains(jaasFlags, flag) {
+			return "", fmt.Errorf("unknown flag %s for login module %s, supported values are %s", module.Flag, module.Class, strings.Join(jaasFlags, ", "))
+		}
+
+		fmt.Fprintf(&sb, "  %s %s", module.Class, flag)
+
+		keys := make([]string, 0, len(module.Options))
+		for k := range module.Options {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+
+		for _, k := range keys {
+			fmt.Fprintf(&sb, "\n    %s=%q", k, module.Options[k])
+		}
+		sb.WriteString(";\n")
+	}
+	sb.WriteString("};\n")
+
+	return sb.String(), nil
+}
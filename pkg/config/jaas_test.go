@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8ssandra/k8ssandra-client/internal/envtest"
+	"github.com/stretchr/testify/require"
+)
+
+var jaasConfig = `
+{
+	"cassandra-jaas-config": {
+		"entries": {
+			"CassandraLogin": [
+				{
+					"class": "com.example.auth.LdapLoginModule",
+					"flag": "sufficient",
+					"options": {
+						"url": "ldaps://ldap.example.com",
+						"debug": "true"
+					}
+				},
+				{
+					"class": "org.apache.cassandra.auth.CassandraLoginModule",
+					"flag": "required"
+				}
+			],
+			"KafkaClient": [
+				{
+					"class": "org.apache.kafka.common.security.plain.PlainLoginModule",
+					"flag": "required"
+				}
+			]
+		}
+	}
+}
+`
+
+func TestJAASConfig(t *testing.T) {
+	require := require.New(t)
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	t.Setenv("CONFIG_FILE_DATA", jaasConfig)
+	configInput, err := parseConfigInput()
+	require.NoError(err)
+
+	require.NoError(createJAASConfig(configInput, os.DirFS(inputDir), DirOutput(tempDir)))
+
+	b, err := os.ReadFile(filepath.Join(tempDir, jaasConfigName))
+	require.NoError(err)
+
+	expected := `// Delegates authentication to Cassandra's configured IAuthenticator
+CassandraLogin {
+  com.example.auth.LdapLoginModule SUFFICIENT
+    debug="true"
+    url="ldaps://ldap.example.com";
+  org.apache.cassandra.auth.CassandraLoginModule REQUIRED;
+};
+KafkaClient {
+  org.apache.kafka.common.security.plain.PlainLoginModule REQUIRED;
+};
+`
+	require.Equal(expected, string(b))
+}
+
+func TestJAASConfigCopiedWithoutOverrides(t *testing.T) {
+	require := require.New(t)
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	require.NoError(createJAASConfig(&ConfigInput{}, os.DirFS(inputDir), DirOutput(tempDir)))
+
+	orig, err := os.ReadFile(filepath.Join(inputDir, jaasConfigName))
+	require.NoError(err)
+	copied, err := os.ReadFile(filepath.Join(tempDir, jaasConfigName))
+	require.NoError(err)
+	require.Equal(orig, copied)
+}
+
+func TestJAASConfigInvalid(t *testing.T) {
+	require := require.New(t)
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+
+	invalid := []map[string][]JAASLoginModule{
+		{"CassandraLogin": {}},
+		{"CassandraLogin": {{Flag: "required"}}},
+		{"CassandraLogin": {{Class: "org.apache.cassandra.auth.CassandraLoginModule", Flag: "mandatory"}}},
+	}
+
+	for _, entries := range invalid {
+		require.Error(createJAASConfig(&ConfigInput{JAAS: JAASOptions{Entries: entries}}, os.DirFS(inputDir), DirOutput(t.TempDir())))
+	}
+}
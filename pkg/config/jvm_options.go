@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	metadata "github.com/burmanm/definitions-parser/pkg/types"
+)
+
+// Alias tables for the jvm17-server.options and jvm21-server.options. The definitions-parser only generates tables
+// up to JDK11, these follow the same format.
+var (
+	jvmModernServerOptionsPrefix = map[string]metadata.Metadata{
+		// Heap and memory
+		"initial_heap_size":         {Key: "-Xms", BuilderType: metadata.StringBuilder, ValueType: metadata.SuppressedValue},
+		"max_heap_size":             {Key: "-Xmx", BuilderType: metadata.StringBuilder, ValueType: metadata.SuppressedValue},
+		"young_generation_size":     {Key: "-Xmn", BuilderType: metadata.StringBuilder, ValueType: metadata.SuppressedValue},
+		"per_thread_stack_size":     {Key: "-Xss", BuilderType: metadata.StringBuilder, ValueType: metadata.SuppressedValue},
+		"max_direct_memory_size":    {Key: "-XX:MaxDirectMemorySize", BuilderType: metadata.StringBuilder, ValueType: metadata.StringValue},
+		"max_metaspace_size":        {Key: "-XX:MaxMetaspaceSize", BuilderType: metadata.StringBuilder, ValueType: metadata.StringValue},
+		"soft_max_heap_size":        {Key: "-XX:SoftMaxHeapSize", BuilderType: metadata.StringBuilder, ValueType: metadata.StringValue},
+		"always_pre_touch":          {Key: "-XX:+AlwaysPreTouch", BuilderType: metadata.BooleanBuilder, ValueType: metadata.StaticConstant},
+		"use_numa":                  {Key: "-XX:+UseNUMA", BuilderType: metadata.BooleanBuilder, ValueType: metadata.StaticConstant},
+		"use_transparent_hugepages": {Key: "-XX:+UseTransparentHugePages", BuilderType: metadata.BooleanBuilder, ValueType: metadata.StaticConstant},
+
+		// Common GC settings
+		"garbage_collector":      {Key: "", BuilderType: metadata.StringBuilder, ValueType: metadata.TemplateValue},
+		"parallel_gc_threads":    {Key: "-XX:ParallelGCThreads", BuilderType: metadata.IntegerBuilder, ValueType: metadata.StringValue},
+		"conc_gc_threads":        {Key: "-XX:ConcGCThreads", BuilderType: metadata.IntegerBuilder, ValueType: metadata.StringValue},
+		"max_gc_pause_millis":    {Key: "-XX:MaxGCPauseMillis", BuilderType: metadata.IntegerBuilder, ValueType: metadata.StringValue},
+		"max_tenuring_threshold": {Key: "-XX:MaxTenuringThreshold", BuilderType: metadata.IntegerBuilder, ValueType: metadata.StringValue},
+
+		// G1
+		"g1_heap_region_size":               {Key: "-XX:G1HeapRegionSize", BuilderType: metadata.StringBuilder, ValueType: metadata.StringValue},
+		"initiating_heap_occupancy_percent": {Key: "-XX:InitiatingHeapOccupancyPercent", BuilderType: metadata.IntegerBuilder, ValueType: metadata.StringValue},
+		"parallel_ref_proc_enabled":         {Key: "-XX:+ParallelRefProcEnabled", BuilderType: metadata.BooleanBuilder, ValueType: metadata.StaticConstant},
+
+		// ZGC
+		"z_collection_interval":        {Key: "-XX:ZCollectionInterval", BuilderType: metadata.IntegerBuilder, ValueType: metadata.StringValue},
+		"z_allocation_spike_tolerance": {Key: "-XX:ZAllocationSpikeTolerance", BuilderType: metadata.StringBuilder, ValueType: metadata.StringValue},
+		"z_uncommit_delay":             {Key: "-XX:ZUncommitDelay", BuilderType: metadata.IntegerBuilder, ValueType: metadata.StringValue},
+
+		// Shenandoah
+		"shenandoah_gc_heuristics": {Key: "-XX:ShenandoahGCHeuristics", BuilderType: metadata.StringBuilder, ValueType: metadata.StringValue},
+		"shenandoah_gc_mode":       {Key: "-XX:ShenandoahGCMode", BuilderType: metadata.StringBuilder, ValueType: metadata.StringValue},
+
+		// Cassandra requirements
+		"jdk_attach_allow_attach_self":           {Key: "-Djdk.attach.allowAttachSelf=true", BuilderType: metadata.BooleanBuilder, ValueType: metadata.StaticConstant},
+		"io_netty_try_reflection_set_accessible": {Key: "-Dio.netty.tryReflectionSetAccessible=true", BuilderType: metadata.BooleanBuilder, ValueType: metadata.StaticConstant},
+	}
+
+	Jvm17ServerOptionsPrefix = withJVMOptions(jvmModernServerOptionsPrefix, map[string]metadata.Metadata{
+		// Obsoleted in JDK20
+		"g1r_set_updating_pause_time_percent": {Key: "-XX:G1RSetUpdatingPauseTimePercent", BuilderType: metadata.IntegerBuilder, ValueType: metadata.StringValue},
+	})
+
+	Jvm21ServerOptionsPrefix = withJVMOptions(jvmModernServerOptionsPrefix, map[string]metadata.Metadata{
+		"z_generational": {Key: "-XX:+ZGenerational", BuilderType: metadata.BooleanBuilder, ValueType: metadata.StaticConstant},
+	})
+)
+
+func withJVMOptions(base, additional map[string]metadata.Metadata) map[string]metadata.Metadata {
+	options := maps.Clone(base)
+	maps.Copy(options, additional)
+	return options
+}
+
+// strictJVMOptionFiles reject the keys missing from their alias table. The older files ignore unknown keys to keep
+// the existing configurations working.
+var strictJVMOptionFiles = []string{"jvm17-server.options", "jvm21-server.options"}
+
+// validateJVMOptions checks that every key has an alias and that the value fits the alias type
+func validateJVMOptions(options map[string]interface{}, filename string) error {
+	if !slices.Contains(strictJVMOptionFiles, filename) {
+		return nil
+	}
+
+	aliases := optionsFilenameToMap(filename)
+	section := strings.TrimSuffix(filename, ".options") + "-options"
+
+	for _, k := range slices.Sorted(maps.Keys(options)) {
+		if k == "additional-jvm-opts" {
+			continue
+		}
+
+		alias, found := aliases[k]
+		if !found {
+			return fmt.Errorf("unknown option %s in %s, supported options are: %s", k, section, strings.Join(slices.Sorted(maps.Keys(aliases)), ", "))
+		}
+
+		value := fmt.Sprintf("%v", options[k])
+		switch alias.BuilderType {
+		case metadata.IntegerBuilder:
+			if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+				return fmt.Errorf("option %s in %s must be an integer, got %s", k, section, value)
+			}
+		case metadata.BooleanBuilder:
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("option %s in %s must be a boolean, got %s", k, section, value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// jvmAliasOutput renders the alias value of the strictJVMOptionFiles as a JVM option, an empty string means the option
+// is not written. Boolean flags are only written when set to true, Metadata.Output never writes them.
+func jvmAliasOutput(alias metadata.Metadata, value string) string {
+	if alias.ValueType == metadata.StaticConstant {
+		if set, err := strconv.ParseBool(value); err == nil && set {
+			return alias.Key
+		}
+		return ""
+	}
+	return alias.Output(value)
+}
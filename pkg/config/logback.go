@@ -0,0 +1,191 @@
+package config
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+)
+
+const logbackConfigName = "logback.xml"
+
+var logbackLevels = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "OFF", "ALL", "INHERITED", "NULL"}
+
+// xmlNode is a generic XML element, comments are not preserved
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",chardata"`
+	Nodes   []*xmlNode `xml:",any"`
+}
+
+func (n *xmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n *xmlNode) setAttr(name, value string) {
+	for i, a := range n.Attrs {
+		if a.Name.Local == name {
+			n.Attrs[i].Value = value
+			return
+		}
+	}
+	n.Attrs = append(n.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+}
+
+func (n *xmlNode) children(name string) []*xmlNode {
+	found := make([]*xmlNode, 0, 1)
+	for _, c := range n.Nodes {
+		if c.XMLName.Local == name {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// trimWhitespace removes the formatting whitespace, so that the output can be indented again
+func (n *xmlNode) trimWhitespace() {
+	if len(n.Nodes) > 0 && strings.TrimSpace(n.Content) == "" {
+		n.Content = ""
+	}
+	for _, c := range n.Nodes {
+		c.trimWhitespace()
+	}
+}
+
+func newXMLNode(name string, attrs ...string) *xmlNode {
+	n := &xmlNode{XMLName: xml.Name{Local: name}}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		n.setAttr(attrs[i], attrs[i+1])
+	}
+	return n
+}
+
+func validateLogbackLevel(level string) (string, error) {
+	upper := strings.ToUpper(level)
+	if !slices.Contains(logbackLevels, upper) {
+		return "", fmt.Errorf("unknown logback level %s", level)
+	}
+	return upper, nil
+}
+
+// createLogbackXml applies the LogbackOptions to the base logback.xml. Without any options the file is copied as is.
+func createLogbackXml(configInput *ConfigInput, base fs.FS, out Output) error {
+	opts := configInput.Logback
+	if opts.RootLevel == "" && len(opts.Loggers) == 0 && len(opts.Appenders) == 0 && opts.JSONEncoder == "" {
+		if err := copyBaseFile(base, out, logbackConfigName); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	b, err := fs.ReadFile(base, logbackConfigName)
+	if err != nil {
+		return err
+	}
+
+	rendered, err := renderLogbackXml(b, opts)
+	if err != nil {
+		return err
+	}
+
+	return out.WriteFile(logbackConfigName, rendered, 0660)
+}
+
+func renderLogbackXml(base []byte, opts LogbackOptions) ([]byte, error) {
+	root := &xmlNode{}
+	if err := xml.Unmarshal(base, root); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", logbackConfigName, err)
+	}
+
+	if root.XMLName.Local != "configuration" {
+		return nil, fmt.Errorf("invalid %s: root element is %s, expected configuration", logbackConfigName, root.XMLName.Local)
+	}
+
+	rootLoggers := root.children("root")
+	if len(rootLoggers) == 0 {
+		rootLogger := newXMLNode("root", "level", "INFO")
+		root.Nodes = append(root.Nodes, rootLogger)
+		rootLoggers = append(rootLoggers, rootLogger)
+	}
+	rootLogger := rootLoggers[0]
+
+	if opts.RootLevel != "" {
+		level, err := validateLogbackLevel(opts.RootLevel)
+		if err != nil {
+			return nil, err
+		}
+		rootLogger.setAttr("level", level)
+	}
+
+	if opts.JSONEncoder != "" {
+		for _, appender := range root.children("appender") {
+			for i, c := range appender.Nodes {
+				if c.XMLName.Local == "encoder" {
+					appender.Nodes[i] = newXMLNode("encoder", "class", opts.JSONEncoder)
+				}
+			}
+		}
+	}
+
+	for _, appenderOpts := range opts.Appenders {
+		if appenderOpts.Name == "" || appenderOpts.Class == "" {
+			return nil, fmt.Errorf("logback appender requires a name and a class")
+		}
+
+		for _, existing := range root.children("appender") {
+			if existing.attr("name") == appenderOpts.Name {
+				return nil, fmt.Errorf("logback appender %s already exists", appenderOpts.Name)
+			}
+		}
+
+		appender := &xmlNode{}
+		if err := xml.Unmarshal([]byte(fmt.Sprintf("<appender>%s</appender>", appenderOpts.Config)), appender); err != nil {
+			return nil, fmt.Errorf("invalid config for logback appender %s: %w", appenderOpts.Name, err)
+		}
+		appender.setAttr("name", appenderOpts.Name)
+		appender.setAttr("class", appenderOpts.Class)
+
+		// Appenders must be defined before they're referenced
+		rootIdx := slices.Index(root.Nodes, rootLogger)
+		root.Nodes = slices.Insert(root.Nodes, rootIdx, appender)
+		rootLogger.Nodes = append(rootLogger.Nodes, newXMLNode("appender-ref", "ref", appenderOpts.Name))
+	}
+
+	loggerNames := make([]string, 0, len(opts.Loggers))
+	for name := range opts.Loggers {
+		loggerNames = append(loggerNames, name)
+	}
+	slices.Sort(loggerNames)
+
+loggers:
+	for _, name := range loggerNames {
+		level, err := validateLogbackLevel(opts.Loggers[name])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, logger := range root.children("logger") {
+			if logger.attr("name") == name {
+				logger.setAttr("level", level)
+				continue loggers
+			}
+		}
+		root.Nodes = append(root.Nodes, newXMLNode("logger", "name", name, "level", level))
+	}
+
+	root.trimWhitespace()
+	out, err := xml.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out, '\n'), nil
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8ssandra/k8ssandra-client/internal/envtest"
+	"github.com/stretchr/testify/require"
+)
+
+var logbackConfig = `
+{
+	"logback-xml": {
+		"root-level": "warn",
+		"loggers": {
+			"org.apache.cassandra": "info",
+			"org.apache.cassandra.db.compaction": "debug"
+		},
+		"json-encoder": "ch.qos.logback.classic.encoder.JsonEncoder",
+		"appenders": [
+			{
+				"name": "SYSLOG",
+				"class": "ch.qos.logback.classic.net.SyslogAppender",
+				"config": "<syslogHost>syslog.example.com</syslogHost><facility>USER</facility>"
+			}
+		]
+	}
+}
+`
+
+func TestLogbackXml(t *testing.T) {
+	require := require.New(t)
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	t.Setenv("CONFIG_FILE_DATA", logbackConfig)
+	configInput, err := parseConfigInput()
+	require.NoError(err)
+
+	require.NoError(createLogbackXml(configInput, os.DirFS(inputDir), DirOutput(tempDir)))
+
+	b, err := os.ReadFile(filepath.Join(tempDir, logbackConfigName))
+	require.NoError(err)
+
+	root := &xmlNode{}
+	require.NoError(xml.Unmarshal(b, root))
+
+	require.Equal("true", root.attr("scan"))
+	rootLogger := root.children("root")[0]
+	require.Equal("WARN", rootLogger.attr("level"))
+
+	refs := make([]string, 0)
+	for _, ref := range rootLogger.children("appender-ref") {
+		refs = append(refs, ref.attr("ref"))
+	}
+	require.Equal([]string{"SYSTEMLOG", "STDOUT", "ASYNCDEBUGLOG", "SYSLOG"}, refs)
+
+	loggers := make(map[string]string)
+	for _, logger := range root.children("logger") {
+		loggers[logger.attr("name")] = logger.attr("level")
+	}
+	require.Equal(map[string]string{"org.apache.cassandra": "INFO", "org.apache.cassandra.db.compaction": "DEBUG"}, loggers)
+
+	appenders := make(map[string]*xmlNode)
+	for _, appender := range root.children("appender") {
+		appenders[appender.attr("name")] = appender
+	}
+	require.Len(appenders, 5)
+	require.Equal("ch.qos.logback.classic.encoder.JsonEncoder", appenders["STDOUT"].children("encoder")[0].attr("class"))
+	require.Empty(appenders["STDOUT"].children("encoder")[0].children("pattern"))
+	require.Equal("INFO", appenders["STDOUT"].children("filter")[0].children("level")[0].Content)
+	require.Equal("syslog.example.com", appenders["SYSLOG"].children("syslogHost")[0].Content)
+	require.Equal("ch.qos.logback.classic.net.SyslogAppender", appenders["SYSLOG"].attr("class"))
+	require.Equal("${cassandra.logdir}/system.log", appenders["SYSTEMLOG"].children("file")[0].Content)
+}
+
+func TestLogbackXmlCopiedWithoutOverrides(t *testing.T) {
+	require := require.New(t)
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	require.NoError(createLogbackXml(&ConfigInput{}, os.DirFS(inputDir), DirOutput(tempDir)))
+
+	orig, err := os.ReadFile(filepath.Join(inputDir, logbackConfigName))
+	require.NoError(err)
+	copied, err := os.ReadFile(filepath.Join(tempDir, logbackConfigName))
+	require.NoError(err)
+	require.Equal(orig, copied)
+
+	// Missing base file is not an error without overrides
+	require.NoError(createLogbackXml(&ConfigInput{}, os.DirFS(t.TempDir()), DirOutput(tempDir)))
+}
+
+func TestLogbackXmlInvalidOptions(t *testing.T) {
+	require := require.New(t)
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+
+	invalid := []LogbackOptions{
+		{RootLevel: "verbose"},
+		{Loggers: map[string]string{"org.apache.cassandra": "loud"}},
+		{Appenders: []LogbackAppender{{Name: "STDOUT", Class: "ch.qos.logback.core.ConsoleAppender"}}},
+		{Appenders: []LogbackAppender{{Name: "NOCLASS"}}},
+		{Appenders: []LogbackAppender{{Name: "BROKEN", Class: "ch.qos.logback.core.ConsoleAppender", Config: "<unclosed>"}}},
+	}
+
+	for _, opts := range invalid {
+		require.Error(createLogbackXml(&ConfigInput{Logback: opts}, os.DirFS(inputDir), DirOutput(t.TempDir())))
+	}
+}
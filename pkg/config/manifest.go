@@ -0,0 +1,167 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// ManifestFileName is written to the output directory next to the generated files
+	ManifestFileName = "k8ssandra-config-manifest.json"
+
+	LayerBase         = "base"
+	LayerCopied       = "copied"
+	LayerK8ssandra    = "k8ssandra"
+	LayerPodOverrides = "pod-overrides"
+)
+
+// Manifest lists the generated files with their checksums. Comparing the checksum of two manifests tells if the
+// effective configuration has changed.
+type Manifest struct {
+	Node     string         `json:"node,omitempty"`
+	Checksum string         `json:"checksum"`
+	Files    []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Name string `json:"name"`
+	// Source is the base config file the output was generated from, empty if there was none. Render records it
+	// relative to the base config, Builder prefixes it with the input directory.
+	Source   string `json:"source,omitempty"`
+	Checksum string `json:"checksum"`
+	// Layers are the ConfigInput sections and other inputs which modified the file, in the order they were applied
+	Layers []string `json:"layers"`
+}
+
+// manifestBuilder records the files while they're generated, checksums are calculated when the build is done
+type manifestBuilder struct {
+	base  fs.FS
+	files []ManifestFile
+}
+
+func newManifestBuilder(base fs.FS) *manifestBuilder {
+	return &manifestBuilder{base: base}
+}
+
+// add records a generated file. Only files which were rendered are included in the manifest.
+func (m *manifestBuilder) add(name, sourceName string, layers ...string) {
+	source := ""
+	if sourceName != "" {
+		if _, err := fs.Stat(m.base, sourceName); err == nil {
+			source = sourceName
+			layers = append([]string{LayerBase}, layers...)
+		}
+	}
+
+	m.files = append(m.files, ManifestFile{
+		Name:   name,
+		Source: source,
+		Layers: slices.DeleteFunc(layers, func(s string) bool { return s == "" }),
+	})
+}
+
+func (m *manifestBuilder) build(node string, docs *Documents) *Manifest {
+	manifest := &Manifest{
+		Node:  node,
+		Files: make([]ManifestFile, 0, len(m.files)),
+	}
+
+	for _, f := range m.files {
+		data, found := docs.Get(f.Name)
+		if !found {
+			continue
+		}
+		f.Checksum = dataChecksum(data)
+		manifest.Files = append(manifest.Files, f)
+	}
+
+	slices.SortFunc(manifest.Files, func(a, b ManifestFile) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	h := sha256.New()
+	for _, f := range manifest.Files {
+		fmt.Fprintf(h, "%s:%s\n", f.Name, f.Checksum)
+	}
+	manifest.Checksum = "sha256:" + hex.EncodeToString(h.Sum(nil))
+
+	return manifest
+}
+
+// layer returns the name if the condition is true, empty names are not recorded
+func layer(name string, condition bool) string {
+	if condition {
+		return name
+	}
+	return ""
+}
+
+func dataChecksum(data []byte) string {
+	h := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+func writeManifest(manifest *Manifest, targetDir string) error {
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(targetDir, ManifestFileName), append(b, '\n'), 0660)
+}
+
+// ReadManifest reads the manifest from the output directory of a previous build, returns nil if there is none
+func ReadManifest(targetDir string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(targetDir, ManifestFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(b, manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestFileName, err)
+	}
+
+	return manifest, nil
+}
+
+// Changed returns the names of the files which were added, removed or modified compared to the previous manifest
+func (m *Manifest) Changed(previous *Manifest) []string {
+	if previous == nil {
+		names := make([]string, 0, len(m.Files))
+		for _, f := range m.Files {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+
+	checksums := make(map[string]string, len(previous.Files))
+	for _, f := range previous.Files {
+		checksums[f.Name] = f.Checksum
+	}
+
+	changed := make([]string, 0)
+	for _, f := range m.Files {
+		if checksum, found := checksums[f.Name]; !found || checksum != f.Checksum {
+			changed = append(changed, f.Name)
+		}
+		delete(checksums, f.Name)
+	}
+
+	for name := range checksums {
+		changed = append(changed, name)
+	}
+
+	slices.Sort(changed)
+	return changed
+}
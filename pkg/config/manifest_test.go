@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8ssandra/k8ssandra-client/internal/envtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildWritesManifest(t *testing.T) {
+	require := require.New(t)
+	t.Setenv("CONFIG_FILE_DATA", existingConfig)
+	t.Setenv("POD_IP", "172.27.0.1")
+	t.Setenv("RACK_NAME", "r1")
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	require.NoError(NewBuilder(inputDir, tempDir).Build(t.Context()))
+
+	manifest, err := ReadManifest(tempDir)
+	require.NoError(err)
+	require.NotNil(manifest)
+	require.NotEmpty(manifest.Checksum)
+
+	files := make(map[string]ManifestFile)
+	for _, f := range manifest.Files {
+		files[f.Name] = f
+		data, err := os.ReadFile(filepath.Join(tempDir, f.Name))
+		require.NoError(err)
+		require.Equal(dataChecksum(data), f.Checksum)
+	}
+
+	require.NotContains(files, ManifestFileName)
+
+	cassYaml := files["cassandra.yaml"]
+	require.Equal(filepath.Join(inputDir, latestCassandraConfigName), cassYaml.Source)
+	require.Equal([]string{LayerBase, "cassandra-yaml", LayerK8ssandra}, cassYaml.Layers)
+
+	require.Equal([]string{LayerBase, "cassandra-env-sh"}, files["cassandra-env.sh"].Layers)
+	require.Equal([]string{LayerBase, "jvm11-server-options"}, files["jvm11-server.options"].Layers)
+	require.Equal([]string{LayerBase}, files["jvm17-server.options"].Layers)
+	require.Equal([]string{LayerBase}, files["jvm21-server.options"].Layers)
+	require.Equal([]string{LayerK8ssandra}, files["cassandra-rackdc.properties"].Layers)
+	require.Empty(files["cassandra-rackdc.properties"].Source)
+	require.Equal([]string{LayerBase, LayerCopied}, files["jvm11-clients.options"].Layers)
+	require.Equal([]string{LayerBase}, files[logbackConfigName].Layers)
+
+	// Same input produces the same checksums
+	tempDir2 := t.TempDir()
+	require.NoError(NewBuilder(inputDir, tempDir2).Build(t.Context()))
+
+	manifest2, err := ReadManifest(tempDir2)
+	require.NoError(err)
+	require.Equal(manifest.Checksum, manifest2.Checksum)
+	require.Empty(manifest2.Changed(manifest))
+}
+
+func TestBuildDryRun(t *testing.T) {
+	require := require.New(t)
+	t.Setenv("CONFIG_FILE_DATA", existingConfig)
+	t.Setenv("POD_IP", "172.27.0.1")
+	t.Setenv("RACK_NAME", "r1")
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	require.NoError(NewBuilder(inputDir, tempDir, WithDryRun()).Build(t.Context()))
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(err)
+	require.Empty(entries)
+
+	// Errors are still reported
+	t.Setenv("CONFIG_FILE_DATA", "{")
+	require.Error(NewBuilder(inputDir, tempDir, WithDryRun()).Build(t.Context()))
+}
+
+func TestManifestChanged(t *testing.T) {
+	require := require.New(t)
+
+	previous := &Manifest{Files: []ManifestFile{
+		{Name: "cassandra.yaml", Checksum: "sha256:a"},
+		{Name: "jvm11-server.options", Checksum: "sha256:b"},
+		{Name: "logback.xml", Checksum: "sha256:c"},
+	}}
+
+	current := &Manifest{Files: []ManifestFile{
+		{Name: "cassandra-env.sh", Checksum: "sha256:d"},
+		{Name: "cassandra.yaml", Checksum: "sha256:a"},
+		{Name: "jvm11-server.options", Checksum: "sha256:e"},
+	}}
+
+	require.Equal([]string{"cassandra-env.sh", "jvm11-server.options", "logback.xml"}, current.Changed(previous))
+	require.Equal([]string{"cassandra-env.sh", "cassandra.yaml", "jvm11-server.options"}, current.Changed(nil))
+	require.Empty(current.Changed(current))
+}
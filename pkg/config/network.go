@@ -0,0 +1,232 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	NetworkModePodIP      = "pod-ip"
+	NetworkModeHostIP     = "host-ip"
+	NetworkModeServiceIP  = "service-ip"
+	NetworkModeExternalIP = "external-ip"
+	NetworkModeDNS        = "dns"
+
+	IPFamilyIPv4 = "ipv4"
+	IPFamilyIPv6 = "ipv6"
+
+	RPCAddressModeWildcard = "wildcard"
+	RPCAddressModeListen   = "listen"
+)
+
+func mergeNetworkingOptions(lowerPriority, higherPriority NetworkingOptions) NetworkingOptions {
+	merged := lowerPriority
+	if higherPriority.BroadcastMode != "" {
+		merged.BroadcastMode = higherPriority.BroadcastMode
+	}
+	if higherPriority.InternodeBroadcastMode != "" {
+		merged.InternodeBroadcastMode = higherPriority.InternodeBroadcastMode
+	}
+	if higherPriority.IPFamily != "" {
+		merged.IPFamily = higherPriority.IPFamily
+	}
+	if higherPriority.RPCAddressMode != "" {
+		merged.RPCAddressMode = higherPriority.RPCAddressMode
+	}
+	if higherPriority.ServiceIP != "" {
+		merged.ServiceIP = higherPriority.ServiceIP
+	}
+	if higherPriority.ExternalIP != "" {
+		merged.ExternalIP = higherPriority.ExternalIP
+	}
+	if higherPriority.Hostname != "" {
+		merged.Hostname = higherPriority.Hostname
+	}
+	if higherPriority.DNSDomain != "" {
+		merged.DNSDomain = higherPriority.DNSDomain
+	}
+	return merged
+}
+
+// parseIPList parses the given comma separated lists of IPs in order, skipping duplicates and invalid values
+func parseIPList(values ...string) []net.IP {
+	ips := make([]net.IP, 0, len(values))
+	for _, value := range values {
+	ipLoop:
+		for _, part := range strings.Split(value, ",") {
+			ip := net.ParseIP(strings.TrimSpace(part))
+			if ip == nil {
+				continue
+			}
+			for _, existing := range ips {
+				if existing.Equal(ip) {
+					continue ipLoop
+				}
+			}
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+func ipFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return IPFamilyIPv4
+	}
+	return IPFamilyIPv6
+}
+
+func wildcardAddress(family string) net.IP {
+	if family == IPFamilyIPv6 {
+		return net.ParseIP(ipv6Local)
+	}
+	return net.ParseIP(ipv4Local)
+}
+
+// addressResolver picks addresses of the preferred family. If the family was requested explicitly, an address of
+// another family is never used.
+type addressResolver struct {
+	family string
+	strict bool
+}
+
+func (r addressResolver) pick(ips []net.IP, source string) (net.IP, error) {
+	if len(ips) == 0 {
+		return nil, nil
+	}
+
+	for _, ip := range ips {
+		if r.family == "" || ipFamily(ip) == r.family {
+			return ip, nil
+		}
+	}
+
+	if r.strict {
+		return nil, fmt.Errorf("no %s address available in %s", r.family, source)
+	}
+
+	return ips[0], nil
+}
+
+// resolveAddresses sets the listen, rpc and broadcast addresses of the node. The overrides take priority over the
+// NetworkingOptions parsed from the environment.
+func (n *NodeInfo) resolveAddresses(overrides NetworkingOptions) error {
+	opts := mergeNetworkingOptions(n.Networking, overrides)
+
+	r := addressResolver{family: opts.IPFamily, strict: opts.IPFamily != ""}
+	switch opts.IPFamily {
+	case "", IPFamilyIPv4, IPFamilyIPv6:
+	default:
+		return fmt.Errorf("unknown ip-family %s, supported values are %s and %s", opts.IPFamily, IPFamilyIPv4, IPFamilyIPv6)
+	}
+
+	listenIP, err := r.pick(n.PodIPs, "POD_IPS")
+	if err != nil {
+		return err
+	}
+	n.ListenIP = listenIP
+
+	if r.family == "" && listenIP != nil {
+		r.family = ipFamily(listenIP)
+	}
+
+	switch opts.RPCAddressMode {
+	case "", RPCAddressModeWildcard:
+		n.RPCIP = nil
+		if r.family != "" {
+			n.RPCIP = wildcardAddress(r.family)
+		}
+	case RPCAddressModeListen:
+		if listenIP == nil {
+			return fmt.Errorf("rpc-address-mode %s requires a valid address in POD_IPS", RPCAddressModeListen)
+		}
+		n.RPCIP = listenIP
+	default:
+		return fmt.Errorf("unknown rpc-address-mode %s, supported values are %s and %s", opts.RPCAddressMode, RPCAddressModeWildcard, RPCAddressModeListen)
+	}
+
+	broadcastMode := opts.BroadcastMode
+	if broadcastMode == "" {
+		broadcastMode = NetworkModePodIP
+	}
+
+	if broadcastMode == NetworkModeHostIP && len(n.HostIPs) == 0 && n.hostIPForBroadcast && overrides.BroadcastMode == "" {
+		// USE_HOST_IP_FOR_BROADCAST without a valid HOST_IP has always left broadcast_rpc_address unset
+		n.BroadcastIP, n.BroadcastHostname = nil, ""
+	} else if n.BroadcastIP, n.BroadcastHostname, err = n.broadcastAddress(broadcastMode, opts, r); err != nil {
+		return err
+	}
+
+	n.InternodeBroadcastIP, n.InternodeBroadcastHostname = nil, ""
+	if opts.InternodeBroadcastMode != "" {
+		if n.InternodeBroadcastIP, n.InternodeBroadcastHostname, err = n.broadcastAddress(opts.InternodeBroadcastMode, opts, r); err != nil {
+			return err
+		}
+		if n.InternodeBroadcastIP.Equal(n.ListenIP) {
+			// Same as the default
+			n.InternodeBroadcastIP = nil
+		}
+	}
+
+	return nil
+}
+
+func (n *NodeInfo) broadcastAddress(mode string, opts NetworkingOptions, r addressResolver) (net.IP, string, error) {
+	var ips []net.IP
+	var source string
+
+	switch mode {
+	case NetworkModePodIP:
+		// Pod IPs are optional for backwards compatibility
+		ip, err := r.pick(n.PodIPs, "POD_IPS")
+		return ip, "", err
+	case NetworkModeHostIP:
+		ips, source = n.HostIPs, "HOST_IPS"
+	case NetworkModeServiceIP:
+		ips, source = parseIPList(opts.ServiceIP), "service-ip"
+	case NetworkModeExternalIP:
+		ips, source = parseIPList(opts.ExternalIP), "external-ip"
+	case NetworkModeDNS:
+		hostname := opts.Hostname
+		if hostname == "" && opts.DNSDomain != "" && n.Name != "" {
+			hostname = fmt.Sprintf("%s.%s", n.Name, strings.TrimPrefix(opts.DNSDomain, "."))
+		}
+		if hostname == "" {
+			return nil, "", fmt.Errorf("broadcast mode %s requires hostname or dns-domain", mode)
+		}
+		return nil, hostname, nil
+	default:
+		return nil, "", fmt.Errorf("unknown broadcast mode %s, supported values are %s", mode,
+			strings.Join([]string{NetworkModePodIP, NetworkModeHostIP, NetworkModeServiceIP, NetworkModeExternalIP, NetworkModeDNS}, ", "))
+	}
+
+	if len(ips) == 0 {
+		return nil, "", fmt.Errorf("broadcast mode %s requires a valid address in %s", mode, source)
+	}
+
+	ip, err := r.pick(ips, source)
+	return ip, "", err
+}
+
+// internodeBroadcastAddress returns the value for broadcast_address or an empty string if it should match listen_address
+func (n *NodeInfo) internodeBroadcastAddress() string {
+	if n.InternodeBroadcastHostname != "" {
+		return n.InternodeBroadcastHostname
+	}
+	if n.InternodeBroadcastIP != nil {
+		return n.InternodeBroadcastIP.String()
+	}
+	return ""
+}
+
+// broadcastRPCAddress returns the value for broadcast_rpc_address
+func (n *NodeInfo) broadcastRPCAddress() string {
+	if n.BroadcastHostname != "" {
+		return n.BroadcastHostname
+	}
+	if n.BroadcastIP != nil {
+		return n.BroadcastIP.String()
+	}
+	return ""
+}
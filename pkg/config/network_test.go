@@ -0,0 +1,206 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8ssandra/k8ssandra-client/internal/envtest"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var networkingConfig = `
+{
+	"networking": {
+		"broadcast-mode": "service-ip",
+		"internode-broadcast-mode": "external-ip",
+		"external-ip": "203.0.113.10"
+	},
+	"pod-overrides": {
+		"cluster1-dc1-r1-sts-0": {
+			"networking": {
+				"service-ip": "198.51.100.7"
+			}
+		}
+	},
+	"cluster-info": {
+		"name": "cluster1",
+		"seeds": "cluster1-seed-service"
+	},
+	"datacenter-info": {
+		"name": "dc1"
+	}
+}
+`
+
+func TestParseIPList(t *testing.T) {
+	require := require.New(t)
+	ips := parseIPList("10.0.0.1", "10.0.0.1, fd00::1,invalid", "")
+	require.Len(ips, 2)
+	require.Equal("10.0.0.1", ips[0].String())
+	require.Equal("fd00::1", ips[1].String())
+}
+
+func TestNetworkingModesIPv4(t *testing.T) {
+	require := require.New(t)
+	t.Setenv("POD_IP", "10.244.1.5")
+	t.Setenv("HOST_IP", "172.18.0.2")
+	t.Setenv("NODE_EXTERNAL_IP", "203.0.113.10")
+	t.Setenv("SERVICE_IP", "198.51.100.7")
+
+	nodeInfo, err := parseNodeInfo()
+	require.NoError(err)
+	require.Equal("10.244.1.5", nodeInfo.ListenIP.String())
+	require.Equal("10.244.1.5", nodeInfo.BroadcastIP.String())
+	require.Equal(ipv4Local, nodeInfo.RPCIP.String())
+	require.Nil(nodeInfo.InternodeBroadcastIP)
+
+	require.NoError(nodeInfo.resolveAddresses(NetworkingOptions{BroadcastMode: NetworkModeServiceIP, InternodeBroadcastMode: NetworkModeExternalIP}))
+	require.Equal("10.244.1.5", nodeInfo.ListenIP.String())
+	require.Equal("198.51.100.7", nodeInfo.broadcastRPCAddress())
+	require.Equal("203.0.113.10", nodeInfo.internodeBroadcastAddress())
+
+	require.NoError(nodeInfo.resolveAddresses(NetworkingOptions{InternodeBroadcastMode: NetworkModeHostIP, RPCAddressMode: RPCAddressModeListen}))
+	require.Equal("10.244.1.5", nodeInfo.broadcastRPCAddress())
+	require.Equal("172.18.0.2", nodeInfo.internodeBroadcastAddress())
+	require.Equal("10.244.1.5", nodeInfo.RPCIP.String())
+
+	// pod-ip for internode is the same as the default
+	require.NoError(nodeInfo.resolveAddresses(NetworkingOptions{InternodeBroadcastMode: NetworkModePodIP}))
+	require.Empty(nodeInfo.internodeBroadcastAddress())
+}
+
+func TestNetworkingModesIPv6(t *testing.T) {
+	require := require.New(t)
+	t.Setenv("POD_IP", "fd00:10:244:4::7")
+	t.Setenv("HOST_IP", "fd00:18::2")
+	t.Setenv("USE_HOST_IP_FOR_BROADCAST", "true")
+
+	nodeInfo, err := parseNodeInfo()
+	require.NoError(err)
+	require.Equal("fd00:10:244:4::7", nodeInfo.ListenIP.String())
+	require.Equal("fd00:18::2", nodeInfo.BroadcastIP.String())
+	require.Equal(ipv6Local, nodeInfo.RPCIP.String())
+
+	// Pod name is required to create the hostname
+	require.Error(nodeInfo.resolveAddresses(NetworkingOptions{BroadcastMode: NetworkModeDNS, DNSDomain: "dc1.example.com"}))
+
+	nodeInfo.Name = "cluster1-dc1-r1-sts-0"
+	require.NoError(nodeInfo.resolveAddresses(NetworkingOptions{BroadcastMode: NetworkModeDNS, InternodeBroadcastMode: NetworkModeDNS, DNSDomain: "dc1.example.com"}))
+	require.Equal("cluster1-dc1-r1-sts-0.dc1.example.com", nodeInfo.broadcastRPCAddress())
+	require.Equal("cluster1-dc1-r1-sts-0.dc1.example.com", nodeInfo.internodeBroadcastAddress())
+	require.Nil(nodeInfo.BroadcastIP)
+
+	require.NoError(nodeInfo.resolveAddresses(NetworkingOptions{BroadcastMode: NetworkModeDNS, Hostname: "node0.example.com"}))
+	require.Equal("node0.example.com", nodeInfo.broadcastRPCAddress())
+}
+
+func TestNetworkingModesDualStack(t *testing.T) {
+	require := require.New(t)
+	t.Setenv("POD_IP", "10.244.1.5")
+	t.Setenv("POD_IPS", "10.244.1.5,fd00:10:244:1::5")
+	t.Setenv("HOST_IPS", "172.18.0.2,fd00:18::2")
+
+	nodeInfo, err := parseNodeInfo()
+	require.NoError(err)
+	require.Len(nodeInfo.PodIPs, 2)
+	require.Equal("10.244.1.5", nodeInfo.ListenIP.String())
+	require.Equal(ipv4Local, nodeInfo.RPCIP.String())
+
+	require.NoError(nodeInfo.resolveAddresses(NetworkingOptions{IPFamily: IPFamilyIPv6, BroadcastMode: NetworkModeHostIP}))
+	require.Equal("fd00:10:244:1::5", nodeInfo.ListenIP.String())
+	require.Equal(ipv6Local, nodeInfo.RPCIP.String())
+	require.Equal("fd00:18::2", nodeInfo.BroadcastIP.String())
+
+	// Service has only IPv4 address, but we requested IPv6
+	require.Error(nodeInfo.resolveAddresses(NetworkingOptions{IPFamily: IPFamilyIPv6, BroadcastMode: NetworkModeServiceIP, ServiceIP: "198.51.100.7"}))
+
+	// Without explicit preference we fall back to the available family
+	require.NoError(nodeInfo.resolveAddresses(NetworkingOptions{BroadcastMode: NetworkModeServiceIP, ServiceIP: "fd00:96::7"}))
+	require.Equal("10.244.1.5", nodeInfo.ListenIP.String())
+	require.Equal("fd00:96::7", nodeInfo.BroadcastIP.String())
+
+	t.Setenv("IP_FAMILY", "ipv6")
+	nodeInfo, err = parseNodeInfo()
+	require.NoError(err)
+	require.Equal("fd00:10:244:1::5", nodeInfo.ListenIP.String())
+	require.Equal("fd00:10:244:1::5", nodeInfo.BroadcastIP.String())
+}
+
+func TestNetworkingModesErrors(t *testing.T) {
+	require := require.New(t)
+	t.Setenv("POD_IP", "10.244.1.5")
+
+	nodeInfo, err := parseNodeInfo()
+	require.NoError(err)
+
+	require.Error(nodeInfo.resolveAddresses(NetworkingOptions{BroadcastMode: "unknown"}))
+	require.Error(nodeInfo.resolveAddresses(NetworkingOptions{IPFamily: "ipv5"}))
+	require.Error(nodeInfo.resolveAddresses(NetworkingOptions{RPCAddressMode: "unknown"}))
+	require.Error(nodeInfo.resolveAddresses(NetworkingOptions{BroadcastMode: NetworkModeHostIP}))
+	require.Error(nodeInfo.resolveAddresses(NetworkingOptions{BroadcastMode: NetworkModeServiceIP}))
+	require.Error(nodeInfo.resolveAddresses(NetworkingOptions{InternodeBroadcastMode: NetworkModeExternalIP}))
+	require.Error(nodeInfo.resolveAddresses(NetworkingOptions{BroadcastMode: NetworkModeDNS}))
+	require.Error(nodeInfo.resolveAddresses(NetworkingOptions{IPFamily: IPFamilyIPv6}))
+
+	t.Setenv("BROADCAST_MODE", "unknown")
+	_, err = parseNodeInfo()
+	require.Error(err)
+
+	t.Setenv("POD_IP", "")
+	t.Setenv("BROADCAST_MODE", "")
+	nodeInfo, err = parseNodeInfo()
+	require.NoError(err)
+	require.EqualError(nodeInfo.resolveAddresses(NetworkingOptions{RPCAddressMode: RPCAddressModeListen}), "rpc-address-mode listen requires a valid address in POD_IPS")
+}
+
+func TestNetworkingLegacyHostIP(t *testing.T) {
+	require := require.New(t)
+	t.Setenv("POD_IP", "10.244.1.5")
+	t.Setenv("USE_HOST_IP_FOR_BROADCAST", "true")
+
+	// Without a valid HOST_IP the broadcast address is left unset as before the broadcast modes
+	for _, hostIP := range []string{"", "not-an-ip"} {
+		t.Setenv("HOST_IP", hostIP)
+		nodeInfo, err := parseNodeInfo()
+		require.NoError(err, hostIP)
+		require.Nil(nodeInfo.BroadcastIP, hostIP)
+		require.Empty(nodeInfo.broadcastRPCAddress(), hostIP)
+
+		// Unlike the explicit mode
+		require.Error(nodeInfo.resolveAddresses(NetworkingOptions{InternodeBroadcastMode: NetworkModeHostIP}))
+	}
+
+	t.Setenv("HOST_IP", "172.18.0.2")
+	nodeInfo, err := parseNodeInfo()
+	require.NoError(err)
+	require.Equal("172.18.0.2", nodeInfo.broadcastRPCAddress())
+}
+
+func TestNetworkingBuild(t *testing.T) {
+	require := require.New(t)
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	t.Setenv("CONFIG_FILE_DATA", networkingConfig)
+	t.Setenv("POD_NAME", "cluster1-dc1-r1-sts-0")
+	t.Setenv("POD_IP", "10.244.1.5")
+	t.Setenv("RACK_NAME", "r1")
+
+	require.NoError(NewBuilder(inputDir, tempDir).Build(t.Context()))
+
+	yamlFile, err := os.ReadFile(filepath.Join(tempDir, "cassandra.yaml"))
+	require.NoError(err)
+
+	cassandraYaml := make(map[string]any)
+	require.NoError(yaml.Unmarshal(yamlFile, cassandraYaml))
+
+	require.Equal("10.244.1.5", cassandraYaml["listen_address"])
+	require.Equal("203.0.113.10", cassandraYaml["broadcast_address"])
+	require.Equal("198.51.100.7", cassandraYaml["broadcast_rpc_address"])
+
+	// Another pod without the per-pod service-ip can not use the service-ip mode
+	t.Setenv("POD_NAME", "cluster1-dc1-r1-sts-1")
+	require.Error(NewBuilder(inputDir, t.TempDir()).Build(t.Context()))
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+
+	"github.com/charmbracelet/log"
+)
+
+// Output receives the rendered config files
+type Output interface {
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// DirOutput writes the rendered files to a directory
+type DirOutput string
+
+func (d DirOutput) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(filepath.Join(string(d), name), data, perm)
+}
+
+// Document is a rendered config file
+type Document struct {
+	Name string
+	Data []byte
+	Perm fs.FileMode
+}
+
+// Documents keeps the rendered files in memory, writing the same name again replaces the earlier content
+type Documents struct {
+	docs map[string]*Document
+}
+
+func NewDocuments() *Documents {
+	return &Documents{docs: make(map[string]*Document)}
+}
+
+func (d *Documents) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	d.docs[name] = &Document{Name: name, Data: slices.Clone(data), Perm: perm}
+	return nil
+}
+
+// Get returns the content of the rendered file
+func (d *Documents) Get(name string) ([]byte, bool) {
+	doc, found := d.docs[name]
+	if !found {
+		return nil, false
+	}
+	return doc.Data, true
+}
+
+// Names returns the names of the rendered files in sorted order
+func (d *Documents) Names() []string {
+	names := make([]string, 0, len(d.docs))
+	for name := range d.docs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// WriteTo writes all the rendered files to the output
+func (d *Documents) WriteTo(out Output) error {
+	for _, name := range d.Names() {
+		doc := d.docs[name]
+		if err := out.WriteFile(doc.Name, doc.Data, doc.Perm); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Render generates the Cassandra config files for the node without reading the environment. The base config files
+// are read from base, the manifest sources are relative to it. The rendered files are returned in memory and are
+// also written to out, unless it is nil.
+func Render(configInput *ConfigInput, nodeInfo *NodeInfo, base fs.FS, out Output) (*Documents, *Manifest, error) {
+	node, podOverrides, networking, err := prepareRender(configInput, nodeInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	flavor, err := resolveServerFlavor(configInput, base)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := flavor.validate(configInput, podOverrides); err != nil {
+		return nil, nil, err
+	}
+
+	// The overrides are merged into a copy, the caller's ConfigInput is not modified
+	input := *configInput
+
+	docs := NewDocuments()
+	manifest := newManifestBuilder(base)
+
+	// Apply non-cassandra.yaml overrides directly into configInput so they participate in standard merging
+	input.CassandraEnv = mergeCassandraEnvOptions(input.CassandraEnv, podOverrides.CassandraEnv)
+	input.RackDC = mergeRackDCOptions(input.RackDC, podOverrides.RackDC)
+
+	// Create cassandra-env.sh
+	if err := createCassandraEnv(&input, base, docs); err != nil {
+		return nil, nil, err
+	}
+	manifest.add("cassandra-env.sh", "cassandra-env.sh",
+		layer("cassandra-env-sh", !reflect.DeepEqual(input.CassandraEnv, CassandraEnvOptions{})),
+		layer(LayerPodOverrides, !reflect.DeepEqual(podOverrides.CassandraEnv, CassandraEnvOptions{})))
+
+	// Create jvm*-server.options (merge per-pod overrides inside the helper)
+	if err := createJVMOptions(&input, node, base, docs, podOverrides); err != nil {
+		return nil, nil, err
+	}
+	manifest.add("jvm-server.options", "jvm-server.options", layer("jvm-server-options", len(input.ServerOptions) > 0), layer(LayerPodOverrides, len(podOverrides.ServerOptions) > 0))
+	for _, jvm := range []struct {
+		name             string
+		options, podOpts map[string]interface{}
+	}{
+		{"jvm11-server", input.ServerOptions11, podOverrides.ServerOptions11},
+		{"jvm17-server", input.ServerOptions17, podOverrides.ServerOptions17},
+		{"jvm21-server", input.ServerOptions21, podOverrides.ServerOptions21},
+	} {
+		manifest.add(jvm.name+".options", jvm.name+".options", layer(jvm.name+"-options", len(jvm.options) > 0), layer(LayerPodOverrides, len(jvm.podOpts) > 0), layer("jvm-auto-tuning", input.JVMAutoTuning.Enabled))
+	}
+
+	// Create cassandra.yaml (apply per-pod overrides at the very end)
+	finalCassYaml := podOverrides.CassYaml
+	cassandraYaml, err := renderCassandraYaml(&input, node, base, finalCassYaml)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := writeYaml(cassandraYaml, docs, "cassandra.yaml"); err != nil {
+		return nil, nil, err
+	}
+	manifest.add("cassandra.yaml", flavor.cassandraYamlSource(base), layer(flavor.name, flavor.cassandraYamlDefaults != nil), layer("cassandra-yaml", len(input.CassYaml) > 0), layer("networking", networking != NetworkingOptions{}), LayerK8ssandra, layer(LayerPodOverrides, len(finalCassYaml) > 0))
+
+	// DSE has its own settings in dse.yaml, per-pod overrides are applied last like in cassandra.yaml
+	if flavor.name == ServerTypeDSE {
+		if err := flavor.createDseYaml(&input, base, docs, podOverrides.DseYaml); err != nil {
+			return nil, nil, err
+		}
+		manifest.add(dseConfigName, dseConfigName, layer(flavor.name, flavor.dseYamlDefaults != nil), layer("dse-yaml", len(input.DseYaml) > 0), layer(LayerPodOverrides, len(podOverrides.DseYaml) > 0))
+	}
+
+	// Create rack information, the rendered cassandra.yaml decides if the snitch or the location provider reads it
+	if err := createRackProperties(&input, node, docs, usesLocationProvider(cassandraYaml)); err != nil {
+		return nil, nil, err
+	}
+	manifest.add("cassandra-rackdc.properties", "", LayerK8ssandra, layer("cassandra-rackdc-properties", !reflect.DeepEqual(input.RackDC, RackDCOptions{})))
+
+	// Create logback.xml, commitlog_archiving.properties and cassandra-jaas.config (copied as is without overrides)
+	if err := createLogbackXml(&input, base, docs); err != nil {
+		return nil, nil, err
+	}
+	manifest.add(logbackConfigName, logbackConfigName, layer("logback-xml", !reflect.DeepEqual(input.Logback, LogbackOptions{})))
+
+	if err := createCommitLogArchiving(&input, base, docs); err != nil {
+		return nil, nil, err
+	}
+	manifest.add(commitLogArchivingConfigName, commitLogArchivingConfigName, layer("commitlog-archiving-properties", input.CommitLogArchiving != CommitLogArchivingOptions{}))
+
+	if err := createJAASConfig(&input, base, docs); err != nil {
+		return nil, nil, err
+	}
+	manifest.add(jaasConfigName, jaasConfigName, layer("cassandra-jaas-config", len(input.JAAS.Entries) > 0))
+
+	// Copy files which we're not modifying
+	if err := copyFiles(base, docs); err != nil {
+		return nil, nil, err
+	}
+	for _, f := range copiedFiles {
+		manifest.add(f, f, LayerCopied)
+	}
+
+	return finishRender(docs, manifest.build(node.Name, docs), out)
+}
+
+// RenderSidecar generates the sidecar.yaml for the node, see Render
+func RenderSidecar(configInput *ConfigInput, nodeInfo *NodeInfo, base fs.FS, out Output) (*Documents, *Manifest, error) {
+	node, _, _, err := prepareRender(configInput, nodeInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	docs := NewDocuments()
+	manifest := newManifestBuilder(base)
+
+	if err := createSidecarYaml(configInput, node, base, docs); err != nil {
+		return nil, nil, err
+	}
+	manifest.add(sidecarConfigName, sidecarConfigName, layer("sidecar-yaml", len(configInput.SidecarYaml) > 0), LayerK8ssandra)
+
+	return finishRender(docs, manifest.build(node.Name, docs), out)
+}
+
+// prepareRender resolves the node addresses with the networking options of the ConfigInput, which take priority
+// over the ones of the NodeInfo. The returned NodeInfo is a copy.
+func prepareRender(configInput *ConfigInput, nodeInfo *NodeInfo) (*NodeInfo, *ConfigOverrides, NetworkingOptions, error) {
+	if configInput == nil || nodeInfo == nil {
+		return nil, nil, NetworkingOptions{}, errors.New("ConfigInput and NodeInfo are required")
+	}
+
+	podOverrides := podOverridesForNode(configInput, nodeInfo)
+
+	node := *nodeInfo
+	networking := mergeNetworkingOptions(configInput.Networking, podOverrides.Networking)
+	if err := node.resolveAddresses(networking); err != nil {
+		return nil, nil, NetworkingOptions{}, err
+	}
+
+	log.Infof("Parsed ConfigInput and NodeInfo for node %s", node.Name)
+	return &node, podOverrides, networking, nil
+}
+
+func finishRender(docs *Documents, manifest *Manifest, out Output) (*Documents, *Manifest, error) {
+	if out != nil {
+		if err := docs.WriteTo(out); err != nil {
+			return nil, nil, err
+		}
+	}
+	return docs, manifest, nil
+}
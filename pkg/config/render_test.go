@@ -0,0 +1,190 @@
+package config
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/k8ssandra/k8ssandra-client/internal/envtest"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRenderWithoutEnvironment(t *testing.T) {
+	require := require.New(t)
+
+	configInput, err := DecodeConfigInput([]byte(existingConfig))
+	require.NoError(err)
+
+	nodeInfo := &NodeInfo{
+		Name:   "cluster1-dc1-r1-sts-0",
+		Rack:   "r1",
+		PodIPs: []net.IP{net.ParseIP("172.27.0.1")},
+	}
+
+	base := os.DirFS(filepath.Join(envtest.RootDir(), "testfiles"))
+	docs, manifest, err := Render(configInput, nodeInfo, base, nil)
+	require.NoError(err)
+
+	// The caller's NodeInfo is not modified
+	require.Nil(nodeInfo.ListenIP)
+
+	require.Contains(docs.Names(), "cassandra.yaml")
+	require.Contains(docs.Names(), "cassandra-env.sh")
+	require.Contains(docs.Names(), "jvm11-server.options")
+	require.NotContains(docs.Names(), ManifestFileName)
+
+	cassYaml, found := docs.Get("cassandra.yaml")
+	require.True(found)
+
+	doc := make(map[string]any)
+	require.NoError(yaml.Unmarshal(cassYaml, doc))
+	require.Equal("172.27.0.1", doc["listen_address"])
+	require.Equal(configInput.ClusterInfo.Name, doc["cluster_name"])
+
+	rackDC, found := docs.Get("cassandra-rackdc.properties")
+	require.True(found)
+	require.Contains(string(rackDC), "rack=r1")
+
+	require.Equal(nodeInfo.Name, manifest.Node)
+	require.Len(manifest.Files, len(docs.Names()))
+	for _, f := range manifest.Files {
+		data, found := docs.Get(f.Name)
+		require.True(found)
+		require.Equal(dataChecksum(data), f.Checksum)
+	}
+
+	// Sources are relative to the base config
+	files := make(map[string]ManifestFile)
+	for _, f := range manifest.Files {
+		files[f.Name] = f
+	}
+	require.Equal(latestCassandraConfigName, files["cassandra.yaml"].Source)
+
+	// Writing to the output produces the same files
+	tempDir := t.TempDir()
+	_, manifest2, err := Render(configInput, nodeInfo, base, DirOutput(tempDir))
+	require.NoError(err)
+	require.Equal(manifest.Checksum, manifest2.Checksum)
+
+	for _, name := range docs.Names() {
+		data, err := os.ReadFile(filepath.Join(tempDir, name))
+		require.NoError(err)
+		expected, _ := docs.Get(name)
+		require.Equal(expected, data)
+	}
+}
+
+func TestRenderTwiceFromSameInput(t *testing.T) {
+	require := require.New(t)
+
+	// The spare capacity would let an append write over the caller's slices
+	additionalOpts := make([]string, 1, 4)
+	additionalOpts[0] = "-Dcassandra.ring_delay_ms=0"
+	serverOpts := make([]any, 1, 4)
+	serverOpts[0] = "-Dcassandra.consistent.rangemovement=false"
+
+	configInput := &ConfigInput{
+		ConfigOverrides: ConfigOverrides{
+			CassYaml: map[string]interface{}{
+				"client_encryption_options": map[string]any{"enabled": true, "optional": true},
+			},
+			ServerOptions11: map[string]interface{}{"additional-jvm-opts": serverOpts},
+			CassandraEnv: CassandraEnvOptions{
+				AdditionalOpts: additionalOpts,
+				Env:            map[string]string{"OTEL_SERVICE_NAME": "cassandra"},
+			},
+			RackDC: RackDCOptions{AdditionalProperties: map[string]string{"ec2_naming_scheme": "standard"}},
+		},
+		PodOverrides: map[string]ConfigOverrides{
+			"pod-0": {
+				CassYaml: map[string]interface{}{
+					"client_encryption_options": map[string]any{"optional": false},
+				},
+				ServerOptions11: map[string]interface{}{"additional-jvm-opts": []any{"-Dpod=0"}},
+				CassandraEnv: CassandraEnvOptions{
+					AdditionalOpts: []string{"-Dpod=0"},
+					Env:            map[string]string{"OTEL_SERVICE_NAME": "cassandra-0"},
+				},
+				RackDC: RackDCOptions{AdditionalProperties: map[string]string{"ec2_naming_scheme": "legacy"}},
+			},
+		},
+	}
+
+	snapshot, err := yaml.Marshal(configInput)
+	require.NoError(err)
+
+	base := os.DirFS(filepath.Join(envtest.RootDir(), "testfiles"))
+	render := func(name string) *Manifest {
+		_, manifest, err := Render(configInput, &NodeInfo{Name: name, Rack: "r1", PodIPs: []net.IP{net.ParseIP("172.27.0.1")}}, base, nil)
+		require.NoError(err)
+		return manifest
+	}
+
+	first := render("pod-0")
+	// Another pod's render must not see the first pod's overrides
+	other := render("pod-1")
+	second := render("pod-0")
+	require.Equal(first.Checksum, second.Checksum)
+	require.NotEqual(first.Checksum, other.Checksum)
+
+	after, err := yaml.Marshal(configInput)
+	require.NoError(err)
+	require.Equal(string(snapshot), string(after))
+	require.Equal([]string{"-Dcassandra.ring_delay_ms=0"}, configInput.CassandraEnv.AdditionalOpts)
+	require.Equal("-Dcassandra.ring_delay_ms=0", additionalOpts[:2][0])
+	require.Empty(additionalOpts[:2][1])
+	require.Empty(serverOpts[:2][1])
+}
+
+func TestRenderInMemoryBase(t *testing.T) {
+	require := require.New(t)
+
+	base := fstest.MapFS{
+		"cassandra.yaml":        {Data: []byte("cluster_name: base\nnum_tokens: 16\n")},
+		"cassandra-env.sh":      {Data: []byte("JMX_PORT=\"7199\"\n")},
+		"jvm-server.options":    {Data: []byte("-Xss256k\n")},
+		"jvm11-clients.options": {Data: []byte("-Djdk.attach.allowAttachSelf=true\n")},
+	}
+
+	configInput := &ConfigInput{ClusterInfo: ClusterInfo{Name: "cluster1"}}
+	configInput.CassYaml = map[string]any{"num_tokens": 8}
+
+	docs, manifest, err := Render(configInput, &NodeInfo{Rack: "r1", PodIPs: []net.IP{net.ParseIP("10.0.0.1")}}, base, nil)
+	require.NoError(err)
+	require.Equal([]string{"cassandra-env.sh", "cassandra-rackdc.properties", "cassandra.yaml", "jvm-server.options", "jvm11-clients.options"}, docs.Names())
+
+	cassYaml, _ := docs.Get("cassandra.yaml")
+	doc := make(map[string]any)
+	require.NoError(yaml.Unmarshal(cassYaml, doc))
+	require.Equal("cluster1", doc["cluster_name"])
+	require.Equal(8, doc["num_tokens"])
+
+	copied, _ := docs.Get("jvm11-clients.options")
+	require.Equal(base["jvm11-clients.options"].Data, copied)
+
+	require.Len(manifest.Files, 5)
+
+	// Missing inputs are reported as errors instead of reading the environment
+	_, _, err = Render(configInput, nil, base, nil)
+	require.Error(err)
+
+	_, _, err = Render(configInput, &NodeInfo{}, fstest.MapFS{}, nil)
+	require.Error(err)
+}
+
+func TestRenderSidecar(t *testing.T) {
+	require := require.New(t)
+
+	configInput, err := DecodeConfigInput([]byte(`{"sidecar-yaml": {"sidecar": {"port": 9044}}}`))
+	require.NoError(err)
+
+	base := os.DirFS(filepath.Join(envtest.RootDir(), "testfiles"))
+	docs, manifest, err := RenderSidecar(configInput, &NodeInfo{PodIPs: []net.IP{net.ParseIP("10.0.0.1")}}, base, nil)
+	require.NoError(err)
+	require.Equal([]string{sidecarConfigName}, docs.Names())
+	require.Len(manifest.Files, 1)
+	require.Equal([]string{LayerBase, "sidecar-yaml", LayerK8ssandra}, manifest.Files[0].Layers)
+}
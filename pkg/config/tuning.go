@@ -0,0 +1,290 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	metadata "github.com/burmanm/definitions-parser/pkg/types"
+)
+
+const (
+	mebibyte = 1024 * 1024
+
+	// Compressed oops are disabled with larger heaps than this, making 32GB heap effectively smaller than 31GB
+	maxCompressedOopsHeapMB = 31 * 1024
+
+	// cgroup v1 reports unlimited memory as a page aligned max int64
+	cgroupV1UnlimitedMemory = int64(1) << 60
+)
+
+// heapDerivedOptions are calculated from the heap size
+var heapDerivedOptions = []string{"-Xms", "-Xmx", "-Xmn", "-XX:MaxDirectMemorySize"}
+
+// cgroupRoot is the mount point of the container's cgroup filesystem
+var cgroupRoot = "/sys/fs/cgroup"
+
+// jvmTuning holds the inputs for calculating the JVM options from container resources
+type jvmTuning struct {
+	resources ContainerResources
+	// explicit options are set by the user outside the tuned file, tuned options never replace them
+	explicit []string
+}
+
+func newJVMTuning(configInput *ConfigInput, nodeInfo *NodeInfo, podOverrides *ConfigOverrides) (*jvmTuning, error) {
+	resources, err := containerResources(nodeInfo.Resources, cgroupRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	if resources.MemoryLimit <= 0 {
+		return nil, errors.New("jvm-auto-tuning requires a container memory limit, set CONTAINER_MEMORY_LIMIT or run with a cgroup memory limit")
+	}
+
+	explicit := explicitJVMOptions(configInput.ServerOptions, podOverrides.ServerOptions, "jvm-server.options")
+	explicit = append(explicit, configInput.CassandraEnv.AdditionalOpts...)
+
+	return &jvmTuning{
+		resources: resources,
+		explicit:  explicit,
+	}, nil
+}
+
+// options returns the tuned options for the given JVM version which are not overridden by the user. If the user
+// has set the heap size, none of the values derived from the heap size are used.
+func (t *jvmTuning) options(jvmMajor int, gcName string, fileOptions []string) []string {
+	overriding := append(slices.Clone(t.explicit), fileOptions...)
+	tuned := autoTunedJVMOptions(t.resources, jvmMajor, gcName)
+
+	if slices.ContainsFunc(overriding, func(opt string) bool {
+		key := jvmOptionKey(opt)
+		return key == "-Xms" || key == "-Xmx"
+	}) {
+		tuned = slices.DeleteFunc(tuned, func(opt string) bool {
+			return slices.Contains(heapDerivedOptions, jvmOptionKey(opt))
+		})
+	}
+
+	return filterJVMOptions(tuned, overriding)
+}
+
+// containerResources fills the limits missing from the downward API with the values from the cgroup filesystem
+func containerResources(resources ContainerResources, root string) (ContainerResources, error) {
+	if resources.MemoryLimit > 0 && resources.CPULimit > 0 {
+		return resources, nil
+	}
+
+	cgroupResources, err := readCgroupResources(root)
+	if err != nil {
+		return resources, err
+	}
+
+	if resources.MemoryLimit <= 0 {
+		resources.MemoryLimit = cgroupResources.MemoryLimit
+	}
+
+	if resources.CPULimit <= 0 {
+		resources.CPULimit = cgroupResources.CPULimit
+	}
+
+	return resources, nil
+}
+
+// readCgroupResources reads the memory and CPU limits from cgroup v2 or v1 files, missing files or unlimited values
+// are returned as zeros
+func readCgroupResources(root string) (ContainerResources, error) {
+	resources := ContainerResources{}
+
+	if memoryMax, found, err := readCgroupFile(filepath.Join(root, "memory.max")); err != nil {
+		return resources, err
+	} else if found {
+		// cgroup v2
+		if memoryMax != "max" {
+			if resources.MemoryLimit, err = strconv.ParseInt(memoryMax, 10, 64); err != nil {
+				return resources, fmt.Errorf("invalid memory.max: %w", err)
+			}
+		}
+
+		cpuMax, found, err := readCgroupFile(filepath.Join(root, "cpu.max"))
+		if err != nil || !found {
+			return resources, err
+		}
+
+		fields := strings.Fields(cpuMax)
+		if len(fields) == 2 && fields[0] != "max" {
+			quota, errQ := strconv.ParseFloat(fields[0], 64)
+			period, errP := strconv.ParseFloat(fields[1], 64)
+			if errQ != nil || errP != nil || period <= 0 {
+				return resources, fmt.Errorf("invalid cpu.max: %s", cpuMax)
+			}
+			resources.CPULimit = quota / period
+		}
+
+		return resources, nil
+	}
+
+	// cgroup v1
+	if memoryLimit, found, err := readCgroupFile(filepath.Join(root, "memory", "memory.limit_in_bytes")); err != nil {
+		return resources, err
+	} else if found {
+		limit, err := strconv.ParseInt(memoryLimit, 10, 64)
+		if err != nil {
+			return resources, fmt.Errorf("invalid memory.limit_in_bytes: %w", err)
+		}
+		if limit < cgroupV1UnlimitedMemory {
+			resources.MemoryLimit = limit
+		}
+	}
+
+	quotaStr, foundQ, err := readCgroupFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return resources, err
+	}
+	periodStr, foundP, err := readCgroupFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return resources, err
+	}
+
+	if foundQ && foundP {
+		quota, errQ := strconv.ParseFloat(quotaStr, 64)
+		period, errP := strconv.ParseFloat(periodStr, 64)
+		if errQ != nil || errP != nil {
+			return resources, fmt.Errorf("invalid cpu.cfs_quota_us or cpu.cfs_period_us: %s / %s", quotaStr, periodStr)
+		}
+		if quota > 0 && period > 0 {
+			resources.CPULimit = quota / period
+		}
+	}
+
+	return resources, nil
+}
+
+func readCgroupFile(path string) (string, bool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return strings.TrimSpace(string(b)), true, nil
+}
+
+// autoTunedJVMOptions calculates the heap, GC threads and direct memory for the given JVM major version and
+// garbage collector:
+//
+//   - CMS uses the cassandra-env.sh formula max(min(1/2 ram, 1GB), min(1/4 ram, 8GB)) with a young generation of
+//     min(1/4 heap, 100MB per core)
+//   - G1 uses half of the memory, capped at 31GB to keep compressed oops enabled. The young generation is not set
+//     since it would override the pause time goals.
+//   - ZGC and Shenandoah use half of the memory without a cap
+//
+// ParallelGCThreads and ConcGCThreads are set to the number of cores for G1 and CMS as recommended in
+// jvm11-server.options, concurrent collectors get a quarter of the cores for the concurrent threads. Half of the
+// memory left outside the heap is given to MaxDirectMemorySize, the rest is for the metaspace, thread stacks and
+// the native allocations.
+func autoTunedJVMOptions(resources ContainerResources, jvmMajor int, gcName string) []string {
+	memoryMB := resources.MemoryLimit / mebibyte
+	if memoryMB <= 0 {
+		return []string{}
+	}
+
+	if gcName == "" || (gcName == CMS && jvmMajor >= 17) {
+		gcName = G1GC
+	}
+
+	cores := int64(math.Ceil(resources.CPULimit))
+
+	options := make([]string, 0, 6)
+
+	var heapMB int64
+	switch gcName {
+	case CMS:
+		heapMB = max(min(memoryMB/2, 1024), min(memoryMB/4, 8192))
+		youngMB := heapMB / 4
+		if cores > 0 {
+			youngMB = min(youngMB, 100*cores)
+		}
+		options = append(options, fmt.Sprintf("-Xmn%dM", youngMB))
+	case ZGC, Shenandoah:
+		heapMB = memoryMB / 2
+	default:
+		heapMB = min(memoryMB/2, maxCompressedOopsHeapMB)
+	}
+
+	options = append(options, fmt.Sprintf("-Xms%dM", heapMB), fmt.Sprintf("-Xmx%dM", heapMB))
+
+	if cores > 0 {
+		concThreads := cores
+		if gcName == ZGC || gcName == Shenandoah {
+			concThreads = max(1, cores/4)
+		}
+		options = append(options, fmt.Sprintf("-XX:ParallelGCThreads=%d", cores), fmt.Sprintf("-XX:ConcGCThreads=%d", concThreads))
+	}
+
+	if directMB := (memoryMB - heapMB) / 2; directMB > 0 {
+		options = append(options, fmt.Sprintf("-XX:MaxDirectMemorySize=%dM", directMB))
+	}
+
+	return options
+}
+
+// jvmOptionKey returns the part of the option which identifies it, without the value
+func jvmOptionKey(option string) string {
+	for _, prefix := range []string{"-Xms", "-Xmx", "-Xmn", "-Xss"} {
+		if strings.HasPrefix(option, prefix) {
+			return prefix
+		}
+	}
+	if loc := strings.Index(option, "="); loc > 0 {
+		return option[:loc]
+	}
+	return option
+}
+
+// filterJVMOptions removes the options which have the same key as any of the overriding options
+func filterJVMOptions(options []string, overriding ...[]string) []string {
+	keys := make(map[string]struct{})
+	for _, opts := range overriding {
+		for _, opt := range opts {
+			keys[jvmOptionKey(opt)] = struct{}{}
+		}
+	}
+
+	filtered := make([]string, 0, len(options))
+	for _, opt := range options {
+		if _, found := keys[jvmOptionKey(opt)]; !found {
+			filtered = append(filtered, opt)
+		}
+	}
+	return filtered
+}
+
+// explicitJVMOptions renders the options set by the user for the given file without the base file options
+func explicitJVMOptions(baseOptions, overrideOptions map[string]interface{}, filename string) []string {
+	s := optionsFilenameToMap(filename)
+	explicit := make([]string, 0, len(baseOptions)+len(overrideOptions))
+	for _, options := range []map[string]interface{}{baseOptions, overrideOptions} {
+		for k, v := range options {
+			if k == "additional-jvm-opts" {
+				if addOpts, ok := v.([]any); ok {
+					for _, opt := range addOpts {
+						explicit = append(explicit, fmt.Sprintf("%v", opt))
+					}
+				}
+				continue
+			}
+			if outputVal, found := s[k]; found && outputVal.ValueType != metadata.TemplateValue {
+				if output := jvmAliasOutput(outputVal, fmt.Sprintf("%v", v)); output != "" {
+					explicit = append(explicit, output)
+				}
+			}
+		}
+	}
+	return explicit
+}
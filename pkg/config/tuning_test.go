@@ -0,0 +1,224 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8ssandra/k8ssandra-client/internal/envtest"
+	"github.com/stretchr/testify/require"
+)
+
+var autoTuningConfig = `
+{
+	"jvm-auto-tuning": {
+		"enabled": true
+	},
+	"jvm17-server-options": {
+		"garbage_collector": "ZGC"
+	},
+	"cluster-info": {
+		"name": "test",
+		"seeds": "test-seed-service"
+	},
+	"datacenter-info": {
+		"name": "datacenter1"
+	}
+}
+`
+
+var autoTuningExplicitHeapConfig = `
+{
+	"jvm-auto-tuning": {
+		"enabled": true
+	},
+	"jvm-server-options": {
+		"max_heap_size": "2G"
+	},
+	"jvm11-server-options": {
+		"parallel_gc_threads": "2"
+	},
+	"cluster-info": {
+		"name": "test",
+		"seeds": "test-seed-service"
+	},
+	"datacenter-info": {
+		"name": "datacenter1"
+	}
+}
+`
+
+func TestAutoTunedJVMOptions(t *testing.T) {
+	gib := int64(1024 * mebibyte)
+	tests := []struct {
+		name      string
+		resources ContainerResources
+		jvmMajor  int
+		gcName    string
+		expected  []string
+	}{
+		{
+			name:      "G1 small container",
+			resources: ContainerResources{MemoryLimit: 4 * gib, CPULimit: 2},
+			jvmMajor:  11,
+			gcName:    G1GC,
+			expected:  []string{"-Xms2048M", "-Xmx2048M", "-XX:ParallelGCThreads=2", "-XX:ConcGCThreads=2", "-XX:MaxDirectMemorySize=1024M"},
+		},
+		{
+			name:      "G1 large heap is capped",
+			resources: ContainerResources{MemoryLimit: 128 * gib, CPULimit: 15.5},
+			jvmMajor:  17,
+			gcName:    "",
+			expected:  []string{"-Xms31744M", "-Xmx31744M", "-XX:ParallelGCThreads=16", "-XX:ConcGCThreads=16", "-XX:MaxDirectMemorySize=49664M"},
+		},
+		{
+			name:      "CMS",
+			resources: ContainerResources{MemoryLimit: 16 * gib, CPULimit: 4},
+			jvmMajor:  11,
+			gcName:    CMS,
+			expected:  []string{"-Xmn400M", "-Xms4096M", "-Xmx4096M", "-XX:ParallelGCThreads=4", "-XX:ConcGCThreads=4", "-XX:MaxDirectMemorySize=6144M"},
+		},
+		{
+			name:      "CMS is not available in JDK17",
+			resources: ContainerResources{MemoryLimit: 16 * gib},
+			jvmMajor:  17,
+			gcName:    CMS,
+			expected:  []string{"-Xms8192M", "-Xmx8192M", "-XX:MaxDirectMemorySize=4096M"},
+		},
+		{
+			name:      "ZGC",
+			resources: ContainerResources{MemoryLimit: 96 * gib, CPULimit: 8},
+			jvmMajor:  21,
+			gcName:    ZGC,
+			expected:  []string{"-Xms49152M", "-Xmx49152M", "-XX:ParallelGCThreads=8", "-XX:ConcGCThreads=2", "-XX:MaxDirectMemorySize=24576M"},
+		},
+		{
+			name:      "no memory limit",
+			resources: ContainerResources{CPULimit: 8},
+			jvmMajor:  11,
+			gcName:    G1GC,
+			expected:  []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, autoTunedJVMOptions(test.resources, test.jvmMajor, test.gcName))
+		})
+	}
+}
+
+func TestReadCgroupResources(t *testing.T) {
+	require := require.New(t)
+
+	v2 := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(v2, "memory.max"), []byte("8589934592\n"), 0644))
+	require.NoError(os.WriteFile(filepath.Join(v2, "cpu.max"), []byte("250000 100000\n"), 0644))
+
+	resources, err := readCgroupResources(v2)
+	require.NoError(err)
+	require.Equal(int64(8589934592), resources.MemoryLimit)
+	require.Equal(2.5, resources.CPULimit)
+
+	require.NoError(os.WriteFile(filepath.Join(v2, "memory.max"), []byte("max\n"), 0644))
+	require.NoError(os.WriteFile(filepath.Join(v2, "cpu.max"), []byte("max 100000\n"), 0644))
+
+	resources, err = readCgroupResources(v2)
+	require.NoError(err)
+	require.Equal(ContainerResources{}, resources)
+
+	v1 := t.TempDir()
+	require.NoError(os.MkdirAll(filepath.Join(v1, "memory"), 0755))
+	require.NoError(os.MkdirAll(filepath.Join(v1, "cpu"), 0755))
+	require.NoError(os.WriteFile(filepath.Join(v1, "memory", "memory.limit_in_bytes"), []byte("4294967296\n"), 0644))
+	require.NoError(os.WriteFile(filepath.Join(v1, "cpu", "cpu.cfs_quota_us"), []byte("400000\n"), 0644))
+	require.NoError(os.WriteFile(filepath.Join(v1, "cpu", "cpu.cfs_period_us"), []byte("100000\n"), 0644))
+
+	resources, err = readCgroupResources(v1)
+	require.NoError(err)
+	require.Equal(int64(4294967296), resources.MemoryLimit)
+	require.Equal(4.0, resources.CPULimit)
+
+	require.NoError(os.WriteFile(filepath.Join(v1, "memory", "memory.limit_in_bytes"), []byte("9223372036854771712\n"), 0644))
+	require.NoError(os.WriteFile(filepath.Join(v1, "cpu", "cpu.cfs_quota_us"), []byte("-1\n"), 0644))
+
+	resources, err = readCgroupResources(v1)
+	require.NoError(err)
+	require.Equal(ContainerResources{}, resources)
+
+	// Downward API values take priority
+	resources, err = containerResources(ContainerResources{MemoryLimit: 1024}, v2)
+	require.NoError(err)
+	require.Equal(int64(1024), resources.MemoryLimit)
+}
+
+func TestJVMAutoTuning(t *testing.T) {
+	require := require.New(t)
+	optionsDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	t.Setenv("CONFIG_FILE_DATA", autoTuningConfig)
+	t.Setenv("CONTAINER_MEMORY_LIMIT", "8589934592")
+	t.Setenv("CONTAINER_CPU_LIMIT", "4")
+	configInput, err := parseConfigInput()
+	require.NoError(err)
+	nodeInfo, err := parseNodeInfo()
+	require.NoError(err)
+
+	require.NoError(createJVMOptions(configInput, nodeInfo, os.DirFS(optionsDir), DirOutput(tempDir), &ConfigOverrides{}))
+
+	options, err := readJvmServerOptions(os.DirFS(tempDir), "jvm-server.options")
+	require.NoError(err)
+	require.NotContains(options, "-Xmx4096M")
+
+	options11, err := readJvmServerOptions(os.DirFS(tempDir), "jvm11-server.options")
+	require.NoError(err)
+	require.Contains(options11, "-Xms4096M")
+	require.Contains(options11, "-Xmx4096M")
+	require.Contains(options11, "-XX:ParallelGCThreads=4")
+	require.Contains(options11, "-XX:ConcGCThreads=4")
+	require.Contains(options11, "-XX:MaxDirectMemorySize=2048M")
+
+	options17, err := readJvmServerOptions(os.DirFS(tempDir), "jvm17-server.options")
+	require.NoError(err)
+	require.Contains(options17, "-XX:+UseZGC")
+	require.Contains(options17, "-XX:ConcGCThreads=1")
+
+	options21, err := readJvmServerOptions(os.DirFS(tempDir), "jvm21-server.options")
+	require.NoError(err)
+	require.Contains(options21, "-XX:+UseG1GC")
+	require.Contains(options21, "-Xmx4096M")
+	require.Contains(options21, "-XX:ConcGCThreads=4")
+}
+
+func TestJVMAutoTuningExplicitOptionsWin(t *testing.T) {
+	require := require.New(t)
+	optionsDir := filepath.Join(envtest.RootDir(), "testfiles")
+	tempDir := t.TempDir()
+
+	t.Setenv("CONFIG_FILE_DATA", autoTuningExplicitHeapConfig)
+	configInput, err := parseConfigInput()
+	require.NoError(err)
+	nodeInfo := &NodeInfo{Resources: ContainerResources{MemoryLimit: 8589934592, CPULimit: 4}}
+
+	require.NoError(createJVMOptions(configInput, nodeInfo, os.DirFS(optionsDir), DirOutput(tempDir), &ConfigOverrides{}))
+
+	options, err := readJvmServerOptions(os.DirFS(tempDir), "jvm-server.options")
+	require.NoError(err)
+	require.Contains(options, "-Xmx2G")
+
+	options11, err := readJvmServerOptions(os.DirFS(tempDir), "jvm11-server.options")
+	require.NoError(err)
+	for _, opt := range options11 {
+		key := jvmOptionKey(opt)
+		require.NotContains(heapDerivedOptions, key)
+	}
+	require.Contains(options11, "-XX:ParallelGCThreads=2")
+	require.NotContains(options11, "-XX:ParallelGCThreads=4")
+	require.Contains(options11, "-XX:ConcGCThreads=4")
+
+	// Without a memory limit, the tuning can not be done
+	cgroupRoot = t.TempDir()
+	t.Cleanup(func() { cgroupRoot = "/sys/fs/cgroup" })
+	require.Error(createJVMOptions(configInput, &NodeInfo{}, os.DirFS(optionsDir), DirOutput(t.TempDir()), &ConfigOverrides{}))
+}
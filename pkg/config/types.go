@@ -6,10 +6,19 @@ import "net"
 
 type ConfigInput struct {
 	ClusterInfo     ClusterInfo            `json:"cluster-info" yaml:"cluster-info"`
+	ServerType      string                 `json:"server-type,omitempty" yaml:"server-type,omitempty"` // cassandra, dse or hcd, detected from the base config files if not set
 	DatacenterInfo  DatacenterInfo         `json:"datacenter-info" yaml:"datacenter-info"`
 	SidecarYaml     map[string]interface{} `json:"sidecar-yaml,omitempty" yaml:"sidecar-yaml,omitempty"` // This is not supported in the per-pod configuration at this moment
 	ConfigOverrides `yaml:",inline"`
 	PodOverrides    map[string]ConfigOverrides `json:"pod-overrides,omitempty" yaml:"pod-overrides,omitempty"`
+	JVMAutoTuning   JVMAutoTuningOptions       `json:"jvm-auto-tuning,omitempty" yaml:"jvm-auto-tuning,omitempty"`
+
+	Logback            LogbackOptions            `json:"logback-xml,omitempty" yaml:"logback-xml,omitempty"`
+	CommitLogArchiving CommitLogArchivingOptions `json:"commitlog-archiving-properties,omitempty" yaml:"commitlog-archiving-properties,omitempty"`
+	JAAS               JAASOptions               `json:"cassandra-jaas-config,omitempty" yaml:"cassandra-jaas-config,omitempty"`
+
+	// Runtime settings have no config file, they're only applied to the running node by the config watch
+	Runtime RuntimeOptions `json:"runtime,omitempty" yaml:"runtime,omitempty"`
 
 	// At some point, parse the remaining unknown keys when we decide what to do with them..
 }
@@ -20,13 +29,139 @@ type ConfigOverrides struct {
 	ServerOptions11 map[string]interface{} `json:"jvm11-server-options,omitempty" yaml:"jvm11-server-options,omitempty"`
 	ServerOptions17 map[string]interface{} `json:"jvm17-server-options,omitempty" yaml:"jvm17-server-options,omitempty"`
 	ServerOptions21 map[string]interface{} `json:"jvm21-server-options,omitempty" yaml:"jvm21-server-options,omitempty"`
+	DseYaml         map[string]interface{} `json:"dse-yaml,omitempty" yaml:"dse-yaml,omitempty"` // Only supported by DSE
 	CassandraEnv    CassandraEnvOptions    `json:"cassandra-env-sh,omitempty" yaml:"cassandra-env-sh,omitempty"`
+	Networking      NetworkingOptions      `json:"networking,omitempty" yaml:"networking,omitempty"`
+	RackDC          RackDCOptions          `json:"cassandra-rackdc-properties,omitempty" yaml:"cassandra-rackdc-properties,omitempty"`
 }
 
 type CassandraEnvOptions struct {
 	MallocArenaMax int      `json:"malloc-arena-max,omitempty" yaml:"malloc-arena-max,omitempty"`
 	HeapDumpDir    string   `json:"heap-dump-dir,omitempty" yaml:"heap-dump-dir,omitempty"`
 	AdditionalOpts []string `json:"additional-jvm-opts,omitempty" yaml:"additional-jvm-opts,omitempty"`
+
+	JMX *JMXOptions `json:"jmx,omitempty" yaml:"jmx,omitempty"`
+
+	// Env variables are exported before the base cassandra-env.sh, the values can refer to other variables
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// JavaAgents are added to the JVM_OPTS as -javaagent:path=args, for example metrics exporters
+	JavaAgents []JavaAgent `json:"java-agents,omitempty" yaml:"java-agents,omitempty"`
+}
+
+type JMXOptions struct {
+	// Local is written as LOCAL_JMX, false allows remote connections. The base file defaults to local only.
+	Local *bool `json:"local,omitempty" yaml:"local,omitempty"`
+	Port  int   `json:"port,omitempty" yaml:"port,omitempty"`
+	// RMIPort must differ from the Port if SSL is enabled for remote connections
+	RMIPort int `json:"rmi-port,omitempty" yaml:"rmi-port,omitempty"`
+
+	Authenticate *bool  `json:"authenticate,omitempty" yaml:"authenticate,omitempty"`
+	PasswordFile string `json:"password-file,omitempty" yaml:"password-file,omitempty"`
+	AccessFile   string `json:"access-file,omitempty" yaml:"access-file,omitempty"`
+
+	SSL *JMXSSLOptions `json:"ssl,omitempty" yaml:"ssl,omitempty"`
+}
+
+// JMXSSLOptions values are passed to the JVM through JVM_OPTS and must not contain whitespace. The passwords are read
+// from the password files when Cassandra starts, they are not written to cassandra-env.sh.
+type JMXSSLOptions struct {
+	Enabled                bool     `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	NeedClientAuth         bool     `json:"need-client-auth,omitempty" yaml:"need-client-auth,omitempty"`
+	EnabledProtocols       []string `json:"enabled-protocols,omitempty" yaml:"enabled-protocols,omitempty"`
+	EnabledCipherSuites    []string `json:"enabled-cipher-suites,omitempty" yaml:"enabled-cipher-suites,omitempty"`
+	Keystore               string   `json:"keystore,omitempty" yaml:"keystore,omitempty"`
+	KeystorePasswordFile   string   `json:"keystore-password-file,omitempty" yaml:"keystore-password-file,omitempty"`
+	Truststore             string   `json:"truststore,omitempty" yaml:"truststore,omitempty"`
+	TruststorePasswordFile string   `json:"truststore-password-file,omitempty" yaml:"truststore-password-file,omitempty"`
+}
+
+type JavaAgent struct {
+	Path string `json:"path" yaml:"path"`
+	Args string `json:"args,omitempty" yaml:"args,omitempty"`
+}
+
+type RuntimeOptions struct {
+	// TraceProbability is the probability of tracing a request, between 0 and 1
+	TraceProbability *float64 `json:"trace-probability,omitempty" yaml:"trace-probability,omitempty"`
+}
+
+// LogbackOptions modify the logback.xml from the base config
+type LogbackOptions struct {
+	// RootLevel is the level of the root logger
+	RootLevel string `json:"root-level,omitempty" yaml:"root-level,omitempty"`
+	// Loggers maps the logger names to their levels, existing loggers are updated
+	Loggers map[string]string `json:"loggers,omitempty" yaml:"loggers,omitempty"`
+	// Appenders are added to the configuration and referenced from the root logger
+	Appenders []LogbackAppender `json:"appenders,omitempty" yaml:"appenders,omitempty"`
+	// JSONEncoder replaces the encoder of every appender with the given class, for example
+	// ch.qos.logback.classic.encoder.JsonEncoder
+	JSONEncoder string `json:"json-encoder,omitempty" yaml:"json-encoder,omitempty"`
+}
+
+type LogbackAppender struct {
+	Name  string `json:"name" yaml:"name"`
+	Class string `json:"class" yaml:"class"`
+	// Config is the XML content inside the appender element
+	Config string `json:"config,omitempty" yaml:"config,omitempty"`
+}
+
+// CommitLogArchivingOptions are written to commitlog_archiving.properties
+type CommitLogArchivingOptions struct {
+	ArchiveCommand     string `json:"archive-command,omitempty" yaml:"archive-command,omitempty"`
+	RestoreCommand     string `json:"restore-command,omitempty" yaml:"restore-command,omitempty"`
+	RestoreDirectories string `json:"restore-directories,omitempty" yaml:"restore-directories,omitempty"`
+	RestorePointInTime string `json:"restore-point-in-time,omitempty" yaml:"restore-point-in-time,omitempty"`
+	Precision          string `json:"precision,omitempty" yaml:"precision,omitempty"`
+}
+
+// JAASOptions replace or add login configuration entries in cassandra-jaas.config
+type JAASOptions struct {
+	Entries map[string][]JAASLoginModule `json:"entries,omitempty" yaml:"entries,omitempty"`
+}
+
+type JAASLoginModule struct {
+	Class string `json:"class" yaml:"class"`
+	// Flag is one of required, requisite, sufficient or optional
+	Flag    string            `json:"flag" yaml:"flag"`
+	Options map[string]string `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// JVMAutoTuningOptions enable calculating the heap and GC settings from the container resource limits. The
+// calculated values are written to the jvm11, jvm17 and jvm21 server options and the explicitly set options
+// always take priority over them.
+type JVMAutoTuningOptions struct {
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+}
+
+// RackDCOptions are written to cassandra-rackdc.properties in addition to the dc and rack of the node
+type RackDCOptions struct {
+	// PreferLocal is written as prefer_local_connections to cassandra.yaml when RackDCFileLocationProvider is used
+	PreferLocal *bool  `json:"prefer-local,omitempty" yaml:"prefer-local,omitempty"`
+	DCSuffix    string `json:"dc-suffix,omitempty" yaml:"dc-suffix,omitempty"`
+	// AdditionalProperties are written as is, for example ec2_naming_scheme
+	AdditionalProperties map[string]string `json:"additional-properties,omitempty" yaml:"additional-properties,omitempty"`
+}
+
+// NetworkingOptions select how the listen and broadcast addresses are derived. Every field has an
+// environment variable counterpart (see parseNodeInfo), the values set here take priority over them.
+type NetworkingOptions struct {
+	// BroadcastMode selects the source of broadcast_rpc_address (the address advertised to clients), defaults to pod-ip
+	BroadcastMode string `json:"broadcast-mode,omitempty" yaml:"broadcast-mode,omitempty"`
+	// InternodeBroadcastMode selects the source of broadcast_address, when empty it is the same as listen_address
+	InternodeBroadcastMode string `json:"internode-broadcast-mode,omitempty" yaml:"internode-broadcast-mode,omitempty"`
+	// IPFamily is the preferred family (ipv4 or ipv6) on dual-stack clusters, defaults to the family of POD_IP
+	IPFamily string `json:"ip-family,omitempty" yaml:"ip-family,omitempty"`
+	// RPCAddressMode is either wildcard (default) or listen, which binds the native transport to listen_address
+	RPCAddressMode string `json:"rpc-address-mode,omitempty" yaml:"rpc-address-mode,omitempty"`
+	// ServiceIP is the per-pod Service or LoadBalancer IP, comma separated for dual-stack
+	ServiceIP string `json:"service-ip,omitempty" yaml:"service-ip,omitempty"`
+	// ExternalIP is the ExternalIP of the node running the pod, comma separated for dual-stack
+	ExternalIP string `json:"external-ip,omitempty" yaml:"external-ip,omitempty"`
+	// Hostname is broadcasted as is in the dns mode
+	Hostname string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	// DNSDomain is appended to the pod name to create the hostname in the dns mode if Hostname is not set
+	DNSDomain string `json:"dns-domain,omitempty" yaml:"dns-domain,omitempty"`
 }
 
 type ClusterInfo struct {
@@ -49,8 +184,31 @@ type NodeInfo struct {
 	Name        string
 	Rack        string
 	ListenIP    net.IP
-	BroadcastIP net.IP
+	BroadcastIP net.IP // Advertised to the clients as broadcast_rpc_address
 	RPCIP       net.IP
+
+	// InternodeBroadcastIP is used as broadcast_address, nil means it is the same as ListenIP
+	InternodeBroadcastIP net.IP
+
+	// Hostnames replace the IPs above when the dns mode is used
+	BroadcastHostname          string
+	InternodeBroadcastHostname string
+
+	// Raw inputs the addresses above are resolved from
+	PodIPs     []net.IP
+	HostIPs    []net.IP
+	Networking NetworkingOptions
+
+	// Resources are the container limits from the downward API, zero values are read from the cgroup filesystem
+	Resources ContainerResources
+
+	// hostIPForBroadcast is set by USE_HOST_IP_FOR_BROADCAST, which leaves the broadcast address unset without HOST_IPS
+	hostIPForBroadcast bool
+}
+
+type ContainerResources struct {
+	MemoryLimit int64   // In bytes
+	CPULimit    float64 // In cores
 }
 
 var (
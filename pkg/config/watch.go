@@ -0,0 +1,502 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultWatchInterval = 10 * time.Second
+
+	// rootLoggerName is the logback name of the root logger
+	rootLoggerName = "ROOT"
+)
+
+var dataRateRegexp = regexp.MustCompile(`^(\d+)\s*(B|KiB|MiB)/s$`)
+
+// RuntimeChange is a setting which can be changed on the running node, Command has the nodetool arguments for it
+type RuntimeChange struct {
+	Setting string
+	Value   string
+	Command []string
+}
+
+func (c RuntimeChange) String() string {
+	return fmt.Sprintf("%s=%s", c.Setting, c.Value)
+}
+
+// RuntimeApplier changes the settings of the running Cassandra node
+type RuntimeApplier interface {
+	Apply(ctx context.Context, change RuntimeChange) error
+}
+
+// NodetoolApplier applies the changes with nodetool, Args are added before the command, for example the JMX
+// credentials
+type NodetoolApplier struct {
+	Path string
+	Args []string
+}
+
+func (n *NodetoolApplier) Apply(ctx context.Context, change RuntimeChange) error {
+	args := append(slices.Clone(n.Args), change.Command...)
+	out, err := exec.CommandContext(ctx, n.Path, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("nodetool %s failed: %w: %s", strings.Join(change.Command, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ReloadReport lists what happened to the changes of a single reload
+type ReloadReport struct {
+	Checksum        string
+	Applied         []RuntimeChange
+	Failed          []RuntimeChange
+	RestartRequired []string
+}
+
+// Watcher renders the config files again when the ConfigInput source file changes. The settings Cassandra can
+// change at runtime are applied to the running node, the rest of the changes are only written to the output
+// directory and require a restart.
+type Watcher struct {
+	builder  *Builder
+	source   string
+	applier  RuntimeApplier
+	interval time.Duration
+
+	sourceChecksum string
+	// runtime holds the settings without a config file, nil until the first reload
+	runtime *RuntimeOptions
+	// pending changes are retried on every poll until they're applied, the node might not be running yet
+	pending map[string]RuntimeChange
+}
+
+type WatcherOption func(*Watcher)
+
+func WithWatchInterval(interval time.Duration) WatcherOption {
+	return func(w *Watcher) {
+		w.interval = interval
+	}
+}
+
+func NewWatcher(inputDir, outputDir, source string, applier RuntimeApplier, opts ...WatcherOption) *Watcher {
+	w := &Watcher{
+		builder:  NewBuilder(inputDir, outputDir, WithConfigInputFile(source)),
+		source:   source,
+		applier:  applier,
+		interval: defaultWatchInterval,
+		pending:  make(map[string]RuntimeChange),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Run polls the source until the context is cancelled. A ConfigMap volume is updated by replacing a symlink, so
+// polling the content is more reliable than file events.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if report, err := w.Reload(ctx); err != nil {
+			log.Error("Failed to reload the config", "source", w.source, "error", err)
+		} else if report != nil {
+			logReport(report)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func logReport(report *ReloadReport) {
+	log.Info("Config reloaded", "checksum", report.Checksum)
+	for _, change := range report.Applied {
+		log.Info("Applied runtime setting", "setting", change.Setting, "value", change.Value)
+	}
+	for _, change := range report.Failed {
+		log.Warn("Failed to apply runtime setting, retrying", "setting", change.Setting, "value", change.Value)
+	}
+	if len(report.RestartRequired) > 0 {
+		log.Warn("Config changes require a restart", "changes", strings.Join(report.RestartRequired, ","))
+	}
+}
+
+// Reload renders the config if the source has changed and retries the pending runtime changes. The report is nil
+// if there was nothing to do.
+func (w *Watcher) Reload(ctx context.Context) (*ReloadReport, error) {
+	data, err := os.ReadFile(w.source)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	report := &ReloadReport{}
+	if checksum != w.sourceChecksum {
+		if err := w.render(report); err != nil {
+			return nil, err
+		}
+		w.sourceChecksum = checksum
+	} else if len(w.pending) == 0 {
+		return nil, nil
+	}
+
+	for _, setting := range slices.Sorted(maps.Keys(w.pending)) {
+		change := w.pending[setting]
+		if err := w.applier.Apply(ctx, change); err != nil {
+			log.Debug("Runtime setting not applied", "setting", setting, "error", err)
+			report.Failed = append(report.Failed, change)
+			continue
+		}
+		report.Applied = append(report.Applied, change)
+		delete(w.pending, setting)
+	}
+
+	return report, nil
+}
+
+// render builds the config to a temporary directory, compares it to the previous output and moves the files to the
+// output directory
+func (w *Watcher) render(report *ReloadReport) error {
+	outputDir := w.builder.configOutputDir
+
+	tempDir, err := os.MkdirTemp("", "k8ssandra-config-watch")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			log.Warnf("Failed to remove watch directory %s: %v", tempDir, err)
+		}
+	}()
+
+	configInput, err := w.builder.parseConfigInput()
+	if err != nil {
+		return err
+	}
+
+	if p := configInput.Runtime.TraceProbability; p != nil && (*p < 0 || *p > 1) {
+		return fmt.Errorf("invalid trace-probability %v, must be between 0 and 1", *p)
+	}
+
+	manifest, err := w.builder.build(tempDir)
+	if err != nil {
+		return err
+	}
+	report.Checksum = manifest.Checksum
+
+	previous, err := ReadManifest(outputDir)
+	if err != nil {
+		return err
+	}
+
+	// Without a previous build, there is nothing running with an older config
+	if previous != nil {
+		for _, name := range manifest.Changed(previous) {
+			changes, restart, err := diffFile(name, outputDir, tempDir)
+			if err != nil {
+				return err
+			}
+			for _, change := range changes {
+				w.pending[change.Setting] = change
+			}
+			report.RestartRequired = append(report.RestartRequired, restart...)
+		}
+	}
+
+	if w.runtime == nil || !reflect.DeepEqual(w.runtime.TraceProbability, configInput.Runtime.TraceProbability) {
+		if p := configInput.Runtime.TraceProbability; p != nil {
+			value := strconv.FormatFloat(*p, 'f', -1, 64)
+			w.pending["trace_probability"] = RuntimeChange{Setting: "trace_probability", Value: value, Command: []string{"settraceprobability", value}}
+		}
+	}
+	w.runtime = &configInput.Runtime
+
+	for _, f := range manifest.Files {
+		if err := replaceFile(filepath.Join(tempDir, f.Name), filepath.Join(outputDir, f.Name)); err != nil {
+			return err
+		}
+	}
+
+	// Files no longer generated would still be read by Cassandra, only the files of the previous build are removed
+	if previous != nil {
+		for _, f := range previous.Files {
+			if slices.ContainsFunc(manifest.Files, func(current ManifestFile) bool { return current.Name == f.Name }) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(outputDir, f.Name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	return writeManifest(manifest, outputDir)
+}
+
+// replaceFile copies the source over the target with a rename, so that the target is never read half written
+func replaceFile(source, target string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		// Only left behind if the rename was not done
+		if err := os.Remove(tmp.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("Failed to remove temporary file %s: %v", tmp.Name(), err)
+		}
+	}()
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), target)
+}
+
+// diffFile returns the runtime changes for a changed file and the changes which require a restart
+func diffFile(name, previousDir, currentDir string) ([]RuntimeChange, []string, error) {
+	switch name {
+	case "cassandra.yaml":
+		previous, err := readYamlMap(filepath.Join(previousDir, name))
+		if err != nil {
+			return nil, nil, err
+		}
+		current, err := readYamlMap(filepath.Join(currentDir, name))
+		if err != nil {
+			return nil, nil, err
+		}
+		return diffCassandraYaml(previous, current)
+	case logbackConfigName:
+		previous, previousRest, err := readLoggerLevels(filepath.Join(previousDir, name))
+		if err != nil {
+			return nil, nil, err
+		}
+		current, currentRest, err := readLoggerLevels(filepath.Join(currentDir, name))
+		if err != nil {
+			return nil, nil, err
+		}
+		changes, restart := diffLoggerLevels(previous, current)
+		if previousRest != currentRest {
+			// Something else than the levels was changed, such as the appenders
+			restart = append(restart, name)
+		}
+		return changes, restart, nil
+	default:
+		return nil, []string{name}, nil
+	}
+}
+
+// diffCassandraYaml returns the changed keys, the ones in runtimeCassandraYamlSettings are applied with nodetool
+func diffCassandraYaml(previous, current map[string]any) ([]RuntimeChange, []string, error) {
+	keys := slices.Sorted(maps.Keys(previous))
+	for k := range current {
+		if _, found := previous[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	changes := make([]RuntimeChange, 0)
+	restart := make([]string, 0)
+	for _, k := range keys {
+		value, found := current[k]
+		if reflect.DeepEqual(previous[k], value) {
+			continue
+		}
+
+		if toCommand, runtime := runtimeCassandraYamlSettings[k]; runtime && found {
+			command, err := toCommand(fmt.Sprintf("%v", value))
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid %s: %w", k, err)
+			}
+			changes = append(changes, RuntimeChange{Setting: k, Value: fmt.Sprintf("%v", value), Command: command})
+			continue
+		}
+
+		restart = append(restart, "cassandra.yaml:"+k)
+	}
+
+	return changes, restart, nil
+}
+
+// runtimeCassandraYamlSettings are the cassandra.yaml keys which nodetool can change without a restart
+var runtimeCassandraYamlSettings = map[string]func(value string) ([]string, error){
+	"compaction_throughput": func(value string) ([]string, error) {
+		mib, err := dataRateMiB(value)
+		return []string{"setcompactionthroughput", mib}, err
+	},
+	"compaction_throughput_mb_per_sec": func(value string) ([]string, error) {
+		return []string{"setcompactionthroughput", value}, nil
+	},
+	"stream_throughput_outbound": func(value string) ([]string, error) {
+		mib, err := dataRateMiB(value)
+		return []string{"setstreamthroughput", "-m", mib}, err
+	},
+	"stream_throughput_outbound_megabits_per_sec": func(value string) ([]string, error) {
+		return []string{"setstreamthroughput", value}, nil
+	},
+	"inter_dc_stream_throughput_outbound": func(value string) ([]string, error) {
+		mib, err := dataRateMiB(value)
+		return []string{"setinterdcstreamthroughput", "-m", mib}, err
+	},
+	"inter_dc_stream_throughput_outbound_megabits_per_sec": func(value string) ([]string, error) {
+		return []string{"setinterdcstreamthroughput", value}, nil
+	},
+	"concurrent_compactors": func(value string) ([]string, error) {
+		return []string{"setconcurrentcompactors", value}, nil
+	},
+}
+
+// dataRateMiB converts the Cassandra 4.1 data rate, such as 64MiB/s, to MiB/s. nodetool only takes whole MiB/s and
+// treats 0 as unthrottled, the rates which are not a whole number of MiB/s are rejected instead of rounded.
+func dataRateMiB(value string) (string, error) {
+	parts := dataRateRegexp.FindStringSubmatch(value)
+	if parts == nil {
+		return "", fmt.Errorf("unsupported data rate %s", value)
+	}
+
+	rate, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", err
+	}
+
+	unit := int64(1)
+	switch parts[2] {
+	case "B":
+		unit = mebibyte
+	case "KiB":
+		unit = 1024
+	}
+
+	if rate%unit != 0 {
+		return "", fmt.Errorf("data rate %s is not a whole number of MiB/s", value)
+	}
+
+	return strconv.FormatInt(rate/unit, 10), nil
+}
+
+// diffLoggerLevels returns the changed logger levels. Removed loggers can not be reset to the logback.xml value
+// without a restart.
+func diffLoggerLevels(previous, current map[string]string) ([]RuntimeChange, []string) {
+	changes := make([]RuntimeChange, 0)
+	restart := make([]string, 0)
+
+	for _, name := range slices.Sorted(maps.Keys(current)) {
+		if previous[name] != current[name] {
+			changes = append(changes, RuntimeChange{
+				Setting: "logger:" + name,
+				Value:   current[name],
+				Command: []string{"setlogginglevel", name, current[name]},
+			})
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(previous)) {
+		if _, found := current[name]; !found {
+			restart = append(restart, "logback.xml:"+name)
+		}
+	}
+
+	return changes, restart
+}
+
+func readYamlMap(path string) (map[string]any, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]any)
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", filepath.Base(path), err)
+	}
+	return m, nil
+}
+
+// readLoggerLevels returns the levels of the root and the named loggers and the rest of the file without them
+func readLoggerLevels(path string) (map[string]string, string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, "", nil
+		}
+		return nil, "", err
+	}
+
+	root := &xmlNode{}
+	if err := xml.Unmarshal(b, root); err != nil {
+		return nil, "", fmt.Errorf("invalid %s: %w", logbackConfigName, err)
+	}
+
+	levels := make(map[string]string)
+	for _, logger := range root.children("root") {
+		if level := logger.attr("level"); level != "" {
+			levels[rootLoggerName] = strings.ToUpper(level)
+		}
+		logger.setAttr("level", "")
+	}
+
+	loggers := root.children("logger")
+	for _, logger := range loggers {
+		if level := logger.attr("level"); level != "" {
+			levels[logger.attr("name")] = strings.ToUpper(level)
+		}
+	}
+	// Added and removed loggers are handled as level changes
+	root.Nodes = slices.DeleteFunc(root.Nodes, func(n *xmlNode) bool { return slices.Contains(loggers, n) })
+
+	root.trimWhitespace()
+	rest, err := xml.Marshal(root)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return levels, string(rest), nil
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8ssandra/k8ssandra-client/internal/envtest"
+	"github.com/stretchr/testify/require"
+)
+
+var watchConfig = `
+{
+	"cassandra-yaml": {
+		"compaction_throughput": "%s",
+		"concurrent_reads": %d
+	},
+	"logback-xml": {
+		"loggers": {
+			"org.apache.cassandra.db": "%s"
+		}
+	},
+	"runtime": {
+		"trace-probability": 0.01
+	},
+	"cluster-info": {
+		"name": "cluster1",
+		"seeds": "cluster1-seed-service"
+	},
+	"datacenter-info": {
+		"name": "dc1"
+	}
+}
+`
+
+type fakeApplier struct {
+	applied []RuntimeChange
+	fail    bool
+}
+
+func (f *fakeApplier) Apply(ctx context.Context, change RuntimeChange) error {
+	if f.fail {
+		return errors.New("connection refused")
+	}
+	f.applied = append(f.applied, change)
+	return nil
+}
+
+func writeWatchSource(t *testing.T, path, throughput string, concurrentReads int, level string) {
+	t.Helper()
+	data := []byte(fmt.Sprintf(watchConfig, throughput, concurrentReads, level))
+	require.NoError(t, os.WriteFile(path, data, 0644))
+}
+
+func TestWatcherReload(t *testing.T) {
+	require := require.New(t)
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+	outputDir := t.TempDir()
+	source := filepath.Join(t.TempDir(), "config.json")
+
+	t.Setenv("POD_NAME", "cluster1-dc1-r1-sts-0")
+	t.Setenv("POD_IP", "172.27.0.1")
+	t.Setenv("RACK_NAME", "r1")
+
+	writeWatchSource(t, source, "64MiB/s", 32, "INFO")
+	applier := &fakeApplier{fail: true}
+	w := NewWatcher(inputDir, outputDir, source, applier)
+
+	// The first render has nothing to compare to, the runtime settings are pending until the node is reachable
+	report, err := w.Reload(t.Context())
+	require.NoError(err)
+	require.NotNil(report)
+	require.Empty(report.RestartRequired)
+	require.Equal([]RuntimeChange{{Setting: "trace_probability", Value: "0.01", Command: []string{"settraceprobability", "0.01"}}}, report.Failed)
+
+	manifest, err := ReadManifest(outputDir)
+	require.NoError(err)
+	require.Equal(report.Checksum, manifest.Checksum)
+
+	applier.fail = false
+	report, err = w.Reload(t.Context())
+	require.NoError(err)
+	require.Len(report.Applied, 1)
+	require.Empty(report.Failed)
+
+	// Nothing changed
+	report, err = w.Reload(t.Context())
+	require.NoError(err)
+	require.Nil(report)
+
+	writeWatchSource(t, source, "128MiB/s", 64, "DEBUG")
+	report, err = w.Reload(t.Context())
+	require.NoError(err)
+	require.Equal([]string{"cassandra.yaml:concurrent_reads"}, report.RestartRequired)
+	require.Equal([]RuntimeChange{
+		{Setting: "compaction_throughput", Value: "128MiB/s", Command: []string{"setcompactionthroughput", "128"}},
+		{Setting: "logger:org.apache.cassandra.db", Value: "DEBUG", Command: []string{"setlogginglevel", "org.apache.cassandra.db", "DEBUG"}},
+	}, report.Applied)
+
+	// The output has the new config for the next restart
+	cassandraYaml, err := readYamlMap(filepath.Join(outputDir, "cassandra.yaml"))
+	require.NoError(err)
+	require.Equal("64", fmt.Sprintf("%v", cassandraYaml["concurrent_reads"]))
+}
+
+func TestWatcherReplacesFiles(t *testing.T) {
+	require := require.New(t)
+	inputDir := filepath.Join(envtest.RootDir(), "testfiles")
+	outputDir := t.TempDir()
+	source := filepath.Join(t.TempDir(), "config.json")
+
+	t.Setenv("POD_NAME", "cluster1-dc1-r1-sts-0")
+	t.Setenv("POD_IP", "172.27.0.1")
+	t.Setenv("RACK_NAME", "r1")
+
+	writeWatchSource(t, source, "64MiB/s", 32, "INFO")
+	w := NewWatcher(inputDir, outputDir, source, &fakeApplier{})
+	_, err := w.Reload(t.Context())
+	require.NoError(err)
+
+	// A file generated by the previous build, which the next one no longer generates, and a file not generated at all
+	manifest, err := ReadManifest(outputDir)
+	require.NoError(err)
+	manifest.Files = append(manifest.Files, ManifestFile{Name: dseConfigName, Checksum: "sha256:removed"})
+	require.NoError(writeManifest(manifest, outputDir))
+	require.NoError(os.WriteFile(filepath.Join(outputDir, dseConfigName), []byte("server_id: old\n"), 0660))
+	require.NoError(os.WriteFile(filepath.Join(outputDir, "keep.txt"), []byte("keep\n"), 0660))
+
+	writeWatchSource(t, source, "128MiB/s", 32, "INFO")
+	_, err = w.Reload(t.Context())
+	require.NoError(err)
+
+	_, err = os.Stat(filepath.Join(outputDir, dseConfigName))
+	require.True(os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(outputDir, "keep.txt"))
+	require.NoError(err)
+
+	// No temporary files are left behind and the permissions of the generated files are kept
+	entries, err := os.ReadDir(outputDir)
+	require.NoError(err)
+	for _, entry := range entries {
+		require.NotEqual(byte('.'), entry.Name()[0], entry.Name())
+	}
+
+	info, err := os.Stat(filepath.Join(outputDir, "cassandra-env.sh"))
+	require.NoError(err)
+	require.NotZero(info.Mode().Perm() & 0100)
+
+	cassandraYaml, err := readYamlMap(filepath.Join(outputDir, "cassandra.yaml"))
+	require.NoError(err)
+	require.Equal("128MiB/s", cassandraYaml["compaction_throughput"])
+}
+
+func TestReplaceFile(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+
+	source := filepath.Join(dir, "source")
+	target := filepath.Join(dir, "target")
+	require.NoError(os.WriteFile(source, []byte("new"), 0750))
+	require.NoError(os.WriteFile(target, []byte("old content"), 0600))
+
+	require.NoError(replaceFile(source, target))
+
+	b, err := os.ReadFile(target)
+	require.NoError(err)
+	require.Equal("new", string(b))
+
+	info, err := os.Stat(target)
+	require.NoError(err)
+	require.Equal(os.FileMode(0750), info.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(err)
+	require.Len(entries, 2)
+
+	require.Error(replaceFile(filepath.Join(dir, "missing"), target))
+}
+
+func TestDiffCassandraYaml(t *testing.T) {
+	require := require.New(t)
+
+	changes, restart, err := diffCassandraYaml(
+		map[string]any{"stream_throughput_outbound": "24MiB/s", "num_tokens": 16, "concurrent_compactors": 2, "removed": true},
+		map[string]any{"stream_throughput_outbound": "48MiB/s", "num_tokens": 8, "concurrent_compactors": 4, "added": true},
+	)
+	require.NoError(err)
+	require.Equal([]RuntimeChange{
+		{Setting: "concurrent_compactors", Value: "4", Command: []string{"setconcurrentcompactors", "4"}},
+		{Setting: "stream_throughput_outbound", Value: "48MiB/s", Command: []string{"setstreamthroughput", "-m", "48"}},
+	}, changes)
+	require.Equal([]string{"cassandra.yaml:added", "cassandra.yaml:num_tokens", "cassandra.yaml:removed"}, restart)
+
+	_, _, err = diffCassandraYaml(map[string]any{}, map[string]any{"compaction_throughput": "64Mbps"})
+	require.Error(err)
+
+	mib, err := dataRateMiB("2048KiB/s")
+	require.NoError(err)
+	require.Equal("2", mib)
+
+	mib, err = dataRateMiB("0MiB/s")
+	require.NoError(err)
+	require.Equal("0", mib)
+
+	// nodetool would take the truncated 0 as unthrottled
+	_, err = dataRateMiB("512KiB/s")
+	require.Error(err)
+
+	_, _, err = diffCassandraYaml(map[string]any{}, map[string]any{"compaction_throughput": "1048577B/s"})
+	require.Error(err)
+}
+
+func TestDiffLoggerLevels(t *testing.T) {
+	require := require.New(t)
+
+	changes, restart := diffLoggerLevels(
+		map[string]string{"ROOT": "INFO", "org.apache.cassandra": "DEBUG", "com.example": "WARN"},
+		map[string]string{"ROOT": "WARN", "org.apache.cassandra": "DEBUG", "org.apache.cassandra.db": "TRACE"},
+	)
+	require.Equal([]RuntimeChange{
+		{Setting: "logger:ROOT", Value: "WARN", Command: []string{"setlogginglevel", "ROOT", "WARN"}},
+		{Setting: "logger:org.apache.cassandra.db", Value: "TRACE", Command: []string{"setlogginglevel", "org.apache.cassandra.db", "TRACE"}},
+	}, changes)
+	require.Equal([]string{"logback.xml:com.example"}, restart)
+}
@@ -0,0 +1,48 @@
+package diag
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"path"
+	"time"
+)
+
+// archive writes the collected files to a gzipped tar archive under a single top level directory
+type archive struct {
+	gz     *gzip.Writer
+	tw     *tar.Writer
+	prefix string
+	now    time.Time
+}
+
+func newArchive(out io.Writer, prefix string) *archive {
+	gz := gzip.NewWriter(out)
+	return &archive{
+		gz:     gz,
+		tw:     tar.NewWriter(gz),
+		prefix: prefix,
+		now:    time.Now(),
+	}
+}
+
+func (a *archive) add(name string, data []byte) error {
+	header := &tar.Header{
+		Name:    path.Join(a.prefix, name),
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: a.now,
+	}
+	if err := a.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := a.tw.Write(data)
+	return err
+}
+
+func (a *archive) close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
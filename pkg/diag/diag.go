@@ -0,0 +1,409 @@
+package diag
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/k8ssandra/k8ssandra-client/pkg/helmutil"
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/describe"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	CassandraContainer = "cassandra"
+	LoggerContainer    = "server-system-logger"
+
+	// configDir is where the config builder renders the Cassandra config files in the cassandra container
+	configDir = "/config"
+
+	// The k8ssandra-operator labels the CassandraDatacenters it creates with their K8ssandraCluster
+	clusterNameLabel      = "k8ssandra.io/cluster-name"
+	clusterNamespaceLabel = "k8ssandra.io/cluster-namespace"
+	operatorNameLabel     = "app.kubernetes.io/name"
+)
+
+var (
+	// NodetoolCommands are run in the cassandra container of every pod of the datacenter
+	NodetoolCommands = []string{"status", "info", "tpstats", "compactionstats"}
+
+	cassandraDatacenterGVK = schema.GroupVersionKind{Group: "cassandra.datastax.com", Version: "v1beta1", Kind: "CassandraDatacenter"}
+	k8ssandraClusterGVK    = schema.GroupVersionKind{Group: "k8ssandra.io", Version: "v1alpha1", Kind: "K8ssandraCluster"}
+)
+
+// Executor runs a command in a container of the pod and returns its standard output
+type Executor interface {
+	Exec(ctx context.Context, namespace, pod, container string, command []string) ([]byte, error)
+}
+
+// Collector gathers the diagnostics of a datacenter into a bundle. Failing to collect a single file does not stop the
+// collection, the failures are written to errors.txt in the bundle.
+type Collector struct {
+	client       client.Client
+	clientset    kubernetes.Interface
+	executor     Executor
+	nodetoolArgs []string
+	tailLines    int64
+}
+
+// CollectorOption configures the Collector
+type CollectorOption func(*Collector)
+
+// WithNodetoolArgs adds arguments to every nodetool command, such as the authentication parameters
+func WithNodetoolArgs(args []string) CollectorOption {
+	return func(c *Collector) {
+		c.nodetoolArgs = args
+	}
+}
+
+// WithTailLines limits the container logs to the last lines, all the lines are collected if lines is not positive
+func WithTailLines(lines int64) CollectorOption {
+	return func(c *Collector) {
+		c.tailLines = lines
+	}
+}
+
+// NewCollector returns a Collector reading the resources with the client, the logs and pod descriptions with the
+// clientset and running the commands in the pods with the executor
+func NewCollector(c client.Client, clientset kubernetes.Interface, executor Executor, opts ...CollectorOption) *Collector {
+	collector := &Collector{
+		client:    c,
+		clientset: clientset,
+		executor:  executor,
+	}
+	for _, opt := range opts {
+		opt(collector)
+	}
+	return collector
+}
+
+// BundleName returns the timestamped name of the datacenter's diagnostics bundle, without the extension
+func BundleName(dcName string, t time.Time) string {
+	return fmt.Sprintf("%s-diag-%s", dcName, t.UTC().Format("20060102-150405"))
+}
+
+// collection is a single bundle being written
+type collection struct {
+	ctx     context.Context
+	archive *archive
+	errors  []string
+}
+
+func (c *collection) add(name string, data []byte) error {
+	log.Debug("Adding file to the diagnostics bundle", "file", name)
+	return c.archive.add(name, data)
+}
+
+// failed records the failure to collect the file
+func (c *collection) failed(name string, err error) {
+	log.Warn("Failed to collect diagnostics", "file", name, "error", err)
+	c.errors = append(c.errors, fmt.Sprintf("%s: %v", name, err))
+}
+
+// Collect writes the diagnostics bundle of the CassandraDatacenter as a tar.gz archive to out, with the files in the
+// bundle directory. Returns the files which could not be collected.
+func (c *Collector) Collect(ctx context.Context, namespace, dcName, bundle string, out io.Writer) ([]string, error) {
+	dc := &unstructured.Unstructured{}
+	dc.SetGroupVersionKind(cassandraDatacenterGVK)
+	if err := c.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: dcName}, dc); err != nil {
+		return nil, fmt.Errorf("failed to get CassandraDatacenter %s/%s: %w", namespace, dcName, err)
+	}
+
+	col := &collection{ctx: ctx, archive: newArchive(out, bundle)}
+
+	steps := []func(*collection, *unstructured.Unstructured) error{
+		c.collectResources,
+		c.collectEvents,
+		c.collectPods,
+		c.collectOperatorLogs,
+	}
+	for _, step := range steps {
+		if err := step(col, dc); err != nil {
+			return col.errors, err
+		}
+	}
+
+	if len(col.errors) > 0 {
+		if err := col.add("errors.txt", []byte(strings.Join(col.errors, "\n")+"\n")); err != nil {
+			return col.errors, err
+		}
+	}
+
+	return col.errors, col.archive.close()
+}
+
+func (c *Collector) collectResources(col *collection, dc *unstructured.Unstructured) error {
+	if err := addObject(col, "cassandradatacenter.yaml", dc.Object); err != nil {
+		return err
+	}
+
+	clusterName := dc.GetLabels()[clusterNameLabel]
+	if clusterName == "" {
+		return nil
+	}
+
+	clusterNamespace := dc.GetLabels()[clusterNamespaceLabel]
+	if clusterNamespace == "" {
+		clusterNamespace = dc.GetNamespace()
+	}
+
+	kc := &unstructured.Unstructured{}
+	kc.SetGroupVersionKind(k8ssandraClusterGVK)
+	if err := c.client.Get(col.ctx, types.NamespacedName{Namespace: clusterNamespace, Name: clusterName}, kc); err != nil {
+		// The K8ssandraCluster is in the control plane when the datacenter is in a data plane
+		col.failed("k8ssandracluster.yaml", err)
+		return nil
+	}
+
+	return addObject(col, "k8ssandracluster.yaml", kc.Object)
+}
+
+func (c *Collector) collectEvents(col *collection, dc *unstructured.Unstructured) error {
+	events := &corev1.EventList{}
+	if err := c.client.List(col.ctx, events, client.InNamespace(dc.GetNamespace())); err != nil {
+		col.failed("events.yaml", err)
+		return nil
+	}
+
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return eventTime(events.Items[i]).Before(eventTime(events.Items[j]))
+	})
+
+	items := make([]any, 0, len(events.Items))
+	for i := range events.Items {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&events.Items[i])
+		if err != nil {
+			return err
+		}
+		items = append(items, redactObject(obj))
+	}
+
+	return addObject(col, "events.yaml", map[string]any{"items": items})
+}
+
+func eventTime(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// datacenterPods returns the pods of the datacenter's StatefulSets. The owner references are used, the pod labels are
+// incorrect if the datacenter name is overridden.
+func (c *Collector) datacenterPods(ctx context.Context, dc *unstructured.Unstructured) ([]corev1.Pod, error) {
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := c.client.List(ctx, statefulSets, client.InNamespace(dc.GetNamespace())); err != nil {
+		return nil, err
+	}
+
+	owners := make(map[types.UID]bool)
+	for _, sts := range statefulSets.Items {
+		if ownedBy(sts.OwnerReferences, dc.GetUID()) {
+			owners[sts.UID] = true
+		}
+	}
+
+	pods := &corev1.PodList{}
+	if err := c.client.List(ctx, pods, client.InNamespace(dc.GetNamespace())); err != nil {
+		return nil, err
+	}
+
+	dcPods := make([]corev1.Pod, 0)
+	for _, pod := range pods.Items {
+		for _, owner := range pod.OwnerReferences {
+			if owners[owner.UID] {
+				dcPods = append(dcPods, pod)
+				break
+			}
+		}
+	}
+
+	sort.Slice(dcPods, func(i, j int) bool {
+		return dcPods[i].Name < dcPods[j].Name
+	})
+
+	return dcPods, nil
+}
+
+func ownedBy(references []metav1.OwnerReference, uid types.UID) bool {
+	for _, owner := range references {
+		if owner.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Collector) collectPods(col *collection, dc *unstructured.Unstructured) error {
+	pods, err := c.datacenterPods(col.ctx, dc)
+	if err != nil {
+		col.failed("pods", err)
+		return nil
+	}
+
+	for _, pod := range pods {
+		dir := path.Join("pods", pod.Name)
+		log.Info("Collecting pod diagnostics", "pod", pod.Name)
+
+		describer := &describe.PodDescriber{Interface: c.clientset}
+		description, err := describer.Describe(pod.Namespace, pod.Name, describe.DescriberSettings{ShowEvents: true, ChunkSize: 500})
+		if err != nil {
+			col.failed(path.Join(dir, "describe.txt"), err)
+		} else if err := col.add(path.Join(dir, "describe.txt"), redactText([]byte(description))); err != nil {
+			return err
+		}
+
+		for _, container := range []string{CassandraContainer, LoggerContainer} {
+			if err := c.collectLogs(col, pod, container, dir); err != nil {
+				return err
+			}
+		}
+
+		if !hasContainer(pod, CassandraContainer) {
+			continue
+		}
+
+		for _, command := range NodetoolCommands {
+			name := path.Join(dir, "nodetool", command+".txt")
+			cmd := append(append([]string{"nodetool"}, c.nodetoolArgs...), command)
+			output, err := c.executor.Exec(col.ctx, pod.Namespace, pod.Name, CassandraContainer, cmd)
+			if err != nil {
+				col.failed(name, err)
+				continue
+			}
+			if err := col.add(name, output); err != nil {
+				return err
+			}
+		}
+
+		if err := c.collectConfig(col, pod, dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hasContainer(pod corev1.Pod, name string) bool {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// collectLogs adds the container logs and the logs of the previous container if it restarted
+func (c *Collector) collectLogs(col *collection, pod corev1.Pod, container, dir string) error {
+	if !hasContainer(pod, container) {
+		return nil
+	}
+
+	previous := false
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == container && status.RestartCount > 0 {
+			previous = true
+		}
+	}
+
+	if err := c.addLogs(col, pod, container, false, path.Join(dir, "logs", container+".log")); err != nil {
+		return err
+	}
+
+	if previous {
+		return c.addLogs(col, pod, container, true, path.Join(dir, "logs", container+".previous.log"))
+	}
+
+	return nil
+}
+
+func (c *Collector) addLogs(col *collection, pod corev1.Pod, container string, previous bool, name string) error {
+	opts := &corev1.PodLogOptions{Container: container, Previous: previous}
+	if c.tailLines > 0 {
+		opts.TailLines = &c.tailLines
+	}
+
+	logs, err := c.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).DoRaw(col.ctx)
+	if err != nil {
+		col.failed(name, err)
+		return nil
+	}
+
+	// Cassandra logs its configuration at startup and the operators log the objects they reconcile
+	return col.add(name, redactText(logs))
+}
+
+// collectConfig adds the config files rendered to the cassandra container with the sensitive values redacted
+func (c *Collector) collectConfig(col *collection, pod corev1.Pod, dir string) error {
+	output, err := c.executor.Exec(col.ctx, pod.Namespace, pod.Name, CassandraContainer, []string{"find", configDir, "-maxdepth", "1", "-type", "f"})
+	if err != nil {
+		col.failed(path.Join(dir, "config"), err)
+		return nil
+	}
+
+	for _, file := range strings.Fields(string(output)) {
+		name := path.Join(dir, "config", path.Base(file))
+		data, err := c.executor.Exec(col.ctx, pod.Namespace, pod.Name, CassandraContainer, []string{"cat", file})
+		if err != nil {
+			col.failed(name, err)
+			continue
+		}
+		if err := col.add(name, redactText(data)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collectOperatorLogs adds the logs of all the containers of the operator pods in any namespace
+func (c *Collector) collectOperatorLogs(col *collection, _ *unstructured.Unstructured) error {
+	requirement, err := labels.NewRequirement(operatorNameLabel, selection.In, helmutil.OperatorCharts)
+	if err != nil {
+		return err
+	}
+
+	pods := &corev1.PodList{}
+	if err := c.client.List(col.ctx, pods, client.MatchingLabelsSelector{Selector: labels.NewSelector().Add(*requirement)}); err != nil {
+		col.failed("operators", err)
+		return nil
+	}
+
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			name := path.Join("operators", pod.Namespace, pod.Name, container.Name+".log")
+			if err := c.addLogs(col, pod, container.Name, false, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func addObject(col *collection, name string, obj map[string]any) error {
+	b, err := yaml.Marshal(redactObject(obj))
+	if err != nil {
+		return err
+	}
+	return col.add(name, b)
+}
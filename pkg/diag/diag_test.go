@@ -0,0 +1,312 @@
+package diag
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k8ssandra/k8ssandra-client/pkg/config"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeExecutor returns the output of the commands by their joined arguments
+type fakeExecutor struct {
+	outputs  map[string]string
+	commands []string
+}
+
+func (f *fakeExecutor) Exec(ctx context.Context, namespace, pod, container string, command []string) ([]byte, error) {
+	cmd := strings.Join(command, " ")
+	f.commands = append(f.commands, cmd)
+	output, found := f.outputs[cmd]
+	if !found {
+		return nil, errors.New("command terminated with exit code 1")
+	}
+	return []byte(output), nil
+}
+
+func datacenter() *unstructured.Unstructured {
+	dc := &unstructured.Unstructured{}
+	dc.SetGroupVersionKind(cassandraDatacenterGVK)
+	dc.SetNamespace("cassandra")
+	dc.SetName("dc1")
+	dc.SetUID("dc-uid")
+	dc.SetLabels(map[string]string{clusterNameLabel: "demo", clusterNamespaceLabel: "cassandra"})
+	dc.Object["spec"] = map[string]any{
+		"clusterName":         "demo",
+		"superuserSecretName": "demo-superuser",
+		"config": map[string]any{
+			"cassandra-yaml": map[string]any{
+				"client_encryption_options": map[string]any{"keystore": "/etc/keystore", "keystore_password": "changeit"},
+			},
+		},
+	}
+	return dc
+}
+
+func k8ssandraCluster() *unstructured.Unstructured {
+	kc := &unstructured.Unstructured{}
+	kc.SetGroupVersionKind(k8ssandraClusterGVK)
+	kc.SetNamespace("cassandra")
+	kc.SetName("demo")
+	kc.Object["spec"] = map[string]any{"medusa": map[string]any{"storageProperties": map[string]any{"credentials": "s3-secret"}}}
+	return kc
+}
+
+func dcObjects() []client.Object {
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "cassandra",
+			Name:            "demo-dc1-default-sts",
+			UID:             "sts-uid",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "CassandraDatacenter", Name: "dc1", UID: "dc-uid"}},
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "cassandra",
+			Name:            "demo-dc1-default-sts-0",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "StatefulSet", Name: sts.Name, UID: "sts-uid"}},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: CassandraContainer}, {Name: LoggerContainer}}},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{Name: CassandraContainer, RestartCount: 1}, {Name: LoggerContainer}},
+		},
+	}
+
+	// Not owned by the datacenter
+	other := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "cassandra", Name: "other-0"}}
+
+	operator := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "k8ssandra-operator", Name: "cass-operator-abc", Labels: map[string]string{operatorNameLabel: "cass-operator"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "manager"}}},
+	}
+
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "cassandra", Name: "dc1.1"},
+		InvolvedObject: corev1.ObjectReference{Kind: "CassandraDatacenter", Name: "dc1"},
+		Reason:         "ScalingUpRack",
+		LastTimestamp:  metav1.NewTime(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)),
+	}
+
+	return []client.Object{datacenter(), k8ssandraCluster(), sts, pod, other, operator, event}
+}
+
+func newFakeClient(t *testing.T, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(cassandraDatacenterGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(k8ssandraClusterGVK, &unstructured.Unstructured{})
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func readBundle(t *testing.T, data []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		b, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(b)
+	}
+	return files
+}
+
+func TestCollect(t *testing.T) {
+	require := require.New(t)
+
+	objs := dcObjects()
+	clientset := kubefake.NewClientset(objs[3].(*corev1.Pod), objs[5].(*corev1.Pod))
+	executor := &fakeExecutor{outputs: map[string]string{
+		"nodetool --username admin --password secret status":  "UN  10.0.0.1",
+		"nodetool --username admin --password secret info":    "ID : 1234",
+		"nodetool --username admin --password secret tpstats": "Pool Name",
+		"find /config -maxdepth 1 -type f":                    "/config/cassandra.yaml\n/config/jvm-server.options\n",
+		"cat /config/cassandra.yaml":                          "cluster_name: demo\nclient_encryption_options:\n  keystore_password: changeit\n",
+		"cat /config/jvm-server.options":                      "-Xmx1G\n",
+	}}
+
+	collector := NewCollector(newFakeClient(t, objs...), clientset, executor, WithNodetoolArgs([]string{"--username", "admin", "--password", "secret"}))
+
+	var out bytes.Buffer
+	failures, err := collector.Collect(t.Context(), "cassandra", "dc1", "dc1-diag", &out)
+	require.NoError(err)
+	require.Equal([]string{"pods/demo-dc1-default-sts-0/nodetool/compactionstats.txt: command terminated with exit code 1"}, failures)
+
+	files := readBundle(t, out.Bytes())
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	require.ElementsMatch([]string{
+		"dc1-diag/cassandradatacenter.yaml",
+		"dc1-diag/k8ssandracluster.yaml",
+		"dc1-diag/events.yaml",
+		"dc1-diag/pods/demo-dc1-default-sts-0/describe.txt",
+		"dc1-diag/pods/demo-dc1-default-sts-0/logs/cassandra.log",
+		"dc1-diag/pods/demo-dc1-default-sts-0/logs/cassandra.previous.log",
+		"dc1-diag/pods/demo-dc1-default-sts-0/logs/server-system-logger.log",
+		"dc1-diag/pods/demo-dc1-default-sts-0/nodetool/status.txt",
+		"dc1-diag/pods/demo-dc1-default-sts-0/nodetool/info.txt",
+		"dc1-diag/pods/demo-dc1-default-sts-0/nodetool/tpstats.txt",
+		"dc1-diag/pods/demo-dc1-default-sts-0/config/cassandra.yaml",
+		"dc1-diag/pods/demo-dc1-default-sts-0/config/jvm-server.options",
+		"dc1-diag/operators/k8ssandra-operator/cass-operator-abc/manager.log",
+		"dc1-diag/errors.txt",
+	}, names)
+
+	require.Contains(files["dc1-diag/cassandradatacenter.yaml"], "keystore_password: <redacted>")
+	require.Contains(files["dc1-diag/cassandradatacenter.yaml"], "superuserSecretName: demo-superuser")
+	require.Contains(files["dc1-diag/k8ssandracluster.yaml"], "credentials: <redacted>")
+	require.Contains(files["dc1-diag/events.yaml"], "ScalingUpRack")
+	require.Equal("cluster_name: demo\nclient_encryption_options:\n  keystore_password: <redacted>\n", files["dc1-diag/pods/demo-dc1-default-sts-0/config/cassandra.yaml"])
+	require.Equal("UN  10.0.0.1", files["dc1-diag/pods/demo-dc1-default-sts-0/nodetool/status.txt"])
+	require.NotContains(files["dc1-diag/errors.txt"], "secret")
+}
+
+func TestCollectMissingDatacenter(t *testing.T) {
+	require := require.New(t)
+
+	collector := NewCollector(newFakeClient(t), kubefake.NewClientset(), &fakeExecutor{})
+	var out bytes.Buffer
+	_, err := collector.Collect(t.Context(), "cassandra", "dc1", "dc1-diag", &out)
+	require.ErrorContains(err, "failed to get CassandraDatacenter cassandra/dc1")
+	require.Zero(out.Len())
+}
+
+func TestRedactText(t *testing.T) {
+	require := require.New(t)
+
+	config := `authenticator: PasswordAuthenticator
+keystore_password: changeit
+  truststore_password: "changeit"
+-Djavax.net.ssl.keyStorePassword=changeit
+AWS_SECRET_ACCESS_KEY=abc
+export FOO_PASSWORD="two words" FOO_USER=cassandra
+JVM_OPTS="$JVM_OPTS -Djavax.net.ssl.trustStorePassword=changeit -Dcassandra.ring_delay_ms=0"
+`
+	require.Equal(`authenticator: PasswordAuthenticator
+keystore_password: <redacted>
+  truststore_password: <redacted>
+-Djavax.net.ssl.keyStorePassword=<redacted>
+AWS_SECRET_ACCESS_KEY=<redacted>
+export FOO_PASSWORD=<redacted> FOO_USER=cassandra
+JVM_OPTS="$JVM_OPTS -Djavax.net.ssl.trustStorePassword=<redacted> -Dcassandra.ring_delay_ms=0"
+`, string(redactText([]byte(config))))
+}
+
+func TestRedactDescription(t *testing.T) {
+	require := require.New(t)
+
+	description := `Containers:
+  cassandra:
+    Environment:
+      CONFIG_FILE_DATA:  {"cassandra-yaml":{"authenticator":"PasswordAuthenticator","server_encryption_options":{"keystore_password":"s3cr3t","truststore_password": "tru\"st"}},"cluster-info":{"name":"demo"}}
+      POD_IP:             (v1:status.podIP)
+`
+	require.Equal(`Containers:
+  cassandra:
+    Environment:
+      CONFIG_FILE_DATA:  {"cassandra-yaml":{"authenticator":"PasswordAuthenticator","server_encryption_options":{"keystore_password":"<redacted>","truststore_password": "<redacted>"}},"cluster-info":{"name":"demo"}}
+      POD_IP:             (v1:status.podIP)
+`, string(redactText([]byte(description))))
+
+	logs := `{"level":"info","msg":"reconciling","config":{"jmx_password":12345,"name":"demo"}}
+INFO  [main] Config.java:1234 - Node configuration:[keystore_password=changeit, cluster_name=demo]
+`
+	require.Equal(`{"level":"info","msg":"reconciling","config":{"jmx_password":"<redacted>","name":"demo"}}
+INFO  [main] Config.java:1234 - Node configuration:[keystore_password=<redacted> cluster_name=demo]
+`, string(redactText([]byte(logs))))
+}
+
+func TestRedactCassandraEnv(t *testing.T) {
+	require := require.New(t)
+	tempDir := t.TempDir()
+
+	t.Setenv("CONFIG_FILE_DATA", `{
+	"cassandra-env-sh": {
+		"env": {
+			"BACKUP_TOKEN": "s3cr3t-token"
+		},
+		"jmx": {
+			"local": false,
+			"port": 7199,
+			"rmi-port": 7200,
+			"ssl": {
+				"enabled": true,
+				"keystore": "/etc/jmx/keystore.jks"
+			}
+		},
+		"additional-jvm-opts": [
+			"-Djavax.net.ssl.keyStorePassword=k3yst0re-pw",
+			"-Djavax.net.ssl.trustStorePassword=trustst0re-pw"
+		]
+	},
+	"cluster-info": {
+		"name": "cluster1",
+		"seeds": "cluster1-seed-service"
+	},
+	"datacenter-info": {
+		"name": "dc1"
+	}
+}`)
+	t.Setenv("POD_NAME", "cluster1-dc1-r1-sts-0")
+	t.Setenv("POD_IP", "172.27.0.1")
+	t.Setenv("RACK_NAME", "r1")
+
+	require.NoError(config.NewBuilder(filepath.Join("..", "..", "testfiles"), tempDir).Build(t.Context()))
+	env, err := os.ReadFile(filepath.Join(tempDir, "cassandra-env.sh"))
+	require.NoError(err)
+	require.Contains(string(env), "k3yst0re-pw")
+
+	redactedEnv := string(redactText(env))
+	require.NotContains(redactedEnv, "s3cr3t-token")
+	require.NotContains(redactedEnv, "k3yst0re-pw")
+	require.NotContains(redactedEnv, "trustst0re-pw")
+	require.Contains(redactedEnv, "export BACKUP_TOKEN=<redacted>\n")
+	require.Contains(redactedEnv, `JVM_OPTS="$JVM_OPTS -Djavax.net.ssl.keyStorePassword=<redacted>"`)
+	require.Contains(redactedEnv, `JVM_OPTS="$JVM_OPTS -Djavax.net.ssl.keyStore=/etc/jmx/keystore.jks"`)
+}
+
+func TestBundleName(t *testing.T) {
+	require.Equal(t, "dc1-diag-20260102-030405", BundleName("dc1", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+}
+
+func TestDatacenterPods(t *testing.T) {
+	require := require.New(t)
+
+	c := newFakeClient(t, dcObjects()...)
+	dc := datacenter()
+	require.NoError(c.Get(t.Context(), types.NamespacedName{Namespace: "cassandra", Name: "dc1"}, dc))
+
+	pods, err := NewCollector(c, nil, nil).datacenterPods(t.Context(), dc)
+	require.NoError(err)
+	require.Len(pods, 1)
+	require.Equal("demo-dc1-default-sts-0", pods[0].Name)
+}
@@ -0,0 +1,68 @@
+package diag
+
+import (
+	"regexp"
+)
+
+const redacted = "<redacted>"
+
+var (
+	// sensitiveKey matches the keys of the values which are removed from the collected resources and config files
+	sensitiveKey = regexp.MustCompile(`(?i)(password|passwd|token|credentials|secret_key|access_key|private_key)`)
+
+	// sensitiveLine matches key: value and key=value lines with a sensitive key in config files and descriptions
+	sensitiveLine = regexp.MustCompile(`(?im)^(\s*-?\s*"?[\w.-]*(?:password|passwd|token|credentials|secret_key|access_key|private_key)[\w.-]*"?\s*[:=]\s*).+$`)
+
+	// sensitiveAssignment matches key=value tokens with a sensitive key anywhere on the line, such as the system
+	// properties in JVM_OPTS and the exported variables of cassandra-env.sh. The value ends at a quote or whitespace,
+	// unless it's quoted itself.
+	sensitiveAssignment = regexp.MustCompile(`(?i)([\w.-]*(?:password|passwd|token|credentials|secret_key|access_key|private_key)[\w.-]*=)("[^"\n]*"|'[^'\n]*'|[^\s"']+)`)
+
+	// sensitiveJSONField matches "key":"value" pairs with a sensitive key anywhere on the line, such as the
+	// CONFIG_FILE_DATA in the pod descriptions and the JSON logs of the operators
+	sensitiveJSONField = regexp.MustCompile(`(?i)("[\w.-]*(?:password|passwd|token|credentials|secret_key|access_key|private_key)[\w.-]*"\s*:\s*)("(?:[^"\\\n]|\\.)*"|[^\s,}\]]+)`)
+)
+
+// redactObject replaces the values of the sensitive keys in the object and removes the managed fields
+func redactObject(obj map[string]any) map[string]any {
+	if metadata, ok := obj["metadata"].(map[string]any); ok {
+		delete(metadata, "managedFields")
+		if annotations, ok := metadata["annotations"].(map[string]any); ok {
+			// The last applied configuration has the whole object unredacted
+			delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+		}
+	}
+	return redactValue(obj).(map[string]any)
+}
+
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		redactedMap := make(map[string]any, len(v))
+		for key, child := range v {
+			if sensitiveKey.MatchString(key) {
+				if _, nested := child.(map[string]any); !nested {
+					redactedMap[key] = redacted
+					continue
+				}
+			}
+			redactedMap[key] = redactValue(child)
+		}
+		return redactedMap
+	case []any:
+		redactedList := make([]any, 0, len(v))
+		for _, child := range v {
+			redactedList = append(redactedList, redactValue(child))
+		}
+		return redactedList
+	default:
+		return v
+	}
+}
+
+// redactText replaces the values of the sensitive keys in config files, descriptions and logs
+func redactText(data []byte) []byte {
+	data = sensitiveLine.ReplaceAll(data, []byte("${1}"+redacted))
+	data = sensitiveJSONField.ReplaceAll(data, []byte(`${1}"`+redacted+`"`))
+	return sensitiveAssignment.ReplaceAll(data, []byte("${1}"+redacted))
+}
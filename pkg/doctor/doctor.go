@@ -0,0 +1,570 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/k8ssandra/k8ssandra-client/pkg/helmutil"
+	"github.com/k8ssandra/k8ssandra-client/pkg/kubernetes"
+	release "helm.sh/helm/v4/pkg/release/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// MinKubernetesVersion and MaxKubernetesVersion are the oldest and newest Kubernetes minor versions the operators
+	// are tested with
+	MinKubernetesVersion = "1.30"
+	MaxKubernetesVersion = "1.36"
+
+	// certManagerInjectAnnotation is set on the webhook configurations which get their CA bundle from cert-manager
+	certManagerInjectAnnotation = "cert-manager.io/inject-ca-from"
+	certManagerCRD              = "certificates.cert-manager.io"
+	certManagerNameLabel        = "app.kubernetes.io/name"
+)
+
+// Status is the outcome of a single check
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Result is the outcome of a single check with a human readable explanation
+type Result struct {
+	Check   string `json:"check"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// Report has the results of all the checks
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Failed is true if any of the checks failed
+func (r Report) Failed() bool {
+	return slices.ContainsFunc(r.Results, func(result Result) bool {
+		return result.Status == StatusFail
+	})
+}
+
+// Count returns the number of checks with the status
+func (r Report) Count(status Status) int {
+	count := 0
+	for _, result := range r.Results {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Permission is an operation the plugin needs to be allowed to do
+type Permission struct {
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+	// Namespaced permissions are checked in the namespace of the Doctor
+	Namespaced bool
+}
+
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource = fmt.Sprintf("%s.%s", p.Resource, p.Group)
+	}
+	if p.Subresource != "" {
+		resource = fmt.Sprintf("%s/%s", resource, p.Subresource)
+	}
+	return fmt.Sprintf("%s %s", p.Verb, resource)
+}
+
+// PluginPermissions are the operations of the plugin commands: the CRD upgrades, the Helm releases which are stored in
+// Secrets, the datacenter operations and nodetool. The custom resources migrated by the CRD upgrades depend on the
+// charts and are added by migrationPermissions.
+var PluginPermissions = []Permission{
+	// CRD upgrades create the new CRDs, apply the existing ones and remove the migrated storedVersions
+	{Verb: "get", Group: apiextensionsv1.GroupName, Resource: "customresourcedefinitions"},
+	{Verb: "create", Group: apiextensionsv1.GroupName, Resource: "customresourcedefinitions"},
+	{Verb: "patch", Group: apiextensionsv1.GroupName, Resource: "customresourcedefinitions"},
+	{Verb: "update", Group: apiextensionsv1.GroupName, Resource: "customresourcedefinitions", Subresource: "status"},
+	// Helm release storage
+	{Verb: "get", Resource: "secrets", Namespaced: true},
+	{Verb: "list", Resource: "secrets", Namespaced: true},
+	{Verb: "create", Resource: "secrets", Namespaced: true},
+	{Verb: "update", Resource: "secrets", Namespaced: true},
+	{Verb: "delete", Resource: "secrets", Namespaced: true},
+	{Verb: "list", Group: appsv1.GroupName, Resource: "deployments", Namespaced: true},
+	// Stopping and starting the datacenters, nodetool fetches the pod's datacenter
+	{Verb: "get", Group: "cassandra.datastax.com", Resource: "cassandradatacenters", Namespaced: true},
+	{Verb: "update", Group: "cassandra.datastax.com", Resource: "cassandradatacenters", Namespaced: true},
+	{Verb: "list", Resource: "pods", Namespaced: true},
+	{Verb: "get", Resource: "pods", Namespaced: true},
+	{Verb: "get", Group: appsv1.GroupName, Resource: "statefulsets", Namespaced: true},
+	{Verb: "create", Resource: "pods", Subresource: "exec", Namespaced: true},
+}
+
+// migrationPermissions are the operations of the CRD upgrades on the custom resources of the operator charts, the
+// stored objects are listed and rewritten in all the namespaces
+func migrationPermissions(operators []*release.Release) ([]Permission, error) {
+	permissions := make([]Permission, 0)
+	seen := make(map[string]bool)
+	for _, rel := range operators {
+		crds, err := helmutil.ReleaseCRDs(rel)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, crd := range crds {
+			group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+			plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+			if group == "" || plural == "" || seen[plural+"."+group] {
+				continue
+			}
+			seen[plural+"."+group] = true
+
+			for _, verb := range []string{"list", "update"} {
+				permissions = append(permissions, Permission{Verb: verb, Group: group, Resource: plural})
+			}
+		}
+	}
+
+	return permissions, nil
+}
+
+// Doctor checks that the cluster has healthy operators before running operations against it
+type Doctor struct {
+	client    client.Client
+	version   discovery.ServerVersionInterface
+	namespace string
+
+	minVersion *semver.Version
+	maxVersion *semver.Version
+}
+
+// NewDoctor returns a Doctor checking the cluster with the client. The namespaced permissions are checked in
+// namespace, empty namespace checks them in all the namespaces. The supported Kubernetes versions are minVersion to
+// maxVersion (major.minor).
+func NewDoctor(c client.Client, serverVersion discovery.ServerVersionInterface, namespace, minVersion, maxVersion string) (*Doctor, error) {
+	minV, err := semver.NewVersion(minVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minimum Kubernetes version %s: %w", minVersion, err)
+	}
+
+	maxV, err := semver.NewVersion(maxVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maximum Kubernetes version %s: %w", maxVersion, err)
+	}
+
+	if minV.GreaterThan(maxV) {
+		return nil, fmt.Errorf("minimum Kubernetes version %s is newer than the maximum %s", minVersion, maxVersion)
+	}
+
+	return &Doctor{
+		client:     c,
+		version:    serverVersion,
+		namespace:  namespace,
+		minVersion: minV,
+		maxVersion: maxV,
+	}, nil
+}
+
+// OperatorReleases returns the releases of the operator charts
+func OperatorReleases(releases []*release.Release) []*release.Release {
+	operators := make([]*release.Release, 0, len(releases))
+	for _, rel := range releases {
+		if rel.Chart != nil && rel.Chart.Metadata != nil && helmutil.ValidateOperatorChart(rel.Chart.Metadata.Name) == nil {
+			operators = append(operators, rel)
+		}
+	}
+
+	sort.Slice(operators, func(i, j int) bool {
+		if operators[i].Namespace != operators[j].Namespace {
+			return operators[i].Namespace < operators[j].Namespace
+		}
+		return operators[i].Name < operators[j].Name
+	})
+
+	return operators
+}
+
+// Run checks the cluster and the operator releases. Failing to run a check is reported as a failed check, an error
+// is only returned if the context is done.
+func (d *Doctor) Run(ctx context.Context, releases []*release.Release) (Report, error) {
+	report := Report{}
+	add := func(results ...Result) {
+		report.Results = append(report.Results, results...)
+	}
+
+	add(d.checkKubernetesVersion())
+
+	operators := OperatorReleases(releases)
+	if len(operators) == 0 {
+		add(Result{Check: "operators", Status: StatusFail, Message: fmt.Sprintf("no %s release installed", strings.Join(helmutil.OperatorCharts, " or "))})
+	}
+
+	for _, rel := range operators {
+		add(d.checkCRDs(ctx, rel), d.checkDeployments(ctx, rel))
+	}
+
+	add(d.checkWebhooks(ctx, operators)...)
+	add(d.checkCertManager(ctx))
+	add(d.checkPermissions(ctx, operators))
+
+	return report, ctx.Err()
+}
+
+func releaseName(rel *release.Release) string {
+	return fmt.Sprintf("%s/%s", rel.Namespace, rel.Name)
+}
+
+func (d *Doctor) checkKubernetesVersion() Result {
+	result := Result{Check: "kubernetes version"}
+
+	info, err := d.version.ServerVersion()
+	if err != nil {
+		result.Status, result.Message = StatusFail, fmt.Sprintf("failed to get the server version: %v", err)
+		return result
+	}
+
+	current, err := serverVersion(info)
+	if err != nil {
+		result.Status, result.Message = StatusWarn, err.Error()
+		return result
+	}
+
+	supported := fmt.Sprintf("%d.%d to %d.%d", d.minVersion.Major(), d.minVersion.Minor(), d.maxVersion.Major(), d.maxVersion.Minor())
+	switch {
+	case current.LessThan(d.minVersion):
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("%s is older than the supported versions %s", info.GitVersion, supported)
+	case current.GreaterThan(d.maxVersion):
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("%s is newer than the tested versions %s", info.GitVersion, supported)
+	default:
+		result.Status = StatusPass
+		result.Message = fmt.Sprintf("%s is within the supported versions %s", info.GitVersion, supported)
+	}
+
+	return result
+}
+
+// serverVersion returns the major.minor version of the server, ignoring the patch and the provider suffixes such as
+// v1.31.2-gke.1000 or a 31+ minor version
+func serverVersion(info *version.Info) (*semver.Version, error) {
+	v, err := semver.NewVersion(info.GitVersion)
+	if err == nil {
+		return semver.New(v.Major(), v.Minor(), 0, "", ""), nil
+	}
+
+	v, err = semver.NewVersion(fmt.Sprintf("%s.%s", info.Major, strings.TrimSuffix(info.Minor, "+")))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the server version %s", info.GitVersion)
+	}
+	return v, nil
+}
+
+func (d *Doctor) checkCRDs(ctx context.Context, rel *release.Release) Result {
+	result := Result{Check: fmt.Sprintf("crds %s", releaseName(rel))}
+	chartVersion := rel.Chart.Metadata.Version
+
+	statuses, err := helmutil.CompareReleaseCRDs(ctx, d.client, rel)
+	if err != nil {
+		result.Status, result.Message = StatusFail, err.Error()
+		return result
+	}
+
+	missing, differs := make([]string, 0), make([]string, 0)
+	for _, status := range statuses {
+		switch status.State {
+		case helmutil.CRDMissing:
+			missing = append(missing, status.Name)
+		case helmutil.CRDDiffers:
+			differs = append(differs, status.Name)
+		}
+	}
+
+	switch {
+	case len(missing) > 0:
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("missing CRDs of %s %s: %s", rel.Chart.Metadata.Name, chartVersion, strings.Join(missing, ", "))
+	case len(differs) > 0:
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("CRDs do not match %s %s, upgrade them with helm crds upgrade: %s", rel.Chart.Metadata.Name, chartVersion, strings.Join(differs, ", "))
+	default:
+		result.Status = StatusPass
+		result.Message = fmt.Sprintf("%d CRDs match %s %s", len(statuses), rel.Chart.Metadata.Name, chartVersion)
+	}
+
+	return result
+}
+
+func (d *Doctor) checkDeployments(ctx context.Context, rel *release.Release) Result {
+	result := Result{Check: fmt.Sprintf("deployments %s", releaseName(rel))}
+
+	deployments := &appsv1.DeploymentList{}
+	if err := d.client.List(ctx, deployments, client.InNamespace(rel.Namespace), client.MatchingLabels{helmutil.InstanceLabel: rel.Name}); err != nil {
+		result.Status, result.Message = StatusFail, fmt.Sprintf("failed to list the deployments: %v", err)
+		return result
+	}
+
+	if len(deployments.Items) == 0 {
+		result.Status, result.Message = StatusFail, "no deployments found for the release"
+		return result
+	}
+
+	ready, notReady := make([]string, 0), make([]string, 0)
+	for i := range deployments.Items {
+		if kubernetes.DeploymentReady(&deployments.Items[i]) {
+			ready = append(ready, deployments.Items[i].Name)
+		} else {
+			notReady = append(notReady, deployments.Items[i].Name)
+		}
+	}
+
+	if len(notReady) > 0 {
+		result.Status, result.Message = StatusFail, fmt.Sprintf("not ready: %s", strings.Join(notReady, ", "))
+		return result
+	}
+
+	result.Status, result.Message = StatusPass, fmt.Sprintf("ready: %s", strings.Join(ready, ", "))
+	return result
+}
+
+// webhook is a single webhook of the validating or mutating webhook configurations
+type webhook struct {
+	configuration string
+	annotations   map[string]string
+	clientConfig  admissionregistrationv1.WebhookClientConfig
+}
+
+func (d *Doctor) webhooks(ctx context.Context) ([]webhook, error) {
+	webhooks := make([]webhook, 0)
+
+	validating := &admissionregistrationv1.ValidatingWebhookConfigurationList{}
+	if err := d.client.List(ctx, validating); err != nil {
+		return nil, err
+	}
+	for _, configuration := range validating.Items {
+		for _, w := range configuration.Webhooks {
+			webhooks = append(webhooks, webhook{configuration: configuration.Name, annotations: configuration.Annotations, clientConfig: w.ClientConfig})
+		}
+	}
+
+	mutating := &admissionregistrationv1.MutatingWebhookConfigurationList{}
+	if err := d.client.List(ctx, mutating); err != nil {
+		return nil, err
+	}
+	for _, configuration := range mutating.Items {
+		for _, w := range configuration.Webhooks {
+			webhooks = append(webhooks, webhook{configuration: configuration.Name, annotations: configuration.Annotations, clientConfig: w.ClientConfig})
+		}
+	}
+
+	return webhooks, nil
+}
+
+// checkWebhooks checks the webhook configurations of the operators have a CA bundle and their service has ready
+// endpoints to call. The webhooks are selected by their service namespace.
+func (d *Doctor) checkWebhooks(ctx context.Context, operators []*release.Release) []Result {
+	namespaces := make([]string, 0, len(operators))
+	for _, rel := range operators {
+		namespaces = append(namespaces, rel.Namespace)
+	}
+
+	webhooks, err := d.webhooks(ctx)
+	if err != nil {
+		return []Result{{Check: "webhooks", Status: StatusFail, Message: fmt.Sprintf("failed to list the webhook configurations: %v", err)}}
+	}
+
+	problems := make(map[string][]string)
+	configurations := make([]string, 0)
+	for _, w := range webhooks {
+		service := w.clientConfig.Service
+		if service == nil || !slices.Contains(namespaces, service.Namespace) {
+			continue
+		}
+
+		if _, found := problems[w.configuration]; !found {
+			problems[w.configuration] = make([]string, 0)
+			configurations = append(configurations, w.configuration)
+		}
+
+		if problem := d.webhookProblem(ctx, w); problem != "" && !slices.Contains(problems[w.configuration], problem) {
+			problems[w.configuration] = append(problems[w.configuration], problem)
+		}
+	}
+
+	if len(configurations) == 0 {
+		return []Result{{Check: "webhooks", Status: StatusPass, Message: "no operator webhooks configured"}}
+	}
+
+	sort.Strings(configurations)
+	results := make([]Result, 0, len(configurations))
+	for _, configuration := range configurations {
+		result := Result{Check: fmt.Sprintf("webhook %s", configuration), Status: StatusPass, Message: "service has ready endpoints"}
+		if len(problems[configuration]) > 0 {
+			result.Status, result.Message = StatusFail, strings.Join(problems[configuration], ", ")
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func (d *Doctor) webhookProblem(ctx context.Context, w webhook) string {
+	service := w.clientConfig.Service
+	if len(w.clientConfig.CABundle) == 0 {
+		if _, found := w.annotations[certManagerInjectAnnotation]; found {
+			return "no CA bundle, cert-manager has not injected it"
+		}
+		return "no CA bundle"
+	}
+
+	svc := &corev1.Service{}
+	if err := d.client.Get(ctx, client.ObjectKey{Namespace: service.Namespace, Name: service.Name}, svc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Sprintf("service %s/%s not found", service.Namespace, service.Name)
+		}
+		return fmt.Sprintf("failed to get service %s/%s: %v", service.Namespace, service.Name, err)
+	}
+
+	endpointSlices := &discoveryv1.EndpointSliceList{}
+	if err := d.client.List(ctx, endpointSlices, client.InNamespace(service.Namespace), client.MatchingLabels{discoveryv1.LabelServiceName: service.Name}); err != nil {
+		return fmt.Sprintf("failed to list the endpoints of service %s/%s: %v", service.Namespace, service.Name, err)
+	}
+
+	for _, slice := range endpointSlices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				return ""
+			}
+		}
+	}
+
+	return fmt.Sprintf("service %s/%s has no ready endpoints", service.Namespace, service.Name)
+}
+
+// checkCertManager checks cert-manager is installed and ready if any webhook configuration gets its CA bundle from it
+func (d *Doctor) checkCertManager(ctx context.Context) Result {
+	result := Result{Check: "cert-manager"}
+
+	webhooks, err := d.webhooks(ctx)
+	if err != nil {
+		result.Status, result.Message = StatusFail, fmt.Sprintf("failed to list the webhook configurations: %v", err)
+		return result
+	}
+
+	required := slices.ContainsFunc(webhooks, func(w webhook) bool {
+		_, found := w.annotations[certManagerInjectAnnotation]
+		return found
+	})
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	err = d.client.Get(ctx, client.ObjectKey{Name: certManagerCRD}, crd)
+	if apierrors.IsNotFound(err) {
+		if required {
+			result.Status, result.Message = StatusFail, "required by the webhooks, but not installed"
+		} else {
+			result.Status, result.Message = StatusPass, "not installed, not required by any webhook"
+		}
+		return result
+	} else if err != nil {
+		result.Status, result.Message = StatusFail, fmt.Sprintf("failed to get CRD %s: %v", certManagerCRD, err)
+		return result
+	}
+
+	deployments := &appsv1.DeploymentList{}
+	if err := d.client.List(ctx, deployments, client.MatchingLabels{certManagerNameLabel: "cert-manager"}); err != nil {
+		result.Status, result.Message = StatusFail, fmt.Sprintf("failed to list the cert-manager deployments: %v", err)
+		return result
+	}
+
+	status := StatusWarn
+	if required {
+		status = StatusFail
+	}
+
+	if len(deployments.Items) == 0 {
+		result.Status, result.Message = status, "CRDs are installed, but no cert-manager deployment was found"
+		return result
+	}
+
+	for i := range deployments.Items {
+		if !kubernetes.DeploymentReady(&deployments.Items[i]) {
+			result.Status, result.Message = status, fmt.Sprintf("deployment %s/%s is not ready", deployments.Items[i].Namespace, deployments.Items[i].Name)
+			return result
+		}
+	}
+
+	result.Status, result.Message = StatusPass, "installed and ready"
+	return result
+}
+
+// checkPermissions asks the API server if the current user is allowed to do the plugin's operations
+func (d *Doctor) checkPermissions(ctx context.Context, operators []*release.Release) Result {
+	result := Result{Check: "rbac"}
+
+	migration, err := migrationPermissions(operators)
+	if err != nil {
+		result.Status, result.Message = StatusFail, err.Error()
+		return result
+	}
+	permissions := append(slices.Clone(PluginPermissions), migration...)
+
+	denied := make([]string, 0)
+	for _, permission := range permissions {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:        permission.Verb,
+					Group:       permission.Group,
+					Resource:    permission.Resource,
+					Subresource: permission.Subresource,
+				},
+			},
+		}
+		if permission.Namespaced {
+			review.Spec.ResourceAttributes.Namespace = d.namespace
+		}
+
+		if err := d.client.Create(ctx, review); err != nil {
+			result.Status, result.Message = StatusFail, fmt.Sprintf("failed to review access to %s: %v", permission, err)
+			return result
+		}
+
+		if !review.Status.Allowed {
+			denied = append(denied, permission.String())
+		}
+	}
+
+	scope := "in all namespaces"
+	if d.namespace != "" {
+		scope = fmt.Sprintf("in namespace %s", d.namespace)
+	}
+
+	if len(denied) > 0 {
+		result.Status, result.Message = StatusFail, fmt.Sprintf("not allowed %s: %s", scope, strings.Join(denied, ", "))
+		return result
+	}
+
+	result.Status, result.Message = StatusPass, fmt.Sprintf("%d plugin operations allowed %s", len(permissions), scope)
+	return result
+}
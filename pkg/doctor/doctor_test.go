@@ -0,0 +1,309 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/k8ssandra/k8ssandra-client/pkg/helmutil"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v4/pkg/chart/common"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	release "helm.sh/helm/v4/pkg/release/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+const testCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: cassandratasks.control.k8ssandra.io
+spec:
+  group: control.k8ssandra.io
+  names:
+    kind: CassandraTask
+    listKind: CassandraTaskList
+    plural: cassandratasks
+  scope: Namespaced
+  versions:
+    - name: v1alpha1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+`
+
+type fakeServerVersion struct {
+	info *version.Info
+	err  error
+}
+
+func (s fakeServerVersion) ServerVersion() (*version.Info, error) {
+	return s.info, s.err
+}
+
+func operatorRelease() *release.Release {
+	return &release.Release{
+		Name:      "cass-operator",
+		Namespace: "cass-operator",
+		Version:   1,
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: helmutil.CassOperatorChartName, Version: "0.40.0"},
+			Files:    []*common.File{{Name: "crds/cassandratask.yaml", Data: []byte(testCRD)}},
+		},
+	}
+}
+
+func operatorDeployment(ready bool) *appsv1.Deployment {
+	replicas := int32(1)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cass-operator",
+			Namespace: "cass-operator",
+			Labels:    map[string]string{helmutil.InstanceLabel: "cass-operator"},
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+	if ready {
+		deployment.Status = appsv1.DeploymentStatus{UpdatedReplicas: 1, AvailableReplicas: 1}
+	}
+	return deployment
+}
+
+func operatorCRD() *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "cassandratasks.control.k8ssandra.io"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "control.k8ssandra.io",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: "CassandraTask", ListKind: "CassandraTaskList", Plural: "cassandratasks"},
+			Scope: apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{
+					Name:    "v1alpha1",
+					Served:  true,
+					Storage: true,
+					Schema:  &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{Type: "object"}},
+				},
+			},
+		},
+	}
+}
+
+func operatorWebhook(caBundle []byte) *admissionregistrationv1.ValidatingWebhookConfiguration {
+	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "cass-operator-validating-webhook-configuration",
+			Annotations: map[string]string{certManagerInjectAnnotation: "cass-operator/cass-operator-serving-cert"},
+		},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{
+				Name: "vcassandradatacenter.kb.io",
+				ClientConfig: admissionregistrationv1.WebhookClientConfig{
+					Service:  &admissionregistrationv1.ServiceReference{Namespace: "cass-operator", Name: "cass-operator-webhook-service"},
+					CABundle: caBundle,
+				},
+			},
+		},
+	}
+}
+
+func webhookService(ready bool) []client.Object {
+	return []client.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "cass-operator", Name: "cass-operator-webhook-service"}},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "cass-operator",
+				Name:      "cass-operator-webhook-service-abcde",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "cass-operator-webhook-service"},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}}},
+		},
+	}
+}
+
+func certManager() []client.Object {
+	return []client.Object{
+		&apiextensionsv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: certManagerCRD}},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "cert-manager", Namespace: "cert-manager", Labels: map[string]string{certManagerNameLabel: "cert-manager"}},
+			Status:     appsv1.DeploymentStatus{UpdatedReplicas: 1, AvailableReplicas: 1},
+		},
+	}
+}
+
+// newClient returns a fake client allowing the access reviews except for the denied resources
+func newClient(t *testing.T, denied []string, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, apiextensionsv1.AddToScheme(scheme))
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if review, ok := obj.(*authorizationv1.SelfSubjectAccessReview); ok {
+					attributes := review.Spec.ResourceAttributes
+					review.Status.Allowed = true
+					for _, resource := range denied {
+						if attributes.Resource == resource {
+							review.Status.Allowed = false
+						}
+					}
+					return nil
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).
+		Build()
+}
+
+func statuses(report Report) map[string]Status {
+	checks := make(map[string]Status, len(report.Results))
+	for _, result := range report.Results {
+		checks[result.Check] = result.Status
+	}
+	return checks
+}
+
+func newDoctor(t *testing.T, c client.Client, gitVersion string) *Doctor {
+	d, err := NewDoctor(c, fakeServerVersion{info: &version.Info{GitVersion: gitVersion}}, "cass-operator", MinKubernetesVersion, MaxKubernetesVersion)
+	require.NoError(t, err)
+	return d
+}
+
+func TestHealthyCluster(t *testing.T) {
+	require := require.New(t)
+
+	objs := []client.Object{operatorCRD(), operatorDeployment(true), operatorWebhook([]byte("ca"))}
+	objs = append(objs, webhookService(true)...)
+	objs = append(objs, certManager()...)
+
+	d := newDoctor(t, newClient(t, nil, objs...), "v1.31.2-gke.1000")
+	report, err := d.Run(t.Context(), []*release.Release{operatorRelease()})
+	require.NoError(err)
+	require.False(report.Failed())
+
+	require.Equal(map[string]Status{
+		"kubernetes version":                                     StatusPass,
+		"crds cass-operator/cass-operator":                       StatusPass,
+		"deployments cass-operator/cass-operator":                StatusPass,
+		"webhook cass-operator-validating-webhook-configuration": StatusPass,
+		"cert-manager":                                           StatusPass,
+		"rbac":                                                   StatusPass,
+	}, statuses(report))
+}
+
+func TestUnhealthyCluster(t *testing.T) {
+	require := require.New(t)
+
+	objs := []client.Object{operatorDeployment(false), operatorWebhook(nil)}
+	objs = append(objs, webhookService(false)...)
+
+	d := newDoctor(t, newClient(t, []string{"pods"}, objs...), "v1.29.0")
+	report, err := d.Run(t.Context(), []*release.Release{operatorRelease()})
+	require.NoError(err)
+	require.True(report.Failed())
+	require.Equal(6, report.Count(StatusFail))
+
+	for _, result := range report.Results {
+		switch result.Check {
+		case "crds cass-operator/cass-operator":
+			require.Contains(result.Message, "cassandratasks.control.k8ssandra.io")
+		case "webhook cass-operator-validating-webhook-configuration":
+			require.Equal("no CA bundle, cert-manager has not injected it", result.Message)
+		case "cert-manager":
+			require.Equal("required by the webhooks, but not installed", result.Message)
+		case "rbac":
+			require.Equal("not allowed in namespace cass-operator: list pods, get pods, create pods/exec", result.Message)
+		}
+	}
+}
+
+func TestWebhookWithoutEndpoints(t *testing.T) {
+	require := require.New(t)
+
+	objs := []client.Object{operatorCRD(), operatorDeployment(true), operatorWebhook([]byte("ca"))}
+	objs = append(objs, webhookService(false)...)
+	objs = append(objs, certManager()...)
+
+	d := newDoctor(t, newClient(t, nil, objs...), "v1.36.1")
+	report, err := d.Run(t.Context(), []*release.Release{operatorRelease()})
+	require.NoError(err)
+
+	require.Equal(Result{
+		Check:   "webhook cass-operator-validating-webhook-configuration",
+		Status:  StatusFail,
+		Message: "service cass-operator/cass-operator-webhook-service has no ready endpoints",
+	}, report.Results[3])
+}
+
+func TestNoOperators(t *testing.T) {
+	require := require.New(t)
+
+	other := operatorRelease()
+	other.Chart.Metadata.Name = "medusa"
+
+	d := newDoctor(t, newClient(t, nil), "v1.31.0")
+	report, err := d.Run(t.Context(), []*release.Release{other})
+	require.NoError(err)
+
+	require.Equal(map[string]Status{
+		"kubernetes version": StatusPass,
+		"operators":          StatusFail,
+		"webhooks":           StatusPass,
+		"cert-manager":       StatusPass,
+		"rbac":               StatusPass,
+	}, statuses(report))
+}
+
+func TestKubernetesVersion(t *testing.T) {
+	require := require.New(t)
+
+	for gitVersion, expected := range map[string]Status{
+		"v1.29.9":       StatusFail,
+		"v1.30.0":       StatusPass,
+		"v1.34.5+k3s1":  StatusPass,
+		"v1.37.0":       StatusWarn,
+		"not-a-version": StatusWarn,
+	} {
+		d := newDoctor(t, newClient(t, nil), gitVersion)
+		require.Equal(expected, d.checkKubernetesVersion().Status, gitVersion)
+	}
+
+	d, err := NewDoctor(newClient(t, nil), fakeServerVersion{err: errors.New("unreachable")}, "", MinKubernetesVersion, MaxKubernetesVersion)
+	require.NoError(err)
+	require.Equal(StatusFail, d.checkKubernetesVersion().Status)
+
+	_, err = NewDoctor(newClient(t, nil), fakeServerVersion{}, "", "1.34", "1.30")
+	require.Error(err)
+}
+
+func TestMigrationPermissions(t *testing.T) {
+	require := require.New(t)
+
+	d, err := NewDoctor(newClient(t, []string{"cassandratasks"}), fakeServerVersion{}, "", MinKubernetesVersion, MaxKubernetesVersion)
+	require.NoError(err)
+
+	result := d.checkPermissions(t.Context(), []*release.Release{operatorRelease()})
+	require.Equal(StatusFail, result.Status)
+	require.Equal("not allowed in all namespaces: list cassandratasks.control.k8ssandra.io, update cassandratasks.control.k8ssandra.io", result.Message)
+
+	result = newDoctor(t, newClient(t, nil), "v1.31.0").checkPermissions(t.Context(), []*release.Release{operatorRelease(), operatorRelease()})
+	require.Equal(StatusPass, result.Status)
+	require.Equal(fmt.Sprintf("%d plugin operations allowed in namespace cass-operator", len(PluginPermissions)+2), result.Message)
+}
@@ -0,0 +1,402 @@
+package helmutil
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/k8ssandra/k8ssandra-client/pkg/util"
+	chartutil "helm.sh/helm/v4/pkg/chart/v2/util"
+)
+
+const (
+	// chartCacheMetadata is written to the extracted chart release directory after all the files have been extracted
+	chartCacheMetadata = ".k8ssandra-chart.json"
+	// extractPrefix is the prefix of the temporary directories the chart releases are extracted to
+	extractPrefix = ".extract-"
+)
+
+var (
+	// ErrChartCacheIncomplete is returned for cached chart releases without the metadata, such as partially extracted
+	// ones from an interrupted run or the ones extracted by older versions
+	ErrChartCacheIncomplete = fmt.Errorf("chart release cache is incomplete")
+)
+
+// chartMetadata is the digest of the downloaded chart archive and the digests of the extracted files. SignedBy is set
+// if the provenance of the archive was verified.
+type chartMetadata struct {
+	Digest    string            `json:"digest"`
+	SignedBy  string            `json:"signedBy,omitempty"`
+	Extracted time.Time         `json:"extracted"`
+	Files     map[string]string `json:"files"`
+}
+
+// CachedChart is an extracted chart release in the cache
+type CachedChart struct {
+	Repo      string
+	Chart     string
+	Version   string
+	Dir       string
+	Digest    string
+	SignedBy  string
+	Extracted time.Time
+	Size      int64
+}
+
+// WithNoCache makes the Upgrader download the chart release for every upgrade instead of using the cache
+func WithNoCache() UpgraderOption {
+	return func(u *Upgrader) {
+		u.noCache = true
+	}
+}
+
+// ChartCacheDir returns the cache directory of the chart release
+func ChartCacheDir(repoName, chartName, chartVersion string) (string, error) {
+	return util.GetCacheDir(repoName, filepath.Join(chartName, chartVersion))
+}
+
+// ExtractChartRelease extracts the downloaded chart archive to the cache and records the digests of the archive and
+// the extracted files, and the signer of a verified archive. The release is extracted to a temporary directory first,
+// an interrupted extraction never replaces the cached release.
+func ExtractChartRelease(saved, repoName, chartName, chartVersion, signedBy string) (string, error) {
+	extractDir, err := ChartCacheDir(repoName, chartName, chartVersion)
+	if err != nil {
+		return "", err
+	}
+
+	parentDir := filepath.Dir(extractDir)
+	if _, err := util.CreateIfNotExistsDir(parentDir); err != nil {
+		return "", err
+	}
+
+	tmpDir, err := os.MkdirTemp(parentDir, extractPrefix)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Warn("Failed to remove chart extraction directory", "path", tmpDir, "error", err)
+		}
+	}()
+
+	if err := extractChart(saved, signedBy, tmpDir); err != nil {
+		return "", err
+	}
+
+	if err := os.RemoveAll(extractDir); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmpDir, extractDir); err != nil {
+		return "", err
+	}
+
+	return extractDir, nil
+}
+
+// extractChart expands the chart archive to the target directory and writes the metadata
+func extractChart(saved, signedBy, targetDir string) error {
+	digest, err := fileDigest(saved)
+	if err != nil {
+		return err
+	}
+
+	if err := chartutil.ExpandFile(targetDir, saved); err != nil {
+		return err
+	}
+
+	files, err := fileDigests(targetDir)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(chartMetadata{Digest: digest, SignedBy: signedBy, Extracted: time.Now().UTC(), Files: files})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(targetDir, chartCacheMetadata), b, 0644)
+}
+
+// VerifyCachedChart checks that the cached chart release in dir has all the extracted files unmodified
+func VerifyCachedChart(dir string) error {
+	_, err := verifyCachedChart(dir)
+	return err
+}
+
+// verifyCachedChart verifies the cached chart release and returns its metadata
+func verifyCachedChart(dir string) (*chartMetadata, error) {
+	metadata, err := readChartMetadata(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fileDigests(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for path, digest := range metadata.Files {
+		actual, found := files[path]
+		if !found {
+			return nil, fmt.Errorf("chart release cache is missing %s", path)
+		}
+		if actual != digest {
+			return nil, fmt.Errorf("chart release cache has modified %s", path)
+		}
+	}
+
+	for path := range files {
+		if _, found := metadata.Files[path]; !found {
+			return nil, fmt.Errorf("chart release cache has unexpected file %s", path)
+		}
+	}
+
+	return metadata, nil
+}
+
+func readChartMetadata(dir string) (*chartMetadata, error) {
+	b, err := os.ReadFile(filepath.Join(dir, chartCacheMetadata))
+	if os.IsNotExist(err) {
+		return nil, ErrChartCacheIncomplete
+	} else if err != nil {
+		return nil, err
+	}
+
+	var metadata chartMetadata
+	if err := json.Unmarshal(b, &metadata); err != nil {
+		return nil, fmt.Errorf("%w, unreadable metadata: %v", ErrChartCacheIncomplete, err)
+	}
+
+	return &metadata, nil
+}
+
+// ListChartCache returns the cached chart releases of the repository
+func ListChartCache(repoName string) ([]CachedChart, error) {
+	repoDir, err := util.GetCacheDir(repoName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	chartDirs, err := os.ReadDir(repoDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	cached := make([]CachedChart, 0)
+	for _, chartDir := range chartDirs {
+		if !chartDir.IsDir() || strings.HasPrefix(chartDir.Name(), ".") {
+			continue
+		}
+
+		versionDirs, err := os.ReadDir(filepath.Join(repoDir, chartDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, versionDir := range versionDirs {
+			if !versionDir.IsDir() || strings.HasPrefix(versionDir.Name(), ".") ||
+				isLegacyChartDir(chartDir.Name(), filepath.Join(repoDir, chartDir.Name(), versionDir.Name())) {
+				continue
+			}
+
+			entry := CachedChart{
+				Repo:    repoName,
+				Chart:   chartDir.Name(),
+				Version: versionDir.Name(),
+				Dir:     filepath.Join(repoDir, chartDir.Name(), versionDir.Name()),
+			}
+
+			if metadata, err := readChartMetadata(entry.Dir); err == nil {
+				entry.Digest = metadata.Digest
+				entry.SignedBy = metadata.SignedBy
+				entry.Extracted = metadata.Extracted
+			}
+
+			if entry.Size, err = dirSize(entry.Dir); err != nil {
+				return nil, err
+			}
+
+			cached = append(cached, entry)
+		}
+	}
+
+	sort.Slice(cached, func(i, j int) bool {
+		if cached[i].Chart != cached[j].Chart {
+			return cached[i].Chart < cached[j].Chart
+		}
+		return cached[i].Version < cached[j].Version
+	})
+
+	return cached, nil
+}
+
+// CleanChartCache removes the cached releases of the chart, or of all the charts in the repository if chartName is
+// empty, including the unversioned releases extracted by the older versions. Returns the removed directories.
+func CleanChartCache(repoName, chartName string) ([]string, error) {
+	cached, err := ListChartCache(repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make([]string, 0, len(cached))
+	for _, entry := range cached {
+		if chartName != "" && entry.Chart != chartName {
+			continue
+		}
+
+		if err := os.RemoveAll(entry.Dir); err != nil {
+			return removed, err
+		}
+		removed = append(removed, entry.Dir)
+	}
+
+	// Leftovers of interrupted extractions
+	pattern, err := util.GetCacheDir(repoName, filepath.Join("*", extractPrefix+"*"))
+	if chartName != "" {
+		pattern, err = util.GetCacheDir(repoName, filepath.Join(chartName, extractPrefix+"*"))
+	}
+	if err != nil {
+		return removed, err
+	}
+
+	leftovers, err := filepath.Glob(pattern)
+	if err != nil {
+		return removed, err
+	}
+
+	// Releases extracted by the older versions
+	legacy, err := legacyChartDirs(repoName, chartName)
+	if err != nil {
+		return removed, err
+	}
+	leftovers = append(leftovers, legacy...)
+
+	for _, leftover := range leftovers {
+		if err := os.RemoveAll(leftover); err != nil {
+			return removed, err
+		}
+		removed = append(removed, leftover)
+	}
+
+	return removed, nil
+}
+
+// isLegacyChartDir reports if dir is a chart release extracted by the older versions without the version directory,
+// to <repo>/<chart>/<chart>. The versioned releases have the chart one level deeper.
+func isLegacyChartDir(chartName, dir string) bool {
+	if filepath.Base(dir) != chartName {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, chartutil.ChartfileName))
+	return err == nil
+}
+
+// legacyChartDirs returns the chart releases extracted by the older versions, of the chart or of all the charts in the
+// repository if chartName is empty
+func legacyChartDirs(repoName, chartName string) ([]string, error) {
+	pattern, err := util.GetCacheDir(repoName, filepath.Join("*", "*"))
+	if chartName != "" {
+		pattern, err = util.GetCacheDir(repoName, filepath.Join(chartName, chartName))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	legacy := make([]string, 0)
+	for _, dir := range matches {
+		if isLegacyChartDir(filepath.Base(filepath.Dir(dir)), dir) {
+			legacy = append(legacy, dir)
+		}
+	}
+	return legacy, nil
+}
+
+// removeDownload removes the temporary directory of the downloaded chart archive
+func removeDownload(saved string) {
+	removeDir(filepath.Dir(saved))
+}
+
+func removeDir(dir string) {
+	if err := os.RemoveAll(dir); err != nil {
+		log.Warn("Failed to remove chart download directory", "path", dir, "error", err)
+	}
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+// fileDigests returns the digests of the files under dir, except the metadata
+func fileDigests(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == chartCacheMetadata {
+			return nil
+		}
+
+		digest, err := fileDigest(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = digest
+		return nil
+	})
+	return files, err
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
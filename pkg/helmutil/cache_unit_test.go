@@ -0,0 +1,212 @@
+package helmutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/require"
+	chartutil "helm.sh/helm/v4/pkg/chart/v2/util"
+	"helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// chartRepository serves the chart releases from a directory as a Helm repository. The releases are signed if signer
+// is set.
+func chartRepository(t *testing.T, signer *openpgp.Entity, versions ...string) (*httptest.Server, *int) {
+	dir := t.TempDir()
+	for _, version := range versions {
+		ch := testChart(version)
+		archive, err := chartutil.Save(ch, dir)
+		require.NoError(t, err)
+
+		if signer != nil {
+			signChart(t, signer, archive, ch)
+		}
+	}
+
+	downloads := 0
+	files := http.FileServer(http.Dir(dir))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if filepath.Ext(r.URL.Path) == ".tgz" {
+			downloads++
+		}
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	index, err := repo.IndexDirectory(dir, server.URL)
+	require.NoError(t, err)
+	require.NoError(t, index.WriteFile(filepath.Join(dir, "index.yaml"), 0644))
+
+	return server, &downloads
+}
+
+func setCacheHome(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HELM_CACHE_HOME", t.TempDir())
+	t.Setenv("HELM_CONFIG_HOME", t.TempDir())
+}
+
+func TestExtractChartRelease(t *testing.T) {
+	require := require.New(t)
+	setCacheHome(t)
+
+	saved, err := chartutil.Save(testChart("0.40.0"), t.TempDir())
+	require.NoError(err)
+
+	chartDir, err := ExtractChartRelease(saved, K8ssandraRepoName, "cass-operator", "0.40.0", "")
+	require.NoError(err)
+	require.NoError(VerifyCachedChart(chartDir))
+
+	digest, err := fileDigest(saved)
+	require.NoError(err)
+
+	cached, err := ListChartCache(K8ssandraRepoName)
+	require.NoError(err)
+	require.Len(cached, 1)
+	require.Equal("cass-operator", cached[0].Chart)
+	require.Equal("0.40.0", cached[0].Version)
+	require.Equal(chartDir, cached[0].Dir)
+	require.Equal(digest, cached[0].Digest)
+	require.Positive(cached[0].Size)
+
+	// Modified, added and missing files fail the verification
+	configMap := filepath.Join(chartDir, "cass-operator", "templates", "configmap.yaml")
+	require.NoError(os.WriteFile(configMap, []byte("kind: Secret\n"), 0644))
+	require.ErrorContains(VerifyCachedChart(chartDir), "modified cass-operator/templates/configmap.yaml")
+
+	require.NoError(os.Remove(configMap))
+	require.ErrorContains(VerifyCachedChart(chartDir), "missing cass-operator/templates/configmap.yaml")
+
+	_, err = ExtractChartRelease(saved, K8ssandraRepoName, "cass-operator", "0.40.0", "")
+	require.NoError(err)
+	require.NoError(os.WriteFile(filepath.Join(chartDir, "cass-operator", "templates", "extra.yaml"), []byte("kind: Secret\n"), 0644))
+	require.ErrorContains(VerifyCachedChart(chartDir), "unexpected file cass-operator/templates/extra.yaml")
+
+	// Without the metadata the extraction did not finish
+	require.NoError(os.Remove(filepath.Join(chartDir, chartCacheMetadata)))
+	require.ErrorIs(VerifyCachedChart(chartDir), ErrChartCacheIncomplete)
+}
+
+func TestCleanChartCache(t *testing.T) {
+	require := require.New(t)
+	setCacheHome(t)
+
+	saved, err := chartutil.Save(testChart("0.40.0"), t.TempDir())
+	require.NoError(err)
+
+	operatorDir, err := ExtractChartRelease(saved, K8ssandraRepoName, "cass-operator", "0.40.0", "")
+	require.NoError(err)
+	otherDir, err := ExtractChartRelease(saved, K8ssandraRepoName, "other", "0.40.0", "")
+	require.NoError(err)
+
+	// Leftover of an interrupted extraction is not listed, but it's cleaned
+	leftover := filepath.Join(filepath.Dir(operatorDir), extractPrefix+"123")
+	require.NoError(os.MkdirAll(leftover, 0755))
+
+	cached, err := ListChartCache(K8ssandraRepoName)
+	require.NoError(err)
+	require.Len(cached, 2)
+
+	removed, err := CleanChartCache(K8ssandraRepoName, "cass-operator")
+	require.NoError(err)
+	require.ElementsMatch([]string{operatorDir, leftover}, removed)
+	require.DirExists(otherDir)
+
+	removed, err = CleanChartCache(K8ssandraRepoName, "")
+	require.NoError(err)
+	require.Equal([]string{otherDir}, removed)
+
+	cached, err = ListChartCache(K8ssandraRepoName)
+	require.NoError(err)
+	require.Empty(cached)
+}
+
+func TestLegacyChartCache(t *testing.T) {
+	require := require.New(t)
+	setCacheHome(t)
+
+	saved, err := chartutil.Save(testChart("0.40.0"), t.TempDir())
+	require.NoError(err)
+
+	operatorDir, err := ExtractChartRelease(saved, K8ssandraRepoName, "cass-operator", "0.40.0", "")
+	require.NoError(err)
+
+	// Older versions extracted the chart without the version directory
+	legacyDir := filepath.Join(filepath.Dir(operatorDir), "cass-operator")
+	require.NoError(chartutil.ExpandFile(filepath.Dir(operatorDir), saved))
+	require.FileExists(filepath.Join(legacyDir, chartutil.ChartfileName))
+
+	cached, err := ListChartCache(K8ssandraRepoName)
+	require.NoError(err)
+	require.Len(cached, 1)
+	require.Equal(operatorDir, cached[0].Dir)
+
+	removed, err := CleanChartCache(K8ssandraRepoName, "")
+	require.NoError(err)
+	require.ElementsMatch([]string{operatorDir, legacyDir}, removed)
+	require.NoDirExists(legacyDir)
+}
+
+func TestChartDirRedownloads(t *testing.T) {
+	require := require.New(t)
+	setCacheHome(t)
+
+	server, downloads := chartRepository(t, nil, "0.40.0")
+
+	u, err := NewUpgrader(nil, "test-repo", server.URL, "cass-operator", nil)
+	require.NoError(err)
+
+	chartDir, cleanup, err := u.ChartDir("0.40.0")
+	require.NoError(err)
+	cleanup()
+	require.Equal(1, *downloads)
+	require.NoError(VerifyCachedChart(chartDir))
+
+	// Verified cache is used
+	cachedDir, cleanup, err := u.ChartDir("0.40.0")
+	require.NoError(err)
+	cleanup()
+	require.Equal(chartDir, cachedDir)
+	require.Equal(1, *downloads)
+
+	// Partially extracted release is downloaded again
+	require.NoError(os.Remove(filepath.Join(chartDir, chartCacheMetadata)))
+	_, cleanup, err = u.ChartDir("0.40.0")
+	require.NoError(err)
+	cleanup()
+	require.Equal(2, *downloads)
+	require.NoError(VerifyCachedChart(chartDir))
+
+	// Corrupted release is downloaded again
+	require.NoError(os.WriteFile(filepath.Join(chartDir, "cass-operator", "Chart.yaml"), []byte("name: broken\n"), 0644))
+	_, cleanup, err = u.ChartDir("0.40.0")
+	require.NoError(err)
+	cleanup()
+	require.Equal(3, *downloads)
+	require.NoError(VerifyCachedChart(chartDir))
+}
+
+func TestChartDirNoCache(t *testing.T) {
+	require := require.New(t)
+	setCacheHome(t)
+
+	server, downloads := chartRepository(t, nil, "0.40.0")
+
+	u, err := NewUpgrader(nil, "test-repo", server.URL, "cass-operator", nil, WithNoCache())
+	require.NoError(err)
+
+	chartDir, cleanup, err := u.ChartDir("0.40.0")
+	require.NoError(err)
+	require.FileExists(filepath.Join(chartDir, "cass-operator", "Chart.yaml"))
+	cleanup()
+	require.NoDirExists(chartDir)
+	require.Equal(1, *downloads)
+
+	cached, err := ListChartCache("test-repo")
+	require.NoError(err)
+	require.Empty(cached)
+}
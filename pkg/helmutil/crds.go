@@ -1,8 +1,10 @@
 package helmutil
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"slices"
@@ -10,6 +12,7 @@ import (
 
 	"github.com/charmbracelet/log"
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -22,58 +25,133 @@ const (
 	AllSubCharts = "_"
 )
 
-// Upgrader is a utility to update the CRDs in a helm chart's pre-upgrade hook
+// Upgrader is a utility to update the CRDs in a helm chart's pre-upgrade hook. The CRDs are server-side applied.
 type Upgrader struct {
-	client    client.Client
-	repoName  string
-	repoURL   string
-	chartName string
-	subCharts []string
+	client         client.Client
+	repoName       string
+	repoURL        string
+	chartName      string
+	subCharts      []string
+	fieldManager   string
+	forceConflicts bool
+	noCache        bool
+	verification   ChartVerification
+	takeovers      []FieldTakeover
 }
 
 // NewUpgrader returns a new Upgrader client
-func NewUpgrader(c client.Client, repoName, repoURL, chartName string, subCharts []string) (*Upgrader, error) {
-	return &Upgrader{
-		client:    c,
-		repoName:  repoName,
-		repoURL:   repoURL,
-		chartName: chartName,
-		subCharts: subCharts,
-	}, nil
+func NewUpgrader(c client.Client, repoName, repoURL, chartName string, subCharts []string, opts ...UpgraderOption) (*Upgrader, error) {
+	u := &Upgrader{
+		client:       c,
+		repoName:     repoName,
+		repoURL:      repoURL,
+		chartName:    chartName,
+		subCharts:    subCharts,
+		fieldManager: FieldManager,
+	}
+
+	for _, opt := range opts {
+		opt(u)
+	}
+
+	return u, nil
 }
 
 // Upgrade installs the missing CRDs or updates them if they exists already
 func (u *Upgrader) Upgrade(ctx context.Context, chartVersion string) ([]unstructured.Unstructured, error) {
 	log.SetLevel(log.DebugLevel)
 	log.Info("Processing request to upgrade project CustomResourceDefinitions", "repoName", u.repoName, "chartName", u.chartName, "chartVersion", chartVersion)
-	chartDir, err := GetChartTargetDir(u.repoName, u.chartName)
+	chartDir, cleanup, err := u.ChartDir(chartVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	return u.ApplyCRDs(ctx, chartDir)
+}
+
+// UpgradeFromPath installs or updates the CRDs from a local chart archive (.tgz) or unpacked chart directory instead of
+// the chart repository
+func (u *Upgrader) UpgradeFromPath(ctx context.Context, chartPath string) ([]unstructured.Unstructured, error) {
+	log.Info("Processing request to upgrade project CustomResourceDefinitions", "chartPath", chartPath, "chartName", u.chartName)
+	chartDir, cleanup, err := LocalChartDir(chartPath, u.chartName)
 	if err != nil {
 		return nil, err
 	}
+	defer cleanup()
+
+	return u.ApplyCRDs(ctx, chartDir)
+}
+
+// ChartDir returns the directory of the chart release in the cache, downloading and extracting it if it's missing or
+// fails the verification. With WithNoCache the release is extracted to a temporary directory, which is removed by the
+// returned cleanup function.
+func (u *Upgrader) ChartDir(chartVersion string) (string, func(), error) {
+	cleanup := func() {}
+
+	if u.noCache {
+		chartDir, cleanup, err := u.downloadChart(chartVersion)
+		return chartDir, cleanup, err
+	}
+
+	chartDir, err := ChartCacheDir(u.repoName, u.chartName, chartVersion)
+	if err != nil {
+		return "", cleanup, err
+	}
 
 	if fs, err := os.Stat(chartDir); os.IsNotExist(err) {
 		log.Info("Downloading chart release from remote repository", "repoURL", u.repoURL, "chartName", u.chartName, "chartVersion", chartVersion, "chartDir", chartDir)
-		downloadDir, err := DownloadChartRelease(u.repoName, u.repoURL, u.chartName, chartVersion)
-		if err != nil {
-			return nil, err
-		}
-
-		extractDir, err := ExtractChartRelease(downloadDir, u.repoName, u.chartName, chartVersion)
-		if err != nil {
-			return nil, err
-		}
-		chartDir = extractDir
 	} else if err != nil {
 		log.Error("Failed to check chart release directory", "error", err)
-		return nil, err
+		return "", cleanup, err
 	} else if !fs.IsDir() {
 		err := fmt.Errorf("chart release is not a directory: %s", chartDir)
 		log.Error("Target chart release path is not a directory", "directory", chartDir, "error", err)
-		return nil, err
+		return "", cleanup, err
+	} else if metadata, err := verifyCachedChart(chartDir); err != nil {
+		log.Warn("Downloading chart release again, the cached release failed verification", "directory", chartDir, "error", err)
+	} else if u.verification.Required() && metadata.SignedBy == "" {
+		log.Warn("Downloading chart release again, the provenance of the cached release was not verified", "directory", chartDir)
 	} else {
-		log.Info("Using cached chart release", "directory", chartDir)
+		log.Info("Using cached chart release", "directory", chartDir, "signedBy", metadata.SignedBy)
+		return chartDir, cleanup, nil
 	}
 
+	saved, signedBy, err := DownloadChartRelease(u.repoName, u.repoURL, u.chartName, chartVersion, u.verification)
+	if err != nil {
+		return "", cleanup, err
+	}
+	defer removeDownload(saved)
+
+	chartDir, err = ExtractChartRelease(saved, u.repoName, u.chartName, chartVersion, signedBy)
+	return chartDir, cleanup, err
+}
+
+// downloadChart downloads and extracts the chart release to a temporary directory, bypassing the cache
+func (u *Upgrader) downloadChart(chartVersion string) (string, func(), error) {
+	cleanup := func() {}
+
+	log.Info("Downloading chart release from remote repository without caching", "repoURL", u.repoURL, "chartName", u.chartName, "chartVersion", chartVersion)
+	saved, signedBy, err := DownloadChartRelease(u.repoName, u.repoURL, u.chartName, chartVersion, u.verification)
+	if err != nil {
+		return "", cleanup, err
+	}
+
+	// The archive is in a temporary directory of its own, the chart is extracted next to it
+	downloadDir := filepath.Dir(saved)
+	cleanup = func() { removeDir(downloadDir) }
+
+	extractDir := filepath.Join(downloadDir, "chart")
+	if err := extractChart(saved, signedBy, extractDir); err != nil {
+		cleanup()
+		return "", func() {}, err
+	}
+
+	return extractDir, cleanup, nil
+}
+
+// CRDs returns the CRDs of the chart and the selected subcharts in chartDir
+func (u *Upgrader) CRDs(chartDir string) ([]unstructured.Unstructured, error) {
 	crds := make([]unstructured.Unstructured, 0)
 
 	// For each dir under the charts subdir, check the "crds/"
@@ -86,71 +164,97 @@ func (u *Upgrader) Upgrade(ctx context.Context, chartVersion string) ([]unstruct
 		}
 	}
 
+	return crds, nil
+}
+
+// ApplyCRDs installs or updates the CRDs of an already extracted chart
+func (u *Upgrader) ApplyCRDs(ctx context.Context, chartDir string) ([]unstructured.Unstructured, error) {
+	crds, err := u.CRDs(chartDir)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, obj := range crds {
 		log.Info("Processing CustomResourceDefinition", "name", obj.GetName())
 		existingCrd := obj.DeepCopy()
-		err = u.client.Get(ctx, client.ObjectKey{Name: obj.GetName()}, existingCrd)
+		err := u.client.Get(ctx, client.ObjectKey{Name: obj.GetName()}, existingCrd)
 		if apierrors.IsNotFound(err) {
 			log.Debug("Creating CustomResourceDefinition", "name", obj.GetName())
-			if err = u.client.Create(ctx, &obj); err != nil {
-				return nil, errors.Wrapf(err, "failed to create CRD %s", obj.GetName())
+			if _, err := u.apply(ctx, &obj); err != nil {
+				return nil, err
 			}
+			continue
 		} else if err != nil {
 			return nil, errors.Wrapf(err, "failed to fetch state of %s", obj.GetName())
-		} else {
-			log.Debug("Updating CustomResourceDefinition", "name", obj.GetName())
-			obj.SetResourceVersion(existingCrd.GetResourceVersion())
-
-			// TODO We need to check which versions we have available here before updating
-			unstructured := obj.UnstructuredContent()
-			var definition apiextensionsv1.CustomResourceDefinition
-			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructured, &definition); err != nil {
-				return nil, errors.Wrapf(err, "failed to convert unstructured to CustomResourceDefinition %s", obj.GetName())
-			}
+		}
 
-			updatedVersions := make([]string, 0, len(definition.Spec.Versions))
-			for _, version := range definition.Spec.Versions {
-				updatedVersions = append(updatedVersions, version.Name)
-			}
-			log.Debug("Read CustomResourceDefinition versions", "name", obj.GetName(), "versions", updatedVersions)
+		log.Debug("Updating CustomResourceDefinition", "name", obj.GetName())
 
-			existing := existingCrd.UnstructuredContent()
-			var existingDefinition apiextensionsv1.CustomResourceDefinition
-			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(existing, &existingDefinition); err != nil {
-				return nil, errors.Wrapf(err, "failed to convert unstructured to CustomResourceDefinition %s", obj.GetName())
-			}
+		if err := u.migrateLegacyManagers(ctx, existingCrd); err != nil {
+			return nil, err
+		}
 
-			storedVersions := existingDefinition.Status.StoredVersions
-
-			if !slices.Equal(storedVersions, updatedVersions) {
-				// Check if storedVersion has any versions that are not in updatedVersions
-				// If so, we need to remove them from the storedVersions
-				removed := false
-				for _, storedVersion := range storedVersions {
-					if !slices.Contains(updatedVersions, storedVersion) {
-						log.Debug("Removing CustomResourceDefinition version", "name", obj.GetName(), "version", storedVersion)
-						// storedVersions = slices.DeleteFunc(storedVersions, func(e string) bool { return e == storedVersion })
-						removed = true
-					}
-				}
-
-				if removed {
-					log.Debug("Updating CustomResourceDefinition versions", "name", obj.GetName(), "storedVersions", storedVersions, "updatedVersions", updatedVersions)
-					existingDefinition.Status.StoredVersions = updatedVersions
-					if err := u.client.Status().Update(ctx, &existingDefinition); err != nil {
-						return nil, errors.Wrapf(err, "failed to update CRD storedVersions %s", obj.GetName())
-					}
-					obj.SetResourceVersion(existingDefinition.GetResourceVersion())
-				}
-			}
+		// The takeovers are reported from the managed fields after the legacy Update managers are migrated, the
+		// fields forced from other managers, including the legacy ones applying their fields, are reported
+		managedFields := existingCrd.GetManagedFields()
 
-			if err = u.client.Update(ctx, &obj); err != nil {
-				return nil, errors.Wrapf(err, "failed to update CRD %s", obj.GetName())
+		// TODO We need to check which versions we have available here before updating
+		unstructured := obj.UnstructuredContent()
+		var definition apiextensionsv1.CustomResourceDefinition
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructured, &definition); err != nil {
+			return nil, errors.Wrapf(err, "failed to convert unstructured to CustomResourceDefinition %s", obj.GetName())
+		}
+
+		updatedVersions := make([]string, 0, len(definition.Spec.Versions))
+		for _, version := range definition.Spec.Versions {
+			updatedVersions = append(updatedVersions, version.Name)
+		}
+		log.Debug("Read CustomResourceDefinition versions", "name", obj.GetName(), "versions", updatedVersions)
+
+		existing := existingCrd.UnstructuredContent()
+		var existingDefinition apiextensionsv1.CustomResourceDefinition
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(existing, &existingDefinition); err != nil {
+			return nil, errors.Wrapf(err, "failed to convert unstructured to CustomResourceDefinition %s", obj.GetName())
+		}
+
+		// Check if storedVersion has any versions that are not in updatedVersions
+		// If so, the objects are migrated to the new storage version before they're removed from the storedVersions
+		removed := droppedStoredVersions(&existingDefinition, &definition)
+		for _, storedVersion := range removed {
+			log.Debug("Removing CustomResourceDefinition version", "name", obj.GetName(), "version", storedVersion)
+		}
+
+		if len(removed) > 0 {
+			if err := u.migrateStoredVersions(ctx, &existingDefinition, &definition, removed); err != nil {
+				return nil, err
 			}
 		}
+
+		applied, err := u.apply(ctx, &obj)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := u.recordTakeovers(obj.GetName(), managedFields, applied.GetManagedFields()); err != nil {
+			return nil, err
+		}
 	}
 
-	return crds, err
+	return crds, nil
+}
+
+// droppedStoredVersions returns the versions in the status.storedVersions of the existing CRD which the updated CRD
+// no longer has
+func droppedStoredVersions(existing, updated *apiextensionsv1.CustomResourceDefinition) []string {
+	removed := make([]string, 0)
+	for _, stored := range existing.Status.StoredVersions {
+		if !slices.ContainsFunc(updated.Spec.Versions, func(v apiextensionsv1.CustomResourceDefinitionVersion) bool {
+			return v.Name == stored
+		}) {
+			removed = append(removed, stored)
+		}
+	}
+	return removed
 }
 
 func findCRDDirs(chartDir string, subCharts []string) ([]string, error) {
@@ -164,17 +268,19 @@ func findCRDDirs(chartDir string, subCharts []string) ([]string, error) {
 			return false
 		}
 
-		chartParts := strings.Split(filepath.Clean(path), string(os.PathSeparator))
-		chartName := chartParts[len(chartParts)-2]
-		subChart := false
-		if len(chartParts) > 3 {
-			subChart = chartParts[len(chartParts)-3] == "charts"
+		// Only the part inside chartDir is inspected, the chart itself could be in a directory called charts
+		rel, err := filepath.Rel(chartDir, path)
+		if err != nil {
+			return false
 		}
 
-		if !subChart {
+		chartParts := strings.Split(rel, string(os.PathSeparator))
+		if len(chartParts) < 3 || chartParts[len(chartParts)-3] != "charts" {
 			return true
 		}
 
+		chartName := chartParts[len(chartParts)-2]
+
 		if _, found := chartsList[AllSubCharts]; found {
 			return true
 		}
@@ -199,6 +305,59 @@ func findCRDDirs(chartDir string, subCharts []string) ([]string, error) {
 	return dirs, err
 }
 
+// ExportCRDs writes the CRDs as YAML files named after the CRDs to outputDir, which is created if it does not exist.
+// Returns the paths of the written files.
+func ExportCRDs(crds []unstructured.Unstructured, outputDir string) ([]string, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(crds))
+	for _, crd := range crds {
+		b, err := marshalCRD(crd)
+		if err != nil {
+			return nil, err
+		}
+
+		target := filepath.Join(outputDir, crd.GetName()+".yaml")
+		if err := os.WriteFile(target, b, 0644); err != nil {
+			return nil, err
+		}
+		files = append(files, target)
+	}
+
+	return files, nil
+}
+
+// WriteCRDs writes the CRDs to out as a multi-document YAML stream
+func WriteCRDs(crds []unstructured.Unstructured, out io.Writer) error {
+	for _, crd := range crds {
+		b, err := marshalCRD(crd)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(out, "---\n%s", b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func marshalCRD(crd unstructured.Unstructured) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(crd.Object); err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal CRD %s", crd.GetName())
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 func parseChartCRDs(crds *[]unstructured.Unstructured, crdDir string) error {
 	if err := FilterCharts(crds, crdDir, []string{"CustomResourceDefinition"}); err != nil {
 		return errors.Wrapf(err, "failed to parse CustomResourceDefinition directory %s", crdDir)
@@ -0,0 +1,246 @@
+package helmutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/charmbracelet/log"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/csaupgrade"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/structured-merge-diff/v6/fieldpath"
+)
+
+const (
+	// FieldManager is the server-side apply field manager owning the CRD fields set by the Upgrader
+	FieldManager = "k8ssandra-client"
+)
+
+var (
+	// legacyFieldManagers wrote the CRDs before they were server-side applied: helm install, the earlier Upgrader, which
+	// updated them with the kubectl-k8ssandra user agent as the field manager, and the API server for objects written
+	// before the managed fields were tracked. Their fields are taken over by the Upgrader's field manager instead of
+	// conflicting with it.
+	legacyFieldManagers = sets.New("helm", "kubectl-k8ssandra", "before-first-apply")
+
+	// conflictManager is the field manager in the message of a field manager conflict cause
+	conflictManager = regexp.MustCompile(`conflict with "([^"]+)"`)
+)
+
+// UpgraderOption modifies how the Upgrader applies the CRDs
+type UpgraderOption func(*Upgrader)
+
+// WithFieldManager sets the server-side apply field manager, the default is FieldManager
+func WithFieldManager(fieldManager string) UpgraderOption {
+	return func(u *Upgrader) {
+		u.fieldManager = fieldManager
+	}
+}
+
+// WithForceConflicts takes the ownership of the fields other managers have set to different values instead of failing
+// the upgrade. The fields taken over are reported by Takeovers.
+func WithForceConflicts() UpgraderOption {
+	return func(u *Upgrader) {
+		u.forceConflicts = true
+	}
+}
+
+// FieldTakeover lists the fields of a CRD which were owned by another field manager before the upgrade
+type FieldTakeover struct {
+	CRD     string
+	Manager string
+	Fields  []string
+}
+
+// Takeovers returns the fields taken over from other field managers by the upgrades, either with WithForceConflicts or
+// from the legacy field managers, which are taken over without it
+func (u *Upgrader) Takeovers() []FieldTakeover {
+	return u.takeovers
+}
+
+// migrateLegacyManagers moves the ownership of the fields set with Update operations by the legacy field managers to
+// the Upgrader's field manager, like kubectl does when switching from client-side to server-side apply. The existing
+// object is updated with the patched managed fields.
+func (u *Upgrader) migrateLegacyManagers(ctx context.Context, existing *unstructured.Unstructured) error {
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(existing, legacyFieldManagers, u.fieldManager)
+	if err != nil {
+		return errors.Wrapf(err, "failed to migrate managed fields of CRD %s", existing.GetName())
+	}
+	if patch == nil {
+		return nil
+	}
+
+	log.Info("Migrating CustomResourceDefinition fields from legacy field managers", "name", existing.GetName(), "fieldManager", u.fieldManager)
+	if err := u.client.Patch(ctx, existing, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+		return errors.Wrapf(err, "failed to migrate managed fields of CRD %s", existing.GetName())
+	}
+	return nil
+}
+
+// apply server-side applies the CRD. Conflicts with other field managers fail the apply unless WithForceConflicts is
+// set, conflicts only with the legacy field managers are forced and other conflicts are retried. The versions of a CRD
+// are an atomic list, once owned by the Upgrader's field manager the applied versions replace the existing ones.
+func (u *Upgrader) apply(ctx context.Context, obj *unstructured.Unstructured, opts ...client.ApplyOption) (*unstructured.Unstructured, error) {
+	applied, err := u.applyOwned(ctx, obj, u.forceConflicts, opts)
+	if err != nil && !u.forceConflicts && onlyLegacyConflicts(err) {
+		log.Info("Taking over CustomResourceDefinition fields from legacy field managers", "name", obj.GetName(), "conflicts", fieldManagerConflicts(err))
+		applied, err = u.applyOwned(ctx, obj, true, opts)
+	}
+
+	if err != nil {
+		if fields := fieldManagerConflicts(err); len(fields) > 0 {
+			return nil, fmt.Errorf("CRD %s has fields owned by other managers, use force conflicts to take them over: %v", obj.GetName(), fields)
+		}
+		return nil, errors.Wrapf(err, "failed to apply CRD %s", obj.GetName())
+	}
+
+	return applied, nil
+}
+
+func (u *Upgrader) applyOwned(ctx context.Context, obj *unstructured.Unstructured, force bool, opts []client.ApplyOption) (*unstructured.Unstructured, error) {
+	var applied *unstructured.Unstructured
+	err := retry.OnError(retry.DefaultRetry, isRetryableConflict, func() error {
+		applied = obj.DeepCopy()
+		applied.SetResourceVersion("")
+		applied.SetManagedFields(nil)
+
+		applyOpts := append([]client.ApplyOption{client.FieldOwner(u.fieldManager)}, opts...)
+		if force {
+			applyOpts = append(applyOpts, client.ForceOwnership)
+		}
+		return u.client.Apply(ctx, client.ApplyConfigurationFromUnstructured(applied), applyOpts...)
+	})
+	return applied, err
+}
+
+// isRetryableConflict is true for conflicts other than the field manager conflicts, which would fail again
+func isRetryableConflict(err error) bool {
+	return apierrors.IsConflict(err) && len(fieldManagerConflicts(err)) == 0
+}
+
+// onlyLegacyConflicts is true if the err has field manager conflicts and all of them are with the legacy field managers
+func onlyLegacyConflicts(err error) bool {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return false
+	}
+
+	legacy := false
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		match := conflictManager.FindStringSubmatch(cause.Message)
+		if match == nil || !legacyFieldManagers.Has(match[1]) {
+			return false
+		}
+		legacy = true
+	}
+	return legacy
+}
+
+func fieldManagerConflicts(err error) []string {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return nil
+	}
+
+	details := status.Status().Details
+	if details == nil {
+		return nil
+	}
+
+	fields := make([]string, 0)
+	for _, cause := range details.Causes {
+		if cause.Type == metav1.CauseTypeFieldManagerConflict {
+			fields = append(fields, fmt.Sprintf("%s (%s)", cause.Field, cause.Message))
+		}
+	}
+	return fields
+}
+
+// recordTakeovers compares the managed fields before and after the apply to find the fields other managers lost to
+// the Upgrader's field manager
+func (u *Upgrader) recordTakeovers(name string, before, after []metav1.ManagedFieldsEntry) error {
+	owned, err := managedFieldSets(after)
+	if err != nil {
+		return err
+	}
+
+	previous, err := managedFieldSets(before)
+	if err != nil {
+		return err
+	}
+
+	ours, found := owned[managerKey(u.fieldManager, metav1.ManagedFieldsOperationApply)]
+	if !found {
+		return nil
+	}
+
+	managers := make([]string, 0, len(previous))
+	for manager := range previous {
+		managers = append(managers, manager)
+	}
+	sort.Strings(managers)
+
+	for _, manager := range managers {
+		if manager == managerKey(u.fieldManager, metav1.ManagedFieldsOperationApply) {
+			continue
+		}
+
+		lost := previous[manager]
+		if remaining, found := owned[manager]; found {
+			lost = lost.Difference(remaining)
+		}
+
+		taken := lost.Intersection(ours).Leaves()
+		if taken.Empty() {
+			continue
+		}
+
+		fields := make([]string, 0)
+		taken.Iterate(func(p fieldpath.Path) {
+			fields = append(fields, p.String())
+		})
+		sort.Strings(fields)
+
+		log.Warn("Took over CustomResourceDefinition fields from another field manager", "name", name, "manager", manager, "fields", fields)
+		u.takeovers = append(u.takeovers, FieldTakeover{CRD: name, Manager: manager, Fields: fields})
+	}
+
+	return nil
+}
+
+func managedFieldSets(entries []metav1.ManagedFieldsEntry) (map[string]*fieldpath.Set, error) {
+	sets := make(map[string]*fieldpath.Set, len(entries))
+	for _, entry := range entries {
+		if entry.FieldsV1 == nil || entry.Subresource != "" {
+			continue
+		}
+
+		set := &fieldpath.Set{}
+		if err := set.FromJSON(bytes.NewReader(entry.FieldsV1.Raw)); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse managed fields of %s", entry.Manager)
+		}
+
+		key := managerKey(entry.Manager, entry.Operation)
+		if existing, found := sets[key]; found {
+			set = existing.Union(set)
+		}
+		sets[key] = set
+	}
+	return sets, nil
+}
+
+func managerKey(manager string, operation metav1.ManagedFieldsOperationType) string {
+	return fmt.Sprintf("%s (%s)", manager, operation)
+}
@@ -0,0 +1,255 @@
+package helmutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newCRDClientBuilder returns a fake client builder returning the managed fields of the server-side applied CRDs
+func newCRDClientBuilder(scheme *runtime.Scheme) *fake.ClientBuilder {
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithReturnManagedFields()
+}
+
+func writeTestCRD(t *testing.T, crd string) string {
+	chartDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "test-chart", "crds"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "test-chart", "crds", "crd.yaml"), []byte(crd), 0644))
+	return chartDir
+}
+
+func TestApplyCRDsFieldManager(t *testing.T) {
+	require := require.New(t)
+
+	scheme := runtime.NewScheme()
+	require.NoError(apiextensionsv1.AddToScheme(scheme))
+	c := newCRDClientBuilder(scheme).Build()
+
+	u, err := NewUpgrader(c, K8ssandraRepoName, StableK8ssandraRepoURL, "test-chart", nil)
+	require.NoError(err)
+
+	chartDir := writeTestCRD(t, testClientConfigCRD)
+	_, err = u.ApplyCRDs(t.Context(), chartDir)
+	require.NoError(err)
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	require.NoError(c.Get(t.Context(), client.ObjectKey{Name: "clientconfigs.config.k8ssandra.io"}, crd))
+	managers := make([]string, 0)
+	for _, entry := range crd.GetManagedFields() {
+		managers = append(managers, managerKey(entry.Manager, entry.Operation))
+	}
+	require.Contains(managers, managerKey(FieldManager, metav1.ManagedFieldsOperationApply))
+
+	// Applying again is a no-op
+	_, err = u.ApplyCRDs(t.Context(), chartDir)
+	require.NoError(err)
+	require.Empty(u.Takeovers())
+}
+
+func TestApplyCRDsConflicts(t *testing.T) {
+	require := require.New(t)
+
+	scheme := runtime.NewScheme()
+	require.NoError(apiextensionsv1.AddToScheme(scheme))
+	c := newCRDClientBuilder(scheme).Build()
+
+	// Another manager owns the served field with a value the chart changes
+	other, err := NewUpgrader(c, K8ssandraRepoName, StableK8ssandraRepoURL, "test-chart", nil, WithFieldManager("other-manager"))
+	require.NoError(err)
+	_, err = other.ApplyCRDs(t.Context(), writeTestCRD(t, testClientConfigCRD))
+	require.NoError(err)
+
+	updatedCRD := `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: clientconfigs.config.k8ssandra.io
+spec:
+  group: config.k8ssandra.io
+  names:
+    kind: ClientConfig
+    listKind: ClientConfigList
+    plural: clientconfigs
+  scope: Cluster
+  versions:
+    - name: v1beta1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+`
+	chartDir := writeTestCRD(t, updatedCRD)
+
+	u, err := NewUpgrader(c, K8ssandraRepoName, StableK8ssandraRepoURL, "test-chart", nil)
+	require.NoError(err)
+	_, err = u.ApplyCRDs(t.Context(), chartDir)
+	require.Error(err)
+	require.Contains(err.Error(), "use force conflicts")
+
+	u, err = NewUpgrader(c, K8ssandraRepoName, StableK8ssandraRepoURL, "test-chart", nil, WithForceConflicts())
+	require.NoError(err)
+	_, err = u.ApplyCRDs(t.Context(), chartDir)
+	require.NoError(err)
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	require.NoError(c.Get(t.Context(), client.ObjectKey{Name: "clientconfigs.config.k8ssandra.io"}, crd))
+	require.Equal(apiextensionsv1.ClusterScoped, crd.Spec.Scope)
+
+	takeovers := u.Takeovers()
+	require.Len(takeovers, 1)
+	require.Equal("clientconfigs.config.k8ssandra.io", takeovers[0].CRD)
+	require.Equal(managerKey("other-manager", metav1.ManagedFieldsOperationApply), takeovers[0].Manager)
+	require.Equal([]string{".spec.scope"}, takeovers[0].Fields)
+}
+
+func TestApplyCRDsReportsLegacyTakeovers(t *testing.T) {
+	require := require.New(t)
+
+	scheme := runtime.NewScheme()
+	require.NoError(apiextensionsv1.AddToScheme(scheme))
+	c := newCRDClientBuilder(scheme).Build()
+
+	// Helm 4 server-side applies the CRDs with the helm field manager
+	helm, err := NewUpgrader(c, K8ssandraRepoName, StableK8ssandraRepoURL, "test-chart", nil, WithFieldManager("helm"))
+	require.NoError(err)
+	_, err = helm.ApplyCRDs(t.Context(), writeTestCRD(t, testClientConfigCRD))
+	require.NoError(err)
+
+	updatedCRD := strings.Replace(testClientConfigCRD, "scope: Namespaced", "scope: Cluster", 1)
+	require.NotEqual(testClientConfigCRD, updatedCRD)
+
+	// The legacy field manager's fields are taken over without force conflicts, but they are still reported
+	u, err := NewUpgrader(c, K8ssandraRepoName, StableK8ssandraRepoURL, "test-chart", nil)
+	require.NoError(err)
+	_, err = u.ApplyCRDs(t.Context(), writeTestCRD(t, updatedCRD))
+	require.NoError(err)
+
+	takeovers := u.Takeovers()
+	require.Len(takeovers, 1)
+	require.Equal(managerKey("helm", metav1.ManagedFieldsOperationApply), takeovers[0].Manager)
+	require.Equal([]string{".spec.scope"}, takeovers[0].Fields)
+}
+
+func TestApplyCRDsReplacesVersions(t *testing.T) {
+	require := require.New(t)
+
+	scheme := runtime.NewScheme()
+	require.NoError(apiextensionsv1.AddToScheme(scheme))
+
+	// The existing CRD was created without server-side apply, like helm install does
+	existing := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "clientconfigs.config.k8ssandra.io"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "config.k8ssandra.io",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: "ClientConfig", ListKind: "ClientConfigList", Plural: "clientconfigs"},
+			Scope: apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				crdVersion("v1alpha1", false, nil),
+				crdVersion("v1beta1", true, nil),
+			},
+		},
+	}
+	c := newCRDClientBuilder(scheme).Build()
+	require.NoError(c.Create(t.Context(), existing, client.FieldOwner("helm")))
+	chartDir := writeTestCRD(t, testClientConfigCRD)
+
+	// The fields of the legacy field managers are migrated, the upgrade does not need force conflicts
+	u, err := NewUpgrader(c, K8ssandraRepoName, StableK8ssandraRepoURL, "test-chart", nil)
+	require.NoError(err)
+	_, err = u.ApplyCRDs(t.Context(), chartDir)
+	require.NoError(err)
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	require.NoError(c.Get(t.Context(), client.ObjectKey{Name: existing.Name}, crd))
+	require.Len(crd.Spec.Versions, 1)
+	require.Equal("v1beta1", crd.Spec.Versions[0].Name)
+
+	managers := make([]string, 0)
+	for _, entry := range crd.GetManagedFields() {
+		managers = append(managers, managerKey(entry.Manager, entry.Operation))
+	}
+	require.NotContains(managers, managerKey("helm", metav1.ManagedFieldsOperationUpdate))
+	require.Contains(managers, managerKey(FieldManager, metav1.ManagedFieldsOperationApply))
+}
+
+func TestApplyCRDsLegacyApplyManager(t *testing.T) {
+	require := require.New(t)
+
+	scheme := runtime.NewScheme()
+	require.NoError(apiextensionsv1.AddToScheme(scheme))
+	c := newCRDClientBuilder(scheme).Build()
+
+	// helm server-side applied the CRD with its own field manager
+	helm, err := NewUpgrader(c, K8ssandraRepoName, StableK8ssandraRepoURL, "test-chart", nil, WithFieldManager("helm"))
+	require.NoError(err)
+	_, err = helm.ApplyCRDs(t.Context(), writeTestCRD(t, testClientConfigCRD))
+	require.NoError(err)
+
+	u, err := NewUpgrader(c, K8ssandraRepoName, StableK8ssandraRepoURL, "test-chart", nil)
+	require.NoError(err)
+	_, err = u.ApplyCRDs(t.Context(), writeTestCRD(t, strings.Replace(testClientConfigCRD, "scope: Namespaced", "scope: Cluster", 1)))
+	require.NoError(err)
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	require.NoError(c.Get(t.Context(), client.ObjectKey{Name: "clientconfigs.config.k8ssandra.io"}, crd))
+	require.Equal(apiextensionsv1.ClusterScoped, crd.Spec.Scope)
+}
+
+func TestApplyCRDsLegacyAndOtherManagers(t *testing.T) {
+	require := require.New(t)
+
+	scheme := runtime.NewScheme()
+	require.NoError(apiextensionsv1.AddToScheme(scheme))
+	c := newCRDClientBuilder(scheme).Build()
+
+	// The CRD was installed by helm, then another tool changed the scope
+	existing := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "clientconfigs.config.k8ssandra.io"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group:    "config.k8ssandra.io",
+			Names:    apiextensionsv1.CustomResourceDefinitionNames{Kind: "ClientConfig", ListKind: "ClientConfigList", Plural: "clientconfigs"},
+			Scope:    apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{crdVersion("v1beta1", true, nil)},
+		},
+	}
+	require.NoError(c.Create(t.Context(), existing, client.FieldOwner("helm")))
+	existing.Spec.Scope = apiextensionsv1.ClusterScoped
+	require.NoError(c.Update(t.Context(), existing, client.FieldOwner("other-manager")))
+
+	u, err := NewUpgrader(c, K8ssandraRepoName, StableK8ssandraRepoURL, "test-chart", nil)
+	require.NoError(err)
+	_, err = u.ApplyCRDs(t.Context(), writeTestCRD(t, testClientConfigCRD))
+	require.Error(err)
+	require.Contains(err.Error(), ".spec.scope")
+	require.Contains(err.Error(), "other-manager")
+}
+
+func TestFieldManagerConflicts(t *testing.T) {
+	require := require.New(t)
+
+	gr := schema.GroupResource{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}
+	conflict := apierrors.NewApplyConflict([]metav1.StatusCause{
+		{Type: metav1.CauseTypeFieldManagerConflict, Message: `conflict with "helm" using apiextensions.k8s.io/v1`, Field: ".spec.scope"},
+	}, "Apply failed with 1 conflict")
+	require.Equal([]string{`.spec.scope (conflict with "helm" using apiextensions.k8s.io/v1)`}, fieldManagerConflicts(conflict))
+	require.False(isRetryableConflict(conflict))
+
+	// resourceVersion conflicts are retried
+	stale := apierrors.NewConflict(gr, "clientconfigs.config.k8ssandra.io", nil)
+	require.Empty(fieldManagerConflicts(stale))
+	require.True(isRetryableConflict(stale))
+
+	require.False(isRetryableConflict(apierrors.NewNotFound(gr, "clientconfigs.config.k8ssandra.io")))
+}
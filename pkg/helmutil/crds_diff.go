@@ -0,0 +1,272 @@
+package helmutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CRDAction is what upgrading would do to a CRD in the cluster
+type CRDAction string
+
+const (
+	CRDCreate    CRDAction = "create"
+	CRDUpdate    CRDAction = "update"
+	CRDUnchanged CRDAction = "unchanged"
+)
+
+// CRDChange describes the changes upgrading would make to a single CRD
+type CRDChange struct {
+	Name     string
+	Action   CRDAction
+	Versions []CRDVersionChange
+	// DroppedStoredVersions are the versions in status.storedVersions which the new CRD no longer has
+	DroppedStoredVersions []string
+}
+
+// CRDVersionChange is the difference of a single version of the CRD. Change is added, removed or changed, with the
+// schema, served and storage changes in Diff.
+type CRDVersionChange struct {
+	Version string
+	Change  string
+	Diff    []string
+}
+
+// DryRun reports the changes ApplyCRDs would make, without changing the cluster. The creates and updates are
+// validated by the API server with a server-side dry-run.
+func (u *Upgrader) DryRun(ctx context.Context, chartDir string) ([]CRDChange, error) {
+	crds, err := u.CRDs(chartDir)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]CRDChange, 0, len(crds))
+	for _, obj := range crds {
+		log.Debug("Dry-running CustomResourceDefinition upgrade", "name", obj.GetName())
+		change, err := u.dryRunCRD(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+func (u *Upgrader) dryRunCRD(ctx context.Context, obj unstructured.Unstructured) (CRDChange, error) {
+	change := CRDChange{Name: obj.GetName()}
+
+	existingCrd := obj.DeepCopy()
+	err := u.client.Get(ctx, client.ObjectKey{Name: obj.GetName()}, existingCrd)
+	if apierrors.IsNotFound(err) {
+		created, err := u.apply(ctx, &obj, client.DryRunAll)
+		if err != nil {
+			return change, err
+		}
+
+		definition, err := toCRD(created)
+		if err != nil {
+			return change, err
+		}
+
+		change.Action = CRDCreate
+		for _, version := range definition.Spec.Versions {
+			change.Versions = append(change.Versions, CRDVersionChange{Version: version.Name, Change: "added"})
+		}
+		return change, nil
+	} else if err != nil {
+		return change, errors.Wrapf(err, "failed to fetch state of %s", obj.GetName())
+	}
+
+	existingDefinition, err := toCRD(existingCrd)
+	if err != nil {
+		return change, err
+	}
+
+	updatedDefinition, err := toCRD(&obj)
+	if err != nil {
+		return change, err
+	}
+
+	// The API server rejects dropping a stored version, the upgrade first applies the CRD with the dropped versions
+	// still included and the dry-run validates the same
+	change.DroppedStoredVersions = droppedStoredVersions(existingDefinition, updatedDefinition)
+	target := &obj
+	if len(change.DroppedStoredVersions) > 0 {
+		target, err = intermediateCRD(existingDefinition, updatedDefinition, change.DroppedStoredVersions)
+		if err != nil {
+			return change, err
+		}
+	}
+
+	updated, err := u.apply(ctx, target, client.DryRunAll)
+	if err != nil {
+		return change, err
+	}
+
+	// The dry-run result has the server side defaults, comparing it avoids reporting defaulted fields as changes
+	definition, err := toCRD(updated)
+	if err != nil {
+		return change, err
+	}
+	definition.Spec.Versions = slices.DeleteFunc(definition.Spec.Versions, func(v apiextensionsv1.CustomResourceDefinitionVersion) bool {
+		return slices.Contains(change.DroppedStoredVersions, v.Name)
+	})
+
+	change.Action = CRDUnchanged
+	if !equality.Semantic.DeepEqual(existingDefinition.Spec, definition.Spec) {
+		change.Action = CRDUpdate
+	}
+
+	change.Versions, err = diffCRDVersions(existingDefinition.Spec.Versions, definition.Spec.Versions)
+	if err != nil {
+		return change, err
+	}
+
+	if len(change.DroppedStoredVersions) == 0 {
+		change.DroppedStoredVersions = nil
+	}
+
+	return change, nil
+}
+
+func toCRD(obj *unstructured.Unstructured) (*apiextensionsv1.CustomResourceDefinition, error) {
+	var definition apiextensionsv1.CustomResourceDefinition
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), &definition); err != nil {
+		return nil, errors.Wrapf(err, "failed to convert unstructured to CustomResourceDefinition %s", obj.GetName())
+	}
+	return &definition, nil
+}
+
+// diffCRDVersions compares the versions by name, unchanged versions are not returned
+func diffCRDVersions(existing, updated []apiextensionsv1.CustomResourceDefinitionVersion) ([]CRDVersionChange, error) {
+	changes := make([]CRDVersionChange, 0)
+
+	previous := make(map[string]apiextensionsv1.CustomResourceDefinitionVersion, len(existing))
+	for _, version := range existing {
+		previous[version.Name] = version
+	}
+
+	for _, version := range updated {
+		old, found := previous[version.Name]
+		if !found {
+			changes = append(changes, CRDVersionChange{Version: version.Name, Change: "added"})
+			continue
+		}
+		delete(previous, version.Name)
+
+		diff, err := diffVersion(old, version)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(diff) > 0 {
+			changes = append(changes, CRDVersionChange{Version: version.Name, Change: "changed", Diff: diff})
+		}
+	}
+
+	for _, version := range existing {
+		if _, found := previous[version.Name]; found {
+			changes = append(changes, CRDVersionChange{Version: version.Name, Change: "removed"})
+		}
+	}
+
+	return changes, nil
+}
+
+// diffVersion returns the added (+), removed (-) and changed (~) fields of the version, one line per field
+func diffVersion(existing, updated apiextensionsv1.CustomResourceDefinitionVersion) ([]string, error) {
+	before, err := flattenVersion(existing)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := flattenVersion(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make([]string, 0)
+	for path, value := range after {
+		old, found := before[path]
+		if !found {
+			diff = append(diff, fmt.Sprintf("+ %s: %v", path, value))
+		} else if !reflect.DeepEqual(old, value) {
+			diff = append(diff, fmt.Sprintf("~ %s: %v -> %v", path, old, value))
+		}
+	}
+
+	for path, value := range before {
+		if _, found := after[path]; !found {
+			diff = append(diff, fmt.Sprintf("- %s: %v", path, value))
+		}
+	}
+
+	// Sorted by the path, not the prefix
+	sort.Slice(diff, func(i, j int) bool {
+		return diff[i][2:] < diff[j][2:]
+	})
+
+	return diff, nil
+}
+
+func flattenVersion(version apiextensionsv1.CustomResourceDefinitionVersion) (map[string]any, error) {
+	b, err := json.Marshal(version)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "name")
+
+	flat := make(map[string]any)
+	flatten("", fields, flat)
+	return flat, nil
+}
+
+func flatten(prefix string, value any, flat map[string]any) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			flat[prefix] = "{}"
+		}
+		for key, child := range v {
+			flatten(joinPath(prefix, key), child, flat)
+		}
+	case []any:
+		if len(v) == 0 {
+			flat[prefix] = "[]"
+		}
+		for i, child := range v {
+			flatten(fmt.Sprintf("%s[%d]", prefix, i), child, flat)
+		}
+	default:
+		flat[prefix] = v
+	}
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	if strings.ContainsAny(key, ".[]") {
+		return fmt.Sprintf("%s[%q]", prefix, key)
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,183 @@
+package helmutil
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// dryRunApplied records the server-side dry-run applies instead of passing them to the fake client, which does not
+// honor the dry-run of applies and would persist them
+type dryRunApplied struct {
+	crds   []apiextensionsv1.CustomResourceDefinition
+	owners []string
+}
+
+func (d *dryRunApplied) apply(ctx context.Context, c client.WithWatch, obj runtime.ApplyConfiguration, opts ...client.ApplyOption) error {
+	applyOpts := &client.ApplyOptions{}
+	applyOpts.ApplyOptions(opts)
+	if !slices.Contains(applyOpts.DryRun, metav1.DryRunAll) {
+		return c.Apply(ctx, obj, opts...)
+	}
+
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	var crd apiextensionsv1.CustomResourceDefinition
+	if err := json.Unmarshal(b, &crd); err != nil {
+		return err
+	}
+	d.crds = append(d.crds, crd)
+	d.owners = append(d.owners, applyOpts.FieldManager)
+	return nil
+}
+
+func crdVersion(name string, storage bool, properties map[string]apiextensionsv1.JSONSchemaProps) apiextensionsv1.CustomResourceDefinitionVersion {
+	return apiextensionsv1.CustomResourceDefinitionVersion{
+		Name:    name,
+		Served:  true,
+		Storage: storage,
+		Schema: &apiextensionsv1.CustomResourceValidation{
+			OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+				Type:       "object",
+				Properties: properties,
+			},
+		},
+	}
+}
+
+func TestDiffCRDVersions(t *testing.T) {
+	require := require.New(t)
+
+	existing := []apiextensionsv1.CustomResourceDefinitionVersion{
+		crdVersion("v1alpha1", false, nil),
+		crdVersion("v1beta1", true, map[string]apiextensionsv1.JSONSchemaProps{
+			"size":    {Type: "integer"},
+			"version": {Type: "string"},
+		}),
+	}
+	updated := []apiextensionsv1.CustomResourceDefinitionVersion{
+		crdVersion("v1beta1", false, map[string]apiextensionsv1.JSONSchemaProps{
+			"size":   {Type: "string"},
+			"racks":  {Type: "array"},
+			"labels": {Type: "object"},
+		}),
+		crdVersion("v1", true, nil),
+	}
+
+	changes, err := diffCRDVersions(existing, updated)
+	require.NoError(err)
+	require.Equal([]CRDVersionChange{
+		{Version: "v1beta1", Change: "changed", Diff: []string{
+			"+ schema.openAPIV3Schema.properties.labels.type: object",
+			"+ schema.openAPIV3Schema.properties.racks.type: array",
+			"~ schema.openAPIV3Schema.properties.size.type: integer -> string",
+			"- schema.openAPIV3Schema.properties.version.type: string",
+			"~ storage: true -> false",
+		}},
+		{Version: "v1", Change: "added"},
+		{Version: "v1alpha1", Change: "removed"},
+	}, changes)
+
+	changes, err = diffCRDVersions(existing, existing)
+	require.NoError(err)
+	require.Empty(changes)
+}
+
+func TestDryRun(t *testing.T) {
+	require := require.New(t)
+
+	scheme := runtime.NewScheme()
+	require.NoError(apiextensionsv1.AddToScheme(scheme))
+
+	existing := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "cassandradatacenters.cassandra.datastax.com"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "cassandra.datastax.com",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: "CassandraDatacenter", Plural: "cassandradatacenters"},
+			Scope: apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				crdVersion("v1alpha1", true, nil),
+			},
+		},
+		Status: apiextensionsv1.CustomResourceDefinitionStatus{StoredVersions: []string{"v1alpha1"}},
+	}
+	applied := &dryRunApplied{}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).WithInterceptorFuncs(interceptor.Funcs{Apply: applied.apply}).Build()
+
+	chartDir := t.TempDir()
+	require.NoError(os.MkdirAll(filepath.Join(chartDir, "cass-operator", "crds"), 0755))
+	require.NoError(os.WriteFile(filepath.Join(chartDir, "cass-operator", "crds", "cassandradatacenters.yaml"), []byte(testCRD), 0644))
+	require.NoError(os.WriteFile(filepath.Join(chartDir, "cass-operator", "crds", "cassandratasks.yaml"), []byte(testTaskCRD), 0644))
+
+	u, err := NewUpgrader(c, K8ssandraRepoName, StableK8ssandraRepoURL, "cass-operator", nil)
+	require.NoError(err)
+
+	changes, err := u.DryRun(t.Context(), chartDir)
+	require.NoError(err)
+	require.Len(changes, 2)
+
+	require.Equal("cassandradatacenters.cassandra.datastax.com", changes[0].Name)
+	require.Equal(CRDUpdate, changes[0].Action)
+	require.Equal([]CRDVersionChange{
+		{Version: "v1beta1", Change: "added"},
+		{Version: "v1alpha1", Change: "removed"},
+	}, changes[0].Versions)
+	require.Equal([]string{"v1alpha1"}, changes[0].DroppedStoredVersions)
+
+	require.Equal(CRDChange{
+		Name:     "cassandratasks.control.k8ssandra.io",
+		Action:   CRDCreate,
+		Versions: []CRDVersionChange{{Version: "v1alpha1", Change: "added"}},
+	}, changes[1])
+
+	// The dry-run applies the same intermediate CRD as the upgrade, with the dropped stored version no longer stored
+	require.Len(applied.crds, 2)
+	require.Equal([]string{FieldManager, FieldManager}, applied.owners)
+	require.Len(applied.crds[0].Spec.Versions, 2)
+	require.Equal("v1beta1", applied.crds[0].Spec.Versions[0].Name)
+	require.True(applied.crds[0].Spec.Versions[0].Storage)
+	require.Equal("v1alpha1", applied.crds[0].Spec.Versions[1].Name)
+	require.False(applied.crds[0].Spec.Versions[1].Storage)
+
+	// Nothing was changed in the cluster
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	require.NoError(c.Get(t.Context(), client.ObjectKeyFromObject(existing), crd))
+	require.Equal([]string{"v1alpha1"}, crd.Status.StoredVersions)
+	require.Len(crd.Spec.Versions, 1)
+	require.Equal("v1alpha1", crd.Spec.Versions[0].Name)
+
+	err = c.Get(t.Context(), client.ObjectKey{Name: "cassandratasks.control.k8ssandra.io"}, crd)
+	require.Error(err)
+}
+
+const testTaskCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: cassandratasks.control.k8ssandra.io
+spec:
+  group: control.k8ssandra.io
+  names:
+    kind: CassandraTask
+    plural: cassandratasks
+  scope: Namespaced
+  versions:
+    - name: v1alpha1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+`
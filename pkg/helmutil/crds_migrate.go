@@ -0,0 +1,285 @@
+package helmutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/k8ssandra/k8ssandra-client/pkg/util"
+	"github.com/pkg/errors"
+	apiextensionshelpers "k8s.io/apiextensions-apiserver/pkg/apihelpers"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	migrationPageSize = 500
+	// migrationPasses is the number of times the objects are listed to catch the ones created during the migration
+	migrationPasses = 3
+)
+
+var (
+	// crdEstablishedTimeout is the time to wait for the CRD with the new storage version to be served
+	crdEstablishedTimeout = 30 * time.Second
+)
+
+// migrationState is the progress of a stored version migration, persisted in the cache directory so that an
+// interrupted migration continues from where it stopped. Migrated has the resourceVersion of each object after it
+// was rewritten, an object with another resourceVersion was written again since and is rewritten.
+type migrationState struct {
+	StorageVersion string            `json:"storageVersion"`
+	Migrated       map[string]string `json:"migrated"`
+	path           string
+}
+
+func migrationStatePath(crdName string) (string, error) {
+	dir, err := util.GetCacheDir("helm", "crd-migrations")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, crdName+".json"), nil
+}
+
+// loadMigrationState returns the saved progress of the migration to storageVersion or an empty state
+func loadMigrationState(crdName, storageVersion string) (*migrationState, error) {
+	path, err := migrationStatePath(crdName)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &migrationState{StorageVersion: storageVersion, Migrated: make(map[string]string), path: path}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var saved migrationState
+	if err := json.Unmarshal(b, &saved); err != nil {
+		log.Warn("Ignoring unreadable stored version migration state", "path", path, "error", err)
+		return state, nil
+	}
+
+	// Progress of a migration to another version is useless, the objects must be written again
+	if saved.StorageVersion == storageVersion && saved.Migrated != nil {
+		log.Info("Resuming stored version migration", "crd", crdName, "migrated", len(saved.Migrated))
+		state.Migrated = saved.Migrated
+	}
+
+	return state, nil
+}
+
+func (s *migrationState) save() error {
+	if _, err := util.CreateIfNotExistsDir(filepath.Dir(s.path)); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, b, 0644)
+}
+
+func (s *migrationState) remove() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// migrateStoredVersions rewrites the custom resources in the storage version of the updated CRD before the removed
+// versions are dropped from status.storedVersions, so that no object is left persisted in a version the API server
+// can no longer read. The CRD is first updated with the removed versions still included.
+//
+// The Upgrader's client must not be restricted to a namespace, the custom resources are listed in all namespaces.
+func (u *Upgrader) migrateStoredVersions(ctx context.Context, existing, updated *apiextensionsv1.CustomResourceDefinition, removed []string) error {
+	storageVersion, err := apiextensionshelpers.GetCRDStorageVersion(updated)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the storage version of CRD %s", updated.GetName())
+	}
+
+	log.Info("Migrating custom resources before removing stored versions", "crd", updated.GetName(), "removed", removed, "storageVersion", storageVersion)
+
+	// Applied by the same field manager as the final CRD, an Update would own the versions and conflict with it
+	obj, err := intermediateCRD(existing, updated, removed)
+	if err != nil {
+		return err
+	}
+
+	if _, err := u.apply(ctx, obj); err != nil {
+		return errors.Wrapf(err, "failed to set storage version %s of CRD %s", storageVersion, updated.GetName())
+	}
+
+	if err := u.waitForEstablished(ctx, updated.GetName()); err != nil {
+		return err
+	}
+
+	state, err := loadMigrationState(updated.GetName(), storageVersion)
+	if err != nil {
+		return err
+	}
+
+	gvk := schema.GroupVersionKind{Group: updated.Spec.Group, Version: storageVersion, Kind: updated.Spec.Names.ListKind}
+	if gvk.Kind == "" {
+		gvk.Kind = updated.Spec.Names.Kind + "List"
+	}
+
+	// Every pass lists all the objects from the API server and rewrites the ones not rewritten yet or written by
+	// someone else since, a pass without new objects leaves none in the removed versions. Objects created during a
+	// pass are caught by the next one.
+	for pass := 1; ; pass++ {
+		migrated, err := u.migrateObjects(ctx, gvk, state)
+		if err != nil {
+			return err
+		}
+
+		if migrated == 0 {
+			break
+		}
+
+		if pass == migrationPasses {
+			return fmt.Errorf("custom resources of CRD %s are still being created in the old versions, rerun the upgrade to continue the migration", updated.GetName())
+		}
+	}
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := u.client.Get(ctx, client.ObjectKey{Name: updated.GetName()}, crd); err != nil {
+		return errors.Wrapf(err, "failed to fetch state of %s", updated.GetName())
+	}
+
+	storedVersions := make([]string, 0, len(crd.Status.StoredVersions))
+	for _, stored := range crd.Status.StoredVersions {
+		if !slices.Contains(removed, stored) {
+			storedVersions = append(storedVersions, stored)
+		}
+	}
+	if !slices.Contains(storedVersions, storageVersion) {
+		storedVersions = append(storedVersions, storageVersion)
+	}
+
+	log.Debug("Updating CustomResourceDefinition versions", "name", updated.GetName(), "storedVersions", storedVersions)
+	crd.Status.StoredVersions = storedVersions
+	if err := u.client.Status().Update(ctx, crd); err != nil {
+		return errors.Wrapf(err, "failed to update CRD storedVersions %s", updated.GetName())
+	}
+
+	if err := state.remove(); err != nil {
+		log.Warn("Failed to remove stored version migration state", "path", state.path, "error", err)
+	}
+
+	log.Info("Migrated custom resources", "crd", updated.GetName(), "migrated", len(state.Migrated), "storedVersions", storedVersions)
+	return nil
+}
+
+// intermediateCRD returns the updated CRD with the removed versions of the existing CRD still included, but no longer
+// as the storage version. The new storage version must be in use before the objects are rewritten, while the removed
+// versions are still served for reading the old objects.
+func intermediateCRD(existing, updated *apiextensionsv1.CustomResourceDefinition, removed []string) (*unstructured.Unstructured, error) {
+	intermediate := updated.DeepCopy()
+	for _, version := range existing.Spec.Versions {
+		if slices.Contains(removed, version.Name) && !slices.ContainsFunc(intermediate.Spec.Versions, func(v apiextensionsv1.CustomResourceDefinitionVersion) bool {
+			return v.Name == version.Name
+		}) {
+			version.Storage = false
+			intermediate.Spec.Versions = append(intermediate.Spec.Versions, version)
+		}
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(intermediate)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to convert CustomResourceDefinition %s to unstructured", updated.GetName())
+	}
+	obj := &unstructured.Unstructured{Object: content}
+	obj.SetGroupVersionKind(apiextensionsv1.SchemeGroupVersion.WithKind("CustomResourceDefinition"))
+	unstructured.RemoveNestedField(obj.Object, "status")
+
+	return obj, nil
+}
+
+// migrateObjects rewrites the objects without changes, which stores them in the current storage version. The objects
+// in the state are skipped, unless they were written again after they were rewritten, which could have been done by
+// an API server still using the old storage version. Returns the number of objects written which were not in the
+// state.
+func (u *Upgrader) migrateObjects(ctx context.Context, gvk schema.GroupVersionKind, state *migrationState) (int, error) {
+	count := 0
+	continueToken := ""
+	for {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk)
+		if err := u.client.List(ctx, list, client.Limit(migrationPageSize), client.Continue(continueToken)); err != nil {
+			return count, errors.Wrapf(err, "failed to list %s", gvk.Kind)
+		}
+
+		for i := range list.Items {
+			item := &list.Items[i]
+			uid := string(item.GetUID())
+			resourceVersion, found := state.Migrated[uid]
+			if found && resourceVersion == item.GetResourceVersion() {
+				continue
+			}
+
+			if err := u.rewriteObject(ctx, item); apierrors.IsNotFound(err) {
+				// Deleted since it was listed
+				continue
+			} else if err != nil {
+				return count, errors.Wrapf(err, "failed to migrate %s %s/%s", gvk.Kind, item.GetNamespace(), item.GetName())
+			}
+
+			state.Migrated[uid] = item.GetResourceVersion()
+			if !found {
+				count++
+			}
+		}
+
+		if err := state.save(); err != nil {
+			return count, err
+		}
+
+		if count > 0 {
+			log.Info("Migrating custom resources", "kind", gvk.Kind, "migrated", len(state.Migrated))
+		}
+
+		continueToken = list.GetContinue()
+		if continueToken == "" {
+			return count, nil
+		}
+	}
+}
+
+// rewriteObject updates the object without changes. On a conflict the object is fetched again and the update retried,
+// the conflicting write could have been done by an API server still using the old storage version.
+func (u *Upgrader) rewriteObject(ctx context.Context, obj *unstructured.Unstructured) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := u.client.Update(ctx, obj)
+		if apierrors.IsConflict(err) {
+			if err := u.client.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				return err
+			}
+		}
+		return err
+	})
+}
+
+func (u *Upgrader) waitForEstablished(ctx context.Context, name string) error {
+	return wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, crdEstablishedTimeout, true, func(ctx context.Context) (bool, error) {
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := u.client.Get(ctx, client.ObjectKey{Name: name}, crd); err != nil {
+			return false, err
+		}
+		return apiextensionshelpers.IsCRDConditionTrue(crd, apiextensionsv1.Established), nil
+	})
+}
@@ -0,0 +1,171 @@
+package helmutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+const testClientConfigCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: clientconfigs.config.k8ssandra.io
+spec:
+  group: config.k8ssandra.io
+  names:
+    kind: ClientConfig
+    listKind: ClientConfigList
+    plural: clientconfigs
+  scope: Namespaced
+  versions:
+    - name: v1beta1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+`
+
+func clientConfig(namespace, name, uid string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(schema.GroupVersionKind{Group: "config.k8ssandra.io", Version: "v1beta1", Kind: "ClientConfig"})
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetUID(types.UID(uid))
+	return obj
+}
+
+func TestMigrateStoredVersions(t *testing.T) {
+	require := require.New(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	scheme := runtime.NewScheme()
+	require.NoError(apiextensionsv1.AddToScheme(scheme))
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(apiextensionsv1.SchemeGroupVersion.WithKind("CustomResourceDefinition"), meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "config.k8ssandra.io", Version: "v1beta1", Kind: "ClientConfig"}, meta.RESTScopeNamespace)
+
+	existing := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "clientconfigs.config.k8ssandra.io"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "config.k8ssandra.io",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: "ClientConfig", ListKind: "ClientConfigList", Plural: "clientconfigs"},
+			Scope: apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				crdVersion("v1alpha1", true, nil),
+				crdVersion("v1beta1", false, nil),
+			},
+		},
+		Status: apiextensionsv1.CustomResourceDefinitionStatus{
+			StoredVersions: []string{"v1alpha1"},
+			Conditions: []apiextensionsv1.CustomResourceDefinitionCondition{
+				{Type: apiextensionsv1.Established, Status: apiextensionsv1.ConditionTrue},
+			},
+		},
+	}
+
+	// The second object is written by someone else while it's migrated
+	conflicts := 0
+	c := newCRDClientBuilder(scheme).
+		WithRESTMapper(mapper).
+		WithStatusSubresource(&apiextensionsv1.CustomResourceDefinition{}).
+		WithObjects(existing, clientConfig("ns1", "first", "uid-1"), clientConfig("ns2", "second", "uid-2"), clientConfig("ns2", "third", "uid-3")).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				if obj.GetName() == "second" && conflicts == 0 {
+					conflicts++
+					return apierrors.NewConflict(schema.GroupResource{Group: "config.k8ssandra.io", Resource: "clientconfigs"}, obj.GetName(), errors.New("object has been modified"))
+				}
+				return c.Update(ctx, obj, opts...)
+			},
+		}).
+		Build()
+
+	before := map[string]string{}
+	for _, key := range []client.ObjectKey{{Namespace: "ns1", Name: "first"}, {Namespace: "ns2", Name: "second"}, {Namespace: "ns2", Name: "third"}} {
+		obj := clientConfig(key.Namespace, key.Name, "")
+		require.NoError(c.Get(t.Context(), key, obj))
+		before[key.Name] = obj.GetResourceVersion()
+	}
+
+	// The first and third objects were migrated by an earlier interrupted run, the third was written again since
+	statePath, err := migrationStatePath(existing.Name)
+	require.NoError(err)
+	require.NoError(os.MkdirAll(filepath.Dir(statePath), 0755))
+	state, err := json.Marshal(migrationState{StorageVersion: "v1beta1", Migrated: map[string]string{"uid-1": before["first"], "uid-3": "1"}})
+	require.NoError(err)
+	require.NoError(os.WriteFile(statePath, state, 0644))
+
+	chartDir := t.TempDir()
+	require.NoError(os.MkdirAll(filepath.Join(chartDir, "test-chart", "crds"), 0755))
+	require.NoError(os.WriteFile(filepath.Join(chartDir, "test-chart", "crds", "clientconfig.yaml"), []byte(testClientConfigCRD), 0644))
+
+	// The CRD was not created with server-side apply, its versions are owned by another manager
+	u, err := NewUpgrader(c, K8ssandraRepoName, StableK8ssandraRepoURL, "test-chart", nil, WithForceConflicts())
+	require.NoError(err)
+
+	_, err = u.ApplyCRDs(t.Context(), chartDir)
+	require.NoError(err)
+
+	// Only the objects missing from the saved progress or written since were rewritten, the conflict was retried
+	first := clientConfig("ns1", "first", "")
+	require.NoError(c.Get(t.Context(), client.ObjectKey{Namespace: "ns1", Name: "first"}, first))
+	require.Equal(before["first"], first.GetResourceVersion())
+
+	for _, name := range []string{"second", "third"} {
+		obj := clientConfig("ns2", name, "")
+		require.NoError(c.Get(t.Context(), client.ObjectKey{Namespace: "ns2", Name: name}, obj))
+		require.NotEqual(before[name], obj.GetResourceVersion(), name)
+	}
+	require.Equal(1, conflicts)
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	require.NoError(c.Get(t.Context(), client.ObjectKey{Name: existing.Name}, crd))
+	require.Equal([]string{"v1beta1"}, crd.Status.StoredVersions)
+	require.Len(crd.Spec.Versions, 1)
+	require.Equal("v1beta1", crd.Spec.Versions[0].Name)
+
+	// The progress is removed after a completed migration
+	_, err = os.Stat(statePath)
+	require.True(os.IsNotExist(err))
+}
+
+func TestLoadMigrationState(t *testing.T) {
+	require := require.New(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	state, err := loadMigrationState("clientconfigs.config.k8ssandra.io", "v1beta1")
+	require.NoError(err)
+	require.Empty(state.Migrated)
+
+	state.Migrated["uid-1"] = "42"
+	require.NoError(state.save())
+
+	state, err = loadMigrationState("clientconfigs.config.k8ssandra.io", "v1beta1")
+	require.NoError(err)
+	require.Equal(map[string]string{"uid-1": "42"}, state.Migrated)
+
+	// Progress of a migration to another storage version is not reused
+	state, err = loadMigrationState("clientconfigs.config.k8ssandra.io", "v1")
+	require.NoError(err)
+	require.Empty(state.Migrated)
+
+	require.NoError(state.remove())
+	require.NoError(state.remove())
+}
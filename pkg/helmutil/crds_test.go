@@ -13,8 +13,13 @@ import (
 	"github.com/k8ssandra/k8ssandra-client/pkg/util"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v4/pkg/chart/common"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	chartutil "helm.sh/helm/v4/pkg/chart/v2/util"
 	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 )
@@ -83,12 +88,8 @@ func TestUpgradingCRDs(t *testing.T) {
 
 //nolint:unparam
 func cleanCache(repoName, chartName string) error {
-	chartDir, err := helmutil.GetChartTargetDir(repoName, chartName)
-	if err != nil {
-		return err
-	}
-
-	return os.RemoveAll(chartDir)
+	_, err := helmutil.CleanChartCache(repoName, chartName)
+	return err
 }
 
 func TestUpgradingStoredVersions(t *testing.T) {
@@ -102,15 +103,10 @@ func TestUpgradingStoredVersions(t *testing.T) {
 	kubeClient := env.GetClientInNamespace(namespace)
 	require.NoError(cleanCache("k8ssandra", chartName))
 
-	// Copy testfiles
-	chartDir, err := helmutil.GetChartTargetDir(helmutil.K8ssandraRepoName, chartName)
-	require.NoError(err)
-
-	crdDir := filepath.Join(chartDir, "crds")
-	_, err = util.CreateIfNotExistsDir(crdDir)
-	require.NoError(err)
-	crdSrc := filepath.Join("..", "..", "testfiles", "crd-upgrader", "multiversion-clientconfig-mockup-v1alpha1.yaml")
-	require.NoError(copyFile(crdSrc, filepath.Join(crdDir, "clientconfig.yaml")))
+	// The chart releases are extracted to the cache like downloaded ones, the cached releases are verified
+	cacheChartRelease(t, chartName, "0.1.0", "multiversion-clientconfig-mockup-v1alpha1.yaml")
+	cacheChartRelease(t, chartName, "0.2.0", "multiversion-clientconfig-mockup-both.yaml")
+	cacheChartRelease(t, chartName, "0.3.0", "multiversion-clientconfig-mockup-v1beta1.yaml")
 
 	testOptions := envtest.CRDInstallOptions{
 		PollInterval: 100 * time.Millisecond,
@@ -141,12 +137,6 @@ func TestUpgradingStoredVersions(t *testing.T) {
 
 	// Upgrade to 0.2.0
 
-	require.NoError(cleanCache("k8ssandra", chartName))
-	_, err = util.CreateIfNotExistsDir(crdDir)
-	require.NoError(err)
-	crdSrc = filepath.Join("..", "..", "testfiles", "crd-upgrader", "multiversion-clientconfig-mockup-both.yaml")
-	require.NoError(copyFile(crdSrc, filepath.Join(crdDir, "clientconfig.yaml")))
-
 	crds, err = u.Upgrade(t.Context(), "0.2.0")
 	require.NoError(err)
 	for _, crd := range crds {
@@ -161,12 +151,6 @@ func TestUpgradingStoredVersions(t *testing.T) {
 
 	// Upgrade to 0.3.0
 
-	require.NoError(cleanCache("k8ssandra", chartName))
-	_, err = util.CreateIfNotExistsDir(crdDir)
-	require.NoError(err)
-	crdSrc = filepath.Join("..", "..", "testfiles", "crd-upgrader", "multiversion-clientconfig-mockup-v1beta1.yaml")
-	require.NoError(copyFile(crdSrc, filepath.Join(crdDir, "clientconfig.yaml")))
-
 	crds, err = u.Upgrade(t.Context(), "0.3.0")
 	require.NoError(err)
 	for _, crd := range crds {
@@ -188,12 +172,6 @@ func TestUpgradingStoredVersions(t *testing.T) {
 
 	// Install 0.2.0
 
-	require.NoError(cleanCache("k8ssandra", chartName))
-	_, err = util.CreateIfNotExistsDir(crdDir)
-	require.NoError(err)
-	crdSrc = filepath.Join("..", "..", "testfiles", "crd-upgrader", "multiversion-clientconfig-mockup-both.yaml")
-	require.NoError(copyFile(crdSrc, filepath.Join(crdDir, "clientconfig.yaml")))
-
 	crds, err = u.Upgrade(t.Context(), "0.2.0")
 	require.NoError(err)
 	for _, crd := range crds {
@@ -208,12 +186,6 @@ func TestUpgradingStoredVersions(t *testing.T) {
 
 	// Upgrade to 0.3.0
 
-	require.NoError(cleanCache("k8ssandra", chartName))
-	_, err = util.CreateIfNotExistsDir(crdDir)
-	require.NoError(err)
-	crdSrc = filepath.Join("..", "..", "testfiles", "crd-upgrader", "multiversion-clientconfig-mockup-v1beta1.yaml")
-	require.NoError(copyFile(crdSrc, filepath.Join(crdDir, "clientconfig.yaml")))
-
 	crds, err = u.Upgrade(t.Context(), "0.3.0")
 	require.NoError(err)
 	for _, crd := range crds {
@@ -227,6 +199,205 @@ func TestUpgradingStoredVersions(t *testing.T) {
 	require.Equal([]string{"v1beta1"}, targetCrd.Status.StoredVersions)
 }
 
+func TestUpgradingFromPath(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	require := require.New(t)
+	chartName := "test-chart"
+	namespace := env.CreateNamespace(t)
+	kubeClient := env.GetClientInNamespace(namespace)
+
+	// Unpacked chart directory
+	chartDir := t.TempDir()
+	chartYaml := fmt.Sprintf("apiVersion: v2\nname: %s\nversion: 0.1.0\n", chartName)
+	require.NoError(os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte(chartYaml), 0644))
+
+	crdDir := filepath.Join(chartDir, "crds")
+	_, err := util.CreateIfNotExistsDir(crdDir)
+	require.NoError(err)
+	crdSrc := filepath.Join("..", "..", "testfiles", "crd-upgrader", "multiversion-clientconfig-mockup-v1alpha1.yaml")
+	require.NoError(copyFile(crdSrc, filepath.Join(crdDir, "clientconfig.yaml")))
+
+	u, err := helmutil.NewUpgrader(kubeClient, helmutil.K8ssandraRepoName, helmutil.StableK8ssandraRepoURL, chartName, []string{})
+	require.NoError(err)
+
+	crds, err := u.UpgradeFromPath(t.Context(), chartDir)
+	require.NoError(err)
+	require.Len(crds, 1)
+
+	targetCrd := &apiextensions.CustomResourceDefinition{}
+	require.NoError(runtime.DefaultUnstructuredConverter.FromUnstructured(crds[0].UnstructuredContent(), targetCrd))
+	testOptions := envtest.CRDInstallOptions{
+		PollInterval: 100 * time.Millisecond,
+		MaxTime:      10 * time.Second,
+	}
+	require.NoError(envtest.WaitForCRDs(env.RestConfig(), []*apiextensions.CustomResourceDefinition{targetCrd}, testOptions))
+	require.NoError(kubeClient.Get(t.Context(), client.ObjectKey{Name: targetCrd.GetName()}, targetCrd))
+	require.Equal([]string{"v1alpha1"}, targetCrd.Status.StoredVersions)
+
+	// Packaged chart archive
+	archive := packageChart(t, chartName, "0.2.0", "multiversion-clientconfig-mockup-both.yaml")
+	_, err = u.UpgradeFromPath(t.Context(), archive)
+	require.NoError(err)
+
+	require.NoError(kubeClient.Get(t.Context(), client.ObjectKey{Name: targetCrd.GetName()}, targetCrd))
+	require.Len(targetCrd.Spec.Versions, 2)
+	require.Equal([]string{"v1alpha1", "v1beta1"}, targetCrd.Status.StoredVersions)
+
+	// The chart name must match the upgrader's
+	_, err = u.UpgradeFromPath(t.Context(), packageChart(t, "other-chart", "0.2.0", "multiversion-clientconfig-mockup-both.yaml"))
+	require.Error(err)
+}
+
+// packageChart writes a chart archive with the CRD testfile as its only CRD
+func packageChart(t *testing.T, chartName, chartVersion, crdFile string) string {
+	crd, err := os.ReadFile(filepath.Join("..", "..", "testfiles", "crd-upgrader", crdFile))
+	require.NoError(t, err)
+
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion: chart.APIVersionV2,
+			Name:       chartName,
+			Version:    chartVersion,
+		},
+		Files: []*common.File{
+			{Name: "crds/clientconfig.yaml", Data: crd},
+		},
+	}
+
+	archive, err := chartutil.Save(ch, t.TempDir())
+	require.NoError(t, err)
+	return archive
+}
+
+// cacheChartRelease extracts a packaged chart release to the cache with the metadata of a downloaded release
+func cacheChartRelease(t *testing.T, chartName, chartVersion, crdFile string) {
+	_, err := helmutil.ExtractChartRelease(packageChart(t, chartName, chartVersion, crdFile), helmutil.K8ssandraRepoName, chartName, chartVersion, "")
+	require.NoError(t, err)
+}
+
+func TestUpgradingLegacyManagedCRD(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	require := require.New(t)
+	namespace := env.CreateNamespace(t)
+	kubeClient := env.GetClientInNamespace(namespace)
+
+	// Installed and updated without server-side apply, like the earlier versions of the upgrader did
+	f, err := os.Open(filepath.Join("..", "..", "testfiles", "crd-upgrader", "multiversion-clientconfig-mockup-v1alpha1.yaml"))
+	require.NoError(err)
+	defer func() {
+		require.NoError(f.Close())
+	}()
+	legacyCrd := &apiextensions.CustomResourceDefinition{}
+	require.NoError(yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(legacyCrd))
+	require.NoError(kubeClient.Create(t.Context(), legacyCrd, client.FieldOwner("kubectl-k8ssandra")))
+
+	testOptions := envtest.CRDInstallOptions{
+		PollInterval: 100 * time.Millisecond,
+		MaxTime:      10 * time.Second,
+	}
+	require.NoError(envtest.WaitForCRDs(env.RestConfig(), []*apiextensions.CustomResourceDefinition{legacyCrd}, testOptions))
+
+	require.NoError(kubeClient.Get(t.Context(), client.ObjectKey{Name: legacyCrd.GetName()}, legacyCrd))
+	legacyCrd.Spec.Versions[0].Schema.OpenAPIV3Schema.Description = "updated without server-side apply"
+	require.NoError(kubeClient.Update(t.Context(), legacyCrd, client.FieldOwner("kubectl-k8ssandra")))
+
+	chartDir := t.TempDir()
+	chartYaml := "apiVersion: v2\nname: test-chart\nversion: 0.2.0\n"
+	require.NoError(os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte(chartYaml), 0644))
+	crdDir := filepath.Join(chartDir, "crds")
+	_, err = util.CreateIfNotExistsDir(crdDir)
+	require.NoError(err)
+	crdSrc := filepath.Join("..", "..", "testfiles", "crd-upgrader", "multiversion-clientconfig-mockup-both.yaml")
+	require.NoError(copyFile(crdSrc, filepath.Join(crdDir, "clientconfig.yaml")))
+
+	// The upgrade changes the versions owned by the legacy field manager without force conflicts
+	u, err := helmutil.NewUpgrader(kubeClient, helmutil.K8ssandraRepoName, helmutil.StableK8ssandraRepoURL, "test-chart", []string{})
+	require.NoError(err)
+	_, err = u.UpgradeFromPath(t.Context(), chartDir)
+	require.NoError(err)
+
+	targetCrd := &apiextensions.CustomResourceDefinition{}
+	require.NoError(kubeClient.Get(t.Context(), client.ObjectKey{Name: legacyCrd.GetName()}, targetCrd))
+	require.Len(targetCrd.Spec.Versions, 2)
+
+	for _, entry := range targetCrd.GetManagedFields() {
+		if entry.Subresource != "" {
+			continue
+		}
+		require.Equal(helmutil.FieldManager, entry.Manager)
+		require.Equal(metav1.ManagedFieldsOperationApply, entry.Operation)
+	}
+	require.Empty(u.Takeovers())
+}
+
+func TestDryRunDroppedStoredVersions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	require := require.New(t)
+	chartName := "test-chart"
+	namespace := env.CreateNamespace(t)
+	kubeClient := env.GetClientInNamespace(namespace)
+
+	chartDir := t.TempDir()
+	chartYaml := fmt.Sprintf("apiVersion: v2\nname: %s\nversion: 0.2.0\n", chartName)
+	require.NoError(os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte(chartYaml), 0644))
+	crdDir := filepath.Join(chartDir, "crds")
+	_, err := util.CreateIfNotExistsDir(crdDir)
+	require.NoError(err)
+	crdSrc := filepath.Join("..", "..", "testfiles", "crd-upgrader", "multiversion-clientconfig-mockup-both.yaml")
+	require.NoError(copyFile(crdSrc, filepath.Join(crdDir, "clientconfig.yaml")))
+
+	u, err := helmutil.NewUpgrader(kubeClient, helmutil.K8ssandraRepoName, helmutil.StableK8ssandraRepoURL, chartName, []string{})
+	require.NoError(err)
+	crds, err := u.UpgradeFromPath(t.Context(), chartDir)
+	require.NoError(err)
+	require.Len(crds, 1)
+
+	installedCrd := &apiextensions.CustomResourceDefinition{}
+	require.NoError(runtime.DefaultUnstructuredConverter.FromUnstructured(crds[0].UnstructuredContent(), installedCrd))
+	testOptions := envtest.CRDInstallOptions{
+		PollInterval: 100 * time.Millisecond,
+		MaxTime:      10 * time.Second,
+	}
+	require.NoError(envtest.WaitForCRDs(env.RestConfig(), []*apiextensions.CustomResourceDefinition{installedCrd}, testOptions))
+	require.NoError(kubeClient.Get(t.Context(), client.ObjectKey{Name: installedCrd.GetName()}, installedCrd))
+	require.Equal([]string{"v1alpha1", "v1beta1"}, installedCrd.Status.StoredVersions)
+
+	// The API server would refuse to remove the stored v1alpha1 with an update, the dry-run must report it instead
+	crdSrc = filepath.Join("..", "..", "testfiles", "crd-upgrader", "multiversion-clientconfig-mockup-v1beta1.yaml")
+	require.NoError(copyFile(crdSrc, filepath.Join(crdDir, "clientconfig.yaml")))
+
+	changes, err := u.DryRun(t.Context(), chartDir)
+	require.NoError(err)
+	require.Len(changes, 1)
+	require.Equal(helmutil.CRDUpdate, changes[0].Action)
+	require.Equal([]string{"v1alpha1"}, changes[0].DroppedStoredVersions)
+
+	removed := false
+	for _, version := range changes[0].Versions {
+		if version.Version == "v1alpha1" {
+			require.Equal("removed", version.Change)
+			removed = true
+		}
+	}
+	require.True(removed)
+
+	// Nothing was changed in the cluster
+	targetCrd := &apiextensions.CustomResourceDefinition{}
+	require.NoError(kubeClient.Get(t.Context(), client.ObjectKey{Name: installedCrd.GetName()}, targetCrd))
+	require.Equal(installedCrd.Spec.Versions, targetCrd.Spec.Versions)
+	require.Equal([]string{"v1alpha1", "v1beta1"}, targetCrd.Status.StoredVersions)
+	require.Equal(installedCrd.GetResourceVersion(), targetCrd.GetResourceVersion())
+}
+
 func copyFile(source, target string) error {
 	src, err := os.Open(source)
 	if err != nil {
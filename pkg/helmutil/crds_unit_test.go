@@ -1,10 +1,16 @@
 package helmutil
 
 import (
+	"bytes"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v4/pkg/chart/common"
+	chartutil "helm.sh/helm/v4/pkg/chart/v2/util"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func TestFindCRDDirs(t *testing.T) {
@@ -62,3 +68,89 @@ func TestFindCRDDirs(t *testing.T) {
 	require.Len(dirs, 1)
 	require.Contains(dirs, chartDir+"/downstream-operator/crds")
 }
+
+func TestFindCRDDirsInChartsDirectory(t *testing.T) {
+	require := require.New(t)
+
+	// An unpacked chart could be in a directory called charts, it must not be mistaken for a subchart
+	chartDir := filepath.Join(t.TempDir(), "charts", "k8ssandra-operator")
+	require.NoError(os.MkdirAll(filepath.Join(chartDir, "crds"), 0755))
+	require.NoError(os.MkdirAll(filepath.Join(chartDir, "charts", "cass-operator", "crds"), 0755))
+
+	dirs, err := findCRDDirs(chartDir, nil)
+	require.NoError(err)
+	require.Equal([]string{filepath.Join(chartDir, "crds")}, dirs)
+
+	dirs, err = findCRDDirs(chartDir, []string{"cass-operator"})
+	require.NoError(err)
+	require.Len(dirs, 2)
+}
+
+func TestLocalChartCRDs(t *testing.T) {
+	require := require.New(t)
+
+	ch := testChart("0.40.0")
+	ch.Files = append(ch.Files, &common.File{Name: "crds/cassandradatacenters.yaml", Data: []byte(testCRD)})
+
+	archive, err := chartutil.Save(ch, t.TempDir())
+	require.NoError(err)
+
+	u, err := NewUpgrader(nil, K8ssandraRepoName, StableK8ssandraRepoURL, "cass-operator", nil)
+	require.NoError(err)
+
+	chartDir, cleanup, err := LocalChartDir(archive, "cass-operator")
+	require.NoError(err)
+
+	crds, err := u.CRDs(chartDir)
+	require.NoError(err)
+	require.Len(crds, 1)
+	require.Equal("cassandradatacenters.cassandra.datastax.com", crds[0].GetName())
+
+	// The extracted archive is removed by the cleanup
+	cleanup()
+	_, err = os.Stat(chartDir)
+	require.True(os.IsNotExist(err))
+
+	// Unpacked directories are used as is
+	unpacked := t.TempDir()
+	require.NoError(chartutil.SaveDir(ch, unpacked))
+	chartDir, cleanup, err = LocalChartDir(filepath.Join(unpacked, "cass-operator"), "")
+	require.NoError(err)
+	defer cleanup()
+	require.Equal(filepath.Join(unpacked, "cass-operator"), chartDir)
+
+	outputDir := filepath.Join(t.TempDir(), "export")
+	files, err := ExportCRDs(crds, outputDir)
+	require.NoError(err)
+	require.Equal([]string{filepath.Join(outputDir, "cassandradatacenters.cassandra.datastax.com.yaml")}, files)
+
+	exported := make([]unstructured.Unstructured, 0)
+	require.NoError(FilterCharts(&exported, outputDir, []string{"CustomResourceDefinition"}))
+	require.Equal(crds, exported)
+
+	var out bytes.Buffer
+	require.NoError(WriteCRDs(crds, &out))
+	require.True(strings.HasPrefix(out.String(), "---\napiVersion: apiextensions.k8s.io/v1\n"))
+
+	_, _, err = LocalChartDir(archive, "k8ssandra-operator")
+	require.ErrorContains(err, "is cass-operator, not k8ssandra-operator")
+}
+
+const testCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: cassandradatacenters.cassandra.datastax.com
+spec:
+  group: cassandra.datastax.com
+  names:
+    kind: CassandraDatacenter
+    plural: cassandradatacenters
+  scope: Namespaced
+  versions:
+    - name: v1beta1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+`
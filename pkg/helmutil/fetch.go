@@ -2,13 +2,13 @@ package helmutil
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/k8ssandra/k8ssandra-client/pkg/util"
+	"github.com/charmbracelet/log"
 	"helm.sh/helm/v4/pkg/action"
 	"helm.sh/helm/v4/pkg/chart/v2/loader"
 	chartutil "helm.sh/helm/v4/pkg/chart/v2/util"
@@ -16,28 +16,56 @@ import (
 	"helm.sh/helm/v4/pkg/downloader"
 	"helm.sh/helm/v4/pkg/getter"
 	"helm.sh/helm/v4/pkg/kube"
+	"helm.sh/helm/v4/pkg/provenance"
+	"helm.sh/helm/v4/pkg/registry"
 	releaseiface "helm.sh/helm/v4/pkg/release"
 	release "helm.sh/helm/v4/pkg/release/v1"
 	"helm.sh/helm/v4/pkg/repo/v1"
 )
 
-// DownloadChartRelease fetches the k8ssandra target version and extracts it to a directory which path is returned
-func DownloadChartRelease(repoName, repoURL, chartName, chartVersion string, options ...getter.Option) (string, error) {
-	// Check if the repo URL is an OCI registry
-	if strings.HasPrefix(repoURL, "oci://") {
-		return "", errors.New("OCI registry support is not implemented in this function")
+// downloadOptions are the options of a chart release download
+type downloadOptions struct {
+	getterOptions   []getter.Option
+	registryOptions []registry.ClientOption
+}
+
+// DownloadOption modifies the chart release download
+type DownloadOption func(*downloadOptions)
+
+// WithGetterOptions adds options to the getters downloading the chart release
+func WithGetterOptions(options ...getter.Option) DownloadOption {
+	return func(o *downloadOptions) {
+		o.getterOptions = append(o.getterOptions, options...)
 	}
+}
 
-	// Regular HTTP(S) repository flow
+// WithRegistryClientOptions adds options to the registry client pulling the chart release from an OCI registry, for
+// example an HTTP client trusting the registry's certificate
+func WithRegistryClientOptions(options ...registry.ClientOption) DownloadOption {
+	return func(o *downloadOptions) {
+		o.registryOptions = append(o.registryOptions, options...)
+	}
+}
+
+// DownloadChartRelease fetches the k8ssandra target version and extracts it to a directory which path is returned.
+// The repoURL can also be an OCI registry (oci://host/path), the chart is then pulled from host/path/chartName.
+// The provenance of the release is verified with the given verification, the identity of the signer is returned for
+// verified releases.
+func DownloadChartRelease(repoName, repoURL, chartName, chartVersion string, verification ChartVerification, options ...DownloadOption) (string, string, error) {
 	// Unfortunately, the helm's chart pull command uses "internal" marked structs, so it can't be used for
 	// pulling the data. Thus, we need to replicate the implementation here and use our own cache
+	opts := downloadOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
 	settings := cli.New()
 	var out strings.Builder
 
 	c := downloader.ChartDownloader{
-		Out: &out,
-		// Keyring: p.Keyring,
-		Verify:  downloader.VerifyNever,
+		Out:     &out,
+		Keyring: verification.keyring(),
+		Verify:  verification.Strategy,
 		Getters: getter.All(settings),
 		Options: []getter.Option{
 			// getter.WithBasicAuth(p.Username, p.Password),
@@ -49,8 +77,21 @@ func DownloadChartRelease(repoName, repoURL, chartName, chartVersion string, opt
 		ContentCache:     settings.ContentCache,
 	}
 
-	c.Options = append(c.Options, options...)
+	c.Options = append(c.Options, opts.getterOptions...)
 
+	if registry.IsOCI(repoURL) {
+		registryClient, err := NewRegistryClient(settings, opts.registryOptions...)
+		if err != nil {
+			return "", "", err
+		}
+		saved, ver, err := downloadOCIChart(&c, registryClient, repoURL, chartName, chartVersion)
+		if err != nil {
+			return "", "", verification.downloadError(repoURL+"/"+chartName, err)
+		}
+		return verifiedDownload(saved, repoURL+"/"+chartName, ver, verification)
+	}
+
+	// Regular HTTP(S) repository flow
 	// helm repo add k8ssandra https://helm.k8ssandra.io/
 	r, err := repo.NewChartRepository(&repo.Entry{
 		Name: repoName,
@@ -58,75 +99,129 @@ func DownloadChartRelease(repoName, repoURL, chartName, chartVersion string, opt
 	}, getter.All(settings))
 
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	// helm repo update k8ssandra
 	index, err := r.DownloadIndexFile()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	// Read the index file for the repository to get chart information and return chart URL
 	repoIndex, err := repo.LoadIndexFile(index)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	// chart name, chart version
 	cv, err := repoIndex.Get(chartName, chartVersion)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	url, err := repo.ResolveReferenceURL(repoURL, cv.URLs[0])
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	// Download to filesystem for extraction purposes
 	dir, err := os.MkdirTemp("", "helmutil-")
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	// _ is ProvenanceVerify (TODO we might want to verify the release)
-	saved, _, err := c.DownloadTo(url, chartVersion, dir)
+	saved, ver, err := c.DownloadTo(url, chartVersion, dir)
 	if err != nil {
-		return "", err
+		removeDir(dir)
+		return "", "", verification.downloadError(url, err)
 	}
 
-	return saved, nil
+	return verifiedDownload(saved, url, ver, verification)
 }
 
-func ExtractChartRelease(saved, repoName, chartName, chartVersion string) (string, error) {
-	// Extract the files
-	subDir := filepath.Join(chartName, chartVersion)
-	extractDir, err := util.GetCacheDir(repoName, subDir)
+// verifiedDownload returns the signer of the downloaded chart release, the download is removed if the verification
+// fails
+func verifiedDownload(saved, ref string, ver *provenance.Verification, verification ChartVerification) (string, string, error) {
+	signedBy, err := verification.signer(ref, ver)
 	if err != nil {
-		return "", err
+		removeDownload(saved)
+		return "", "", err
 	}
+	return saved, signedBy, nil
+}
 
-	if _, err := util.CreateIfNotExistsDir(extractDir); err != nil {
-		return "", err
+// NewRegistryClient returns a client for pulling charts from OCI registries. Credentials are read from the Helm
+// registry config (helm registry login) with a fallback to the Docker config (docker login).
+func NewRegistryClient(settings *cli.EnvSettings, options ...registry.ClientOption) (*registry.Client, error) {
+	opts := []registry.ClientOption{
+		registry.ClientOptCredentialsFile(settings.RegistryConfig),
+		registry.ClientOptEnableCache(true),
 	}
+	return registry.NewClient(append(opts, options...)...)
+}
+
+func downloadOCIChart(c *downloader.ChartDownloader, registryClient *registry.Client, repoURL, chartName, chartVersion string) (string, *provenance.Verification, error) {
+	c.RegistryClient = registryClient
+	c.Options = append(c.Options, getter.WithRegistryClient(registryClient))
+
+	ref := strings.TrimSuffix(repoURL, "/") + "/" + chartName
 
-	// extractDir is a target directory
-	err = chartutil.ExpandFile(extractDir, saved)
+	dir, err := os.MkdirTemp("", "helmutil-")
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return extractDir, nil
+	saved, ver, err := c.DownloadTo(ref, chartVersion, dir)
+	if err != nil {
+		removeDir(dir)
+		return "", nil, err
+	}
+
+	return saved, ver, nil
 }
 
-func GetChartTargetDir(repoName, chartName string) (string, error) {
-	extractDir, err := util.GetCacheDir(repoName, chartName)
+// LocalChartDir returns a directory with the chart from a local archive (.tgz) or unpacked chart directory, for
+// clusters without access to the chart repository. Archives are extracted to a temporary directory which is removed by
+// the returned cleanup function. If chartName is set, the chart must have that name.
+func LocalChartDir(chartPath, chartName string) (string, func(), error) {
+	cleanup := func() {}
+
+	ch, err := loader.Load(chartPath)
+	if err != nil {
+		return "", cleanup, fmt.Errorf("failed to load chart from %s: %w", chartPath, err)
+	}
+
+	if chartName != "" && ch.Metadata.Name != chartName {
+		return "", cleanup, fmt.Errorf("chart in %s is %s, not %s", chartPath, ch.Metadata.Name, chartName)
+	}
+
+	fi, err := os.Stat(chartPath)
 	if err != nil {
-		return "", err
+		return "", cleanup, err
+	}
+
+	if fi.IsDir() {
+		return chartPath, cleanup, nil
+	}
+
+	extractDir, err := os.MkdirTemp("", "helmutil-")
+	if err != nil {
+		return "", cleanup, err
+	}
+
+	cleanup = func() {
+		if err := os.RemoveAll(extractDir); err != nil {
+			log.Warn("Failed to remove chart extraction directory", "path", extractDir, "error", err)
+		}
+	}
+
+	if err := chartutil.ExpandFile(extractDir, chartPath); err != nil {
+		cleanup()
+		return "", func() {}, err
 	}
 
-	return extractDir, err
+	return extractDir, cleanup, nil
 }
 
 func Release(cfg *action.Configuration, releaseName string) (*release.Release, error) {
@@ -0,0 +1,267 @@
+package helmutil
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v4/pkg/chart/common"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	"helm.sh/helm/v4/pkg/chart/v2/loader"
+	chartutil "helm.sh/helm/v4/pkg/chart/v2/util"
+	"helm.sh/helm/v4/pkg/downloader"
+	"helm.sh/helm/v4/pkg/registry"
+)
+
+// fakeRegistry is a minimal stand-in for an OCI registry serving chart artifacts from memory
+type fakeRegistry struct {
+	username  string
+	password  string
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	tags      map[string][]string
+}
+
+func newFakeRegistry(username, password string) *fakeRegistry {
+	return &fakeRegistry{
+		username:  username,
+		password:  password,
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string][]byte),
+		tags:      make(map[string][]string),
+	}
+}
+
+func (r *fakeRegistry) addBlob(data []byte) string {
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	r.blobs[digest] = data
+	return digest
+}
+
+func (r *fakeRegistry) pushChart(t *testing.T, repository string, ch *chart.Chart) {
+	r.pushSignedChart(t, repository, ch, nil)
+}
+
+// pushSignedChart pushes the chart with its provenance file if signer is set, like helm push does for signed charts
+func (r *fakeRegistry) pushSignedChart(t *testing.T, repository string, ch *chart.Chart, signer *openpgp.Entity) {
+	dir := t.TempDir()
+	archive, err := chartutil.Save(ch, dir)
+	require.NoError(t, err)
+	chartData, err := os.ReadFile(archive)
+	require.NoError(t, err)
+	configData, err := json.Marshal(ch.Metadata)
+	require.NoError(t, err)
+
+	layers := []map[string]any{{
+		"mediaType": registry.ChartLayerMediaType,
+		"digest":    r.addBlob(chartData),
+		"size":      len(chartData),
+	}}
+	if signer != nil {
+		signChart(t, signer, archive, ch)
+		provData, err := os.ReadFile(archive + ".prov")
+		require.NoError(t, err)
+		layers = append(layers, map[string]any{
+			"mediaType": registry.ProvLayerMediaType,
+			"digest":    r.addBlob(provData),
+			"size":      len(provData),
+		})
+	}
+
+	manifest, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config": map[string]any{
+			"mediaType": registry.ConfigMediaType,
+			"digest":    r.addBlob(configData),
+			"size":      len(configData),
+		},
+		"layers": layers,
+	})
+	require.NoError(t, err)
+
+	r.manifests[repository+":"+ch.Metadata.Version] = manifest
+	r.manifests[repository+"@"+r.addBlob(manifest)] = manifest
+	r.tags[repository] = append(r.tags[repository], ch.Metadata.Version)
+}
+
+// trustRegistry makes the chart downloads use the HTTP client of the test registry, which trusts its certificate
+func trustRegistry(server *httptest.Server) DownloadOption {
+	return WithRegistryClientOptions(registry.ClientOptHTTPClient(server.Client()))
+}
+
+func (r *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if user, pass, ok := req.BasicAuth(); r.username != "" && (!ok || user != r.username || pass != r.password) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="fake"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case req.URL.Path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+	case strings.HasSuffix(path, "/tags/list"):
+		repository := strings.TrimSuffix(path, "/tags/list")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"name": repository, "tags": r.tags[repository]})
+	case strings.Contains(path, "/manifests/"):
+		repository, reference, _ := strings.Cut(path, "/manifests/")
+		separator := ":"
+		if strings.HasPrefix(reference, "sha256:") {
+			separator = "@"
+		}
+		manifest, found := r.manifests[repository+separator+reference]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		r.write(w, req, "application/vnd.oci.image.manifest.v1+json", manifest)
+	case strings.Contains(path, "/blobs/"):
+		_, digest, _ := strings.Cut(path, "/blobs/")
+		blob, found := r.blobs[digest]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		r.write(w, req, "application/octet-stream", blob)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (r *fakeRegistry) write(w http.ResponseWriter, req *http.Request, contentType string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	w.Header().Set("Docker-Content-Digest", fmt.Sprintf("sha256:%x", sha256.Sum256(data)))
+	if req.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(data)
+}
+
+func testChart(version string) *chart.Chart {
+	return &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion: chart.APIVersionV2,
+			Name:       "cass-operator",
+			Version:    version,
+		},
+		Templates: []*common.File{
+			{Name: "templates/configmap.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\n")},
+		},
+	}
+}
+
+func TestDownloadOCIChartRelease(t *testing.T) {
+	require := require.New(t)
+	setCacheHome(t)
+
+	// Credentials are only found in the Docker config
+	dockerConfig := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dockerConfig)
+	t.Setenv("HELM_REGISTRY_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+
+	fake := newFakeRegistry("mirror", "secret")
+	fake.pushChart(t, "k8ssandra/cass-operator", testChart("0.40.0"))
+	fake.pushChart(t, "k8ssandra/cass-operator", testChart("0.41.0"))
+
+	server := httptest.NewTLSServer(fake)
+	defer server.Close()
+	trusted := trustRegistry(server)
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(err)
+
+	auth := base64.StdEncoding.EncodeToString([]byte("mirror:secret"))
+	dockerAuth := fmt.Sprintf(`{"auths": {%q: {"auth": %q}}}`, serverURL.Host, auth)
+	require.NoError(os.WriteFile(filepath.Join(dockerConfig, "config.json"), []byte(dockerAuth), 0600))
+
+	repoURL := fmt.Sprintf("oci://%s/k8ssandra", serverURL.Host)
+
+	saved, signedBy, err := DownloadChartRelease("test-repo", repoURL, "cass-operator", "0.40.0", ChartVerification{}, trusted)
+	require.NoError(err)
+	defer removeDownload(saved)
+	require.Empty(signedBy)
+
+	ch, err := loader.Load(saved)
+	require.NoError(err)
+	require.Equal("cass-operator", ch.Metadata.Name)
+	require.Equal("0.40.0", ch.Metadata.Version)
+
+	// Without a version the highest tag is pulled
+	latest, _, err := DownloadChartRelease("test-repo", repoURL, "cass-operator", "", ChartVerification{}, trusted)
+	require.NoError(err)
+	defer removeDownload(latest)
+
+	ch, err = loader.Load(latest)
+	require.NoError(err)
+	require.Equal("0.41.0", ch.Metadata.Version)
+
+	_, _, err = DownloadChartRelease("test-repo", repoURL, "cass-operator", "0.39.0", ChartVerification{}, trusted)
+	require.Error(err)
+}
+
+func TestDownloadOCIChartReleaseUnauthorized(t *testing.T) {
+	require := require.New(t)
+	setCacheHome(t)
+
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	t.Setenv("HELM_REGISTRY_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+
+	fake := newFakeRegistry("mirror", "secret")
+	fake.pushChart(t, "k8ssandra/cass-operator", testChart("0.40.0"))
+
+	server := httptest.NewTLSServer(fake)
+	defer server.Close()
+	trusted := trustRegistry(server)
+
+	repoURL := fmt.Sprintf("oci://%s/k8ssandra", strings.TrimPrefix(server.URL, "https://"))
+	_, _, err := DownloadChartRelease("test-repo", repoURL, "cass-operator", "0.40.0", ChartVerification{}, trusted)
+	require.Error(err)
+}
+
+func TestDownloadSignedOCIChartRelease(t *testing.T) {
+	require := require.New(t)
+	setCacheHome(t)
+
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	t.Setenv("HELM_REGISTRY_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+
+	signer := newSigner(t, "k8ssandra-release")
+	fake := newFakeRegistry("", "")
+	fake.pushSignedChart(t, "k8ssandra/cass-operator", testChart("0.40.0"), signer)
+	fake.pushChart(t, "k8ssandra/cass-operator", testChart("0.41.0"))
+
+	server := httptest.NewTLSServer(fake)
+	defer server.Close()
+	trusted := trustRegistry(server)
+
+	repoURL := fmt.Sprintf("oci://%s/k8ssandra", strings.TrimPrefix(server.URL, "https://"))
+
+	verification := ChartVerification{Strategy: downloader.VerifyAlways, Keyring: writeKeyring(t, signer)}
+	saved, signedBy, err := DownloadChartRelease("test-repo", repoURL, "cass-operator", "0.40.0", verification, trusted)
+	require.NoError(err)
+	removeDownload(saved)
+	require.Equal("k8ssandra-release <k8ssandra-release@k8ssandra.io>", signedBy)
+
+	// Unsigned release
+	_, _, err = DownloadChartRelease("test-repo", repoURL, "cass-operator", "0.41.0", verification, trusted)
+	require.Error(err)
+
+	// Signed by a key not in the keyring
+	verification.Keyring = writeKeyring(t, newSigner(t, "someone-else"))
+	_, _, err = DownloadChartRelease("test-repo", repoURL, "cass-operator", "0.40.0", verification, trusted)
+	require.Error(err)
+	require.Contains(err.Error(), verification.Keyring)
+}
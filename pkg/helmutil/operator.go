@@ -0,0 +1,88 @@
+package helmutil
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/charmbracelet/log"
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/chart/v2/loader"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+const (
+	K8ssandraOperatorChartName = "k8ssandra-operator"
+	CassOperatorChartName      = "cass-operator"
+
+	// InstanceLabel is set by the charts to the release name in all the resources of the release
+	InstanceLabel = "app.kubernetes.io/instance"
+)
+
+// OperatorCharts are the operator charts which can be installed from the k8ssandra repository
+var OperatorCharts = []string{K8ssandraOperatorChartName, CassOperatorChartName}
+
+// ActionConfig returns the Helm configuration for the releases in the namespace, the release information is stored
+// in Secrets like the helm CLI does
+func ActionConfig(getter genericclioptions.RESTClientGetter, namespace string) (*action.Configuration, error) {
+	cfg := action.NewConfiguration()
+	if err := cfg.Init(getter, namespace, "secret"); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FetchChart downloads, verifies and extracts the chart to the cache directory. An empty chartVersion fetches the
+// latest version. Returns the directory of the chart and its version.
+func FetchChart(repoName, repoURL, chartName, chartVersion string, verification ChartVerification) (string, string, error) {
+	log.Info("Downloading chart release from remote repository", "repoURL", repoURL, "chartName", chartName, "chartVersion", chartVersion)
+	saved, signedBy, err := DownloadChartRelease(repoName, repoURL, chartName, chartVersion, verification)
+	if err != nil {
+		return "", "", err
+	}
+
+	defer removeDownload(saved)
+
+	ch, err := loader.Load(saved)
+	if err != nil {
+		return "", "", err
+	}
+
+	version := ch.Metadata.Version
+	extractDir, err := ExtractChartRelease(saved, repoName, chartName, version, signedBy)
+	if err != nil {
+		return "", "", err
+	}
+
+	return filepath.Join(extractDir, chartName), version, nil
+}
+
+// OperatorValues returns the release values for the operator charts. The given values are not modified.
+func OperatorValues(values map[string]any, clusterScoped bool) map[string]any {
+	merged := make(map[string]any, len(values)+1)
+	for k, v := range values {
+		merged[k] = v
+	}
+
+	if clusterScoped {
+		global := make(map[string]any)
+		if existing, ok := merged["global"].(map[string]any); ok {
+			for k, v := range existing {
+				global[k] = v
+			}
+		}
+		global["clusterScoped"] = true
+		merged["global"] = global
+	}
+
+	return merged
+}
+
+// ValidateOperatorChart returns an error if the chart is not one of the operator charts
+func ValidateOperatorChart(chartName string) error {
+	for _, name := range OperatorCharts {
+		if name == chartName {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported chart %s, supported charts are %v", chartName, OperatorCharts)
+}
@@ -0,0 +1,91 @@
+package helmutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	releasecommon "helm.sh/helm/v4/pkg/release/common"
+)
+
+func TestOperatorValues(t *testing.T) {
+	require := require.New(t)
+
+	values := map[string]any{
+		"global": map[string]any{"clusterScoped": false, "imagePullSecrets": []any{"pull"}},
+		"image":  map[string]any{"tag": "latest"},
+	}
+
+	merged := OperatorValues(values, true)
+	require.Equal(map[string]any{"clusterScoped": true, "imagePullSecrets": []any{"pull"}}, merged["global"])
+	require.Equal(values["image"], merged["image"])
+
+	// The input is not modified
+	require.Equal(false, values["global"].(map[string]any)["clusterScoped"])
+
+	merged = OperatorValues(nil, true)
+	require.Equal(map[string]any{"global": map[string]any{"clusterScoped": true}}, merged)
+
+	require.Empty(OperatorValues(nil, false))
+}
+
+func TestValidateOperatorChart(t *testing.T) {
+	require := require.New(t)
+	require.NoError(ValidateOperatorChart(K8ssandraOperatorChartName))
+	require.NoError(ValidateOperatorChart(CassOperatorChartName))
+	require.Error(ValidateOperatorChart("medusa"))
+}
+
+func TestCompareChartVersions(t *testing.T) {
+	require := require.New(t)
+
+	cmp, err := CompareChartVersions("1.20.0", "1.21.0")
+	require.NoError(err)
+	require.Equal(-1, cmp)
+
+	cmp, err = CompareChartVersions("v1.21.0", "1.21.0")
+	require.NoError(err)
+	require.Equal(0, cmp)
+
+	cmp, err = CompareChartVersions("1.21.0", "1.20.2")
+	require.NoError(err)
+	require.Equal(1, cmp)
+
+	_, err = CompareChartVersions("1.20.0", "latest")
+	require.ErrorContains(err, "invalid chart version latest")
+}
+
+func TestRollbackUpgrade(t *testing.T) {
+	require := require.New(t)
+
+	// The chart fails to load, Helm did not record a new revision and the release is left as it was
+	cfg := testActionConfig(t, testRelease(1, releasecommon.StatusDeployed, nil))
+	_, err := UpgradeValues(cfg, t.TempDir(), "cass-operator", "cass-operator", "default", strings.NewReader(""), time.Minute)
+	require.Error(err)
+
+	rolledBack, err := RollbackUpgrade(cfg, "cass-operator", 1, time.Minute)
+	require.NoError(err)
+	require.False(rolledBack)
+	history, err := ReleaseHistory(cfg, "cass-operator")
+	require.NoError(err)
+	require.Len(history, 1)
+	require.Equal(releasecommon.StatusDeployed, history[0].Info.Status)
+
+	for _, status := range []releasecommon.Status{releasecommon.StatusFailed, releasecommon.StatusPendingUpgrade, releasecommon.StatusDeployed} {
+		cfg := testActionConfig(t,
+			testRelease(1, releasecommon.StatusSuperseded, nil),
+			testRelease(2, status, nil),
+		)
+
+		rolledBack, err := RollbackUpgrade(cfg, "cass-operator", 1, time.Minute)
+		require.NoError(err, status)
+		require.True(rolledBack, status)
+
+		rel, err := Release(cfg, "cass-operator")
+		require.NoError(err)
+		require.Equal(3, rel.Version)
+		require.Equal(releasecommon.StatusDeployed, rel.Info.Status)
+		require.Equal("Rollback to 1", rel.Info.Description)
+	}
+}
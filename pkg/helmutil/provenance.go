@@ -0,0 +1,122 @@
+package helmutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"helm.sh/helm/v4/pkg/downloader"
+	"helm.sh/helm/v4/pkg/provenance"
+)
+
+const (
+	VerifyNever      = "never"
+	VerifyIfPossible = "if-possible"
+	VerifyAlways     = "always"
+)
+
+// ChartVerification selects how the provenance of the downloaded chart releases is verified. The zero value does not
+// verify the releases.
+type ChartVerification struct {
+	Strategy downloader.VerificationStrategy
+	// Keyring is the public keyring with the trusted signers, DefaultKeyring if empty
+	Keyring string
+}
+
+// NewChartVerification parses the strategy (never, if-possible or always) and returns the verification using keyring
+func NewChartVerification(strategy, keyring string) (ChartVerification, error) {
+	v := ChartVerification{Keyring: keyring}
+	switch strategy {
+	case VerifyNever, "":
+		v.Strategy = downloader.VerifyNever
+	case VerifyIfPossible:
+		v.Strategy = downloader.VerifyIfPossible
+	case VerifyAlways:
+		v.Strategy = downloader.VerifyAlways
+	default:
+		return v, fmt.Errorf("unknown verification strategy %s, must be one of %s, %s or %s", strategy, VerifyNever, VerifyIfPossible, VerifyAlways)
+	}
+	return v, nil
+}
+
+// Required is true if unsigned or unverified chart releases must not be used
+func (v ChartVerification) Required() bool {
+	return v.Strategy == downloader.VerifyAlways
+}
+
+// WithVerification makes the Upgrader verify the provenance of the downloaded chart releases. With a required
+// verification, cached releases which were not verified when they were downloaded are downloaded again.
+func WithVerification(verification ChartVerification) UpgraderOption {
+	return func(u *Upgrader) {
+		u.verification = verification
+	}
+}
+
+// DefaultKeyring returns the GnuPG public keyring of the user, like helm verify does
+func DefaultKeyring() string {
+	if home := os.Getenv("GNUPGHOME"); home != "" {
+		return filepath.Join(home, "pubring.gpg")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".gnupg", "pubring.gpg")
+	}
+	return filepath.Join(home, ".gnupg", "pubring.gpg")
+}
+
+func (v ChartVerification) keyring() string {
+	if v.Keyring == "" {
+		return DefaultKeyring()
+	}
+	return v.Keyring
+}
+
+// signer returns the identity which signed the verified chart release. An empty identity is returned for unverified
+// releases unless the verification is required.
+func (v ChartVerification) signer(ref string, ver *provenance.Verification) (string, error) {
+	if v.Strategy == downloader.VerifyNever {
+		return "", nil
+	}
+
+	if ver == nil || ver.SignedBy == nil {
+		if v.Required() {
+			return "", fmt.Errorf("chart release %s has no verified provenance", ref)
+		}
+		log.Warn("Chart release has no provenance file, it was not verified", "chart", ref)
+		return "", nil
+	}
+
+	signedBy := SignedBy(ver)
+	log.Info("Verified chart release provenance", "chart", ref, "signedBy", signedBy, "hash", ver.FileHash)
+	return signedBy, nil
+}
+
+// downloadError adds the keyring to the errors of downloads with verification, the errors of failed verifications
+// don't tell which keyring the signer was not found in
+func (v ChartVerification) downloadError(ref string, err error) error {
+	if v.Strategy == downloader.VerifyNever {
+		return err
+	}
+	return fmt.Errorf("failed to download and verify chart release %s with keyring %s: %w", ref, v.keyring(), err)
+}
+
+// SignedBy returns the identities of the key which signed the chart release
+func SignedBy(ver *provenance.Verification) string {
+	if ver == nil || ver.SignedBy == nil {
+		return ""
+	}
+
+	identities := make([]string, 0, len(ver.SignedBy.Identities))
+	for name := range ver.SignedBy.Identities {
+		identities = append(identities, name)
+	}
+	sort.Strings(identities)
+
+	if len(identities) == 0 && ver.SignedBy.PrimaryKey != nil {
+		return ver.SignedBy.PrimaryKey.KeyIdString()
+	}
+	return strings.Join(identities, ", ")
+}
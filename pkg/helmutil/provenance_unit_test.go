@@ -0,0 +1,163 @@
+package helmutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	"helm.sh/helm/v4/pkg/downloader"
+	"helm.sh/helm/v4/pkg/provenance"
+)
+
+func newSigner(t *testing.T, name string) *openpgp.Entity {
+	entity, err := openpgp.NewEntity(name, "", name+"@k8ssandra.io", nil)
+	require.NoError(t, err)
+	return entity
+}
+
+// writeKeyring writes the public keys of the entities to a keyring file
+func writeKeyring(t *testing.T, entities ...*openpgp.Entity) string {
+	path := filepath.Join(t.TempDir(), "pubring.gpg")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, f.Close()) }()
+
+	for _, entity := range entities {
+		require.NoError(t, entity.Serialize(f))
+	}
+	return path
+}
+
+// signChart writes the provenance file of the chart archive, like helm package --sign does
+func signChart(t *testing.T, signer *openpgp.Entity, archive string, ch *chart.Chart) {
+	archiveData, err := os.ReadFile(archive)
+	require.NoError(t, err)
+	metadata, err := yaml.Marshal(ch.Metadata)
+	require.NoError(t, err)
+
+	sig := &provenance.Signatory{Entity: signer}
+	prov, err := sig.ClearSign(archiveData, filepath.Base(archive), metadata)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(archive+".prov", []byte(prov), 0644))
+}
+
+func TestNewChartVerification(t *testing.T) {
+	require := require.New(t)
+
+	v, err := NewChartVerification("", "")
+	require.NoError(err)
+	require.Equal(downloader.VerifyNever, v.Strategy)
+	require.False(v.Required())
+
+	v, err = NewChartVerification(VerifyIfPossible, "keyring.gpg")
+	require.NoError(err)
+	require.Equal(downloader.VerifyIfPossible, v.Strategy)
+	require.Equal("keyring.gpg", v.keyring())
+
+	v, err = NewChartVerification(VerifyAlways, "")
+	require.NoError(err)
+	require.True(v.Required())
+
+	t.Setenv("GNUPGHOME", "/gnupg")
+	require.Equal(filepath.Join("/gnupg", "pubring.gpg"), v.keyring())
+
+	_, err = NewChartVerification("sometimes", "")
+	require.Error(err)
+}
+
+func TestDownloadSignedChartRelease(t *testing.T) {
+	require := require.New(t)
+	setCacheHome(t)
+
+	signer := newSigner(t, "k8ssandra-release")
+	server, _ := chartRepository(t, signer, "0.40.0")
+
+	verification := ChartVerification{Strategy: downloader.VerifyAlways, Keyring: writeKeyring(t, signer)}
+	saved, signedBy, err := DownloadChartRelease("test-repo", server.URL, "cass-operator", "0.40.0", verification)
+	require.NoError(err)
+	removeDownload(saved)
+	require.Equal("k8ssandra-release <k8ssandra-release@k8ssandra.io>", signedBy)
+
+	// Signed by a key not in the keyring
+	verification.Keyring = writeKeyring(t, newSigner(t, "someone-else"))
+	_, _, err = DownloadChartRelease("test-repo", server.URL, "cass-operator", "0.40.0", verification)
+	require.Error(err)
+	require.Contains(err.Error(), verification.Keyring)
+
+	verification.Strategy = downloader.VerifyIfPossible
+	_, _, err = DownloadChartRelease("test-repo", server.URL, "cass-operator", "0.40.0", verification)
+	require.Error(err)
+
+	// Without verification the signature is not checked
+	saved, signedBy, err = DownloadChartRelease("test-repo", server.URL, "cass-operator", "0.40.0", ChartVerification{})
+	require.NoError(err)
+	removeDownload(saved)
+	require.Empty(signedBy)
+}
+
+func TestDownloadUnsignedChartRelease(t *testing.T) {
+	require := require.New(t)
+	setCacheHome(t)
+
+	server, _ := chartRepository(t, nil, "0.40.0")
+	keyring := writeKeyring(t, newSigner(t, "k8ssandra-release"))
+
+	saved, signedBy, err := DownloadChartRelease("test-repo", server.URL, "cass-operator", "0.40.0", ChartVerification{Strategy: downloader.VerifyIfPossible, Keyring: keyring})
+	require.NoError(err)
+	removeDownload(saved)
+	require.Empty(signedBy)
+
+	_, _, err = DownloadChartRelease("test-repo", server.URL, "cass-operator", "0.40.0", ChartVerification{Strategy: downloader.VerifyAlways, Keyring: keyring})
+	require.Error(err)
+}
+
+func TestChartDirRequiredVerification(t *testing.T) {
+	require := require.New(t)
+	setCacheHome(t)
+
+	signer := newSigner(t, "k8ssandra-release")
+	server, downloads := chartRepository(t, signer, "0.40.0")
+
+	// Cached without verification
+	u, err := NewUpgrader(nil, "test-repo", server.URL, "cass-operator", nil)
+	require.NoError(err)
+	chartDir, cleanup, err := u.ChartDir("0.40.0")
+	require.NoError(err)
+	cleanup()
+	require.Equal(1, *downloads)
+
+	verification := ChartVerification{Strategy: downloader.VerifyAlways, Keyring: writeKeyring(t, signer)}
+	u, err = NewUpgrader(nil, "test-repo", server.URL, "cass-operator", nil, WithVerification(verification))
+	require.NoError(err)
+
+	// The unverified cached release is downloaded again and verified
+	_, cleanup, err = u.ChartDir("0.40.0")
+	require.NoError(err)
+	cleanup()
+	require.Equal(2, *downloads)
+
+	cached, err := ListChartCache("test-repo")
+	require.NoError(err)
+	require.Len(cached, 1)
+	require.Equal(chartDir, cached[0].Dir)
+	require.Equal("k8ssandra-release <k8ssandra-release@k8ssandra.io>", cached[0].SignedBy)
+
+	// The verified cached release is used
+	_, cleanup, err = u.ChartDir("0.40.0")
+	require.NoError(err)
+	cleanup()
+	require.Equal(2, *downloads)
+
+	// Failed verification does not use the cache
+	verification.Keyring = writeKeyring(t, newSigner(t, "someone-else"))
+	require.NoError(os.RemoveAll(chartDir))
+	u, err = NewUpgrader(nil, "test-repo", server.URL, "cass-operator", nil, WithVerification(verification))
+	require.NoError(err)
+	_, _, err = u.ChartDir("0.40.0")
+	require.Error(err)
+	require.NoDirExists(chartDir)
+}
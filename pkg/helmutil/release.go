@@ -2,11 +2,14 @@ package helmutil
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/k8ssandra/k8ssandra-client/pkg/util"
 	"gopkg.in/yaml.v3"
 	"helm.sh/helm/v4/pkg/action"
@@ -14,6 +17,8 @@ import (
 	chart "helm.sh/helm/v4/pkg/chart/v2"
 	"helm.sh/helm/v4/pkg/chart/v2/loader"
 	"helm.sh/helm/v4/pkg/cli"
+	"helm.sh/helm/v4/pkg/kube"
+	releasecommon "helm.sh/helm/v4/pkg/release/common"
 	release "helm.sh/helm/v4/pkg/release/v1"
 )
 
@@ -33,11 +38,19 @@ func SetValues(cfg *action.Configuration, releaseName string) (map[string]any, e
 	return client.Run(releaseName)
 }
 
-func UpgradeValues(cfg *action.Configuration, chartDir, chartName, releaseName string, inputValues *os.File) (*release.Release, error) {
+// UpgradeValues upgrades the release to the chart in chartDir/chartName with the values read from inputValues. The
+// values replace the previous release values, they're expected to be the result of MergeValuesFile. CRDs are not
+// touched, they must be upgraded before the release.
+func UpgradeValues(cfg *action.Configuration, chartDir, chartName, releaseName, namespace string, inputValues io.Reader, timeout time.Duration) (*release.Release, error) {
 	u := action.NewUpgrade(cfg)
-	u.ReuseValues = true
-
-	// Needs the chartPath we just Merged values from ..
+	u.Namespace = namespace
+	// ReuseValues would also keep the previous chart's defaults, the merged values already include the user set ones
+	u.ResetValues = true
+	u.SkipCRDs = true
+	u.WaitStrategy = kube.StatusWatcherStrategy
+	if timeout > 0 {
+		u.Timeout = timeout
+	}
 
 	// Check chart dependencies to make sure all are present in /charts
 	chartDir = filepath.Join(chartDir, chartName)
@@ -72,6 +85,51 @@ func UpgradeValues(cfg *action.Configuration, chartDir, chartName, releaseName s
 	return legacyRelease(u.Run(releaseName, ch, values))
 }
 
+// RollbackUpgrade returns the release to previousRevision after a failed upgrade and waits for the resources to become
+// ready. Upgrades failing before Helm recorded a new revision, such as chart load or values validation errors, left
+// the release untouched and are not rolled back. Returns true if the release was rolled back.
+func RollbackUpgrade(cfg *action.Configuration, releaseName string, previousRevision int, timeout time.Duration) (bool, error) {
+	rel, err := Release(cfg, releaseName)
+	if err != nil {
+		return false, err
+	}
+
+	status := releasecommon.StatusUnknown
+	if rel.Info != nil {
+		status = rel.Info.Status
+	}
+
+	if rel.Version <= previousRevision && status != releasecommon.StatusFailed && status != releasecommon.StatusPendingUpgrade {
+		return false, nil
+	}
+
+	r := action.NewRollback(cfg)
+	r.WaitStrategy = kube.StatusWatcherStrategy
+	if rel.Version > previousRevision {
+		r.Version = previousRevision
+	}
+	if timeout > 0 {
+		r.Timeout = timeout
+	}
+	return true, r.Run(releaseName)
+}
+
+// CompareChartVersions compares two chart versions, returning -1, 0 or 1 if current is older, equal to or newer than
+// target
+func CompareChartVersions(current, target string) (int, error) {
+	cv, err := semver.NewVersion(current)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chart version %s: %w", current, err)
+	}
+
+	tv, err := semver.NewVersion(target)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chart version %s: %w", target, err)
+	}
+
+	return cv.Compare(tv), nil
+}
+
 func MergeValuesFile(cfg *action.Configuration, settings *cli.EnvSettings, chartDir, chartVersion, chartName, releaseName string) (*os.File, error) {
 	// Create temp file with merged default values.yaml (with comments) and helm modified values
 	// If there were changes, upgrade Helm release with the new overridden settings
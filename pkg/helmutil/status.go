@@ -0,0 +1,192 @@
+package helmutil
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v4/pkg/action"
+	chartutil "helm.sh/helm/v4/pkg/chart/common/util"
+	release "helm.sh/helm/v4/pkg/release/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	deser "k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CRDState is how a CRD in the cluster compares to the same CRD in the installed chart
+type CRDState string
+
+const (
+	CRDMatches CRDState = "matches"
+	CRDDiffers CRDState = "differs"
+	CRDMissing CRDState = "missing"
+)
+
+// CRDStatus is the state of a single CRD of the installed chart, Versions has the differences of the cluster CRD from
+// the chart's CRD
+type CRDStatus struct {
+	Name     string
+	State    CRDState
+	Versions []CRDVersionChange
+}
+
+// ValueOverride is a user supplied value of the release and the chart's default value for the same path. Default is
+// nil if the chart has no default for the path.
+type ValueOverride struct {
+	Path    string
+	Default any
+	Value   any
+}
+
+// ReleaseHistory returns the revisions of the release, oldest first
+func ReleaseHistory(cfg *action.Configuration, releaseName string) ([]*release.Release, error) {
+	historyAction := action.NewHistory(cfg)
+	releases, err := historyAction.Run(releaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]*release.Release, 0, len(releases))
+	for _, rel := range releases {
+		legacyRelease, err := legacyRelease(rel, nil)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, legacyRelease)
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Version < history[j].Version
+	})
+
+	return history, nil
+}
+
+// DefaultValues returns the default values of the release's chart, including the defaults of its subcharts
+func DefaultValues(rel *release.Release) (map[string]any, error) {
+	return chartutil.CoalesceValues(rel.Chart, nil)
+}
+
+// AllValues returns the user supplied values of the release merged onto the chart defaults, the values the release
+// was rendered with
+func AllValues(rel *release.Release) (map[string]any, error) {
+	return chartutil.CoalesceValues(rel.Chart, rel.Config)
+}
+
+// ValueOverrides compares the user supplied values to the defaults, one entry per user supplied leaf value sorted by
+// the path. Values equal to the default are included, they're still pinned by the user.
+func ValueOverrides(values, defaults map[string]any) []ValueOverride {
+	set := make(map[string]any)
+	flatten("", values, set)
+
+	defaulted := make(map[string]any)
+	flatten("", defaults, defaulted)
+
+	overrides := make([]ValueOverride, 0, len(set))
+	for path, value := range set {
+		overrides = append(overrides, ValueOverride{Path: path, Default: defaulted[path], Value: value})
+	}
+
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].Path < overrides[j].Path
+	})
+
+	return overrides
+}
+
+// Overridden is true if the user supplied value is different from the chart default
+func (v ValueOverride) Overridden() bool {
+	return !reflect.DeepEqual(v.Default, v.Value)
+}
+
+// ReleaseCRDs returns the CRDs of the release's chart and its subcharts
+func ReleaseCRDs(rel *release.Release) ([]unstructured.Unstructured, error) {
+	crds := make([]unstructured.Unstructured, 0)
+	dec := deser.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
+
+	for _, crdObject := range rel.Chart.CRDObjects() {
+		docs, err := parseYamlDocs(crdObject.File.Data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse CRD file %s", crdObject.Filename)
+		}
+
+		for _, b := range docs {
+			crd := unstructured.Unstructured{}
+			_, gvk, err := dec.Decode(b, nil, &crd)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to decode CRD file %s", crdObject.Filename)
+			}
+
+			if gvk.Kind != "CustomResourceDefinition" {
+				continue
+			}
+
+			crds = append(crds, crd)
+		}
+	}
+
+	return crds, nil
+}
+
+// CompareReleaseCRDs compares the CRDs in the cluster to the CRDs of the release's chart version
+func CompareReleaseCRDs(ctx context.Context, c client.Client, rel *release.Release) ([]CRDStatus, error) {
+	crds, err := ReleaseCRDs(rel)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]CRDStatus, 0, len(crds))
+	for _, obj := range crds {
+		status := CRDStatus{Name: obj.GetName()}
+
+		existingCrd := obj.DeepCopy()
+		err := c.Get(ctx, client.ObjectKey{Name: obj.GetName()}, existingCrd)
+		if apierrors.IsNotFound(err) {
+			status.State = CRDMissing
+			statuses = append(statuses, status)
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to fetch state of %s", obj.GetName())
+		}
+
+		chartDefinition, err := toCRD(&obj)
+		if err != nil {
+			return nil, err
+		}
+
+		existingDefinition, err := toCRD(existingCrd)
+		if err != nil {
+			return nil, err
+		}
+
+		// The cluster CRD is compared as if it was upgraded to the chart's version
+		status.Versions, err = diffCRDVersions(existingDefinition.Spec.Versions, chartDefinition.Spec.Versions)
+		if err != nil {
+			return nil, err
+		}
+
+		status.State = CRDMatches
+		if len(status.Versions) > 0 {
+			status.State = CRDDiffers
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses, nil
+}
+
+// CRDsMatch is true if all the CRDs of the release are in the cluster at the release's chart version
+func CRDsMatch(statuses []CRDStatus) bool {
+	for _, status := range statuses {
+		if status.State != CRDMatches {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package helmutil
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/chart/common"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	kubefake "helm.sh/helm/v4/pkg/kube/fake"
+	releasecommon "helm.sh/helm/v4/pkg/release/common"
+	release "helm.sh/helm/v4/pkg/release/v1"
+	"helm.sh/helm/v4/pkg/storage"
+	"helm.sh/helm/v4/pkg/storage/driver"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// testActionConfig returns a Helm configuration storing the releases in memory
+func testActionConfig(t *testing.T, releases ...*release.Release) *action.Configuration {
+	cfg := &action.Configuration{
+		Releases:   storage.Init(driver.NewMemory()),
+		KubeClient: &kubefake.PrintingKubeClient{Out: io.Discard},
+	}
+	for _, rel := range releases {
+		require.NoError(t, cfg.Releases.Create(rel))
+	}
+	return cfg
+}
+
+func testRelease(revision int, status releasecommon.Status, config map[string]any) *release.Release {
+	ch := testChart("0.40.0")
+	ch.Values = map[string]any{
+		"image":    map[string]any{"repository": "k8ssandra/cass-operator", "tag": "v1.22.0"},
+		"replicas": 1,
+	}
+	ch.Files = []*common.File{
+		{Name: "crds/clientconfig.yaml", Data: []byte(testClientConfigCRD)},
+	}
+
+	return &release.Release{
+		Name:      "cass-operator",
+		Namespace: "default",
+		Version:   revision,
+		Chart:     ch,
+		Config:    config,
+		Info:      &release.Info{Status: status, Description: "Install complete"},
+	}
+}
+
+func TestReleaseHistory(t *testing.T) {
+	require := require.New(t)
+
+	cfg := testActionConfig(t,
+		testRelease(2, releasecommon.StatusDeployed, nil),
+		testRelease(1, releasecommon.StatusSuperseded, nil),
+	)
+
+	history, err := ReleaseHistory(cfg, "cass-operator")
+	require.NoError(err)
+	require.Len(history, 2)
+	require.Equal(1, history[0].Version)
+	require.Equal(2, history[1].Version)
+
+	_, err = ReleaseHistory(cfg, "k8ssandra-operator")
+	require.Error(err)
+}
+
+func TestValueOverrides(t *testing.T) {
+	require := require.New(t)
+
+	rel := testRelease(1, releasecommon.StatusDeployed, map[string]any{
+		"image":    map[string]any{"tag": "v1.23.0"},
+		"replicas": 1,
+		"extra":    "value",
+	})
+
+	defaults, err := DefaultValues(rel)
+	require.NoError(err)
+
+	overrides := ValueOverrides(rel.Config, defaults)
+	require.Equal([]ValueOverride{
+		{Path: "extra", Default: nil, Value: "value"},
+		{Path: "image.tag", Default: "v1.22.0", Value: "v1.23.0"},
+		{Path: "replicas", Default: 1, Value: 1},
+	}, overrides)
+	require.True(overrides[0].Overridden())
+	require.True(overrides[1].Overridden())
+	require.False(overrides[2].Overridden())
+
+	all, err := AllValues(rel)
+	require.NoError(err)
+	require.Equal(map[string]any{"repository": "k8ssandra/cass-operator", "tag": "v1.23.0"}, all["image"])
+}
+
+func TestCompareReleaseCRDs(t *testing.T) {
+	require := require.New(t)
+
+	scheme := runtime.NewScheme()
+	require.NoError(apiextensionsv1.AddToScheme(scheme))
+	c := newCRDClientBuilder(scheme).Build()
+
+	rel := testRelease(1, releasecommon.StatusDeployed, nil)
+
+	statuses, err := CompareReleaseCRDs(t.Context(), c, rel)
+	require.NoError(err)
+	require.Equal([]CRDStatus{{Name: "clientconfigs.config.k8ssandra.io", State: CRDMissing}}, statuses)
+	require.False(CRDsMatch(statuses))
+
+	// Older CRD version in the cluster
+	u, err := NewUpgrader(c, K8ssandraRepoName, StableK8ssandraRepoURL, "test-chart", nil)
+	require.NoError(err)
+	_, err = u.ApplyCRDs(t.Context(), writeTestCRD(t, strings.Replace(testClientConfigCRD, "v1beta1", "v1alpha1", 1)))
+	require.NoError(err)
+
+	statuses, err = CompareReleaseCRDs(t.Context(), c, rel)
+	require.NoError(err)
+	require.Len(statuses, 1)
+	require.Equal(CRDDiffers, statuses[0].State)
+	require.Equal([]CRDVersionChange{
+		{Version: "v1beta1", Change: "added"},
+		{Version: "v1alpha1", Change: "removed"},
+	}, statuses[0].Versions)
+
+	// Upgraded to the release's chart version
+	_, err = u.ApplyCRDs(t.Context(), writeTestCRD(t, testClientConfigCRD))
+	require.NoError(err)
+
+	statuses, err = CompareReleaseCRDs(t.Context(), c, rel)
+	require.NoError(err)
+	require.True(CRDsMatch(statuses))
+}
+
+func TestReleaseCRDsFromSubcharts(t *testing.T) {
+	require := require.New(t)
+
+	rel := testRelease(1, releasecommon.StatusDeployed, nil)
+	rel.Chart.Files = nil
+	sub := testRelease(1, releasecommon.StatusDeployed, nil).Chart
+	sub.Metadata = &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "sub", Version: "0.1.0"}
+	rel.Chart.AddDependency(sub)
+
+	crds, err := ReleaseCRDs(rel)
+	require.NoError(err)
+	require.Len(crds, 1)
+	require.Equal("clientconfigs.config.k8ssandra.io", crds[0].GetName())
+}
@@ -5,6 +5,7 @@ import (
 
 	cassdcapi "github.com/k8ssandra/cass-operator/apis/cassandra/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/rest"
@@ -18,13 +19,17 @@ type NamespacedClient struct {
 	Namespace string
 }
 
-// GetClient returns a controller-runtime client with cass-operator API defined
+// GetClient returns a controller-runtime client with cass-operator and CustomResourceDefinition APIs defined
 func GetClient(restConfig *rest.Config) (client.Client, error) {
 	c, err := client.New(restConfig, client.Options{})
 	if err != nil {
 		return nil, err
 	}
 
+	if err := apiextensionsv1.AddToScheme(c.Scheme()); err != nil {
+		return nil, err
+	}
+
 	err = cassdcapi.AddToScheme(c.Scheme())
 
 	return c, err
@@ -0,0 +1,54 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DeploymentReady returns true if the latest generation of the Deployment has all its replicas updated and available
+func DeploymentReady(deployment *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	status := deployment.Status
+	return status.ObservedGeneration >= deployment.Generation &&
+		status.UpdatedReplicas == replicas &&
+		status.AvailableReplicas == replicas
+}
+
+// WaitForDeploymentsReady waits until all the Deployments matching the labels are ready. It fails if there are no
+// matching Deployments when the timeout expires.
+func WaitForDeploymentsReady(ctx context.Context, c client.Client, namespace string, labels map[string]string, timeout time.Duration) error {
+	notReady := []string{}
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		deployments := &appsv1.DeploymentList{}
+		if err := c.List(ctx, deployments, client.InNamespace(namespace), client.MatchingLabels(labels)); err != nil {
+			return false, err
+		}
+
+		notReady = notReady[:0]
+		for i := range deployments.Items {
+			if !DeploymentReady(&deployments.Items[i]) {
+				notReady = append(notReady, deployments.Items[i].Name)
+			}
+		}
+
+		return len(deployments.Items) > 0 && len(notReady) == 0, nil
+	})
+
+	if err != nil && wait.Interrupted(err) {
+		if len(notReady) > 0 {
+			return fmt.Errorf("deployments %v in namespace %s are not ready after %s", notReady, namespace, timeout)
+		}
+		return fmt.Errorf("no deployments matching %v found in namespace %s after %s", labels, namespace, timeout)
+	}
+
+	return err
+}
@@ -0,0 +1,137 @@
+package registration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ServiceAccountAnnotation is set on the destination kubeconfig secret to the namespace/name of the
+	// source ServiceAccount the bound token was requested for
+	ServiceAccountAnnotation = "k8ssandra.io/service-account"
+
+	// TokenExpirationAnnotation is set on the destination kubeconfig secret to the expiration time of the bound token
+	TokenExpirationAnnotation = "k8ssandra.io/token-expiration"
+
+	// TokenExpirationSecondsAnnotation is set on the destination kubeconfig secret to the requested lifetime of the bound token
+	TokenExpirationSecondsAnnotation = "k8ssandra.io/token-expiration-seconds"
+
+	// rootCAConfigMap is published to every namespace by the kube-controller-manager
+	rootCAConfigMap = "kube-root-ca.crt"
+)
+
+// BoundToken is a short-lived ServiceAccount token from the TokenRequest API
+type BoundToken struct {
+	Token               string
+	ExpirationTimestamp time.Time
+	ExpirationSeconds   int64
+}
+
+// Annotations returns the annotations of the destination kubeconfig secret which allow renewing the token
+func (b BoundToken) Annotations(sa *corev1.ServiceAccount) map[string]string {
+	return map[string]string{
+		ServiceAccountAnnotation:         sa.Namespace + "/" + sa.Name,
+		TokenExpirationAnnotation:        b.ExpirationTimestamp.UTC().Format(time.RFC3339),
+		TokenExpirationSecondsAnnotation: strconv.FormatInt(b.ExpirationSeconds, 10),
+	}
+}
+
+// RequestToken requests a bound token for the ServiceAccount. The API server may shorten the expiration.
+func RequestToken(ctx context.Context, c client.Client, sa *corev1.ServiceAccount, expiration time.Duration) (BoundToken, error) {
+	expirationSeconds := int64(expiration.Seconds())
+	tokenRequest := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}
+
+	if err := c.SubResource("token").Create(ctx, sa, tokenRequest); err != nil {
+		return BoundToken{}, fmt.Errorf("failed to request a token for service account %s/%s: %w", sa.Namespace, sa.Name, err)
+	}
+
+	if tokenRequest.Status.Token == "" {
+		return BoundToken{}, fmt.Errorf("token request for service account %s/%s returned no token", sa.Namespace, sa.Name)
+	}
+
+	return BoundToken{
+		Token:               tokenRequest.Status.Token,
+		ExpirationTimestamp: tokenRequest.Status.ExpirationTimestamp.Time,
+		ExpirationSeconds:   expirationSeconds,
+	}, nil
+}
+
+// RootCA returns the CA bundle of the cluster from the root CA ConfigMap of the namespace
+func RootCA(ctx context.Context, c client.Client, namespace string) ([]byte, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKey{Name: rootCAConfigMap, Namespace: namespace}, cm); err != nil {
+		return nil, fmt.Errorf("failed to get the cluster CA from ConfigMap %s/%s: %w", namespace, rootCAConfigMap, err)
+	}
+
+	caData, found := cm.Data["ca.crt"]
+	if !found {
+		return nil, fmt.Errorf("ConfigMap %s/%s has no ca.crt", namespace, rootCAConfigMap)
+	}
+
+	return []byte(caData), nil
+}
+
+// BoundServiceAccount returns the source ServiceAccount and the requested token lifetime of a kubeconfig
+// secret created with a bound token
+func BoundServiceAccount(s *corev1.Secret) (client.ObjectKey, time.Duration, error) {
+	saRef, found := s.Annotations[ServiceAccountAnnotation]
+	if !found {
+		return client.ObjectKey{}, 0, fmt.Errorf("secret %s/%s was not registered with a bound token", s.Namespace, s.Name)
+	}
+
+	namespace, name, found := strings.Cut(saRef, "/")
+	if !found || namespace == "" || name == "" {
+		return client.ObjectKey{}, 0, fmt.Errorf("invalid %s annotation %s in secret %s/%s", ServiceAccountAnnotation, saRef, s.Namespace, s.Name)
+	}
+
+	seconds, err := strconv.ParseInt(s.Annotations[TokenExpirationSecondsAnnotation], 10, 64)
+	if err != nil {
+		return client.ObjectKey{}, 0, fmt.Errorf("invalid %s annotation in secret %s/%s: %w", TokenExpirationSecondsAnnotation, s.Namespace, s.Name, err)
+	}
+
+	return client.ObjectKey{Namespace: namespace, Name: name}, time.Duration(seconds) * time.Second, nil
+}
+
+// NeedsRenewal returns true if the bound token of the kubeconfig secret expires within renewBefore from now.
+// Secrets without a known expiration are always renewed.
+func NeedsRenewal(s *corev1.Secret, renewBefore time.Duration, now time.Time) bool {
+	expiration, err := time.Parse(time.RFC3339, s.Annotations[TokenExpirationAnnotation])
+	if err != nil {
+		return true
+	}
+	return expiration.Sub(now) < renewBefore
+}
+
+// ReplaceToken replaces the token of the current context's user in the kubeconfig
+func ReplaceToken(kubeconfig []byte, token string) ([]byte, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	kubeContext, found := config.Contexts[config.CurrentContext]
+	if !found {
+		return nil, errors.New("kubeconfig has no current context")
+	}
+
+	authInfo, found := config.AuthInfos[kubeContext.AuthInfo]
+	if !found {
+		return nil, fmt.Errorf("kubeconfig has no user %s", kubeContext.AuthInfo)
+	}
+	authInfo.Token = token
+
+	return clientcmd.Write(*config)
+}
@@ -0,0 +1,85 @@
+package registration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRequestToken(t *testing.T) {
+	require := require.New(t)
+
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "k8ssandra-operator", Namespace: "k8ssandra-operator"}}
+	rootCA := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-root-ca.crt", Namespace: "k8ssandra-operator"},
+		Data:       map[string]string{"ca.crt": "ca-data"},
+	}
+	c := fake.NewClientBuilder().WithObjects(sa, rootCA).Build()
+
+	token, err := RequestToken(t.Context(), c, sa, 24*time.Hour)
+	require.NoError(err)
+	require.Equal("fake-token", token.Token)
+	require.Equal(int64(86400), token.ExpirationSeconds)
+
+	annotations := token.Annotations(sa)
+	require.Equal("k8ssandra-operator/k8ssandra-operator", annotations[ServiceAccountAnnotation])
+	require.Equal("86400", annotations[TokenExpirationSecondsAnnotation])
+
+	caData, err := RootCA(t.Context(), c, "k8ssandra-operator")
+	require.NoError(err)
+	require.Equal([]byte("ca-data"), caData)
+
+	_, err = RootCA(t.Context(), c, "missing")
+	require.Error(err)
+}
+
+func TestBoundServiceAccount(t *testing.T) {
+	require := require.New(t)
+
+	s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "dp1", Namespace: "k8ssandra-operator"}}
+	_, _, err := BoundServiceAccount(s)
+	require.ErrorContains(err, "was not registered with a bound token")
+
+	s.Annotations = map[string]string{
+		ServiceAccountAnnotation:         "source-ns/k8ssandra-operator",
+		TokenExpirationSecondsAnnotation: "3600",
+	}
+	key, expiration, err := BoundServiceAccount(s)
+	require.NoError(err)
+	require.Equal(client.ObjectKey{Namespace: "source-ns", Name: "k8ssandra-operator"}, key)
+	require.Equal(time.Hour, expiration)
+}
+
+func TestNeedsRenewal(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	s := &corev1.Secret{}
+	require.True(NeedsRenewal(s, time.Hour, now))
+
+	s.Annotations = map[string]string{TokenExpirationAnnotation: "2026-01-02T05:00:00Z"}
+	require.False(NeedsRenewal(s, time.Hour, now))
+	require.True(NeedsRenewal(s, 3*time.Hour, now))
+}
+
+func TestReplaceToken(t *testing.T) {
+	require := require.New(t)
+
+	kubeconfig, err := clientcmd.Write(CredentialsToKubeconfig([]byte("ca-data"), "old-token", "https://127.0.0.1:6443", "dp1"))
+	require.NoError(err)
+
+	kubeconfig, err = ReplaceToken(kubeconfig, "new-token")
+	require.NoError(err)
+
+	config, err := clientcmd.Load(kubeconfig)
+	require.NoError(err)
+	require.Equal("new-token", config.AuthInfos["dp1"].Token)
+	require.Equal("https://127.0.0.1:6443", config.Clusters["dp1"].Server)
+	require.Equal([]byte("ca-data"), config.Clusters["dp1"].CertificateAuthorityData)
+}
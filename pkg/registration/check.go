@@ -0,0 +1,71 @@
+package registration
+
+import (
+	"context"
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// KubeconfigServer returns the server URL of the context in the kubeconfig, or of the current context if contextName is empty
+func KubeconfigServer(config *clientcmdapi.Config, contextName string) (string, error) {
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+
+	kubeContext, found := config.Contexts[contextName]
+	if !found {
+		return "", fmt.Errorf("context %s not found in kubeconfig", contextName)
+	}
+
+	cluster, found := config.Clusters[kubeContext.Cluster]
+	if !found {
+		return "", fmt.Errorf("cluster %s not found in kubeconfig", kubeContext.Cluster)
+	}
+
+	return cluster.Server, nil
+}
+
+// CheckAccess connects to the cluster with the credentials of the kubeconfig context and returns the username
+// the API server authenticated them as
+func CheckAccess(ctx context.Context, config *clientcmdapi.Config, contextName string) (string, error) {
+	restConfig, err := clientcmd.NewNonInteractiveClientConfig(*config, contextName, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+	if err != nil {
+		return "", err
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return "", err
+	}
+
+	review, err := clientset.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	return review.Status.UserInfo.Username, nil
+}
+
+// ServiceAccountTokenSecrets returns the legacy token secrets of the ServiceAccount
+func ServiceAccountTokenSecrets(ctx context.Context, c client.Client, namespace, saName string) ([]corev1.Secret, error) {
+	secretsList := &corev1.SecretList{}
+	if err := c.List(ctx, secretsList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	secrets := make([]corev1.Secret, 0, 1)
+	for _, s := range secretsList.Items {
+		if s.Annotations[corev1.ServiceAccountNameKey] == saName && s.Type == corev1.SecretTypeServiceAccountToken {
+			secrets = append(secrets, s)
+		}
+	}
+
+	return secrets, nil
+}
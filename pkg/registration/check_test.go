@@ -0,0 +1,75 @@
+package registration
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheckAccess(t *testing.T) {
+	require := require.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer valid-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(metav1.Status{TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}, Status: metav1.StatusFailure, Reason: metav1.StatusReasonUnauthorized, Code: http.StatusUnauthorized, Message: "Unauthorized"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(authenticationv1.SelfSubjectReview{
+			TypeMeta: metav1.TypeMeta{Kind: "SelfSubjectReview", APIVersion: "authentication.k8s.io/v1"},
+			Status: authenticationv1.SelfSubjectReviewStatus{
+				UserInfo: authenticationv1.UserInfo{Username: "system:serviceaccount:k8ssandra-operator:k8ssandra-operator"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	caData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	config := CredentialsToKubeconfig(caData, "valid-token", server.URL, "dp1")
+	serverURL, err := KubeconfigServer(&config, "")
+	require.NoError(err)
+	require.Equal(server.URL, serverURL)
+
+	username, err := CheckAccess(t.Context(), &config, "dp1")
+	require.NoError(err)
+	require.Equal("system:serviceaccount:k8ssandra-operator:k8ssandra-operator", username)
+
+	config.AuthInfos["dp1"].Token = "expired-token"
+	_, err = CheckAccess(t.Context(), &config, "dp1")
+	require.Error(err)
+
+	_, err = KubeconfigServer(&config, "missing")
+	require.ErrorContains(err, "context missing not found")
+}
+
+func TestServiceAccountTokenSecrets(t *testing.T) {
+	require := require.New(t)
+
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "k8ssandra-operator-secret", Namespace: "k8ssandra-operator", Annotations: map[string]string{corev1.ServiceAccountNameKey: "k8ssandra-operator"}},
+		Type:       corev1.SecretTypeServiceAccountToken,
+	}
+	otherSa := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "other-secret", Namespace: "k8ssandra-operator", Annotations: map[string]string{corev1.ServiceAccountNameKey: "other"}},
+		Type:       corev1.SecretTypeServiceAccountToken,
+	}
+	opaque := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "opaque", Namespace: "k8ssandra-operator", Annotations: map[string]string{corev1.ServiceAccountNameKey: "k8ssandra-operator"}},
+		Type:       corev1.SecretTypeOpaque,
+	}
+	c := fake.NewClientBuilder().WithObjects(tokenSecret, otherSa, opaque).Build()
+
+	secrets, err := ServiceAccountTokenSecrets(t.Context(), c, "k8ssandra-operator", "k8ssandra-operator")
+	require.NoError(err)
+	require.Len(secrets, 1)
+	require.Equal("k8ssandra-operator-secret", secrets[0].Name)
+}
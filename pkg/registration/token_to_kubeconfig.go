@@ -14,6 +14,11 @@ func TokenToKubeconfig(s corev1.Secret, server, destinationName string) (clientc
 		return clientcmdapi.Config{}, errors.New("missing required data in secret")
 	}
 
+	return CredentialsToKubeconfig(caData, string(tokenData), server, destinationName), nil
+}
+
+// CredentialsToKubeconfig builds a kubeconfig with a single context using the token for authentication
+func CredentialsToKubeconfig(caData []byte, token, server, destinationName string) clientcmdapi.Config {
 	return clientcmdapi.Config{
 		Clusters: map[string]*clientcmdapi.Cluster{
 			destinationName: {
@@ -23,7 +28,7 @@ func TokenToKubeconfig(s corev1.Secret, server, destinationName string) (clientc
 		},
 		AuthInfos: map[string]*clientcmdapi.AuthInfo{
 			destinationName: {
-				Token: string(tokenData),
+				Token: token,
 			},
 		},
 		Contexts: map[string]*clientcmdapi.Context{
@@ -33,5 +38,5 @@ func TokenToKubeconfig(s corev1.Secret, server, destinationName string) (clientc
 			},
 		},
 		CurrentContext: destinationName,
-	}, nil
+	}
 }